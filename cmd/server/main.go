@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -19,5 +20,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	app.Start()
+	configPath := flag.String("config", os.Getenv("GATEWAY_CONFIG"), "path to a YAML or JSON config file (env: GATEWAY_CONFIG)")
+	flag.Parse()
+
+	app.Start(*configPath)
 }
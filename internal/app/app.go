@@ -5,38 +5,54 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/config"
+	"github.com/atozi-ai/gateway/internal/conversation"
 	"github.com/atozi-ai/gateway/internal/handlers"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/metrics"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers"
 	"github.com/atozi-ai/gateway/internal/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func getEnvFloat(key string, defaultVal float64) float64 {
-	if val := os.Getenv(key); val != "" {
-		if f, err := strconv.ParseFloat(val, 64); err == nil {
-			return f
-		}
+// Start runs the gateway until it receives SIGINT or SIGTERM. configPath may be
+// empty, in which case defaults and environment variables are used. SIGHUP and
+// edits to the config file reload the configuration without a restart.
+func Start(configPath string) {
+	logger.Init()
+	logger.Log.Info().Msg("Initializing application...")
+
+	configs, err := config.NewManager(configPath)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	cfg := configs.Current().Config
+	if configPath != "" {
+		logger.Log.Info().Str("path", configPath).Msg("Loaded configuration file")
 	}
-	return defaultVal
-}
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
-		if i, err := strconv.Atoi(val); err == nil {
-			return i
-		}
+	if err := providers.Init(cfg); err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to initialize providers")
 	}
-	return defaultVal
-}
 
-func Start() {
-	logger.Init()
-	logger.Log.Info().Msg("Initializing application...")
+	shutdownTracing, err := tracing.Init(context.Background(), toTracingConfig(cfg))
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+	if cfg.Tracing.Enabled {
+		logger.Log.Info().
+			Str("endpoint", cfg.Tracing.Endpoint).
+			Float64("sample_ratio", cfg.Tracing.SampleRatio).
+			Msg("Tracing enabled")
+	}
 
 	r := chi.NewRouter()
 
@@ -45,14 +61,7 @@ func Start() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	rateLimitConfig := ratelimit.RateLimitConfig{
-		RequestsPerSecond: getEnvFloat("RATE_LIMIT_REQUESTS_PER_SECOND", 10),
-		RequestsPerMinute: getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 0),
-		RequestsPerHour:   getEnvInt("RATE_LIMIT_REQUESTS_PER_HOUR", 0),
-		RequestsPerDay:    getEnvInt("RATE_LIMIT_REQUESTS_PER_DAY", 0),
-		Burst:             getEnvInt("RATE_LIMIT_BURST", 20),
-		MaxClients:        getEnvInt("RATE_LIMIT_MAX_CLIENTS", 10000),
-	}
+	rateLimitConfig := toRateLimitConfig(cfg)
 
 	rateLimiter := ratelimit.NewRateLimiter(rateLimitConfig)
 	logger.Log.Info().
@@ -69,26 +78,99 @@ func Start() {
 		w.Write([]byte("OK"))
 	})
 
-	chatHandler := handlers.NewChatHandler()
+	metrics.RegisterCircuitBreakers(providers.GetProviderManager().CircuitBreakerStates)
+	r.Handle("/metrics", metrics.Handler())
+
+	keyStore, err := keys.NewStore(cfg.Auth.KeysFile)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to load key store")
+	}
+
+	logger.Log.Info().
+		Str("keys_file", cfg.Auth.KeysFile).
+		Bool("allow_key_passthrough", cfg.Auth.AllowPassthrough).
+		Bool("admin_api_enabled", cfg.Auth.AdminKey != "").
+		Msg("Key store loaded")
+
+	auth := keys.NewAuthenticator(keyStore, cfg.Auth.AllowPassthrough)
+
+	prices := pricing.NewCatalog(toPrices(cfg))
+	providers.GetProviderManager().SetPrices(prices)
+
+	budgets, err := budget.Open(cfg.Budgets.File)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to open budget store")
+	}
+	defer budgets.Close()
+
+	logger.Log.Info().
+		Str("budgets_file", cfg.Budgets.File).
+		Int("budgets", len(budgets.List())).
+		Msg("Budget store loaded")
+
+	conversations := conversation.NewStore(toConversationConfig(cfg))
+
+	chatHandler := handlers.NewChatHandler(auth, prices, budgets)
+	completionsHandler := handlers.NewCompletionsHandler(auth, prices, budgets)
+	responsesHandler := handlers.NewResponsesHandler(auth, prices, budgets, conversations)
+	messagesHandler := handlers.NewMessagesHandler(auth, prices, budgets)
+	generateHandler := handlers.NewGenerateContentHandler(auth, prices, budgets)
+	embeddingsHandler := handlers.NewEmbeddingsHandler(auth, prices, budgets)
 	modelsHandler := handlers.NewModelsHandler()
+	adminHandler := handlers.NewAdminHandler(configs, keyStore, budgets)
+
+	configs.AddValidator(providers.ValidateConfig)
+	configs.OnReload(func(s *config.Snapshot) {
+		// Validated above, so Update cannot fail here.
+		providers.GetProviderManager().Update(s.Config)
+		rateLimiter.UpdateConfig(toRateLimitConfig(s.Config))
+		auth.SetAllowPassthrough(s.Config.Auth.AllowPassthrough)
+		prices.Update(toPrices(s.Config))
+		conversations.Update(toConversationConfig(s.Config))
+	})
 
 	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(tracing.Middleware)
 		ratelimit.RegisterRateLimiter(r, rateLimiter)
 		chatHandler.RegisterRoutes(r)
+		messagesHandler.RegisterRoutes(r)
+		embeddingsHandler.RegisterRoutes(r)
 		modelsHandler.RegisterRoutes(r)
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8082"
+	// Gemini API paths, for Google SDKs pointed at /api or at the server
+	// root.
+	for _, prefix := range []string{"/api/v1beta", "/v1beta"} {
+		r.Route(prefix, func(r chi.Router) {
+			r.Use(tracing.Middleware)
+			ratelimit.RegisterRateLimiter(r, rateLimiter)
+			generateHandler.RegisterRoutes(r)
+		})
 	}
 
+	// OpenAI API paths, for OpenAI SDKs pointed at /v1, and the Anthropic
+	// Messages path, for Anthropic SDKs pointed at the server root.
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(tracing.Middleware)
+		ratelimit.RegisterRateLimiter(r, rateLimiter)
+		completionsHandler.RegisterRoutes(r)
+		responsesHandler.RegisterRoutes(r)
+		messagesHandler.RegisterRoutes(r)
+		embeddingsHandler.RegisterRoutes(r)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		adminHandler.RegisterRoutes(r)
+	})
+
+	port := cfg.Server.Port
+
 	srv := &http.Server{
 		Addr:         ":" + port,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	// Start server in a goroutine
@@ -99,6 +181,21 @@ func Start() {
 		}
 	}()
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go configs.Watch(watchCtx, 2*time.Second)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Log.Info().Msg("Received SIGHUP, reloading configuration")
+			if _, err := configs.Reload(); err != nil {
+				logger.Log.Error().Err(err).Msg("Configuration reload failed; keeping previous configuration")
+			}
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -112,6 +209,53 @@ func Start() {
 		logger.Log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Log.Error().Err(err).Msg("Failed to flush traces")
+	}
+
 	logger.Log.Info().Msg("Server exited")
 
 }
+
+func toRateLimitConfig(cfg *config.Config) ratelimit.RateLimitConfig {
+	return ratelimit.RateLimitConfig{
+		RequestsPerSecond: cfg.RateLimit.RequestsPerSecond,
+		RequestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+		RequestsPerHour:   cfg.RateLimit.RequestsPerHour,
+		RequestsPerDay:    cfg.RateLimit.RequestsPerDay,
+		Burst:             cfg.RateLimit.Burst,
+		MaxClients:        cfg.RateLimit.MaxClients,
+	}
+}
+
+func toTracingConfig(cfg *config.Config) tracing.Config {
+	return tracing.Config{
+		Enabled:     cfg.Tracing.Enabled,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		Headers:     cfg.Tracing.Headers,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}
+}
+
+func toConversationConfig(cfg *config.Config) conversation.Config {
+	return conversation.Config{
+		Enabled:    cfg.Responses.Store.Enabled,
+		TTL:        cfg.Responses.Store.TTL,
+		MaxEntries: cfg.Responses.Store.MaxEntries,
+	}
+}
+
+func toPrices(cfg *config.Config) map[string]pricing.Price {
+	prices := make(map[string]pricing.Price, len(cfg.Pricing))
+	for id, p := range cfg.Pricing {
+		prices[id] = pricing.Price{
+			Input:       p.Input,
+			Output:      p.Output,
+			CachedInput: p.CachedInput,
+			CacheWrite:  p.CacheWrite,
+		}
+	}
+	return prices
+}
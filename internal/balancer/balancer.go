@@ -0,0 +1,541 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/metrics"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/sony/gobreaker"
+)
+
+// Deployment is one member of a pool: a provider built with the deployment's
+// own credentials, and the model ID to request from it.
+type Deployment struct {
+	Name     string // Label in logs and metrics
+	Provider llm.Provider
+	Model    string
+	Weight   int
+	Breaker  *gobreaker.CircuitBreaker // The deployment's own breaker, if any
+
+	// What the deployment supports; see demand. ContextLength is zero when
+	// unlimited.
+	ContextLength int
+	Tools         bool
+	JSONMode      bool
+	// Price returns the deployment's current price, for the lowest-cost
+	// strategy. Nil when unpriced.
+	Price func() (pricing.Price, bool)
+	// Serves reports whether the deployment may be called for a caller with
+	// the given gateway key credentials, which are nil for a pass-through
+	// caller. Nil serves every caller.
+	Serves func(credentials map[string]string) bool
+}
+
+type Config struct {
+	Strategy         string        // One of the Strategy constants (default: weighted)
+	FailureThreshold int           // Consecutive 429/5xx errors before ejection (default: 3)
+	EjectionTime     time.Duration // First ejection; doubles on each further one (default: 30s)
+	MaxEjectionTime  time.Duration // Upper bound of an ejection (default: 5m)
+	SlowStart        time.Duration // Time to regain full weight after ejection (default: 30s)
+	// StreamBufferTokens holds back the first tokens of a stream so a failure
+	// within them can still move on. Zero commits at the first content.
+	StreamBufferTokens int
+}
+
+var DefaultConfig = Config{
+	Strategy:         StrategyWeighted,
+	FailureThreshold: 3,
+	EjectionTime:     30 * time.Second,
+	MaxEjectionTime:  5 * time.Minute,
+	SlowStart:        30 * time.Second,
+}
+
+// minSlowStartShare is the share of its weight a deployment gets right after
+// it is readmitted.
+const minSlowStartShare = 0.1
+
+// member is a deployment with its balancing and health state. All fields
+// below Deployment are guarded by poolProvider.mu.
+type member struct {
+	Deployment
+
+	current      float64   // Smooth weighted round-robin counter
+	failures     int       // Consecutive 429/5xx errors
+	ejections    int       // Consecutive ejections, reset once healthy again
+	ejectedUntil time.Time // Zero when in rotation
+	readmittedAt time.Time // Start of slow start; zero at full weight
+
+	inFlight  int
+	latency   float64 // Moving average of total latency in seconds
+	ttft      float64 // Moving average of stream time to first token in seconds
+	errorRate float64 // Moving average of 429/5xx outcomes, from 0 to 1
+}
+
+// poolProvider spreads requests over equivalent deployments with one of the
+// strategies. Deployments that keep failing are ejected for a while, and
+// those whose circuit breaker is open are skipped; a request that fails on
+// one deployment with a 429 or 5xx error is retried on the next.
+type poolProvider struct {
+	pool     string // Pool name in the config
+	name     string
+	config   Config
+	strategy strategy
+	mu       sync.Mutex
+	members  []*member
+}
+
+func NewPoolProvider(name string, deployments []Deployment, config Config) llm.Provider {
+	if config.Strategy == "" {
+		config.Strategy = DefaultConfig.Strategy
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = DefaultConfig.FailureThreshold
+	}
+	if config.EjectionTime == 0 {
+		config.EjectionTime = DefaultConfig.EjectionTime
+	}
+	if config.MaxEjectionTime == 0 {
+		config.MaxEjectionTime = DefaultConfig.MaxEjectionTime
+	}
+	if config.MaxEjectionTime < config.EjectionTime {
+		config.MaxEjectionTime = config.EjectionTime
+	}
+	if config.SlowStart == 0 {
+		config.SlowStart = DefaultConfig.SlowStart
+	}
+
+	choose, ok := strategies[config.Strategy]
+	if !ok {
+		logger.Log.Warn().
+			Str("pool", name).
+			Str("strategy", config.Strategy).
+			Msg("Unknown pool strategy, using weighted")
+		config.Strategy = StrategyWeighted
+		choose = weighted
+	}
+
+	members := make([]*member, len(deployments))
+	for i, d := range deployments {
+		if d.Weight <= 0 {
+			d.Weight = 1
+		}
+		if d.Name == "" {
+			d.Name = d.Provider.Name() + "/" + d.Model
+		}
+		members[i] = &member{Deployment: d}
+	}
+
+	return &poolProvider{
+		pool:     name,
+		name:     "pool(" + name + ")",
+		config:   config,
+		strategy: choose,
+		members:  members,
+	}
+}
+
+func (p *poolProvider) Name() string {
+	return p.name
+}
+
+// Inherit carries the health and latency state of prev's deployments over
+// to the deployments of the same name in p, for a pool rebuilt from
+// unchanged settings when the configuration is reloaded. Requests in flight
+// on prev stay counted there. Either provider not being a pool is a no-op.
+func Inherit(p, prev llm.Provider) {
+	to, ok := p.(*poolProvider)
+	from, ok2 := prev.(*poolProvider)
+	if !ok || !ok2 || to == from {
+		return
+	}
+
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	old := make(map[string]*member, len(from.members))
+	for _, m := range from.members {
+		old[m.Name] = m
+	}
+	for _, m := range to.members {
+		o, ok := old[m.Name]
+		if !ok {
+			continue
+		}
+		m.current = o.current
+		m.failures = o.failures
+		m.ejections = o.ejections
+		m.ejectedUntil = o.ejectedUntil
+		m.readmittedAt = o.readmittedAt
+		m.latency = o.latency
+		m.ttft = o.ttft
+		m.errorRate = o.errorRate
+	}
+}
+
+// pick chooses the next deployment not in tried that supports d and counts
+// the request as in flight on it, or returns nil if every such deployment has
+// been tried. Ejected deployments and those with an open breaker are skipped
+// unless no other is left, in which case the request is sent anyway rather
+// than failed.
+func (p *poolProvider) pick(tried map[*member]bool, d demand) (*member, float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var healthy, unavailable []*member
+	for _, m := range p.members {
+		if tried[m] || !m.supports(d) {
+			continue
+		}
+		if !m.ejectedUntil.IsZero() && !now.Before(m.ejectedUntil) {
+			m.ejectedUntil = time.Time{}
+			m.readmittedAt = now
+			logger.Log.Info().
+				Str("pool", p.pool).
+				Str("deployment", m.Name).
+				Msg("Deployment readmitted to pool")
+		}
+		if m.ejectedUntil.IsZero() && m.breakerState() != gobreaker.StateOpen {
+			healthy = append(healthy, m)
+		} else {
+			unavailable = append(unavailable, m)
+		}
+	}
+
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = unavailable
+	}
+	if len(candidates) == 0 {
+		return nil, 0
+	}
+
+	m, score := p.strategy(p, candidates, d, now)
+	m.inFlight++
+	return m, score
+}
+
+// effectiveWeight is m's weight, scaled down while it is in slow start.
+func (p *poolProvider) effectiveWeight(m *member, now time.Time) float64 {
+	w := float64(m.Weight)
+	if m.readmittedAt.IsZero() {
+		return w
+	}
+	share := float64(now.Sub(m.readmittedAt)) / float64(p.config.SlowStart)
+	if share >= 1 {
+		m.readmittedAt = time.Time{}
+		return w
+	}
+	if share < minSlowStartShare {
+		share = minSlowStartShare
+	}
+	return w * share
+}
+
+// finish records the outcome of a request picked for m: its latency (zero
+// for embeddings, which would skew the chat averages), its time to first
+// token (zero unless streamed) and what err says about m's health. Nothing
+// is said once ctx, the request's context, has ended.
+func (p *poolProvider) finish(ctx context.Context, m *member, err error, latency, ttft time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m.inFlight--
+
+	if err == nil {
+		if latency > 0 {
+			observeLatency(&m.latency, latency)
+		}
+		if ttft > 0 {
+			observeLatency(&m.ttft, ttft)
+		}
+		m.errorRate -= ewmaWeight * m.errorRate
+		m.failures = 0
+		if m.readmittedAt.IsZero() {
+			m.ejections = 0
+		}
+		return
+	}
+	if ctx.Err() != nil || !isUnhealthy(err) {
+		return
+	}
+
+	m.errorRate += ewmaWeight * (1 - m.errorRate)
+	m.failures++
+	if m.failures < p.config.FailureThreshold {
+		return
+	}
+
+	ejection := p.config.EjectionTime << m.ejections
+	if ejection > p.config.MaxEjectionTime || ejection <= 0 {
+		ejection = p.config.MaxEjectionTime
+	}
+	m.failures = 0
+	m.ejections++
+	m.ejectedUntil = time.Now().Add(ejection)
+	m.readmittedAt = time.Time{}
+
+	metrics.RecordEjection(p.pool, m.Name)
+	logger.Log.Warn().
+		Str("pool", p.pool).
+		Str("deployment", m.Name).
+		Dur("ejection", ejection).
+		Err(err).
+		Msg("Deployment ejected from pool")
+}
+
+// isUnhealthy reports whether err says something about the deployment
+// rather than the request or the client: a rate limit, a server error or a
+// failure to get a response at all.
+func isUnhealthy(err error) bool {
+	if errors.Is(err, context.Canceled) || llm.IsClientError(err) {
+		return false
+	}
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) {
+		return pe.StatusCode == 429 || pe.StatusCode >= 500
+	}
+	return true
+}
+
+// failsOver reports whether a request that failed with err should be sent
+// to the next deployment: the deployment is unhealthy, or the caller's
+// gateway key has no credentials for its provider.
+func failsOver(err error) bool {
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) && pe.Code == "missing_provider_credentials" {
+		return true
+	}
+	return isUnhealthy(err)
+}
+
+// refusal is the error for a request no deployment of the pool can serve:
+// none supports what it needs, or the caller has no credentials for any of
+// those that do.
+func (p *poolProvider) refusal(d demand) error {
+	for _, m := range p.members {
+		if m.capable(d) {
+			return &llm.ProviderError{
+				StatusCode: 403,
+				Message:    fmt.Sprintf("API key has no credentials for any deployment of %q", p.pool),
+				Type:       "permission_error",
+				Code:       "missing_provider_credentials",
+			}
+		}
+	}
+	return &llm.ProviderError{
+		StatusCode: 400,
+		Message:    fmt.Sprintf("no deployment of %q supports this request's tools, JSON mode or context length", p.pool),
+		Type:       "invalid_request_error",
+		Code:       "unsupported_request",
+	}
+}
+
+// logPick logs the deployment chosen for an attempt of a request.
+func (p *poolProvider) logPick(ctx context.Context, m *member, score float64, attempt int) {
+	log := logger.FromContext(ctx)
+	log.Info().
+		Str("pool", p.pool).
+		Str("strategy", p.config.Strategy).
+		Str("deployment", m.Name).
+		Float64("score", score).
+		Int("attempt", attempt).
+		Msg("Pool deployment selected")
+}
+
+func (p *poolProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	var lastErr error
+	tried := make(map[*member]bool)
+	d := chatDemand(req, false)
+
+	for i := 0; ; i++ {
+		m, score := p.pick(tried, d)
+		if m == nil && i == 0 {
+			return nil, p.refusal(d)
+		}
+		if m == nil {
+			break
+		}
+		tried[m] = true
+		p.logPick(ctx, m, score, i)
+
+		attempt := req
+		attempt.Model = m.Model
+		start := time.Now()
+		hopCtx, span := tracing.StartFallback(ctx, i, m.Name)
+		resp, err := m.Provider.Chat(hopCtx, attempt)
+		tracing.End(span, err)
+		p.finish(ctx, m, err, time.Since(start), 0)
+		if err == nil {
+			if resp.Provider == "" {
+				resp.Provider = m.Provider.Name()
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		if !failsOver(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Log.Warn().
+			Str("pool", p.pool).
+			Str("deployment", m.Name).
+			Err(err).
+			Msg("Deployment failed, trying next deployment")
+	}
+
+	return nil, lastErr
+}
+
+// ChatStream moves on to the next deployment only while nothing has been
+// passed to callback, so a client never sees output from two deployments.
+func (p *poolProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	var lastErr error
+	tried := make(map[*member]bool)
+	d := chatDemand(req, true)
+	guard := llm.NewStreamGuard(callback, p.config.StreamBufferTokens)
+
+	for i := 0; ; i++ {
+		m, score := p.pick(tried, d)
+		if m == nil && i == 0 {
+			return p.refusal(d)
+		}
+		if m == nil {
+			break
+		}
+		tried[m] = true
+		p.logPick(ctx, m, score, i)
+
+		attempt := req
+		attempt.Model = m.Model
+		var ttft time.Duration
+		start := time.Now()
+		hopCtx, span := tracing.StartFallback(ctx, i, m.Name)
+		err := m.Provider.ChatStream(hopCtx, attempt, func(chunk *llm.StreamChunk) error {
+			if ttft == 0 {
+				ttft = time.Since(start)
+			}
+			if chunk.Provider == "" {
+				chunk.Provider = m.Provider.Name()
+			}
+			return guard.Send(chunk)
+		})
+		if err == nil {
+			err = guard.Flush()
+		}
+		tracing.End(span, err)
+		p.finish(ctx, m, err, time.Since(start), ttft)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = guard.Fail(err)
+		if guard.Committed() || !failsOver(err) || ctx.Err() != nil {
+			return lastErr
+		}
+		logger.Log.Warn().
+			Str("pool", p.pool).
+			Str("deployment", m.Name).
+			Err(err).
+			Msg("Streaming deployment failed, trying next deployment")
+	}
+
+	return lastErr
+}
+
+func (p *poolProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	var lastErr error
+	tried := make(map[*member]bool)
+	d := demand{credentials: req.Credentials}
+
+	for i := 0; ; i++ {
+		m, score := p.pick(tried, d)
+		if m == nil && i == 0 {
+			return nil, p.refusal(d)
+		}
+		if m == nil {
+			break
+		}
+		tried[m] = true
+		p.logPick(ctx, m, score, i)
+
+		attempt := req
+		attempt.Model = m.Model
+		hopCtx, span := tracing.StartFallback(ctx, i, m.Name)
+		resp, err := llm.Embed(hopCtx, m.Provider, attempt)
+		tracing.End(span, err)
+		p.finish(ctx, m, err, 0, 0)
+		if err == nil {
+			if resp.Provider == "" {
+				resp.Provider = m.Provider.Name()
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		if !failsOver(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Log.Warn().
+			Str("pool", p.pool).
+			Str("deployment", m.Name).
+			Err(err).
+			Msg("Embeddings deployment failed, trying next deployment")
+	}
+
+	return nil, lastErr
+}
+
+// Respond forwards a Responses API request to a deployment, failing over
+// like Embed. Only the request itself can fail over; once a deployment has
+// answered, its body is the caller's.
+func (p *poolProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	var lastErr error
+	tried := make(map[*member]bool)
+	d := demand{credentials: req.Credentials}
+
+	for i := 0; ; i++ {
+		m, score := p.pick(tried, d)
+		if m == nil && i == 0 {
+			return nil, p.refusal(d)
+		}
+		if m == nil {
+			break
+		}
+		tried[m] = true
+		p.logPick(ctx, m, score, i)
+
+		attempt := req
+		attempt.Model = m.Model
+		hopCtx, span := tracing.StartFallback(ctx, i, m.Name)
+		start := time.Now()
+		body, err := llm.Respond(hopCtx, m.Provider, attempt)
+		tracing.End(span, err)
+		p.finish(ctx, m, err, time.Since(start), 0)
+		if err == nil {
+			return body, nil
+		}
+
+		lastErr = err
+		if !failsOver(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Log.Warn().
+			Str("pool", p.pool).
+			Str("deployment", m.Name).
+			Err(err).
+			Msg("Responses deployment failed, trying next deployment")
+	}
+
+	return nil, lastErr
+}
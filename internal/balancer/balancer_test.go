@@ -0,0 +1,380 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// fakeProvider answers every request with err, or with a response naming
+// it. Streams send chunks before ending with err.
+type fakeProvider struct {
+	name   string
+	err    error
+	chunks []string
+	calls  int
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &llm.ChatResponse{Content: f.name}, nil
+}
+
+func (f *fakeProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	f.calls++
+	for _, text := range f.chunks {
+		content := text
+		chunk := &llm.StreamChunk{Choices: []llm.StreamChoice{{Delta: llm.StreamDelta{Content: &content}}}}
+		if err := callback(chunk); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+func (f *fakeProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return io.NopCloser(strings.NewReader(f.name + ":" + req.Model)), nil
+}
+
+var errUnavailable = llm.NewProviderError(503, "unavailable", "api_error", "")
+
+// newPool returns a pool with one deployment, of weight 1, per provider.
+func newPool(config Config, providers ...*fakeProvider) *poolProvider {
+	deployments := make([]Deployment, len(providers))
+	for i, p := range providers {
+		deployments[i] = Deployment{Name: p.name, Provider: p, Model: "m", Tools: true, JSONMode: true}
+	}
+	return NewPoolProvider("test", deployments, config).(*poolProvider)
+}
+
+func (p *poolProvider) member(name string) *member {
+	for _, m := range p.members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestPoolFailsOverAndEjects(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable}
+	good := &fakeProvider{name: "good"}
+	pool := newPool(Config{FailureThreshold: 2, EjectionTime: time.Minute}, bad, good)
+
+	for i := 0; i < 6; i++ {
+		resp, err := pool.Chat(context.Background(), llm.ChatRequest{})
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if resp.Content != "good" {
+			t.Fatalf("request %d served by %q, want good", i, resp.Content)
+		}
+	}
+
+	if bad.calls != 2 {
+		t.Errorf("bad deployment called %d times, want 2 before its ejection", bad.calls)
+	}
+	m := pool.member("bad")
+	if until := time.Until(m.ejectedUntil); until <= 0 || until > time.Minute {
+		t.Errorf("bad deployment ejected for %v, want up to 1m", until)
+	}
+}
+
+func TestPoolEjectionBacksOff(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable}
+	pool := newPool(Config{FailureThreshold: 1, EjectionTime: time.Minute, MaxEjectionTime: 3 * time.Minute}, bad)
+	m := pool.member("bad")
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		// Readmit the deployment, as if its ejection had run out.
+		m.ejectedUntil = time.Now().Add(-time.Second)
+
+		if _, err := pool.Chat(context.Background(), llm.ChatRequest{}); !errors.Is(err, errUnavailable) {
+			t.Fatalf("Chat = %v, want %v", err, errUnavailable)
+		}
+		if got := time.Until(m.ejectedUntil); got > want || got < want-time.Second {
+			t.Errorf("ejected for %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPoolSendsAnywayWhenAllEjected(t *testing.T) {
+	p := &fakeProvider{name: "only"}
+	pool := newPool(Config{}, p)
+	pool.member("only").ejectedUntil = time.Now().Add(time.Hour)
+
+	if _, err := pool.Chat(context.Background(), llm.ChatRequest{}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("ejected deployment called %d times, want 1", p.calls)
+	}
+}
+
+func TestPoolSlowStart(t *testing.T) {
+	pool := newPool(Config{SlowStart: 100 * time.Second}, &fakeProvider{name: "a"})
+	m := pool.member("a")
+	m.Weight = 10
+	m.ejectedUntil = time.Now().Add(-time.Second)
+
+	// Picking readmits the deployment and starts its slow start.
+	if picked, _ := pool.pick(map[*member]bool{}, demand{}); picked != m {
+		t.Fatal("readmitted deployment not picked")
+	}
+	start := m.readmittedAt
+	if start.IsZero() {
+		t.Fatal("readmitted deployment is not in slow start")
+	}
+
+	tests := []struct {
+		after time.Duration
+		want  float64
+	}{
+		{0, 1},                   // minSlowStartShare of the weight
+		{5 * time.Second, 1},     // Still at the minimum share
+		{50 * time.Second, 5},    // Half way
+		{90 * time.Second, 9},    // Almost there
+		{100 * time.Second, 10},  // Full weight, and slow start is over
+		{1000 * time.Second, 10}, // Stays there
+	}
+	for _, tt := range tests {
+		if got := pool.effectiveWeight(m, start.Add(tt.after)); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("weight %v after readmission = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+	if !m.readmittedAt.IsZero() {
+		t.Error("slow start did not end at full weight")
+	}
+}
+
+func TestPoolSuccessResetsFailures(t *testing.T) {
+	flaky := &fakeProvider{name: "flaky"}
+	pool := newPool(Config{FailureThreshold: 2}, flaky)
+	m := pool.member("flaky")
+
+	// Failures that are not consecutive never reach the threshold.
+	for i := 0; i < 4; i++ {
+		flaky.err = errUnavailable
+		pool.Chat(context.Background(), llm.ChatRequest{})
+		flaky.err = nil
+		pool.Chat(context.Background(), llm.ChatRequest{})
+	}
+	if !m.ejectedUntil.IsZero() {
+		t.Error("deployment ejected after failures interleaved with successes")
+	}
+}
+
+func TestPoolDoesNotFailOverOnClientErrors(t *testing.T) {
+	invalid := llm.NewProviderError(400, "bad request", "invalid_request_error", "")
+	first := &fakeProvider{name: "first", err: invalid}
+	second := &fakeProvider{name: "second", err: invalid}
+	pool := newPool(Config{}, first, second)
+
+	if _, err := pool.Chat(context.Background(), llm.ChatRequest{}); !errors.Is(err, invalid) {
+		t.Fatalf("Chat = %v, want %v", err, invalid)
+	}
+	if first.calls+second.calls != 1 {
+		t.Errorf("a 400 was sent to %d deployments, want 1", first.calls+second.calls)
+	}
+}
+
+func TestPoolSkipsDeploymentsWithoutCredentials(t *testing.T) {
+	openai := &fakeProvider{name: "openai"}
+	azure := &fakeProvider{name: "azure"}
+	pool := newPool(Config{}, openai, azure)
+	for _, m := range pool.members {
+		name := m.Name
+		m.Serves = func(credentials map[string]string) bool {
+			_, ok := credentials[name]
+			return ok
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		resp, err := pool.Chat(context.Background(), llm.ChatRequest{Credentials: map[string]string{"azure": "key"}})
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		if resp.Content != "azure" {
+			t.Errorf("request served by %q, want azure", resp.Content)
+		}
+	}
+	if openai.calls != 0 {
+		t.Errorf("deployment without credentials called %d times", openai.calls)
+	}
+
+	_, err := pool.Chat(context.Background(), llm.ChatRequest{})
+	var pe *llm.ProviderError
+	if !errors.As(err, &pe) || pe.StatusCode != 403 || pe.Code != "missing_provider_credentials" {
+		t.Errorf("Chat without credentials = %v, want a 403 missing_provider_credentials error", err)
+	}
+}
+
+func TestPoolRespondFailsOver(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable}
+	good := &fakeProvider{name: "good"}
+	pool := newPool(Config{FailureThreshold: 1, EjectionTime: time.Minute}, bad, good)
+	pool.member("good").Model = "m2"
+
+	for i := 0; i < 3; i++ {
+		body, err := pool.Respond(context.Background(), llm.ResponsesRequest{Model: "p"})
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != "good:m2" {
+			t.Errorf("request %d = %q, want good:m2", i, data)
+		}
+	}
+	if bad.calls != 1 {
+		t.Errorf("bad deployment called %d times, want 1 before ejection", bad.calls)
+	}
+
+	// Client errors are the caller's and are not retried elsewhere.
+	bad.err = llm.NewValidationError("bad input", "invalid")
+	good.err = bad.err
+	calls := bad.calls + good.calls
+	if _, err := pool.Respond(context.Background(), llm.ResponsesRequest{}); err != bad.err {
+		t.Errorf("Respond = %v, want the client error", err)
+	}
+	if tried := bad.calls + good.calls - calls; tried != 1 {
+		t.Errorf("client error tried on %d deployments, want 1", tried)
+	}
+}
+
+func TestPoolStreamFailsOverBeforeOutput(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable}
+	good := &fakeProvider{name: "good", chunks: []string{"Hello"}}
+	pool := newPool(Config{Strategy: StrategyLeastBusy}, bad, good)
+	pool.member("good").inFlight = 10 // So bad is tried first
+
+	var got []string
+	var servedBy string
+	err := pool.ChatStream(context.Background(), llm.ChatRequest{}, func(chunk *llm.StreamChunk) error {
+		got = append(got, *chunk.Choices[0].Delta.Content)
+		servedBy = chunk.Provider
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if bad.calls != 1 || len(got) != 1 || got[0] != "Hello" {
+		t.Errorf("bad called %d times, client got %q; want 1 and [Hello]", bad.calls, got)
+	}
+	// Streams are priced by the deployment that served them.
+	if servedBy != "good" {
+		t.Errorf("chunk provider = %q, want good", servedBy)
+	}
+}
+
+func TestPoolStreamDoesNotFailOverAfterOutput(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable, chunks: []string{"Hel"}}
+	good := &fakeProvider{name: "good", chunks: []string{"Hello"}}
+	pool := newPool(Config{Strategy: StrategyLeastBusy}, bad, good)
+	pool.member("good").inFlight = 10
+
+	var got []string
+	err := pool.ChatStream(context.Background(), llm.ChatRequest{}, func(chunk *llm.StreamChunk) error {
+		got = append(got, *chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if !llm.IsStreamInterrupted(err) {
+		t.Fatalf("ChatStream = %v, want a stream_interrupted error", err)
+	}
+	if good.calls != 0 {
+		t.Error("stream failed over after output reached the client")
+	}
+	if len(got) != 1 || got[0] != "Hel" {
+		t.Errorf("client got %q, want [Hel]", got)
+	}
+}
+
+func TestPoolStreamBufferAllowsFailover(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable, chunks: []string{"Hel"}}
+	good := &fakeProvider{name: "good", chunks: []string{"Hello"}}
+	pool := newPool(Config{Strategy: StrategyLeastBusy, StreamBufferTokens: 10}, bad, good)
+	pool.member("good").inFlight = 10
+
+	var got []string
+	err := pool.ChatStream(context.Background(), llm.ChatRequest{}, func(chunk *llm.StreamChunk) error {
+		got = append(got, *chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if len(got) != 1 || got[0] != "Hello" {
+		t.Errorf("client got %q, want only the second deployment's [Hello]", got)
+	}
+}
+
+func TestPoolIgnoresClientErrors(t *testing.T) {
+	p := &fakeProvider{name: "only", chunks: []string{"Hello"}}
+	pool := newPool(Config{FailureThreshold: 1, EjectionTime: time.Minute}, p)
+	m := pool.member("only")
+
+	// A client that has gone away fails the write of the first chunk.
+	errBrokenPipe := errors.New("write: broken pipe")
+	err := pool.ChatStream(context.Background(), llm.ChatRequest{}, func(*llm.StreamChunk) error {
+		return errBrokenPipe
+	})
+	if !llm.IsStreamInterrupted(err) {
+		t.Fatalf("ChatStream = %v, want a stream_interrupted error", err)
+	}
+	if m.failures != 0 || !m.ejectedUntil.IsZero() {
+		t.Errorf("client write error counted against the deployment: %d failures, ejected until %v", m.failures, m.ejectedUntil)
+	}
+
+	// Nor does a deployment's error once the request's context has ended.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.err, p.chunks = errUnavailable, nil
+	if _, err := pool.Chat(ctx, llm.ChatRequest{}); !errors.Is(err, errUnavailable) {
+		t.Fatalf("Chat = %v, want %v", err, errUnavailable)
+	}
+	if m.failures != 0 || !m.ejectedUntil.IsZero() {
+		t.Errorf("error after cancellation counted against the deployment: %d failures, ejected until %v", m.failures, m.ejectedUntil)
+	}
+}
+
+func TestInheritCarriesHealthOver(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: errUnavailable}
+	good := &fakeProvider{name: "good"}
+	prev := newPool(Config{FailureThreshold: 1, EjectionTime: time.Minute}, bad, good)
+	if _, err := prev.Chat(context.Background(), llm.ChatRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	prev.member("good").inFlight = 3
+
+	// The same deployments, rebuilt on a reload, plus a new one.
+	added := &fakeProvider{name: "added"}
+	next := newPool(Config{FailureThreshold: 1, EjectionTime: time.Minute}, bad, good, added)
+	Inherit(next, prev)
+
+	if m := next.member("bad"); m.ejectedUntil != prev.member("bad").ejectedUntil || m.ejections != 1 {
+		t.Errorf("bad deployment = ejected until %v after %d ejections, want its ejection kept", m.ejectedUntil, m.ejections)
+	}
+	if m := next.member("good"); m.latency == 0 || m.inFlight != 0 {
+		t.Errorf("good deployment = latency %v with %d in flight, want the latency kept and none in flight", m.latency, m.inFlight)
+	}
+	if m := next.member("added"); !m.ejectedUntil.IsZero() || m.latency != 0 {
+		t.Error("new deployment given state")
+	}
+}
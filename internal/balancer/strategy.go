@@ -0,0 +1,240 @@
+package balancer
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+
+	"github.com/sony/gobreaker"
+)
+
+// Strategies for choosing a pool deployment.
+const (
+	// StrategyWeighted is smooth weighted round-robin. Its score is the
+	// deployment's effective weight.
+	StrategyWeighted = "weighted"
+	// StrategyLeastLatency picks the deployment with the lowest latency
+	// average: time to first token for streams, total latency otherwise.
+	StrategyLeastLatency = "least-latency"
+	// StrategyLeastBusy picks the deployment with the fewest requests in
+	// flight relative to its weight.
+	StrategyLeastBusy = "least-busy"
+	// StrategyPowerOfTwo compares two deployments chosen at random and picks
+	// the one with the lower product of load and latency.
+	StrategyPowerOfTwo = "power-of-two-choices"
+	// StrategyLowestCost picks the deployment with the lowest estimated cost
+	// of the request at its price.
+	StrategyLowestCost = "lowest-cost"
+)
+
+const (
+	// ewmaWeight is the weight of the newest sample in the moving averages.
+	ewmaWeight = 0.2
+	// errorPenalty scales a deployment's score by 1 + errorPenalty times its
+	// recent error rate, for the strategies where lower scores win.
+	errorPenalty = 4.0
+	// halfOpenPenalty scales the score of a deployment whose circuit breaker
+	// is probing, for the strategies where lower scores win.
+	halfOpenPenalty = 2.0
+	// defaultOutputTokens is the completion length assumed for cost estimates
+	// when the request sets no max_tokens.
+	defaultOutputTokens = 512
+)
+
+// demand is what a request needs from a deployment.
+type demand struct {
+	stream       bool
+	tools        bool
+	jsonMode     bool
+	promptTokens int // Estimated from the message and tool text
+	maxTokens    int // Zero when the request sets no limit
+	// Gateway key credentials of the caller; nil for pass-through callers.
+	credentials map[string]string
+}
+
+// chatDemand estimates what req needs.
+func chatDemand(req llm.ChatRequest, stream bool) demand {
+	d := demand{
+		stream:       stream,
+		tools:        len(req.Options.Tools) > 0,
+		promptTokens: req.EstimatePromptTokens(),
+		credentials:  req.Credentials,
+	}
+	if rf := req.Options.ResponseFormat; rf != nil {
+		d.jsonMode = rf.Type == "json_object" || rf.Type == "json_schema"
+	}
+	if req.Options.MaxTokens != nil {
+		d.maxTokens = *req.Options.MaxTokens
+	}
+	return d
+}
+
+// supports reports whether m can serve a request with demand d for its
+// caller.
+func (m *member) supports(d demand) bool {
+	return m.capable(d) && (m.Serves == nil || m.Serves(d.credentials))
+}
+
+// capable reports whether m has the features and context length a request
+// with demand d needs.
+func (m *member) capable(d demand) bool {
+	if d.tools && !m.Tools {
+		return false
+	}
+	if d.jsonMode && !m.JSONMode {
+		return false
+	}
+	return m.ContextLength == 0 || d.promptTokens+d.maxTokens <= m.ContextLength
+}
+
+// strategy chooses among candidates, which is never empty, and returns the
+// choice with its score.
+type strategy func(p *poolProvider, candidates []*member, d demand, now time.Time) (*member, float64)
+
+var strategies = map[string]strategy{
+	StrategyWeighted:     weighted,
+	StrategyLeastLatency: leastLatency,
+	StrategyLeastBusy:    leastBusy,
+	StrategyPowerOfTwo:   powerOfTwo,
+	StrategyLowestCost:   lowestCost,
+}
+
+// weighted is smooth weighted round-robin, as in nginx: every candidate's
+// counter grows by its weight and the largest counter wins and is lowered by
+// the total.
+func weighted(p *poolProvider, candidates []*member, _ demand, now time.Time) (*member, float64) {
+	var best *member
+	var bestWeight float64
+	total := 0.0
+	for _, m := range candidates {
+		w := p.effectiveWeight(m, now)
+		m.current += w
+		total += w
+		if best == nil || m.current > best.current {
+			best, bestWeight = m, w
+		}
+	}
+	best.current -= total
+	return best, bestWeight
+}
+
+func leastLatency(p *poolProvider, candidates []*member, d demand, _ time.Time) (*member, float64) {
+	latency := latencies(candidates, d.stream)
+	return lowest(candidates, func(m *member) float64 {
+		return latency(m) * m.penalty()
+	})
+}
+
+func leastBusy(p *poolProvider, candidates []*member, _ demand, _ time.Time) (*member, float64) {
+	return lowest(candidates, func(m *member) float64 {
+		return float64(m.inFlight+1) / float64(m.Weight) * m.penalty()
+	})
+}
+
+// powerOfTwo avoids herding on the single best deployment, which all
+// concurrent requests would otherwise pick until its averages catch up.
+func powerOfTwo(p *poolProvider, candidates []*member, d demand, _ time.Time) (*member, float64) {
+	if len(candidates) > 2 {
+		i := rand.Intn(len(candidates))
+		j := rand.Intn(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+		candidates = []*member{candidates[i], candidates[j]}
+	}
+	latency := latencies(candidates, d.stream)
+	return lowest(candidates, func(m *member) float64 {
+		return float64(m.inFlight+1) * latency(m) * m.penalty()
+	})
+}
+
+// lowestCost scores a deployment by what the request would cost there in USD,
+// assuming a completion of max_tokens or defaultOutputTokens. Deployments
+// without a price come after all priced ones.
+func lowestCost(p *poolProvider, candidates []*member, d demand, _ time.Time) (*member, float64) {
+	usage := llm.Usage{PromptTokens: d.promptTokens, CompletionTokens: d.maxTokens}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = defaultOutputTokens
+	}
+	return lowest(candidates, func(m *member) float64 {
+		if m.Price == nil {
+			return math.Inf(1)
+		}
+		price, ok := m.Price()
+		if !ok {
+			return math.Inf(1)
+		}
+		return price.Cost(usage) * m.penalty()
+	})
+}
+
+// lowest returns the candidate with the lowest score. It starts at a random
+// candidate so ties, such as deployments without latency samples yet, are
+// shared out.
+func lowest(candidates []*member, score func(*member) float64) (*member, float64) {
+	start := rand.Intn(len(candidates))
+	var best *member
+	var bestScore float64
+	for i := range candidates {
+		m := candidates[(start+i)%len(candidates)]
+		s := score(m)
+		if best == nil || s < bestScore {
+			best, bestScore = m, s
+		}
+	}
+	return best, bestScore
+}
+
+// latencies returns the latency average that matters for a request, in
+// seconds: time to first token for streams, total latency otherwise. A
+// deployment without samples yet is taken to be as fast as the fastest
+// candidate, so it gets tried without its error penalty being ignored.
+func latencies(candidates []*member, stream bool) func(*member) float64 {
+	of := func(m *member) float64 {
+		if stream && m.ttft > 0 {
+			return m.ttft
+		}
+		return m.latency
+	}
+
+	fastest := 0.0
+	for _, m := range candidates {
+		if l := of(m); l > 0 && (fastest == 0 || l < fastest) {
+			fastest = l
+		}
+	}
+	return func(m *member) float64 {
+		if l := of(m); l > 0 {
+			return l
+		}
+		return fastest
+	}
+}
+
+// penalty scales a score up for recent errors and a probing breaker.
+func (m *member) penalty() float64 {
+	penalty := 1 + errorPenalty*m.errorRate
+	if m.breakerState() == gobreaker.StateHalfOpen {
+		penalty *= halfOpenPenalty
+	}
+	return penalty
+}
+
+func (m *member) breakerState() gobreaker.State {
+	if m.Breaker == nil {
+		return gobreaker.StateClosed
+	}
+	return m.Breaker.State()
+}
+
+// observeLatency folds a latency sample into the moving average avg, which
+// starts at the first sample.
+func observeLatency(avg *float64, sample time.Duration) {
+	if *avg == 0 {
+		*avg = sample.Seconds()
+		return
+	}
+	*avg += ewmaWeight * (sample.Seconds() - *avg)
+}
@@ -0,0 +1,196 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/pricing"
+)
+
+// strategyPool returns a pool using strategy over deployments "a", "b", ...
+// with the given weights.
+func strategyPool(strategy string, weights ...int) *poolProvider {
+	providers := make([]*fakeProvider, len(weights))
+	for i := range weights {
+		providers[i] = &fakeProvider{name: string(rune('a' + i))}
+	}
+	pool := newPool(Config{Strategy: strategy}, providers...)
+	for i, w := range weights {
+		pool.members[i].Weight = w
+	}
+	return pool
+}
+
+// choose runs the pool's strategy over all its deployments and returns the
+// name of the one picked.
+func choose(pool *poolProvider, d demand) string {
+	m, _ := pool.strategy(pool, pool.members, d, time.Now())
+	return m.Name
+}
+
+func TestWeighted(t *testing.T) {
+	pool := strategyPool(StrategyWeighted, 2, 1)
+
+	var got string
+	for i := 0; i < 6; i++ {
+		got += choose(pool, demand{})
+	}
+	if want := "abaaba"; got != want {
+		t.Errorf("picks = %q, want %q", got, want)
+	}
+}
+
+func TestWeightedSlowStart(t *testing.T) {
+	pool := strategyPool(StrategyWeighted, 1, 1)
+	pool.members[1].readmittedAt = time.Now()
+
+	picks := make(map[string]int)
+	for i := 0; i < 110; i++ {
+		picks[choose(pool, demand{})]++
+	}
+	// b starts at a tenth of its weight, so gets one pick in eleven.
+	if picks["b"] != 10 {
+		t.Errorf("deployment in slow start picked %d times in 110, want 10", picks["b"])
+	}
+}
+
+func TestLeastLatency(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+		setup  func(a, b *member)
+		want   string
+	}{
+		{
+			name:  "lower latency",
+			setup: func(a, b *member) { a.latency, b.latency = 2, 1 },
+			want:  "b",
+		},
+		{
+			name:   "lower time to first token for streams",
+			stream: true,
+			setup: func(a, b *member) {
+				a.latency, a.ttft = 2, 0.1
+				b.latency, b.ttft = 1, 0.5
+			},
+			want: "a",
+		},
+		{
+			name:   "latency for streams without a time to first token",
+			stream: true,
+			setup: func(a, b *member) {
+				a.latency, a.ttft = 2, 0
+				b.latency, b.ttft = 3, 0.5
+			},
+			want: "b",
+		},
+		{
+			name: "errors penalized",
+			setup: func(a, b *member) {
+				a.latency = 2
+				b.latency, b.errorRate = 1, 0.5
+			},
+			want: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := strategyPool(StrategyLeastLatency, 1, 1)
+			tt.setup(pool.members[0], pool.members[1])
+
+			if got := choose(pool, demand{stream: tt.stream}); got != tt.want {
+				t.Errorf("picked %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeastBusy(t *testing.T) {
+	pool := strategyPool(StrategyLeastBusy, 1, 3)
+	a, b := pool.members[0], pool.members[1]
+
+	// b has more requests in flight, but fewer for its weight.
+	a.inFlight, b.inFlight = 1, 2
+	if got := choose(pool, demand{}); got != "b" {
+		t.Errorf("picked %s, want b", got)
+	}
+
+	b.inFlight = 6
+	if got := choose(pool, demand{}); got != "a" {
+		t.Errorf("picked %s, want a", got)
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	pool := strategyPool(StrategyPowerOfTwo, 1, 1)
+	a, b := pool.members[0], pool.members[1]
+
+	// With two candidates both are compared, on load (in flight plus this
+	// request) times latency.
+	a.inFlight, a.latency = 4, 1
+	b.inFlight, b.latency = 1, 2
+	if got := choose(pool, demand{}); got != "b" {
+		t.Errorf("picked %s, want b", got)
+	}
+
+	// The worst of three loses whichever one it is compared with.
+	pool = strategyPool(StrategyPowerOfTwo, 1, 1, 1)
+	for i, m := range pool.members {
+		m.latency = 1
+		m.inFlight = i
+	}
+	picks := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		picks[choose(pool, demand{})]++
+	}
+	if picks["c"] != 0 {
+		t.Errorf("worst deployment picked %d times", picks["c"])
+	}
+	if picks["a"] == 0 || picks["b"] == 0 {
+		t.Errorf("picks = %v, want both a and b picked", picks)
+	}
+}
+
+func TestLowestCost(t *testing.T) {
+	pool := strategyPool(StrategyLowestCost, 1, 1, 1)
+	a, b := pool.members[0], pool.members[1]
+	a.Price = func() (pricing.Price, bool) { return pricing.Price{Input: 3, Output: 15}, true }
+	b.Price = func() (pricing.Price, bool) { return pricing.Price{Input: 1, Output: 4}, true }
+	// c has no price, so comes after every priced deployment.
+
+	if got := choose(pool, demand{promptTokens: 1000}); got != "b" {
+		t.Errorf("picked %s, want b", got)
+	}
+
+	// Output is what b is dearer for, so a long completion makes a cheaper.
+	b.Price = func() (pricing.Price, bool) { return pricing.Price{Input: 1, Output: 20}, true }
+	if got := choose(pool, demand{promptTokens: 1000, maxTokens: 100}); got != "b" {
+		t.Errorf("short completion: picked %s, want b", got)
+	}
+	if got := choose(pool, demand{promptTokens: 1000, maxTokens: 4000}); got != "a" {
+		t.Errorf("long completion: picked %s, want a", got)
+	}
+
+	a.Price, b.Price = nil, func() (pricing.Price, bool) { return pricing.Price{}, false }
+	picks := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		picks[choose(pool, demand{})]++
+	}
+	if len(picks) != 3 {
+		t.Errorf("picks without prices = %v, want all three shared", picks)
+	}
+}
+
+func TestLowestCostSkipsDeploymentsWithoutCredentials(t *testing.T) {
+	pool := strategyPool(StrategyLowestCost, 1, 1)
+	cheap, dear := pool.members[0], pool.members[1]
+	cheap.Price = func() (pricing.Price, bool) { return pricing.Price{Input: 1, Output: 1}, true }
+	dear.Price = func() (pricing.Price, bool) { return pricing.Price{Input: 10, Output: 10}, true }
+	cheap.Serves = func(credentials map[string]string) bool { return credentials["a"] != "" }
+
+	m, _ := pool.pick(map[*member]bool{}, demand{credentials: map[string]string{"b": "key"}})
+	if m != dear {
+		t.Errorf("picked %s, want the only deployment the caller has credentials for", m.Name)
+	}
+}
@@ -0,0 +1,278 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/pricing"
+)
+
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// Scope says what a budget applies to.
+type Scope string
+
+const (
+	ScopeKey   Scope = "key"   // A single gateway key, by key ID
+	ScopeTeam  Scope = "team"  // Every key of a team, by team name
+	ScopeModel Scope = "model" // A model, by the name clients request it under
+)
+
+// Period is the window a budget resets on. Windows are calendar days and
+// months in UTC.
+type Period string
+
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+// DefaultSoftLimit is the share of a cap at which warnings start.
+const DefaultSoftLimit = 0.8
+
+// Budget caps the spend of one subject per period. A zero cap is unlimited;
+// at least one of MaxCost and MaxTokens must be set.
+type Budget struct {
+	ID        string    `json:"id"`
+	Scope     Scope     `json:"scope"`
+	Subject   string    `json:"subject"`
+	Period    Period    `json:"period"`
+	MaxCost   float64   `json:"maxCost,omitempty"`   // USD
+	MaxTokens int64     `json:"maxTokens,omitempty"` // Prompt plus completion tokens
+	SoftLimit float64   `json:"softLimit"`           // Share of a cap that triggers a warning
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Validate checks a budget before it is stored and fills in defaults.
+func (b *Budget) Validate() error {
+	switch b.Scope {
+	case ScopeKey, ScopeTeam, ScopeModel:
+	default:
+		return fmt.Errorf("scope must be key, team or model, got %q", b.Scope)
+	}
+	if b.Subject == "" {
+		return errors.New("subject is required")
+	}
+	switch b.Period {
+	case Daily, Monthly:
+	default:
+		return fmt.Errorf("period must be daily or monthly, got %q", b.Period)
+	}
+	if b.MaxCost < 0 || b.MaxTokens < 0 {
+		return errors.New("caps must not be negative")
+	}
+	if b.MaxCost == 0 && b.MaxTokens == 0 {
+		return errors.New("at least one of maxCost and maxTokens is required")
+	}
+	if b.SoftLimit == 0 {
+		b.SoftLimit = DefaultSoftLimit
+	}
+	if b.SoftLimit < 0 || b.SoftLimit > 1 {
+		return errors.New("softLimit must be between 0 and 1")
+	}
+	return nil
+}
+
+// Spend is what a subject has used in a window.
+type Spend struct {
+	Cost   float64 `json:"cost"`
+	Tokens int64   `json:"tokens"`
+	// Unpriced is set on an estimate for a model without a price, whose cost
+	// cannot be known.
+	Unpriced bool `json:"-"`
+}
+
+// Estimate is what req can be expected to cost at most before it is sent:
+// its estimated prompt plus a completion of its max_tokens, priced at price
+// if the model has one. Without max_tokens only the prompt is counted.
+func Estimate(req llm.ChatRequest, price pricing.Price, priced bool) Spend {
+	usage := llm.Usage{PromptTokens: req.EstimatePromptTokens()}
+	if req.Options.MaxTokens != nil {
+		usage.CompletionTokens = *req.Options.MaxTokens
+	}
+	return estimate(usage, price, priced)
+}
+
+// EstimateEmbeddings is what req can be expected to cost before it is sent.
+func EstimateEmbeddings(req llm.EmbeddingRequest, price pricing.Price, priced bool) Spend {
+	return estimate(llm.Usage{PromptTokens: req.EstimatePromptTokens()}, price, priced)
+}
+
+func estimate(usage llm.Usage, price pricing.Price, priced bool) Spend {
+	spend := Spend{Tokens: int64(usage.PromptTokens + usage.CompletionTokens), Unpriced: !priced}
+	if priced {
+		spend.Cost = price.Cost(usage)
+	}
+	return spend
+}
+
+// Subjects identifies who and what a request is charged to. Empty fields are
+// skipped, e.g. KeyID for callers using a raw provider key.
+type Subjects struct {
+	KeyID string
+	Team  string
+	Model string
+}
+
+func (s Subjects) matches(b Budget) bool {
+	switch b.Scope {
+	case ScopeKey:
+		return s.KeyID != "" && s.KeyID == b.Subject
+	case ScopeTeam:
+		return s.Team != "" && s.Team == b.Subject
+	case ScopeModel:
+		return s.Model != "" && s.Model == b.Subject
+	}
+	return false
+}
+
+func (s Subjects) each(fn func(Scope, string)) {
+	if s.KeyID != "" {
+		fn(ScopeKey, s.KeyID)
+	}
+	if s.Team != "" {
+		fn(ScopeTeam, s.Team)
+	}
+	if s.Model != "" {
+		fn(ScopeModel, s.Model)
+	}
+}
+
+// Tracker enforces budgets and records spend. Budgets are cached in memory;
+// spend is read from and written to the store on every request.
+type Tracker struct {
+	store *store
+	now   func() time.Time
+}
+
+// Open loads budgets and spend from the SQLite file at path, creating it if
+// needed. An empty path keeps everything in memory.
+func Open(path string) (*Tracker, error) {
+	s, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{store: s, now: time.Now}, nil
+}
+
+func (t *Tracker) Close() error {
+	return t.store.close()
+}
+
+// Create validates and stores a new budget.
+func (t *Tracker) Create(b Budget) (Budget, error) {
+	if err := b.Validate(); err != nil {
+		return Budget{}, err
+	}
+	return t.store.createBudget(b, t.now().UTC())
+}
+
+// List returns all budgets in creation order.
+func (t *Tracker) List() []Budget {
+	return t.store.listBudgets()
+}
+
+// Delete removes a budget. Recorded spend is kept.
+func (t *Tracker) Delete(id string) error {
+	return t.store.deleteBudget(id)
+}
+
+// Spent returns what the budget's subject has used in the current window.
+func (t *Tracker) Spent(ctx context.Context, b Budget) (Spend, error) {
+	return t.store.spend(ctx, b.Scope, b.Subject, windowStart(b.Period, t.now().UTC()), t.now().UTC())
+}
+
+// Check is called before a request is dispatched with its Estimate. It
+// returns a budget_exceeded error if any budget that applies to subjects has
+// reached a cap or would pass it with the estimate, an unpriced_model error
+// if a budget with a cost cap applies to a model without a price, and
+// otherwise one warning per budget that has passed its soft limit.
+//
+// Spend is only recorded once responses finish, so concurrent requests that
+// each fit under a cap can still overshoot it together, as can requests
+// whose estimate is short, such as ones without max_tokens.
+func (t *Tracker) Check(ctx context.Context, subjects Subjects, estimate Spend) ([]string, error) {
+	var warnings []string
+
+	for _, b := range t.store.listBudgets() {
+		if !subjects.matches(b) {
+			continue
+		}
+
+		if b.MaxCost > 0 && estimate.Unpriced {
+			return nil, unpriced(b)
+		}
+
+		spent, err := t.Spent(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.MaxCost > 0 && (spent.Cost >= b.MaxCost || spent.Cost+estimate.Cost > b.MaxCost) {
+			return nil, exceeded(b, fmt.Sprintf("$%.2f of $%.2f spent, request estimated at $%.4f", spent.Cost, b.MaxCost, estimate.Cost))
+		}
+		if b.MaxTokens > 0 && (spent.Tokens >= b.MaxTokens || spent.Tokens+estimate.Tokens > b.MaxTokens) {
+			return nil, exceeded(b, fmt.Sprintf("%d of %d tokens spent, request estimated at %d", spent.Tokens, b.MaxTokens, estimate.Tokens))
+		}
+
+		var used float64
+		if b.MaxCost > 0 {
+			used = spent.Cost / b.MaxCost
+		}
+		if b.MaxTokens > 0 {
+			used = max(used, float64(spent.Tokens)/float64(b.MaxTokens))
+		}
+		if used >= b.SoftLimit {
+			warnings = append(warnings, fmt.Sprintf("%s %s budget %s at %.0f%%", b.Period, b.Scope, b.ID, used*100))
+		}
+	}
+
+	return warnings, nil
+}
+
+// Record adds the spend of a finished request to every subject.
+func (t *Tracker) Record(ctx context.Context, subjects Subjects, spend Spend) error {
+	day := t.now().UTC().Format(dayFormat)
+
+	var errs []string
+	subjects.each(func(scope Scope, subject string) {
+		if err := t.store.addSpend(ctx, scope, subject, day, spend); err != nil {
+			errs = append(errs, err.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to record spend: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func exceeded(b Budget, detail string) *llm.ProviderError {
+	return &llm.ProviderError{
+		StatusCode: http.StatusTooManyRequests,
+		Message:    fmt.Sprintf("%s budget for %s %q exceeded (%s)", b.Period, b.Scope, b.Subject, detail),
+		Type:       "insufficient_quota",
+		Code:       "budget_exceeded",
+	}
+}
+
+func unpriced(b Budget) *llm.ProviderError {
+	return &llm.ProviderError{
+		StatusCode: http.StatusForbidden,
+		Message:    fmt.Sprintf("model has no price, so the cost cap of the %s budget for %s %q cannot be enforced; add a price to the pricing config", b.Period, b.Scope, b.Subject),
+		Type:       "permission_error",
+		Code:       "unpriced_model",
+	}
+}
+
+// windowStart returns the first day of the period containing now.
+func windowStart(p Period, now time.Time) time.Time {
+	if p == Monthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,236 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/pricing"
+)
+
+// newTracker returns an in-memory tracker whose clock reads *now.
+func newTracker(t *testing.T, now *time.Time) *Tracker {
+	t.Helper()
+	tracker, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tracker.Close() })
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func create(t *testing.T, tracker *Tracker, b Budget) Budget {
+	t.Helper()
+	b, err := tracker.Create(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func record(t *testing.T, tracker *Tracker, subjects Subjects, spend Spend) {
+	t.Helper()
+	if err := tracker.Record(context.Background(), subjects, spend); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func errorCode(err error) string {
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return ""
+}
+
+func TestWindowRollover(t *testing.T) {
+	now := time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+	key := Subjects{KeyID: "key_1"}
+	daily := create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Daily, MaxTokens: 100})
+	monthly := create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Monthly, MaxTokens: 1000})
+
+	spent := func(b Budget) int64 {
+		t.Helper()
+		s, err := tracker.Spent(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Tokens
+	}
+
+	now = time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	record(t, tracker, key, Spend{Tokens: 10})
+	now = time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	record(t, tracker, key, Spend{Tokens: 20})
+
+	if got := spent(daily); got != 20 {
+		t.Errorf("daily spend = %d, want 20", got)
+	}
+	if got := spent(monthly); got != 30 {
+		t.Errorf("monthly spend = %d, want 30", got)
+	}
+
+	// A new day starts the daily window over, and a new month the monthly.
+	now = time.Date(2026, 2, 1, 0, 30, 0, 0, time.UTC)
+	if got := spent(daily); got != 0 {
+		t.Errorf("daily spend on the next day = %d, want 0", got)
+	}
+	if got := spent(monthly); got != 0 {
+		t.Errorf("monthly spend in the next month = %d, want 0", got)
+	}
+
+	// Windows are in UTC, whatever the zone of the clock.
+	now = time.Date(2026, 1, 31, 20, 0, 0, 0, time.FixedZone("UTC-5", -5*3600))
+	if got := spent(daily); got != 0 {
+		t.Errorf("daily spend at 01:00 UTC on the next day = %d, want 0", got)
+	}
+}
+
+func TestCheckSoftLimit(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+	team := Subjects{KeyID: "key_1", Team: "search"}
+	b := create(t, tracker, Budget{Scope: ScopeTeam, Subject: "search", Period: Daily, MaxCost: 10})
+
+	record(t, tracker, team, Spend{Cost: 7.9})
+	warnings, err := tracker.Check(context.Background(), team, Spend{})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Check under the soft limit = %v, %v; want no warnings", warnings, err)
+	}
+
+	record(t, tracker, team, Spend{Cost: 0.5})
+	warnings, err = tracker.Check(context.Background(), team, Spend{})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if want := "daily team budget " + b.ID + " at 84%"; len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("warnings = %q, want [%q]", warnings, want)
+	}
+
+	// The token cap counts too, whichever is further along.
+	tokens := create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Daily, MaxTokens: 100, SoftLimit: 0.5})
+	record(t, tracker, Subjects{KeyID: "key_1"}, Spend{Tokens: 60})
+	warnings, _ = tracker.Check(context.Background(), team, Spend{})
+	if len(warnings) != 2 || warnings[1] != "daily key budget "+tokens.ID+" at 60%" {
+		t.Errorf("warnings = %q, want the team and key budgets", warnings)
+	}
+}
+
+func TestCheckRejectsEstimateOvershoot(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+	key := Subjects{KeyID: "key_1"}
+	create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Monthly, MaxCost: 1, MaxTokens: 1000})
+	record(t, tracker, key, Spend{Cost: 0.5, Tokens: 500})
+
+	tests := []struct {
+		name     string
+		estimate Spend
+		wantErr  bool
+	}{
+		{"fits", Spend{Cost: 0.4, Tokens: 400}, false},
+		{"reaches the cap exactly", Spend{Cost: 0.5, Tokens: 500}, false},
+		{"passes the cost cap", Spend{Cost: 0.6, Tokens: 10}, true},
+		{"passes the token cap", Spend{Cost: 0.1, Tokens: 501}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tracker.Check(context.Background(), key, tt.estimate)
+			if tt.wantErr && errorCode(err) != "budget_exceeded" {
+				t.Errorf("Check = %v, want budget_exceeded", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Check = %v, want nil", err)
+			}
+		})
+	}
+
+	// Once a cap is reached even a free request is rejected.
+	record(t, tracker, key, Spend{Cost: 0.5})
+	if _, err := tracker.Check(context.Background(), key, Spend{}); errorCode(err) != "budget_exceeded" {
+		t.Errorf("Check at the cap = %v, want budget_exceeded", err)
+	}
+}
+
+func TestCheckRejectsUnpricedModelsUnderCostCaps(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+	create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Daily, MaxCost: 5})
+	create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_2", Period: Daily, MaxTokens: 5000})
+	unpriced := Spend{Tokens: 100, Unpriced: true}
+
+	_, err := tracker.Check(context.Background(), Subjects{KeyID: "key_1"}, unpriced)
+	var pe *llm.ProviderError
+	if !errors.As(err, &pe) || pe.Code != "unpriced_model" || pe.StatusCode != 403 {
+		t.Errorf("Check under a cost cap = %v, want a 403 unpriced_model error", err)
+	}
+
+	// A token cap can still be enforced.
+	if _, err := tracker.Check(context.Background(), Subjects{KeyID: "key_2"}, unpriced); err != nil {
+		t.Errorf("Check under a token cap = %v, want nil", err)
+	}
+	// As can no budget at all.
+	if _, err := tracker.Check(context.Background(), Subjects{KeyID: "key_3"}, unpriced); err != nil {
+		t.Errorf("Check without a budget = %v, want nil", err)
+	}
+}
+
+func TestRecordChargesEachScope(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tracker := newTracker(t, &now)
+	key := create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_1", Period: Daily, MaxCost: 10})
+	team := create(t, tracker, Budget{Scope: ScopeTeam, Subject: "search", Period: Daily, MaxCost: 10})
+	model := create(t, tracker, Budget{Scope: ScopeModel, Subject: "openai/gpt-4o", Period: Daily, MaxCost: 10})
+	other := create(t, tracker, Budget{Scope: ScopeKey, Subject: "key_2", Period: Daily, MaxCost: 10})
+
+	record(t, tracker, Subjects{KeyID: "key_1", Team: "search", Model: "openai/gpt-4o"}, Spend{Cost: 1, Tokens: 10})
+	// A pass-through caller has no key or team, but the model is charged.
+	record(t, tracker, Subjects{Model: "openai/gpt-4o"}, Spend{Cost: 2, Tokens: 20})
+
+	tests := []struct {
+		budget Budget
+		want   Spend
+	}{
+		{key, Spend{Cost: 1, Tokens: 10}},
+		{team, Spend{Cost: 1, Tokens: 10}},
+		{model, Spend{Cost: 3, Tokens: 30}},
+		{other, Spend{}},
+	}
+	for _, tt := range tests {
+		got, err := tracker.Spent(context.Background(), tt.budget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %s spend = %+v, want %+v", tt.budget.Scope, tt.budget.Subject, got, tt.want)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	maxTokens := 100
+	req := llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "0123456789abcdefghij0123456789abcdefghij"}},
+		Options:  llm.ChatOptions{MaxTokens: &maxTokens},
+	}
+	price := pricing.Price{Input: 1_000_000, Output: 2_000_000} // $1 and $2 a token
+
+	got := Estimate(req, price, true)
+	if want := (Spend{Cost: 10 + 200, Tokens: 110}); got != want {
+		t.Errorf("Estimate = %+v, want %+v", got, want)
+	}
+
+	got = Estimate(req, price, false)
+	if want := (Spend{Tokens: 110, Unpriced: true}); got != want {
+		t.Errorf("Estimate without a price = %+v, want %+v", got, want)
+	}
+
+	embeddings := llm.EmbeddingRequest{Input: []string{"01234567", "89abcdef"}}
+	if got, want := EstimateEmbeddings(embeddings, price, true), (Spend{Cost: 4, Tokens: 4}); got != want {
+		t.Errorf("EstimateEmbeddings = %+v, want %+v", got, want)
+	}
+}
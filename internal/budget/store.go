@@ -0,0 +1,178 @@
+package budget
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const dayFormat = "2006-01-02"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS budgets (
+	id         TEXT PRIMARY KEY,
+	scope      TEXT NOT NULL,
+	subject    TEXT NOT NULL,
+	period     TEXT NOT NULL,
+	max_cost   REAL NOT NULL DEFAULT 0,
+	max_tokens INTEGER NOT NULL DEFAULT 0,
+	soft_limit REAL NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS spend (
+	scope   TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	day     TEXT NOT NULL,
+	cost    REAL NOT NULL DEFAULT 0,
+	tokens  INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (scope, subject, day)
+);
+`
+
+// store persists budgets and daily spend totals in SQLite. Budgets are also
+// kept in memory since they are read on every request.
+type store struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	budgets map[string]Budget
+}
+
+func openStore(path string) (*store, error) {
+	dsn := path
+	if dsn == "" {
+		dsn = ":memory:"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open budget store %s: %w", path, err)
+	}
+	// SQLite allows one writer at a time; a single connection also keeps an
+	// in-memory database alive and shared.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize budget store %s: %w", path, err)
+	}
+
+	s := &store{db: db, budgets: make(map[string]Budget)}
+	if err := s.loadBudgets(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+func (s *store) loadBudgets() error {
+	rows, err := s.db.Query(`SELECT id, scope, subject, period, max_cost, max_tokens, soft_limit, created_at FROM budgets`)
+	if err != nil {
+		return fmt.Errorf("failed to load budgets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b Budget
+		var createdAt string
+		if err := rows.Scan(&b.ID, &b.Scope, &b.Subject, &b.Period, &b.MaxCost, &b.MaxTokens, &b.SoftLimit, &createdAt); err != nil {
+			return fmt.Errorf("failed to load budgets: %w", err)
+		}
+		b.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		s.budgets[b.ID] = b
+	}
+	return rows.Err()
+}
+
+func (s *store) createBudget(b Budget, now time.Time) (Budget, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return Budget{}, fmt.Errorf("failed to generate budget id: %w", err)
+	}
+	b.ID = "bgt_" + id
+	b.CreatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.db.Exec(
+		`INSERT INTO budgets (id, scope, subject, period, max_cost, max_tokens, soft_limit, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.Scope, b.Subject, b.Period, b.MaxCost, b.MaxTokens, b.SoftLimit, b.CreatedAt.Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return Budget{}, fmt.Errorf("failed to store budget: %w", err)
+	}
+
+	s.budgets[b.ID] = b
+	return b, nil
+}
+
+func (s *store) listBudgets() []Budget {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Budget, 0, len(s.budgets))
+	for _, b := range s.budgets {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+func (s *store) deleteBudget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.budgets[id]; !ok {
+		return ErrBudgetNotFound
+	}
+	if _, err := s.db.Exec(`DELETE FROM budgets WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	delete(s.budgets, id)
+	return nil
+}
+
+// spend sums a subject's daily totals from the day of from through now.
+func (s *store) spend(ctx context.Context, scope Scope, subject string, from, now time.Time) (Spend, error) {
+	var total Spend
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(cost), 0), COALESCE(SUM(tokens), 0) FROM spend WHERE scope = ? AND subject = ? AND day >= ? AND day <= ?`,
+		scope, subject, from.Format(dayFormat), now.Format(dayFormat),
+	).Scan(&total.Cost, &total.Tokens)
+	if err != nil {
+		return Spend{}, fmt.Errorf("failed to read spend: %w", err)
+	}
+	return total, nil
+}
+
+func (s *store) addSpend(ctx context.Context, scope Scope, subject, day string, add Spend) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO spend (scope, subject, day, cost, tokens) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (scope, subject, day) DO UPDATE SET cost = cost + excluded.cost, tokens = tokens + excluded.tokens`,
+		scope, subject, day, add.Cost, add.Tokens,
+	)
+	return err
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
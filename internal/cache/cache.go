@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Entry is a cached chat result: a response for non-streaming requests, or
+// the chunks of a completed stream.
+type Entry struct {
+	Response  *llm.ChatResponse `json:"response,omitempty"`
+	Chunks    []llm.StreamChunk `json:"chunks,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Store holds cache entries. Implementations must be safe for concurrent use
+// and drop entries older than their TTL.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+}
+
+// Status says how the cache handled a request.
+type Status string
+
+const (
+	Hit    Status = "HIT"
+	Miss   Status = "MISS"
+	Bypass Status = "BYPASS" // The client asked not to read from the cache
+)
+
+// Control is a client's per-request cache policy, parsed from a
+// Cache-Control header.
+type Control struct {
+	NoCache bool          // Do not serve from the cache
+	NoStore bool          // Do not store the response
+	MaxAge  time.Duration // Only serve entries at most this old; zero means the store's TTL
+}
+
+// ParseControl reads the no-cache, no-store and max-age directives of a
+// Cache-Control header value. Other directives are ignored.
+func ParseControl(header string) Control {
+	var c Control
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache":
+			c.NoCache = true
+		case "no-store":
+			c.NoStore = true
+		case "max-age":
+			if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+				c.MaxAge = time.Duration(secs) * time.Second
+				c.NoCache = c.NoCache || secs == 0
+			}
+		}
+	}
+	return c
+}
+
+type requestState struct {
+	control    Control
+	caller     string
+	status     Status
+	similarity float64 // Of the prompt that was served, for semantic hits
+}
+
+type contextKey struct{}
+
+// WithControl returns a context carrying the client's cache policy and the
+// identity of the caller, whose requests are only ever answered from its own
+// entries. The caching provider also records the cache status in it for
+// StatusFromContext.
+func WithControl(ctx context.Context, c Control, caller string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestState{control: c, caller: caller})
+}
+
+// StatusFromContext returns how the cache handled the request, or "" if the
+// request did not go through a caching provider.
+func StatusFromContext(ctx context.Context) Status {
+	if s, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		return s.status
+	}
+	return ""
+}
+
+// SimilarityFromContext returns the cosine similarity between the request
+// and the cached prompt whose answer was served, if it was a semantic hit.
+func SimilarityFromContext(ctx context.Context) (float64, bool) {
+	if s, ok := ctx.Value(contextKey{}).(*requestState); ok && s.status == Hit && s.similarity > 0 {
+		return s.similarity, true
+	}
+	return 0, false
+}
+
+func stateFromContext(ctx context.Context) *requestState {
+	if s, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		return s
+	}
+	return &requestState{}
+}
+
+// Key returns the canonical hash of a request to provider made by caller.
+// The upstream credentials of the request are part of the key, so an entry
+// is only served to the caller that paid for it and only with the key that
+// produced it. Streaming and non-streaming requests get different keys.
+func Key(provider, caller string, req llm.ChatRequest) (string, error) {
+	data, err := json.Marshal(struct {
+		Provider string
+		Caller   string
+		APIKey   string
+		Model    string
+		Messages []llm.Message
+		Options  llm.ChatOptions
+	}{provider, caller, req.APIKey, req.Model, req.Messages, req.Options})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// countingProvider answers each chat with the number of calls so far, so
+// tests can tell cached answers from fresh ones.
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Name() string {
+	return "test"
+}
+
+func (p *countingProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.calls++
+	return &llm.ChatResponse{Content: fmt.Sprint(p.calls)}, nil
+}
+
+func (p *countingProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	p.calls++
+	content := fmt.Sprint(p.calls)
+	return callback(&llm.StreamChunk{Choices: []llm.StreamChoice{{Delta: llm.StreamDelta{Content: &content}}}})
+}
+
+func chatRequest(prompt string) llm.ChatRequest {
+	return llm.ChatRequest{
+		Model:    "m",
+		APIKey:   "sk-a",
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+	}
+}
+
+func TestKey(t *testing.T) {
+	base := chatRequest("hello")
+	key := func(provider, caller string, req llm.ChatRequest) string {
+		t.Helper()
+		k, err := Key(provider, caller, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	want := key("openai", "key_1", base)
+
+	if got := key("openai", "key_1", chatRequest("hello")); got != want {
+		t.Error("identical requests got different keys")
+	}
+	// The caller's upstream credentials are bound to the request, not part
+	// of the key; the key they produced is.
+	withCredentials := chatRequest("hello")
+	withCredentials.Credentials = map[string]string{"openai": "sk-b"}
+	if got := key("openai", "key_1", withCredentials); got != want {
+		t.Error("credentials map changed the key")
+	}
+
+	temperature := float32(0.5)
+	stream := true
+	tests := []struct {
+		name     string
+		provider string
+		caller   string
+		change   func(*llm.ChatRequest)
+	}{
+		{"provider", "azure", "key_1", nil},
+		{"caller", "openai", "key_2", nil},
+		{"API key", "openai", "key_1", func(r *llm.ChatRequest) { r.APIKey = "sk-b" }},
+		{"model", "openai", "key_1", func(r *llm.ChatRequest) { r.Model = "m2" }},
+		{"message", "openai", "key_1", func(r *llm.ChatRequest) { r.Messages[0].Content = "hello!" }},
+		{"system prompt", "openai", "key_1", func(r *llm.ChatRequest) {
+			r.Messages = append([]llm.Message{{Role: llm.RoleSystem, Content: "Be brief."}}, r.Messages...)
+		}},
+		{"options", "openai", "key_1", func(r *llm.ChatRequest) { r.Options.Temperature = &temperature }},
+		{"streaming", "openai", "key_1", func(r *llm.ChatRequest) { r.Options.Stream = &stream }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := chatRequest("hello")
+			if tt.change != nil {
+				tt.change(&req)
+			}
+			if key(tt.provider, tt.caller, req) == want {
+				t.Errorf("a different %s got the same key", tt.name)
+			}
+		})
+	}
+}
+
+func TestParseControl(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Control
+	}{
+		{"", Control{}},
+		{"no-cache", Control{NoCache: true}},
+		{"No-Store, max-age=60", Control{NoStore: true, MaxAge: time.Minute}},
+		{"max-age=0", Control{NoCache: true}},
+		{"max-age=-1, private", Control{}},
+	}
+	for _, tt := range tests {
+		if got := ParseControl(tt.header); got != tt.want {
+			t.Errorf("ParseControl(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// testStores runs fn against each store implementation.
+func testStores(t *testing.T, ttl time.Duration, maxEntries int, fn func(*testing.T, Store)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewMemoryStore(ttl, maxEntries))
+	})
+	t.Run("disk", func(t *testing.T) {
+		s, err := NewDiskStore(t.TempDir(), ttl, maxEntries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(t, s)
+	})
+}
+
+func entry(content string, age time.Duration) *Entry {
+	return &Entry{Response: &llm.ChatResponse{Content: content}, CreatedAt: time.Now().Add(-age)}
+}
+
+func TestStoreExpiresEntries(t *testing.T) {
+	testStores(t, time.Hour, 10, func(t *testing.T, s Store) {
+		s.Set("fresh", entry("fresh", 59*time.Minute))
+		s.Set("stale", entry("stale", 61*time.Minute))
+
+		if e, ok := s.Get("fresh"); !ok || e.Response.Content != "fresh" {
+			t.Errorf("Get(fresh) = %v, %v; want the entry", e, ok)
+		}
+		if _, ok := s.Get("stale"); ok {
+			t.Error("entry older than the TTL served")
+		}
+		if _, ok := s.Get("missing"); ok {
+			t.Error("missing entry served")
+		}
+	})
+}
+
+func TestStoreEvictsOverSize(t *testing.T) {
+	testStores(t, time.Hour, 2, func(t *testing.T, s Store) {
+		s.Set("a", entry("a", 3*time.Second))
+		s.Set("b", entry("b", 2*time.Second))
+		s.Set("c", entry("c", time.Second))
+
+		if _, ok := s.Get("a"); ok {
+			t.Error("oldest entry kept past the size limit")
+		}
+		for _, key := range []string{"b", "c"} {
+			if _, ok := s.Get(key); !ok {
+				t.Errorf("entry %s evicted", key)
+			}
+		}
+	})
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStore(time.Hour, 2)
+	s.Set("a", entry("a", 0))
+	s.Set("b", entry("b", 0))
+	s.Get("a")
+	s.Set("c", entry("c", 0))
+
+	if _, ok := s.Get("b"); ok {
+		t.Error("least recently used entry kept")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Error("recently read entry evicted")
+	}
+}
+
+func TestDiskStoreSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("a", entry("a", 0))
+
+	reopened, err := NewDiskStore(dir, time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := reopened.Get("a"); !ok || e.Response.Content != "a" {
+		t.Errorf("Get after reopening = %v, %v; want the entry", e, ok)
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	upstream := &countingProvider{}
+	p := NewCachingProvider(upstream, NewMemoryStore(time.Hour, 10))
+	chat := func(caller string, control Control, req llm.ChatRequest) (string, Status) {
+		t.Helper()
+		ctx := WithControl(context.Background(), control, caller)
+		resp, err := p.Chat(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Content, StatusFromContext(ctx)
+	}
+
+	if got, status := chat("key_1", Control{}, chatRequest("hello")); got != "1" || status != Miss {
+		t.Errorf("first request = %q, %s; want 1, MISS", got, status)
+	}
+	if got, status := chat("key_1", Control{}, chatRequest("hello")); got != "1" || status != Hit {
+		t.Errorf("repeated request = %q, %s; want the cached 1, HIT", got, status)
+	}
+
+	// Another caller, or the same caller with another upstream key, is not
+	// served the entry.
+	if got, status := chat("key_2", Control{}, chatRequest("hello")); got != "2" || status != Miss {
+		t.Errorf("other caller = %q, %s; want 2, MISS", got, status)
+	}
+	otherKey := chatRequest("hello")
+	otherKey.APIKey = "sk-b"
+	if got, _ := chat("key_1", Control{}, otherKey); got != "3" {
+		t.Errorf("other upstream key = %q, want 3", got)
+	}
+
+	// no-cache skips the lookup but stores the answer; no-store does not
+	// store it.
+	if got, status := chat("key_1", Control{NoCache: true}, chatRequest("hello")); got != "4" || status != Bypass {
+		t.Errorf("no-cache = %q, %s; want 4, BYPASS", got, status)
+	}
+	if got, _ := chat("key_1", Control{}, chatRequest("hello")); got != "4" {
+		t.Errorf("after no-cache = %q, want the refreshed 4", got)
+	}
+	if got, _ := chat("key_1", Control{NoStore: true}, chatRequest("bye")); got != "5" {
+		t.Errorf("no-store = %q, want 5", got)
+	}
+	if got, status := chat("key_1", Control{}, chatRequest("bye")); got != "6" || status != Miss {
+		t.Errorf("after no-store = %q, %s; want 6, MISS", got, status)
+	}
+}
+
+func TestCachingProviderReplaysStreams(t *testing.T) {
+	upstream := &countingProvider{}
+	p := NewCachingProvider(upstream, NewMemoryStore(time.Hour, 10))
+	stream := true
+	req := chatRequest("hello")
+	req.Options.Stream = &stream
+
+	for i := 0; i < 2; i++ {
+		var got []string
+		err := p.ChatStream(WithControl(context.Background(), Control{}, "key_1"), req, func(chunk *llm.StreamChunk) error {
+			got = append(got, *chunk.Choices[0].Delta.Content)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != "1" {
+			t.Errorf("stream %d = %q, want [1]", i+1, got)
+		}
+	}
+	if upstream.calls != 1 {
+		t.Errorf("upstream called %d times, want 1", upstream.calls)
+	}
+}
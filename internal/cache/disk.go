@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+)
+
+// DiskStore keeps one JSON file per entry in a directory, so the cache
+// survives restarts. An index of entry times is kept in memory to enforce
+// the size limit without listing the directory on every write.
+type DiskStore struct {
+	mu         sync.Mutex
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	index      map[string]time.Time // Key -> CreatedAt
+}
+
+// NewDiskStore opens the cache directory dir, creating it if needed, and
+// indexes the entries already in it.
+func NewDiskStore(dir string, ttl time.Duration, maxEntries int) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+
+	s := &DiskStore{
+		dir:        dir,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		index:      make(map[string]time.Time),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %w", dir, err)
+	}
+	for _, f := range files {
+		key, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		s.index[key] = info.ModTime()
+	}
+	s.evictLocked()
+
+	return s, nil
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *DiskStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(createdAt) > s.ttl {
+		s.removeLocked(key)
+		return nil, false
+	}
+
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		delete(s.index, key)
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		logger.Log.Warn().Err(err).Str("key", key).Msg("Dropping unreadable cache entry")
+		s.removeLocked(key)
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (s *DiskStore) Set(key string, entry *Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Log.Warn().Err(err).Msg("Failed to encode cache entry")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write to a temporary file first so readers never see a partial entry.
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		logger.Log.Warn().Err(err).Msg("Failed to write cache entry")
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		logger.Log.Warn().Err(err).Msg("Failed to write cache entry")
+		return
+	}
+
+	s.index[key] = entry.CreatedAt
+	s.evictLocked()
+}
+
+func (s *DiskStore) removeLocked(key string) {
+	os.Remove(s.path(key))
+	delete(s.index, key)
+}
+
+// evictLocked removes expired entries and then the oldest ones until the
+// store is within its size limit.
+func (s *DiskStore) evictLocked() {
+	for key, createdAt := range s.index {
+		if time.Since(createdAt) > s.ttl {
+			s.removeLocked(key)
+		}
+	}
+	for len(s.index) > s.maxEntries {
+		var oldest string
+		for key, createdAt := range s.index {
+			if oldest == "" || createdAt.Before(s.index[oldest]) {
+				oldest = key
+			}
+		}
+		s.removeLocked(oldest)
+	}
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+type vectorItem struct {
+	scope  string
+	vector []float32 // Normalized to unit length
+	entry  *Entry
+}
+
+// VectorIndex is an in-process flat index of embedded prompts. Entries are
+// grouped by scope and only compared within it, so a search costs one dot
+// product per entry in the scope.
+type VectorIndex struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List // Front is the newest entry
+	scopes     map[string][]*list.Element
+}
+
+// NewVectorIndex returns an index holding at most maxEntries entries for up to
+// ttl each.
+func NewVectorIndex(ttl time.Duration, maxEntries int) *VectorIndex {
+	return &VectorIndex{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		scopes:     make(map[string][]*list.Element),
+	}
+}
+
+// Search returns the entry in scope most similar to vector, with its cosine
+// similarity. Entries older than maxAge are skipped when maxAge is non-zero.
+func (x *VectorIndex) Search(scope string, vector []float32, maxAge time.Duration) (*Entry, float64, bool) {
+	query := normalize(vector)
+	if query == nil {
+		return nil, 0, false
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var best *vectorItem
+	bestScore := math.Inf(-1)
+	for _, el := range x.scopes[scope] {
+		item := el.Value.(*vectorItem)
+		age := time.Since(item.entry.CreatedAt)
+		if age > x.ttl || (maxAge > 0 && age > maxAge) || len(item.vector) != len(query) {
+			continue
+		}
+		if score := dot(query, item.vector); score > bestScore {
+			best, bestScore = item, score
+		}
+	}
+
+	if best == nil {
+		return nil, 0, false
+	}
+	return best.entry, bestScore, true
+}
+
+// Add stores entry under vector in scope, evicting expired entries and then
+// the oldest ones if the index is full.
+func (x *VectorIndex) Add(scope string, vector []float32, entry *Entry) {
+	v := normalize(vector)
+	if v == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	el := x.order.PushFront(&vectorItem{scope: scope, vector: v, entry: entry})
+	x.scopes[scope] = append(x.scopes[scope], el)
+
+	for oldest := x.order.Back(); oldest != nil; oldest = x.order.Back() {
+		item := oldest.Value.(*vectorItem)
+		if x.order.Len() <= x.maxEntries && time.Since(item.entry.CreatedAt) <= x.ttl {
+			break
+		}
+		x.removeLocked(oldest)
+	}
+}
+
+func (x *VectorIndex) removeLocked(el *list.Element) {
+	scope := el.Value.(*vectorItem).scope
+	x.order.Remove(el)
+
+	elements := x.scopes[scope]
+	for i, e := range elements {
+		if e == el {
+			elements = append(elements[:i], elements[i+1:]...)
+			break
+		}
+	}
+	if len(elements) == 0 {
+		delete(x.scopes, scope)
+	} else {
+		x.scopes[scope] = elements
+	}
+}
+
+// normalize returns v scaled to unit length, or nil for a zero vector, so
+// that cosine similarity reduces to a dot product.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return nil
+	}
+
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = float32(float64(f) / norm)
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+)
+
+type cachingProvider struct {
+	provider llm.Provider
+	store    Store
+}
+
+// NewCachingProvider serves repeated chat requests from store. Streams are
+// cached once they complete and replayed chunk by chunk. Clients can opt out
+// per request with WithControl.
+func NewCachingProvider(provider llm.Provider, store Store) llm.Provider {
+	return &cachingProvider{
+		provider: provider,
+		store:    store,
+	}
+}
+
+func (p *cachingProvider) Name() string {
+	return p.provider.Name()
+}
+
+// lookup returns the cache key for req and the cached entry, if there is a
+// usable one, and records the cache status in ctx.
+func (p *cachingProvider) lookup(ctx context.Context, req llm.ChatRequest) (string, *Entry) {
+	state := stateFromContext(ctx)
+
+	key, err := Key(p.provider.Name(), state.caller, req)
+	if err != nil {
+		logger.Log.Warn().Err(err).Str("provider", p.provider.Name()).Msg("Failed to compute cache key")
+		state.status = Bypass
+		return "", nil
+	}
+
+	if state.control.NoCache {
+		state.status = Bypass
+		return key, nil
+	}
+
+	entry, ok := p.store.Get(key)
+	if ok && (state.control.MaxAge == 0 || time.Since(entry.CreatedAt) <= state.control.MaxAge) {
+		state.status = Hit
+		return key, entry
+	}
+
+	state.status = Miss
+	return key, nil
+}
+
+func (p *cachingProvider) storable(ctx context.Context, key string) bool {
+	return key != "" && !stateFromContext(ctx).control.NoStore
+}
+
+func (p *cachingProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	key, entry := p.lookup(ctx, req)
+	if entry != nil && entry.Response != nil {
+		resp := *entry.Response
+		return &resp, nil
+	}
+
+	resp, err := p.provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.storable(ctx, key) {
+		stored := *resp
+		p.store.Set(key, &Entry{Response: &stored, CreatedAt: time.Now()})
+	}
+	return resp, nil
+}
+
+func (p *cachingProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	key, entry := p.lookup(ctx, req)
+	if entry != nil {
+		for i := range entry.Chunks {
+			chunk := entry.Chunks[i]
+			if err := callback(&chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	store := p.storable(ctx, key)
+	var chunks []llm.StreamChunk
+
+	err := p.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if store {
+			chunks = append(chunks, *chunk)
+		}
+		return callback(chunk)
+	})
+
+	// Only complete streams are cached; a replay must end the same way.
+	if err == nil && store {
+		p.store.Set(key, &Entry{Chunks: chunks, CreatedAt: time.Now()})
+	}
+	return err
+}
+
+func (p *cachingProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	return llm.Embed(ctx, p.provider, req)
+}
+
+// Respond forwards Responses API requests uncached.
+func (p *cachingProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	return llm.Respond(ctx, p.provider, req)
+}
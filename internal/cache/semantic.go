@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+)
+
+// Embedder computes embeddings for the semantic cache on behalf of the named
+// provider.
+type Embedder interface {
+	Name() string
+	llm.Embedder
+}
+
+type SemanticConfig struct {
+	Embedder       Embedder
+	EmbeddingModel string  // Model name passed to Embedder
+	Threshold      float64 // Minimum cosine similarity for a hit
+}
+
+type semanticProvider struct {
+	provider llm.Provider
+	index    *VectorIndex
+	cfg      SemanticConfig
+}
+
+// NewSemanticProvider serves chat requests whose last user turn is close
+// enough to one already answered. The turn is embedded with cfg.Embedder and
+// compared with earlier turns in index that share the caller, upstream
+// credentials, provider, model, options and the rest of the conversation,
+// including the system prompt.
+func NewSemanticProvider(provider llm.Provider, index *VectorIndex, cfg SemanticConfig) llm.Provider {
+	return &semanticProvider{
+		provider: provider,
+		index:    index,
+		cfg:      cfg,
+	}
+}
+
+func (p *semanticProvider) Name() string {
+	return p.provider.Name()
+}
+
+// prompt splits req, made by caller, into the text of its last user turn and
+// the scope the turn is compared in. ok is false if the request cannot be
+// matched semantically, e.g. because the last turn is not a plain-text user
+// message.
+func (p *semanticProvider) prompt(caller string, req llm.ChatRequest) (text string, scope string, ok bool) {
+	if len(req.Messages) == 0 {
+		return "", "", false
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != llm.RoleUser {
+		return "", "", false
+	}
+	for _, part := range last.Parts {
+		if part.Type != llm.PartText {
+			return "", "", false
+		}
+	}
+	text = last.Text()
+	if text == "" {
+		return "", "", false
+	}
+
+	data, err := json.Marshal(struct {
+		Provider string
+		Caller   string
+		APIKey   string
+		Model    string
+		Context  []llm.Message
+		Options  llm.ChatOptions
+	}{p.provider.Name(), caller, req.APIKey, req.Model, req.Messages[:len(req.Messages)-1], req.Options})
+	if err != nil {
+		return "", "", false
+	}
+
+	sum := sha256.Sum256(data)
+	return text, hex.EncodeToString(sum[:]), true
+}
+
+func (p *semanticProvider) embed(ctx context.Context, req llm.ChatRequest, text string) ([]float32, error) {
+	embedReq := llm.EmbeddingRequest{
+		Model:       p.cfg.EmbeddingModel,
+		Input:       []string{text},
+		Credentials: req.Credentials,
+	}
+	// A pass-through key belongs to the chat provider.
+	if p.cfg.Embedder.Name() == p.provider.Name() {
+		embedReq.APIKey = req.APIKey
+	}
+
+	resp, err := p.cfg.Embedder.Embed(ctx, embedReq)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(resp.Embeddings))
+	}
+	return resp.Embeddings[0], nil
+}
+
+// lookup embeds the prompt of req and returns the closest cached entry if it
+// passes the threshold. The vector and scope are returned so the response can
+// be stored without embedding the prompt again; vector is nil if it must not
+// be stored.
+func (p *semanticProvider) lookup(ctx context.Context, req llm.ChatRequest) (*Entry, []float32, string) {
+	state := stateFromContext(ctx)
+
+	text, scope, ok := p.prompt(state.caller, req)
+	if !ok || (state.control.NoCache && state.control.NoStore) {
+		return nil, nil, ""
+	}
+
+	vector, err := p.embed(ctx, req, text)
+	if err != nil {
+		log := logger.FromContext(ctx)
+		log.Warn().Err(err).Str("provider", p.provider.Name()).Msg("Failed to embed prompt for semantic cache")
+		return nil, nil, ""
+	}
+
+	if state.control.NoCache {
+		state.status = Bypass
+		return nil, vector, scope
+	}
+
+	entry, similarity, ok := p.index.Search(scope, vector, state.control.MaxAge)
+	if ok && similarity >= p.cfg.Threshold {
+		state.status = Hit
+		state.similarity = similarity
+		return entry, nil, ""
+	}
+
+	state.status = Miss
+	return nil, vector, scope
+}
+
+func (p *semanticProvider) storable(ctx context.Context, vector []float32) bool {
+	return vector != nil && !stateFromContext(ctx).control.NoStore
+}
+
+func (p *semanticProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	entry, vector, scope := p.lookup(ctx, req)
+	if entry != nil && entry.Response != nil {
+		resp := *entry.Response
+		return &resp, nil
+	}
+
+	resp, err := p.provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.storable(ctx, vector) {
+		stored := *resp
+		p.index.Add(scope, vector, &Entry{Response: &stored, CreatedAt: time.Now()})
+	}
+	return resp, nil
+}
+
+func (p *semanticProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	entry, vector, scope := p.lookup(ctx, req)
+	if entry != nil && entry.Chunks != nil {
+		for i := range entry.Chunks {
+			chunk := entry.Chunks[i]
+			if err := callback(&chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	store := p.storable(ctx, vector)
+	var chunks []llm.StreamChunk
+
+	err := p.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if store {
+			chunks = append(chunks, *chunk)
+		}
+		return callback(chunk)
+	})
+
+	if err == nil && store {
+		p.index.Add(scope, vector, &Entry{Chunks: chunks, CreatedAt: time.Now()})
+	}
+	return err
+}
+
+func (p *semanticProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	return llm.Embed(ctx, p.provider, req)
+}
+
+// Respond forwards Responses API requests uncached.
+func (p *semanticProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	return llm.Respond(ctx, p.provider, req)
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// fakeEmbedder embeds each known prompt as a fixed vector.
+type fakeEmbedder struct {
+	vectors map[string][]float32
+	calls   int
+}
+
+func (e *fakeEmbedder) Name() string {
+	return "embedder"
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	e.calls++
+	return &llm.EmbeddingResponse{Embeddings: [][]float32{e.vectors[req.Input[0]]}}, nil
+}
+
+// angled returns a unit vector whose cosine similarity with {1, 0} is cos.
+func angled(cos float64) []float32 {
+	return []float32{float32(cos), float32(math.Sqrt(1 - cos*cos))}
+}
+
+func newSemantic(t *testing.T, threshold float64) (llm.Provider, *countingProvider, *fakeEmbedder) {
+	t.Helper()
+	upstream := &countingProvider{}
+	embedder := &fakeEmbedder{vectors: map[string][]float32{
+		"What is the capital of France?":   {1, 0},
+		"what's the capital of france":     angled(0.97),
+		"What is the capital of Germany?":  {0.9, -0.43589},
+		"Tell me about the French capital": angled(0.951),
+		"Tell me about Paris":              angled(0.949),
+	}}
+	p := NewSemanticProvider(upstream, NewVectorIndex(time.Hour, 100), SemanticConfig{
+		Embedder:       embedder,
+		EmbeddingModel: "embed",
+		Threshold:      threshold,
+	})
+	return p, upstream, embedder
+}
+
+func semanticChat(t *testing.T, p llm.Provider, caller string, req llm.ChatRequest) (string, Status, float64) {
+	t.Helper()
+	ctx := WithControl(context.Background(), Control{}, caller)
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	similarity, _ := SimilarityFromContext(ctx)
+	return resp.Content, StatusFromContext(ctx), similarity
+}
+
+func TestSemanticThreshold(t *testing.T) {
+	p, _, _ := newSemantic(t, 0.95)
+	semanticChat(t, p, "key_1", chatRequest("What is the capital of France?"))
+
+	tests := []struct {
+		prompt string
+		hit    bool
+	}{
+		{"what's the capital of france", true},
+		{"Tell me about the French capital", true}, // Just over the threshold
+		{"Tell me about Paris", false},             // Just under it
+		{"What is the capital of Germany?", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prompt, func(t *testing.T) {
+			got, status, similarity := semanticChat(t, p, "key_1", chatRequest(tt.prompt))
+			if tt.hit && (got != "1" || status != Hit || similarity < 0.95) {
+				t.Errorf("got %q, %s at %.2f; want the cached answer", got, status, similarity)
+			}
+			if !tt.hit && (got == "1" || status != Miss) {
+				t.Errorf("got %q, %s; want a fresh answer", got, status)
+			}
+		})
+	}
+}
+
+func TestSemanticScope(t *testing.T) {
+	p, _, _ := newSemantic(t, 0.9)
+	prompt := "What is the capital of France?"
+	semanticChat(t, p, "key_1", chatRequest(prompt))
+
+	temperature := float32(1)
+	tests := []struct {
+		name   string
+		caller string
+		change func(*llm.ChatRequest)
+	}{
+		{"caller", "key_2", nil},
+		{"upstream key", "key_1", func(r *llm.ChatRequest) { r.APIKey = "sk-b" }},
+		{"model", "key_1", func(r *llm.ChatRequest) { r.Model = "m2" }},
+		{"options", "key_1", func(r *llm.ChatRequest) { r.Options.Temperature = &temperature }},
+		{"system prompt", "key_1", func(r *llm.ChatRequest) {
+			r.Messages = append([]llm.Message{{Role: llm.RoleSystem, Content: "Answer in French."}}, r.Messages...)
+		}},
+		{"earlier turns", "key_1", func(r *llm.ChatRequest) {
+			r.Messages = append([]llm.Message{
+				{Role: llm.RoleUser, Content: "Let's talk about Canada."},
+				{Role: llm.RoleAssistant, Content: "Sure."},
+			}, r.Messages...)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := chatRequest(prompt)
+			if tt.change != nil {
+				tt.change(&req)
+			}
+			if got, status, _ := semanticChat(t, p, tt.caller, req); got == "1" || status != Miss {
+				t.Errorf("a different %s was served the cached answer (%s)", tt.name, status)
+			}
+		})
+	}
+
+	// The same prompt in the same scope is still a hit.
+	if got, status, _ := semanticChat(t, p, "key_1", chatRequest(prompt)); got != "1" || status != Hit {
+		t.Errorf("same request = %q, %s; want the cached 1, HIT", got, status)
+	}
+}
+
+func TestSemanticSkipsPromptsItCannotMatch(t *testing.T) {
+	p, upstream, embedder := newSemantic(t, 0.9)
+	tests := []struct {
+		name     string
+		messages []llm.Message
+	}{
+		{"no messages", nil},
+		{"last turn not from the user", []llm.Message{
+			{Role: llm.RoleUser, Content: "What is the capital of France?"},
+			{Role: llm.RoleAssistant, Content: "Paris."},
+		}},
+		{"image input", []llm.Message{{Role: llm.RoleUser, Parts: []llm.ContentPart{
+			{Type: llm.PartText, Text: "What is the capital of France?"},
+			{Type: llm.PartImage},
+		}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := upstream.calls
+			req := chatRequest("")
+			req.Messages = tt.messages
+			if _, status, _ := semanticChat(t, p, "key_1", req); status != "" || upstream.calls != calls+1 {
+				t.Errorf("status %q, want the request sent uncached", status)
+			}
+		})
+	}
+	if embedder.calls != 0 {
+		t.Errorf("embedded %d prompts, want none", embedder.calls)
+	}
+}
+
+func TestVectorIndexEvicts(t *testing.T) {
+	x := NewVectorIndex(time.Hour, 2)
+	add := func(scope string, v []float32, age time.Duration) {
+		x.Add(scope, v, &Entry{Response: &llm.ChatResponse{}, CreatedAt: time.Now().Add(-age)})
+	}
+
+	add("s", []float32{1, 0}, 2*time.Hour) // Already expired
+	if _, _, ok := x.Search("s", []float32{1, 0}, 0); ok {
+		t.Error("expired entry found")
+	}
+
+	add("s", []float32{1, 0}, 3*time.Second)
+	add("s", []float32{0, 1}, 2*time.Second)
+	add("t", []float32{1, 1}, time.Second)
+	if _, score, ok := x.Search("s", []float32{1, 0}, 0); ok && score > 0.99 {
+		t.Error("oldest entry kept past the size limit")
+	}
+	if _, _, ok := x.Search("t", []float32{1, 1}, 0); !ok {
+		t.Error("newest entry evicted")
+	}
+
+	// max-age narrows the search to recent entries.
+	if _, _, ok := x.Search("s", []float32{0, 1}, time.Second); ok {
+		t.Error("entry older than max-age found")
+	}
+}
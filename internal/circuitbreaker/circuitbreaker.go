@@ -3,6 +3,8 @@ package circuitbreaker
 import (
 	"context"
 	"errors"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
@@ -36,7 +38,7 @@ func NewCircuitBreaker(provider llm.Provider, config CircuitBreakerConfig) llm.P
 			return counts.TotalFailures >= uint32(config.FailureThreshold) && failureRatio >= 0.5
 		},
 		IsSuccessful: func(err error) bool {
-			if err == nil {
+			if err == nil || llm.IsClientError(err) || errors.Is(err, context.Canceled) {
 				return true
 			}
 			var pe *llm.ProviderError
@@ -91,7 +93,40 @@ func (c *circuitBreakerProvider) ChatStream(ctx context.Context, req llm.ChatReq
 	return nil
 }
 
+func (c *circuitBreakerProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	result, err := c.cb.Execute(func() (interface{}, error) {
+		return llm.Embed(ctx, c.provider, req)
+	})
+
+	if err != nil {
+		logger.Log.Warn().
+			Str("provider", c.name).
+			Err(err).
+			Msg("Circuit breaker embeddings error")
+		return nil, err
+	}
+
+	return result.(*llm.EmbeddingResponse), nil
+}
+
+func (c *circuitBreakerProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	result, err := c.cb.Execute(func() (interface{}, error) {
+		return llm.Respond(ctx, c.provider, req)
+	})
+
+	if err != nil {
+		logger.Log.Warn().
+			Str("provider", c.name).
+			Err(err).
+			Msg("Circuit breaker responses error")
+		return nil, err
+	}
+
+	return result.(io.ReadCloser), nil
+}
+
 type CircuitBreakerManager struct {
+	mu            sync.RWMutex
 	breakers      map[string]*gobreaker.CircuitBreaker
 	defaultConfig CircuitBreakerConfig
 }
@@ -114,19 +149,66 @@ func NewCircuitBreakerManager(config CircuitBreakerConfig) *CircuitBreakerManage
 }
 
 func (m *CircuitBreakerManager) GetBreaker(name string) *gobreaker.CircuitBreaker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.breakers[name]
 }
 
 func (m *CircuitBreakerManager) GetState(name string) string {
-	if cb, ok := m.breakers[name]; ok {
+	if cb := m.GetBreaker(name); cb != nil {
 		return cb.State().String()
 	}
 	return "unknown"
 }
 
+// States returns the current state of every breaker by provider name.
+func (m *CircuitBreakerManager) States() map[string]gobreaker.State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make(map[string]gobreaker.State, len(m.breakers))
+	for name, cb := range m.breakers {
+		states[name] = cb.State()
+	}
+	return states
+}
+
+// Adopt registers cb, a breaker of a manager with the same settings, under
+// name, so that providers wrapped as name keep its state. It is used to
+// carry breakers over a configuration reload.
+func (m *CircuitBreakerManager) Adopt(name string, cb *gobreaker.CircuitBreaker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.breakers[name] = cb
+}
+
 func (m *CircuitBreakerManager) WrapProvider(provider llm.Provider) llm.Provider {
-	settings := gobreaker.Settings{
-		Name:        provider.Name(),
+	return m.WrapProviderAs(provider, provider.Name())
+}
+
+// WrapProviderAs is WrapProvider with the breaker registered under name, so
+// several instances of one provider, such as the deployments of a pool, each
+// get their own. Providers wrapped under the same name share a breaker.
+func (m *CircuitBreakerManager) WrapProviderAs(provider llm.Provider, name string) llm.Provider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cb, ok := m.breakers[name]
+	if !ok {
+		cb = gobreaker.NewCircuitBreaker(m.settings(name))
+		m.breakers[name] = cb
+	}
+
+	return &circuitBreakerProvider{
+		provider: provider,
+		cb:       cb,
+		name:     provider.Name(),
+	}
+}
+
+func (m *CircuitBreakerManager) settings(name string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:        name,
 		MaxRequests: uint32(m.defaultConfig.SuccessThreshold),
 		Interval:    m.defaultConfig.Timeout,
 		Timeout:     m.defaultConfig.Timeout,
@@ -138,7 +220,7 @@ func (m *CircuitBreakerManager) WrapProvider(provider llm.Provider) llm.Provider
 			return counts.TotalFailures >= uint32(m.defaultConfig.FailureThreshold) && failureRatio >= 0.5
 		},
 		IsSuccessful: func(err error) bool {
-			if err == nil {
+			if err == nil || llm.IsClientError(err) || errors.Is(err, context.Canceled) {
 				return true
 			}
 			var pe *llm.ProviderError
@@ -148,15 +230,6 @@ func (m *CircuitBreakerManager) WrapProvider(provider llm.Provider) llm.Provider
 			return false
 		},
 	}
-
-	cb := gobreaker.NewCircuitBreaker(settings)
-	m.breakers[provider.Name()] = cb
-
-	return &circuitBreakerProvider{
-		provider: provider,
-		cb:       cb,
-		name:     provider.Name(),
-	}
 }
 
 var (
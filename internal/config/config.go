@@ -0,0 +1,570 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the declarative gateway configuration. It is loaded from a YAML or
+// JSON file and then overridden by environment variables.
+type Config struct {
+	Server         ServerConfig              `yaml:"server" json:"server"`
+	Auth           AuthConfig                `yaml:"auth" json:"auth"`
+	Providers      map[string]ProviderConfig `yaml:"providers" json:"providers"`
+	Retry          RetryConfig               `yaml:"retry" json:"retry"`
+	CircuitBreaker CircuitBreakerConfig      `yaml:"circuitBreaker" json:"circuitBreaker"`
+	RateLimit      RateLimitConfig           `yaml:"rateLimit" json:"rateLimit"`
+	Budgets        BudgetsConfig             `yaml:"budgets" json:"budgets"`
+	Tracing        TracingConfig             `yaml:"tracing" json:"tracing"`
+	Cache          CacheConfig               `yaml:"cache" json:"cache"`
+	Responses      ResponsesConfig           `yaml:"responses" json:"responses"`
+	// Aliases map a model name clients may send to a provider/model spec,
+	// which can include "|" separated fallbacks.
+	Aliases map[string]string `yaml:"aliases" json:"aliases"`
+	// Pools are logical models served by several deployments, such as the
+	// same model under different accounts or regions. They are used like
+	// aliases.
+	Pools map[string]PoolConfig `yaml:"pools" json:"pools"`
+	// Pricing adds to or overrides the built-in price list, keyed by
+	// provider/model ID.
+	Pricing map[string]ModelPrice `yaml:"pricing" json:"pricing"`
+}
+
+type ServerConfig struct {
+	Port         string        `yaml:"port" json:"port"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" json:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout" json:"idleTimeout"`
+}
+
+type AuthConfig struct {
+	AdminKey         string `yaml:"adminKey" json:"-"`
+	KeysFile         string `yaml:"keysFile" json:"keysFile"`
+	AllowPassthrough bool   `yaml:"allowPassthrough" json:"allowPassthrough"`
+}
+
+// ProviderConfig overrides the defaults of a single provider. Not every field
+// applies to every provider: Region, the AWS keys, Profile and RoleARN are
+// Bedrock-only, ProjectID, Location and CredentialsFile are Vertex-only, and
+// APIVersion, Deployments and the Entra ID fields are Azure-only.
+type ProviderConfig struct {
+	BaseURL string            `yaml:"baseURL" json:"baseURL,omitempty"`
+	APIKey  string            `yaml:"apiKey" json:"-"`
+	Headers map[string]string `yaml:"headers" json:"-"`
+	Timeout time.Duration     `yaml:"timeout" json:"timeout,omitempty"`
+
+	Region          string `yaml:"region" json:"region,omitempty"`
+	AccessKeyID     string `yaml:"accessKeyID" json:"-"`
+	SecretAccessKey string `yaml:"secretAccessKey" json:"-"`
+	SessionToken    string `yaml:"sessionToken" json:"-"`
+	Profile         string `yaml:"profile" json:"profile,omitempty"`
+	RoleARN         string `yaml:"roleARN" json:"roleARN,omitempty"`
+
+	ProjectID string `yaml:"projectID" json:"projectID,omitempty"`
+	Location  string `yaml:"location" json:"location,omitempty"`
+	// CredentialsFile is a Google service account key file.
+	CredentialsFile string `yaml:"credentialsFile" json:"credentialsFile,omitempty"`
+
+	APIVersion string `yaml:"apiVersion" json:"apiVersion,omitempty"`
+	// Deployments maps model names to Azure deployment names.
+	Deployments  map[string]string `yaml:"deployments" json:"deployments,omitempty"`
+	TenantID     string            `yaml:"tenantID" json:"tenantID,omitempty"`
+	ClientID     string            `yaml:"clientID" json:"clientID,omitempty"`
+	ClientSecret string            `yaml:"clientSecret" json:"-"`
+}
+
+// Overlay returns p with every field that is set in o replaced by o's value.
+func (p ProviderConfig) Overlay(o ProviderConfig) ProviderConfig {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&p.BaseURL, o.BaseURL)
+	set(&p.APIKey, o.APIKey)
+	set(&p.Region, o.Region)
+	set(&p.AccessKeyID, o.AccessKeyID)
+	set(&p.SecretAccessKey, o.SecretAccessKey)
+	set(&p.SessionToken, o.SessionToken)
+	set(&p.Profile, o.Profile)
+	set(&p.RoleARN, o.RoleARN)
+	set(&p.ProjectID, o.ProjectID)
+	set(&p.Location, o.Location)
+	set(&p.CredentialsFile, o.CredentialsFile)
+	set(&p.APIVersion, o.APIVersion)
+	set(&p.TenantID, o.TenantID)
+	set(&p.ClientID, o.ClientID)
+	set(&p.ClientSecret, o.ClientSecret)
+	if o.Headers != nil {
+		p.Headers = o.Headers
+	}
+	if o.Timeout != 0 {
+		p.Timeout = o.Timeout
+	}
+	if o.Deployments != nil {
+		p.Deployments = o.Deployments
+	}
+	return p
+}
+
+// HasCredentials reports whether p sets credentials of its own.
+func (p ProviderConfig) HasCredentials() bool {
+	return p.APIKey != "" || p.AccessKeyID != "" || p.Profile != "" || p.RoleARN != "" ||
+		p.CredentialsFile != "" || p.ClientSecret != ""
+}
+
+// PoolConfig spreads requests for one logical model over several
+// deployments with Strategy: weighted round-robin by default, or
+// least-latency, least-busy, power-of-two-choices or lowest-cost. A deployment is ejected
+// for EjectionTime after FailureThreshold consecutive 429 or 5xx errors, for
+// twice as long on each further ejection up to MaxEjectionTime, and is
+// brought back to its full weight over SlowStart.
+type PoolConfig struct {
+	Deployments      []DeploymentConfig `yaml:"deployments" json:"deployments"`
+	Strategy         string             `yaml:"strategy" json:"strategy,omitempty"`
+	FailureThreshold int                `yaml:"failureThreshold" json:"failureThreshold,omitempty"`
+	EjectionTime     time.Duration      `yaml:"ejectionTime" json:"ejectionTime,omitempty"`
+	MaxEjectionTime  time.Duration      `yaml:"maxEjectionTime" json:"maxEjectionTime,omitempty"`
+	SlowStart        time.Duration      `yaml:"slowStart" json:"slowStart,omitempty"`
+}
+
+// DeploymentConfig is one member of a pool. Its provider settings are laid
+// over the provider's own entry in providers, so a deployment usually only
+// sets its credentials, endpoint or region.
+type DeploymentConfig struct {
+	Name   string `yaml:"name" json:"name,omitempty"` // Label in logs and metrics; defaults to Model
+	Model  string `yaml:"model" json:"model"`         // provider/model
+	Weight int    `yaml:"weight" json:"weight,omitempty"`
+
+	// ContextLength, Tools and JSONMode say what the deployment supports.
+	// Requests it cannot serve are sent to other deployments. Zero and unset
+	// mean no limit.
+	ContextLength int   `yaml:"contextLength" json:"contextLength,omitempty"`
+	Tools         *bool `yaml:"tools" json:"tools,omitempty"`
+	JSONMode      *bool `yaml:"jsonMode" json:"jsonMode,omitempty"`
+
+	ProviderConfig `yaml:",inline"`
+}
+
+type RetryConfig struct {
+	MaxRetries     int           `yaml:"maxRetries" json:"maxRetries"`
+	InitialDelay   time.Duration `yaml:"initialDelay" json:"initialDelay"`
+	MaxDelay       time.Duration `yaml:"maxDelay" json:"maxDelay"`
+	Multiplier     float64       `yaml:"multiplier" json:"multiplier"`
+	RetryableCodes []int         `yaml:"retryableCodes" json:"retryableCodes"`
+	// WithFallback keeps retries enabled for providers in a "|" fallback chain.
+	WithFallback bool `yaml:"withFallback" json:"withFallback"`
+	// StreamBufferTokens holds back the first tokens of a stream so that a
+	// failure within them can still be retried or fall back. With zero, a
+	// stream is committed to its provider at the first content.
+	StreamBufferTokens int `yaml:"streamBufferTokens" json:"streamBufferTokens"`
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failureThreshold" json:"failureThreshold"`
+	SuccessThreshold int           `yaml:"successThreshold" json:"successThreshold"`
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+	RequestsPerMinute int     `yaml:"requestsPerMinute" json:"requestsPerMinute"`
+	RequestsPerHour   int     `yaml:"requestsPerHour" json:"requestsPerHour"`
+	RequestsPerDay    int     `yaml:"requestsPerDay" json:"requestsPerDay"`
+	Burst             int     `yaml:"burst" json:"burst"`
+	MaxClients        int     `yaml:"maxClients" json:"maxClients"`
+}
+
+// BudgetsConfig locates the SQLite database holding spend budgets and the
+// spend recorded against them. Budgets are managed through the admin API.
+type BudgetsConfig struct {
+	File string `yaml:"file" json:"file"`
+}
+
+// TracingConfig controls OpenTelemetry trace export over OTLP/HTTP. Tracing
+// is off unless Enabled is set.
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled" json:"enabled"`
+	Endpoint    string            `yaml:"endpoint" json:"endpoint,omitempty"` // host:port or URL; defaults to localhost:4318
+	Insecure    bool              `yaml:"insecure" json:"insecure"`
+	Headers     map[string]string `yaml:"headers" json:"-"`
+	ServiceName string            `yaml:"serviceName" json:"serviceName"`
+	SampleRatio float64           `yaml:"sampleRatio" json:"sampleRatio"`
+}
+
+// CacheConfig controls the exact-match response cache. It is off unless
+// Enabled is set.
+type CacheConfig struct {
+	Enabled    bool                `yaml:"enabled" json:"enabled"`
+	Backend    string              `yaml:"backend" json:"backend"` // "memory" or "disk"
+	TTL        time.Duration       `yaml:"ttl" json:"ttl"`         // Also applies to the semantic cache
+	MaxEntries int                 `yaml:"maxEntries" json:"maxEntries"`
+	Dir        string              `yaml:"dir" json:"dir,omitempty"` // Directory of the disk backend
+	Semantic   SemanticCacheConfig `yaml:"semantic" json:"semantic"`
+}
+
+// SemanticCacheConfig controls the semantic cache, which serves prompts that
+// are similar rather than identical to earlier ones. It can be enabled on its
+// own or together with the exact-match cache.
+type SemanticCacheConfig struct {
+	Enabled        bool    `yaml:"enabled" json:"enabled"`
+	EmbeddingModel string  `yaml:"embeddingModel" json:"embeddingModel"` // provider/model
+	Threshold      float64 `yaml:"threshold" json:"threshold"`           // Minimum cosine similarity for a hit
+	MaxEntries     int     `yaml:"maxEntries" json:"maxEntries"`
+}
+
+// ResponsesConfig controls the Responses API. The conversation store keeps
+// the history of translated responses so previous_response_id works with any
+// provider; it is off unless enabled. Requests served by OpenAI natively are
+// stored by OpenAI.
+type ResponsesConfig struct {
+	Store ResponseStoreConfig `yaml:"store" json:"store"`
+}
+
+type ResponseStoreConfig struct {
+	Enabled    bool          `yaml:"enabled" json:"enabled"`
+	TTL        time.Duration `yaml:"ttl" json:"ttl"`
+	MaxEntries int           `yaml:"maxEntries" json:"maxEntries"`
+}
+
+// ModelPrice is a model's price in USD per million tokens. CachedInput and
+// CacheWrite default to Input when zero.
+type ModelPrice struct {
+	Input       float64 `yaml:"input" json:"input"`
+	Output      float64 `yaml:"output" json:"output"`
+	CachedInput float64 `yaml:"cachedInput" json:"cachedInput,omitempty"`
+	CacheWrite  float64 `yaml:"cacheWrite" json:"cacheWrite,omitempty"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:         "8082",
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		Auth: AuthConfig{
+			KeysFile: "keys.json",
+		},
+		Providers: map[string]ProviderConfig{},
+		Retry: RetryConfig{
+			MaxRetries:     3,
+			InitialDelay:   500 * time.Millisecond,
+			MaxDelay:       10 * time.Second,
+			Multiplier:     2.0,
+			RetryableCodes: []int{429, 500, 502, 503, 504},
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			SuccessThreshold: 3,
+			Timeout:          30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             20,
+			MaxClients:        10000,
+		},
+		Budgets: BudgetsConfig{
+			File: "budgets.db",
+		},
+		Tracing: TracingConfig{
+			ServiceName: "atozi-gateway",
+			SampleRatio: 1,
+		},
+		Cache: CacheConfig{
+			Backend:    "memory",
+			TTL:        time.Hour,
+			MaxEntries: 10000,
+			Dir:        "cache",
+			Semantic: SemanticCacheConfig{
+				EmbeddingModel: "openai/text-embedding-3-small",
+				Threshold:      0.95,
+				MaxEntries:     10000,
+			},
+		},
+		Responses: ResponsesConfig{
+			Store: ResponseStoreConfig{
+				TTL:        24 * time.Hour,
+				MaxEntries: 10000,
+			},
+		},
+		Aliases: map[string]string{},
+		Pools:   map[string]PoolConfig{},
+		Pricing: map[string]ModelPrice{},
+	}
+}
+
+// Load reads the config file at path (if any), applies environment overrides
+// and validates the result. "${VAR}" references in the file are expanded from
+// the environment so secrets can stay out of the file.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return build(nil, "")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return build(data, path)
+}
+
+// build decodes file contents over the defaults, applies environment
+// overrides and validates. data is nil when there is no config file.
+func build(data []byte, path string) (*Config, error) {
+	cfg := Default()
+
+	if data != nil {
+		if err := decode(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func decode(data []byte, cfg *Config) error {
+	expanded := expandEnv(string(data))
+
+	// YAML is a superset of JSON, so one decoder handles both formats.
+	dec := yaml.NewDecoder(strings.NewReader(expanded))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if cfg.Providers == nil {
+		cfg.Providers = map[string]ProviderConfig{}
+	}
+	if cfg.Aliases == nil {
+		cfg.Aliases = map[string]string{}
+	}
+	if cfg.Pools == nil {
+		cfg.Pools = map[string]PoolConfig{}
+	}
+	if cfg.Pricing == nil {
+		cfg.Pricing = map[string]ModelPrice{}
+	}
+	return nil
+}
+
+// envRef matches a ${VAR} reference in a config file. A bare $VAR is left
+// alone, since "$" is common in keys and passwords.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in s with the values of the
+// environment variables they name, or with nothing if they are unset.
+func expandEnv(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// Validate reports every problem found in the configuration at once.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port == "" {
+		add("server.port must not be empty")
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		add("server timeouts must not be negative")
+	}
+
+	checkProvider := func(path string, p ProviderConfig) {
+		if p.BaseURL != "" {
+			u, err := url.Parse(p.BaseURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("%s.baseURL %q must be an absolute http(s) URL", path, p.BaseURL)
+			}
+		}
+		if p.Timeout < 0 {
+			add("%s.timeout must not be negative", path)
+		}
+		if (p.AccessKeyID == "") != (p.SecretAccessKey == "") {
+			add("%s: accessKeyID and secretAccessKey must be set together", path)
+		}
+	}
+	for name, p := range c.Providers {
+		checkProvider("providers."+name, p)
+	}
+
+	if c.Retry.MaxRetries < 0 {
+		add("retry.maxRetries must not be negative")
+	}
+	if c.Retry.InitialDelay <= 0 {
+		add("retry.initialDelay must be positive")
+	}
+	if c.Retry.MaxDelay < c.Retry.InitialDelay {
+		add("retry.maxDelay must be at least retry.initialDelay")
+	}
+	if c.Retry.Multiplier < 1 {
+		add("retry.multiplier must be at least 1")
+	}
+	for _, code := range c.Retry.RetryableCodes {
+		if code < 400 || code > 599 {
+			add("retry.retryableCodes: %d is not an HTTP error status", code)
+		}
+	}
+	if c.Retry.StreamBufferTokens < 0 {
+		add("retry.streamBufferTokens must not be negative")
+	}
+
+	if c.CircuitBreaker.FailureThreshold <= 0 {
+		add("circuitBreaker.failureThreshold must be positive")
+	}
+	if c.CircuitBreaker.SuccessThreshold <= 0 {
+		add("circuitBreaker.successThreshold must be positive")
+	}
+	if c.CircuitBreaker.Timeout <= 0 {
+		add("circuitBreaker.timeout must be positive")
+	}
+
+	if c.RateLimit.RequestsPerSecond <= 0 {
+		add("rateLimit.requestsPerSecond must be positive")
+	}
+	if c.RateLimit.Burst <= 0 {
+		add("rateLimit.burst must be positive")
+	}
+	if c.RateLimit.RequestsPerMinute < 0 || c.RateLimit.RequestsPerHour < 0 || c.RateLimit.RequestsPerDay < 0 {
+		add("rateLimit window limits must not be negative")
+	}
+	if c.RateLimit.MaxClients < 0 {
+		add("rateLimit.maxClients must not be negative")
+	}
+
+	for alias, target := range c.Aliases {
+		if alias == "" {
+			add("aliases: alias names must not be empty")
+			continue
+		}
+		if target == "" {
+			add("aliases.%s must not be empty", alias)
+			continue
+		}
+		for _, spec := range strings.Split(target, "|") {
+			if _, isPool := c.Pools[spec]; isPool {
+				continue
+			}
+			if _, _, ok := strings.Cut(spec, "/"); !ok {
+				add("aliases.%s: %q must be in provider/model format or name a pool", alias, spec)
+			}
+		}
+	}
+
+	for name, pool := range c.Pools {
+		if name == "" || strings.ContainsAny(name, "/|") {
+			add("pools: %q must be a non-empty name without \"/\" or \"|\"", name)
+			continue
+		}
+		if _, ok := c.Aliases[name]; ok {
+			add("pools.%s: an alias has the same name", name)
+		}
+		if len(pool.Deployments) == 0 {
+			add("pools.%s must have at least one deployment", name)
+		}
+		for i, d := range pool.Deployments {
+			path := fmt.Sprintf("pools.%s.deployments[%d]", name, i)
+			if provider, model, ok := strings.Cut(d.Model, "/"); !ok || provider == "" || model == "" {
+				add("%s.model must be in provider/model format, got %q", path, d.Model)
+			}
+			if d.Weight < 0 {
+				add("%s.weight must not be negative", path)
+			}
+			if d.ContextLength < 0 {
+				add("%s.contextLength must not be negative", path)
+			}
+			checkProvider(path, d.ProviderConfig)
+		}
+		switch pool.Strategy {
+		case "", "weighted", "least-latency", "least-busy", "power-of-two-choices", "lowest-cost":
+		default:
+			add("pools.%s.strategy must be weighted, least-latency, least-busy, power-of-two-choices or lowest-cost, got %q", name, pool.Strategy)
+		}
+		if pool.FailureThreshold < 0 {
+			add("pools.%s.failureThreshold must not be negative", name)
+		}
+		if pool.EjectionTime < 0 || pool.MaxEjectionTime < 0 || pool.SlowStart < 0 {
+			add("pools.%s: durations must not be negative", name)
+		}
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sampleRatio must be between 0 and 1")
+	}
+	if c.Tracing.Enabled && c.Tracing.ServiceName == "" {
+		add("tracing.serviceName must not be empty")
+	}
+
+	if c.Cache.Backend != "memory" && c.Cache.Backend != "disk" {
+		add("cache.backend must be memory or disk, got %q", c.Cache.Backend)
+	}
+	if c.Cache.TTL <= 0 {
+		add("cache.ttl must be positive")
+	}
+	if c.Cache.MaxEntries <= 0 {
+		add("cache.maxEntries must be positive")
+	}
+	if c.Cache.Backend == "disk" && c.Cache.Dir == "" {
+		add("cache.dir must be set for the disk backend")
+	}
+	if c.Cache.Semantic.Enabled {
+		if provider, model, ok := strings.Cut(c.Cache.Semantic.EmbeddingModel, "/"); !ok || provider == "" || model == "" {
+			add("cache.semantic.embeddingModel must be in provider/model format, got %q", c.Cache.Semantic.EmbeddingModel)
+		}
+	}
+	if c.Cache.Semantic.Threshold <= 0 || c.Cache.Semantic.Threshold > 1 {
+		add("cache.semantic.threshold must be in (0, 1]")
+	}
+	if c.Cache.Semantic.MaxEntries <= 0 {
+		add("cache.semantic.maxEntries must be positive")
+	}
+
+	if c.Responses.Store.TTL <= 0 {
+		add("responses.store.ttl must be positive")
+	}
+	if c.Responses.Store.MaxEntries <= 0 {
+		add("responses.store.maxEntries must be positive")
+	}
+
+	for id, price := range c.Pricing {
+		if _, _, ok := strings.Cut(id, "/"); !ok {
+			add("pricing: %q must be in provider/model format", id)
+		}
+		if price.Input < 0 || price.Output < 0 || price.CachedInput < 0 || price.CacheWrite < 0 {
+			add("pricing.%s: prices must not be negative", id)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
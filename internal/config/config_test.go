@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes contents to a config file named name in a temporary
+// directory and returns its path.
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeConfig(t, "gateway.yaml", `
+server:
+  port: "9000"
+  readTimeout: 5s
+retry:
+  maxRetries: 1
+aliases:
+  fast: groq/llama-3.3-70b-versatile|openai/gpt-4o-mini
+pools:
+  gpt-4o:
+    strategy: least-busy
+    deployments:
+      - model: openai/gpt-4o
+        weight: 2
+        apiKey: sk-a
+pricing:
+  custom/model: {input: 1, output: 2}
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout != 5*time.Second {
+		t.Errorf("server = %+v, want port 9000 and a 5s read timeout", cfg.Server)
+	}
+	// Settings missing from the file keep their defaults.
+	if cfg.Server.WriteTimeout != 15*time.Second || cfg.Retry.Multiplier != 2 {
+		t.Errorf("defaults not kept: write timeout %v, multiplier %v", cfg.Server.WriteTimeout, cfg.Retry.Multiplier)
+	}
+	if cfg.Retry.MaxRetries != 1 {
+		t.Errorf("retry.maxRetries = %d, want 1", cfg.Retry.MaxRetries)
+	}
+	if got := cfg.Aliases["fast"]; got != "groq/llama-3.3-70b-versatile|openai/gpt-4o-mini" {
+		t.Errorf("aliases.fast = %q", got)
+	}
+	pool := cfg.Pools["gpt-4o"]
+	if pool.Strategy != "least-busy" || len(pool.Deployments) != 1 {
+		t.Fatalf("pools.gpt-4o = %+v", pool)
+	}
+	if d := pool.Deployments[0]; d.Model != "openai/gpt-4o" || d.Weight != 2 || d.APIKey != "sk-a" {
+		t.Errorf("deployment = %+v, want openai/gpt-4o of weight 2 with its own key", d)
+	}
+	if got := cfg.Pricing["custom/model"]; got != (ModelPrice{Input: 1, Output: 2}) {
+		t.Errorf("pricing = %+v", got)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeConfig(t, "gateway.json", `{"server": {"port": "9001"}, "providers": {"openai": {"baseURL": "https://proxy.example.com/v1"}}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9001" || cfg.Providers["openai"].BaseURL != "https://proxy.example.com/v1" {
+		t.Errorf("config = %+v, want port 9001 and the openai base URL", cfg)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, "gateway.yaml", "retry:\n  maxRetry: 1\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "maxRetry") {
+		t.Errorf("Load = %v, want an error naming the unknown field", err)
+	}
+}
+
+func TestLoadExpandsBracedEnv(t *testing.T) {
+	t.Setenv("TEST_GATEWAY_KEY", "sk-from-env")
+	t.Setenv("TEST_GATEWAY_BARE", "expanded")
+	path := writeConfig(t, "gateway.yaml", `
+auth:
+  adminKey: pa$TEST_GATEWAY_BARE
+providers:
+  openai:
+    apiKey: ${TEST_GATEWAY_KEY}
+  groq:
+    apiKey: ${TEST_GATEWAY_UNSET}
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Providers["openai"].APIKey; got != "sk-from-env" {
+		t.Errorf("${VAR} expanded to %q, want sk-from-env", got)
+	}
+	if got := cfg.Providers["groq"].APIKey; got != "" {
+		t.Errorf("unset ${VAR} expanded to %q, want empty", got)
+	}
+	// A bare $ is part of the value, as in passwords.
+	if got := cfg.Auth.AdminKey; got != "pa$TEST_GATEWAY_BARE" {
+		t.Errorf("bare $VAR expanded to %q, want it kept", got)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = ""
+	cfg.Retry.Multiplier = 0.5
+	cfg.Providers["openai"] = ProviderConfig{BaseURL: "proxy.example.com"}
+	cfg.Aliases["fast"] = "gpt-4o"
+	cfg.Pools["p"] = PoolConfig{Strategy: "random"}
+	cfg.Cache.Semantic.Threshold = 2
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate = nil, want an error")
+	}
+	for _, want := range []string{
+		"server.port must not be empty",
+		"retry.multiplier must be at least 1",
+		`providers.openai.baseURL "proxy.example.com" must be an absolute http(s) URL`,
+		`aliases.fast: "gpt-4o" must be in provider/model format or name a pool`,
+		"pools.p must have at least one deployment",
+		`pools.p.strategy must be weighted, least-latency, least-busy, power-of-two-choices or lowest-cost, got "random"`,
+		"cache.semantic.threshold must be in (0, 1]",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error does not report %q:\n%v", want, err)
+		}
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	t.Setenv("PORT", "9100")
+	t.Setenv("CACHE_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_REQUESTS_PER_SECOND", "2.5")
+	t.Setenv("RATE_LIMIT_BURST", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("OLLAMA_HOST", "gpu-box:11434")
+	path := writeConfig(t, "gateway.yaml", `
+server:
+  port: "9000"
+rateLimit:
+  burst: 7
+providers:
+  aws_bedrock:
+    profile: prod
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != "9100" || !cfg.Cache.Enabled || cfg.RateLimit.RequestsPerSecond != 2.5 {
+		t.Errorf("environment did not override the file: port %q, cache %v, requests per second %v",
+			cfg.Server.Port, cfg.Cache.Enabled, cfg.RateLimit.RequestsPerSecond)
+	}
+	// Empty variables leave the file's value.
+	if cfg.RateLimit.Burst != 7 {
+		t.Errorf("rateLimit.burst = %d, want 7 from the file", cfg.RateLimit.Burst)
+	}
+	// Provider variables are laid over the file's entry.
+	if p := cfg.Providers["aws_bedrock"]; p.Region != "eu-west-1" || p.Profile != "prod" {
+		t.Errorf("aws_bedrock = %+v, want region eu-west-1 and profile prod", p)
+	}
+	if got := cfg.Providers["ollama"].BaseURL; got != "http://gpu-box:11434/v1" {
+		t.Errorf("ollama baseURL = %q, want http://gpu-box:11434/v1", got)
+	}
+	// Providers without variables get no entry.
+	if _, ok := cfg.Providers["vertex"]; ok {
+		t.Error("vertex entry added without any of its variables set")
+	}
+}
+
+func TestEnvRejectsMalformedValues(t *testing.T) {
+	t.Setenv("CACHE_ENABLED", "maybe")
+	t.Setenv("RATE_LIMIT_BURST", "lots")
+	t.Setenv("RATE_LIMIT_REQUESTS_PER_SECOND", "1,5")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("Load = nil, want an error")
+	}
+	for _, want := range []string{
+		`CACHE_ENABLED has invalid value "maybe"`,
+		`RATE_LIMIT_BURST has invalid value "lots"`,
+		`RATE_LIMIT_REQUESTS_PER_SECOND has invalid value "1,5"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load error does not report %q:\n%v", want, err)
+		}
+	}
+}
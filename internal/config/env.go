@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyEnv overrides file settings with the environment variables the gateway
+// has always supported, so existing deployments keep working unchanged. It
+// reports every variable whose value cannot be parsed at once.
+func applyEnv(cfg *Config) error {
+	var errs []error
+	setBool := func(dst *bool, key string) {
+		errs = append(errs, parseEnv(dst, key, strconv.ParseBool))
+	}
+	setInt := func(dst *int, key string) {
+		errs = append(errs, parseEnv(dst, key, strconv.Atoi))
+	}
+	setFloat := func(dst *float64, key string) {
+		errs = append(errs, parseEnv(dst, key, func(val string) (float64, error) {
+			return strconv.ParseFloat(val, 64)
+		}))
+	}
+
+	setString(&cfg.Server.Port, "PORT")
+
+	setString(&cfg.Auth.AdminKey, "ADMIN_API_KEY")
+	setString(&cfg.Auth.KeysFile, "KEYS_FILE")
+	setBool(&cfg.Auth.AllowPassthrough, "ALLOW_KEY_PASSTHROUGH")
+
+	setString(&cfg.Budgets.File, "BUDGETS_FILE")
+
+	setBool(&cfg.Cache.Enabled, "CACHE_ENABLED")
+	setString(&cfg.Cache.Backend, "CACHE_BACKEND")
+	setString(&cfg.Cache.Dir, "CACHE_DIR")
+	setBool(&cfg.Cache.Semantic.Enabled, "SEMANTIC_CACHE_ENABLED")
+	setString(&cfg.Cache.Semantic.EmbeddingModel, "SEMANTIC_CACHE_EMBEDDING_MODEL")
+
+	setBool(&cfg.Responses.Store.Enabled, "RESPONSES_STORE_ENABLED")
+
+	setBool(&cfg.Tracing.Enabled, "TRACING_ENABLED")
+	setString(&cfg.Tracing.Endpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	setString(&cfg.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+
+	setBool(&cfg.Retry.WithFallback, "RETRY_WITH_FALLBACK")
+	setInt(&cfg.Retry.StreamBufferTokens, "RETRY_STREAM_BUFFER_TOKENS")
+
+	setFloat(&cfg.RateLimit.RequestsPerSecond, "RATE_LIMIT_REQUESTS_PER_SECOND")
+	setInt(&cfg.RateLimit.RequestsPerMinute, "RATE_LIMIT_REQUESTS_PER_MINUTE")
+	setInt(&cfg.RateLimit.RequestsPerHour, "RATE_LIMIT_REQUESTS_PER_HOUR")
+	setInt(&cfg.RateLimit.RequestsPerDay, "RATE_LIMIT_REQUESTS_PER_DAY")
+	setInt(&cfg.RateLimit.Burst, "RATE_LIMIT_BURST")
+	setInt(&cfg.RateLimit.MaxClients, "RATE_LIMIT_MAX_CLIENTS")
+
+	updateProvider(cfg, "aws_bedrock", func(p *ProviderConfig) bool {
+		a := setString(&p.AccessKeyID, "AWS_ACCESS_KEY_ID")
+		b := setString(&p.SecretAccessKey, "AWS_SECRET_ACCESS_KEY")
+		c := setString(&p.SessionToken, "AWS_SESSION_TOKEN")
+		d := setString(&p.Region, "AWS_REGION")
+		return a || b || c || d
+	})
+
+	updateProvider(cfg, "vertex", func(p *ProviderConfig) bool {
+		a := setString(&p.ProjectID, "GOOGLE_PROJECT_ID")
+		b := setString(&p.Location, "GOOGLE_LOCATION")
+		c := setString(&p.CredentialsFile, "GOOGLE_APPLICATION_CREDENTIALS")
+		return a || b || c
+	})
+
+	updateProvider(cfg, "azure", func(p *ProviderConfig) bool {
+		a := setString(&p.TenantID, "AZURE_TENANT_ID")
+		b := setString(&p.ClientID, "AZURE_CLIENT_ID")
+		c := setString(&p.ClientSecret, "AZURE_CLIENT_SECRET")
+		return a || b || c
+	})
+
+	updateProvider(cfg, "ollama", func(p *ProviderConfig) bool {
+		host := os.Getenv("OLLAMA_HOST")
+		if host == "" {
+			return false
+		}
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		p.BaseURL = strings.TrimRight(host, "/") + "/v1"
+		return true
+	})
+
+	return errors.Join(errs...)
+}
+
+// updateProvider applies fn to the named provider entry and stores it back
+// only when fn reports a change, so unset variables do not add empty entries.
+func updateProvider(cfg *Config, name string, fn func(*ProviderConfig) bool) {
+	p := cfg.Providers[name]
+	if fn(&p) {
+		cfg.Providers[name] = p
+	}
+}
+
+func setString(dst *string, key string) bool {
+	if val := os.Getenv(key); val != "" {
+		*dst = val
+		return true
+	}
+	return false
+}
+
+// parseEnv sets *dst to the value of the environment variable key, parsed
+// with parse, if it is set.
+func parseEnv[T any](dst *T, key string, parse func(string) (T, error)) error {
+	val := os.Getenv(key)
+	if val == "" {
+		return nil
+	}
+	v, err := parse(val)
+	if err != nil {
+		return fmt.Errorf("environment variable %s has invalid value %q", key, val)
+	}
+	*dst = v
+	return nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+)
+
+// Snapshot is an immutable, versioned configuration. Components read the
+// snapshot that was active when a request started, so a reload never changes
+// settings underneath in-flight work.
+type Snapshot struct {
+	Config   *Config
+	Version  int64
+	LoadedAt time.Time
+	Path     string
+	Checksum string // SHA-256 of the config file, empty without a file
+}
+
+// Manager owns the active configuration snapshot and reloads it on demand.
+type Manager struct {
+	path       string
+	current    atomic.Pointer[Snapshot]
+	mu         sync.Mutex // serializes reloads
+	validators []func(*Config) error
+	listeners  []func(*Snapshot)
+}
+
+// NewManager loads the initial configuration from path. An empty path uses
+// defaults and environment variables only.
+func NewManager(path string) (*Manager, error) {
+	m := &Manager{path: path}
+
+	cfg, checksum, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	m.current.Store(&Snapshot{
+		Config:   cfg,
+		Version:  1,
+		LoadedAt: time.Now().UTC(),
+		Path:     path,
+		Checksum: checksum,
+	})
+	return m, nil
+}
+
+// Current returns the active snapshot.
+func (m *Manager) Current() *Snapshot {
+	return m.current.Load()
+}
+
+// AddValidator registers a check that a new configuration must pass before it
+// is activated. Validators run on reload only, not on the initial load.
+func (m *Manager) AddValidator(fn func(*Config) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validators = append(m.validators, fn)
+}
+
+// OnReload registers a callback that applies a newly activated snapshot.
+func (m *Manager) OnReload(fn func(*Snapshot)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Reload reads and validates the config file and, if it is valid, activates it
+// and notifies listeners. On error the active snapshot is left untouched.
+func (m *Manager) Reload() (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg, checksum, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, validate := range m.validators {
+		if err := validate(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	prev := m.current.Load()
+	next := &Snapshot{
+		Config:   cfg,
+		Version:  prev.Version + 1,
+		LoadedAt: time.Now().UTC(),
+		Path:     m.path,
+		Checksum: checksum,
+	}
+
+	for _, apply := range m.listeners {
+		apply(next)
+	}
+	m.current.Store(next)
+
+	if prev.Config.Server != cfg.Server {
+		logger.Log.Warn().Msg("Server settings changed; they take effect after a restart")
+	}
+	if prev.Config.Auth.KeysFile != cfg.Auth.KeysFile {
+		logger.Log.Warn().Msg("auth.keysFile changed; it takes effect after a restart")
+	}
+	if prev.Config.Budgets != cfg.Budgets {
+		logger.Log.Warn().Msg("budgets.file changed; it takes effect after a restart")
+	}
+	if !reflect.DeepEqual(prev.Config.Tracing, cfg.Tracing) {
+		logger.Log.Warn().Msg("Tracing settings changed; they take effect after a restart")
+	}
+
+	logger.Log.Info().
+		Int64("version", next.Version).
+		Str("checksum", next.Checksum).
+		Msg("Configuration reloaded")
+
+	return next, nil
+}
+
+// Watch polls the config file and reloads it when its contents change. It
+// returns when ctx is cancelled. Without a config file there is nothing to watch.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	if m.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastMod time.Time
+	if info, err := os.Stat(m.path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(m.path)
+		if err != nil || info.ModTime().Equal(lastMod) {
+			continue
+		}
+		lastMod = info.ModTime()
+
+		// Editors often touch the file without changing it; compare contents
+		// before doing a full reload.
+		data, err := os.ReadFile(m.path)
+		if err != nil || checksumOf(data) == m.Current().Checksum {
+			continue
+		}
+
+		if _, err := m.Reload(); err != nil {
+			logger.Log.Error().Err(err).Str("path", m.path).Msg("Config file changed but reload failed; keeping previous configuration")
+		}
+	}
+}
+
+func (m *Manager) load() (*Config, string, error) {
+	if m.path == "" {
+		cfg, err := build(nil, "")
+		return cfg, "", err
+	}
+
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg, err := build(data, m.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, checksumOf(data), nil
+}
+
+func checksumOf(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestManagerReload(t *testing.T) {
+	path := writeConfig(t, "gateway.yaml", "retry:\n  maxRetries: 1\n")
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := m.Current()
+
+	var applied []*Snapshot
+	m.OnReload(func(s *Snapshot) { applied = append(applied, s) })
+
+	if err := os.WriteFile(path, []byte("retry:\n  maxRetries: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	next, err := m.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.Version != first.Version+1 || next.Config.Retry.MaxRetries != 2 {
+		t.Errorf("reloaded snapshot = version %d with %d retries, want version %d with 2", next.Version, next.Config.Retry.MaxRetries, first.Version+1)
+	}
+	if next.Checksum == first.Checksum || next.Checksum != checksumOf([]byte("retry:\n  maxRetries: 2\n")) {
+		t.Errorf("checksum = %s, want that of the new file", next.Checksum)
+	}
+	if m.Current() != next || len(applied) != 1 || applied[0] != next {
+		t.Error("reloaded snapshot not activated and passed to the listener")
+	}
+	// Snapshots are immutable; earlier readers keep theirs.
+	if first.Config.Retry.MaxRetries != 1 {
+		t.Errorf("earlier snapshot changed to %d retries", first.Config.Retry.MaxRetries)
+	}
+}
+
+func TestManagerReloadKeepsConfigOnError(t *testing.T) {
+	path := writeConfig(t, "gateway.yaml", "retry:\n  maxRetries: 1\n")
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := m.Current()
+
+	applied := 0
+	m.OnReload(func(*Snapshot) { applied++ })
+	errRejected := errors.New("unknown provider")
+	m.AddValidator(func(cfg *Config) error {
+		if cfg.Retry.MaxRetries > 5 {
+			return errRejected
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  error
+	}{
+		{"unparsable", "retry: [\n", nil},
+		{"invalid", "retry:\n  maxRetries: -1\n", nil},
+		{"rejected by a validator", "retry:\n  maxRetries: 9\n", errRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := m.Reload()
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("Reload = %v, want an error", err)
+			}
+			if m.Current() != first || applied != 0 {
+				t.Errorf("failed reload replaced the configuration (%d listener calls)", applied)
+			}
+		})
+	}
+
+	// A missing file is an error too.
+	os.Remove(path)
+	if _, err := m.Reload(); err == nil || m.Current() != first {
+		t.Errorf("Reload of a missing file = %v, want an error and the configuration kept", err)
+	}
+}
+
+func TestManagerWatch(t *testing.T) {
+	path := writeConfig(t, "gateway.yaml", "retry:\n  maxRetries: 1\n")
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		m.Watch(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// The poll only compares contents once the modification time moves.
+	touch := func(offset time.Duration) {
+		t.Helper()
+		mod := time.Now().Add(offset)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(version int64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for m.Current().Version != version {
+			if time.Now().After(deadline) {
+				t.Fatalf("version %d after polling, want %d", m.Current().Version, version)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// A file touched without a change is not reloaded.
+	touch(time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	if v := m.Current().Version; v != 1 {
+		t.Errorf("touched file reloaded: version %d, want 1", v)
+	}
+
+	if err := os.WriteFile(path, []byte("retry:\n  maxRetries: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(2 * time.Hour)
+	waitFor(2)
+	if got := m.Current().Config.Retry.MaxRetries; got != 2 {
+		t.Errorf("maxRetries after the change = %d, want 2", got)
+	}
+
+	// An invalid change is skipped and a later valid one picked up.
+	if err := os.WriteFile(path, []byte("retry:\n  maxRetries: -1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(3 * time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	if v := m.Current().Version; v != 2 {
+		t.Errorf("invalid file activated: version %d, want 2", v)
+	}
+	if err := os.WriteFile(path, []byte("retry:\n  maxRetries: 3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(4 * time.Hour)
+	waitFor(3)
+
+	cancel()
+	<-done
+}
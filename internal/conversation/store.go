@@ -0,0 +1,119 @@
+// Package conversation keeps the history of Responses API conversations, so
+// that previous_response_id works with providers that have no server-side
+// state of their own.
+package conversation
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Conversation is the history up to and including one response. Instructions
+// are not part of it; as in the OpenAI API, each request brings its own.
+type Conversation struct {
+	Owner     string // Caller that created it; only it can continue it
+	Messages  []llm.Message
+	CreatedAt time.Time
+}
+
+// Config bounds a Store. A disabled store keeps nothing.
+type Config struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+}
+
+type item struct {
+	id   string
+	conv *Conversation
+}
+
+// Store is an in-memory LRU of conversations by response ID.
+type Store struct {
+	mu    sync.Mutex
+	cfg   Config
+	order *list.List // Front is most recently used
+	items map[string]*list.Element
+}
+
+func NewStore(cfg Config) *Store {
+	return &Store{
+		cfg:   cfg,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Update applies cfg, keeping stored conversations that still fit.
+func (s *Store) Update(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	if !cfg.Enabled {
+		s.order.Init()
+		s.items = make(map[string]*list.Element)
+		return
+	}
+	s.evict()
+}
+
+// Enabled reports whether the store keeps conversations.
+func (s *Store) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.Enabled
+}
+
+// Get returns the conversation stored under id if owner created it. Another
+// caller's conversation is reported as not found.
+func (s *Store) Get(id, owner string) (*Conversation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[id]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*item)
+	if it.conv.Owner != owner {
+		return nil, false
+	}
+	if time.Since(it.conv.CreatedAt) > s.cfg.TTL {
+		s.order.Remove(el)
+		delete(s.items, id)
+		return nil, false
+	}
+
+	s.order.MoveToFront(el)
+	return it.conv, true
+}
+
+// Put stores conv under id. It does nothing if the store is disabled.
+func (s *Store) Put(id string, conv *Conversation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cfg.Enabled {
+		return
+	}
+	if el, ok := s.items[id]; ok {
+		el.Value.(*item).conv = conv
+		s.order.MoveToFront(el)
+		return
+	}
+
+	s.items[id] = s.order.PushFront(&item{id: id, conv: conv})
+	s.evict()
+}
+
+func (s *Store) evict() {
+	for s.order.Len() > s.cfg.MaxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*item).id)
+	}
+}
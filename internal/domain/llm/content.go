@@ -0,0 +1,288 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type ContentPartType string
+
+const (
+	PartText  ContentPartType = "text"
+	PartImage ContentPartType = "image"
+	PartAudio ContentPartType = "audio"
+	PartFile  ContentPartType = "file"
+)
+
+// ContentPart is one piece of multimodal message content. Media parts carry
+// either a URL or inline base64 Data, never both.
+type ContentPart struct {
+	Type ContentPartType
+	Text string
+
+	URL       string // Remote location of the media
+	Data      string // Base64-encoded media bytes
+	MediaType string // MIME type, e.g. "image/png", "audio/wav", "application/pdf"
+
+	Detail   string // Image detail hint ("low", "high", "auto")
+	Filename string // Name of an attached file
+	FileID   string // ID of a file already uploaded to the provider
+}
+
+// Message is a single chat message. Content holds plain text; Parts, when set,
+// holds multimodal content and takes precedence over Content.
+//
+// Assistant messages may carry ToolCalls. Tool messages carry the result of
+// one call in Content and name the call in ToolCallID.
+type Message struct {
+	Role       Role          `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// Text returns the text of the message, joining text parts if there are any.
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+
+	var texts []string
+	for _, p := range m.Parts {
+		if p.Type == PartText {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// CheckParts returns a validation error naming the first content part type in
+// messages that is not in supported. Text is always supported.
+func CheckParts(provider string, messages []Message, supported ...ContentPartType) error {
+	for i, m := range messages {
+		for _, p := range m.Parts {
+			if p.Type == PartText || containsPartType(supported, p.Type) {
+				continue
+			}
+			return &ProviderError{
+				StatusCode: 400,
+				Message:    fmt.Sprintf("provider %q does not accept %s content (messages[%d])", provider, p.Type, i),
+				Type:       "invalid_request_error",
+				Code:       "unsupported_content",
+				Param:      fmt.Sprintf("messages[%d].content", i),
+			}
+		}
+	}
+	return nil
+}
+
+func containsPartType(types []ContentPartType, t ContentPartType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDataURL splits a "data:<mediatype>;base64,<data>" URL. ok is false for
+// any other URL.
+func ParseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// DataURL is the inverse of ParseDataURL.
+func DataURL(mediaType, data string) string {
+	return "data:" + mediaType + ";base64," + data
+}
+
+// wirePart is the OpenAI chat completions representation of a content part.
+type wirePart struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ImageURL   *wireImageURL   `json:"image_url,omitempty"`
+	InputAudio *wireInputAudio `json:"input_audio,omitempty"`
+	File       *wireFile       `json:"file,omitempty"`
+}
+
+type wireImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type wireInputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+type wireFile struct {
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// UnmarshalJSON accepts content either as a string or as an array of OpenAI
+// style content parts. Tool fields are accepted in snake_case (OpenAI) and
+// camelCase (gateway responses).
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role            Role            `json:"role"`
+		Content         json.RawMessage `json:"content"`
+		ToolCalls       []ToolCall      `json:"tool_calls"`
+		ToolCallsCamel  []ToolCall      `json:"toolCalls"`
+		ToolCallID      string          `json:"tool_call_id"`
+		ToolCallIDCamel string          `json:"toolCallId"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = Message{
+		Role:       raw.Role,
+		ToolCalls:  raw.ToolCalls,
+		ToolCallID: raw.ToolCallID,
+	}
+	if m.ToolCalls == nil {
+		m.ToolCalls = raw.ToolCallsCamel
+	}
+	if m.ToolCallID == "" {
+		m.ToolCallID = raw.ToolCallIDCamel
+	}
+
+	content := strings.TrimSpace(string(raw.Content))
+	if content == "" || content == "null" {
+		return nil
+	}
+	if content[0] == '"' {
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+
+	var parts []wirePart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return fmt.Errorf("content must be a string or an array of content parts: %w", err)
+	}
+
+	m.Parts = make([]ContentPart, len(parts))
+	for i, wp := range parts {
+		part, err := fromWirePart(wp)
+		if err != nil {
+			return fmt.Errorf("content[%d]: %w", i, err)
+		}
+		m.Parts[i] = part
+	}
+	return nil
+}
+
+// MarshalJSON writes the message in the OpenAI chat completions format. Parts
+// become a content-part array; an assistant message that only calls tools has
+// null content.
+func (m Message) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Role       Role        `json:"role"`
+		Content    interface{} `json:"content"`
+		ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+		ToolCallID string      `json:"tool_call_id,omitempty"`
+	}{
+		Role:       m.Role,
+		Content:    m.Content,
+		ToolCalls:  m.ToolCalls,
+		ToolCallID: m.ToolCallID,
+	}
+
+	switch {
+	case len(m.Parts) > 0:
+		parts := make([]wirePart, len(m.Parts))
+		for i, p := range m.Parts {
+			parts[i] = toWirePart(p)
+		}
+		out.Content = parts
+	case m.Content == "" && len(m.ToolCalls) > 0:
+		out.Content = nil
+	}
+
+	return json.Marshal(out)
+}
+
+func fromWirePart(wp wirePart) (ContentPart, error) {
+	switch wp.Type {
+	case "text":
+		return ContentPart{Type: PartText, Text: wp.Text}, nil
+
+	case "image_url":
+		if wp.ImageURL == nil || wp.ImageURL.URL == "" {
+			return ContentPart{}, fmt.Errorf("image_url part requires image_url.url")
+		}
+		part := ContentPart{Type: PartImage, Detail: wp.ImageURL.Detail}
+		if mediaType, data, ok := ParseDataURL(wp.ImageURL.URL); ok {
+			part.MediaType, part.Data = mediaType, data
+		} else {
+			part.URL = wp.ImageURL.URL
+		}
+		return part, nil
+
+	case "input_audio":
+		if wp.InputAudio == nil || wp.InputAudio.Data == "" {
+			return ContentPart{}, fmt.Errorf("input_audio part requires input_audio.data")
+		}
+		return ContentPart{
+			Type:      PartAudio,
+			Data:      wp.InputAudio.Data,
+			MediaType: "audio/" + wp.InputAudio.Format,
+		}, nil
+
+	case "file":
+		if wp.File == nil || (wp.File.FileData == "" && wp.File.FileID == "") {
+			return ContentPart{}, fmt.Errorf("file part requires file.file_data or file.file_id")
+		}
+		part := ContentPart{Type: PartFile, Filename: wp.File.Filename, FileID: wp.File.FileID}
+		if wp.File.FileData != "" {
+			mediaType, data, ok := ParseDataURL(wp.File.FileData)
+			if !ok {
+				return ContentPart{}, fmt.Errorf("file.file_data must be a base64 data URL")
+			}
+			part.MediaType, part.Data = mediaType, data
+		}
+		return part, nil
+
+	default:
+		return ContentPart{}, fmt.Errorf("unsupported content part type %q", wp.Type)
+	}
+}
+
+func toWirePart(p ContentPart) wirePart {
+	switch p.Type {
+	case PartImage:
+		url := p.URL
+		if p.Data != "" {
+			url = DataURL(p.MediaType, p.Data)
+		}
+		return wirePart{Type: "image_url", ImageURL: &wireImageURL{URL: url, Detail: p.Detail}}
+	case PartAudio:
+		return wirePart{Type: "input_audio", InputAudio: &wireInputAudio{
+			Data:   p.Data,
+			Format: strings.TrimPrefix(p.MediaType, "audio/"),
+		}}
+	case PartFile:
+		f := &wireFile{FileID: p.FileID, Filename: p.Filename}
+		if p.Data != "" {
+			f.FileData = DataURL(p.MediaType, p.Data)
+		}
+		return wirePart{Type: "file", File: f}
+	default:
+		return wirePart{Type: "text", Text: p.Text}
+	}
+}
@@ -1,6 +1,10 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"io"
+)
 
 type StreamChunk struct {
 	ID                string
@@ -12,6 +16,7 @@ type StreamChunk struct {
 	Raw               []byte // Raw JSON chunk
 	SystemFingerprint *string
 	ServiceTier       *string
+	Provider          string // Provider that served the stream, set by pools and failover
 }
 
 type StreamChoice struct {
@@ -24,13 +29,29 @@ type StreamChoice struct {
 type StreamDelta struct {
 	Role      *string
 	Content   *string
-	ToolCalls []interface{}
+	ToolCalls []ToolCallDelta
 }
 
+// ToolCallDelta is an incremental piece of a streamed tool call. The first
+// delta for an Index carries ID and Function.Name; later ones append to
+// Function.Arguments.
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// Usage is normalized token usage. PromptTokens includes CachedTokens and
+// CacheWriteTokens; CompletionTokens includes ReasoningTokens.
 type Usage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+
+	CachedTokens     int // Prompt tokens read from the provider's prompt cache
+	CacheWriteTokens int // Prompt tokens written to the provider's prompt cache
+	ReasoningTokens  int // Completion tokens spent on hidden reasoning
 }
 
 type Provider interface {
@@ -42,3 +63,47 @@ type Provider interface {
 	// If the callback returns an error, streaming is stopped and that error is returned.
 	ChatStream(ctx context.Context, req ChatRequest, callback func(*StreamChunk) error) error
 }
+
+// Embedder is implemented by providers that can create embeddings.
+type Embedder interface {
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// Embed creates embeddings with p. Decorators use it to forward embedding
+// requests, so an unsupported provider is reported the same way at any depth.
+func Embed(ctx context.Context, p Provider, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	embedder, ok := p.(Embedder)
+	if !ok {
+		return nil, NewProviderError(400, fmt.Sprintf("provider %q does not support embeddings", p.Name()), "invalid_request_error", "embeddings_not_supported")
+	}
+	return embedder.Embed(ctx, req)
+}
+
+// ResponsesRequest is an OpenAI Responses API request forwarded unchanged to
+// a provider that serves the API natively.
+type ResponsesRequest struct {
+	Model  string // Replaces the model in Body
+	Body   []byte // Request body as the client sent it
+	Stream bool
+	APIKey string // API key to use for this request (overrides provider's default)
+
+	// Credentials holds upstream API keys by provider name when the caller
+	// authenticated with a gateway-issued key. Nil for pass-through callers.
+	Credentials map[string]string
+}
+
+// Responder is implemented by providers that serve the OpenAI Responses API
+// natively. The returned body is the upstream response: JSON, or server-sent
+// events for streams. The caller must close it.
+type Responder interface {
+	Respond(ctx context.Context, req ResponsesRequest) (io.ReadCloser, error)
+}
+
+// Respond forwards a Responses API request to p, like Embed.
+func Respond(ctx context.Context, p Provider, req ResponsesRequest) (io.ReadCloser, error) {
+	responder, ok := p.(Responder)
+	if !ok {
+		return nil, NewProviderError(400, fmt.Sprintf("provider %q does not support the Responses API", p.Name()), "invalid_request_error", "responses_not_supported")
+	}
+	return responder.Respond(ctx, req)
+}
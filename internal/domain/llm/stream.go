@@ -0,0 +1,153 @@
+package llm
+
+import "errors"
+
+// charsPerToken is the rough number of characters in a token, used to count
+// tokens before a provider reports them: those a StreamGuard holds back and
+// those of a prompt about to be sent.
+const charsPerToken = 4
+
+// StreamGuard sits between the attempts of a streamed request, such as
+// retries or fallbacks, and the callback that writes to the client. It holds
+// back chunks until one has content, or with a token buffer until that many
+// tokens have arrived, so a failed attempt can be abandoned without the
+// client seeing output from two attempts. Once chunks have been passed on the
+// stream is committed, and a failure ends it with a stream_interrupted error.
+type StreamGuard struct {
+	callback  func(*StreamChunk) error
+	buffer    int
+	pending   []*StreamChunk
+	tokens    int
+	committed bool
+}
+
+// NewStreamGuard returns a guard that commits to the stream once
+// bufferTokens tokens have arrived, or at the first content when zero.
+func NewStreamGuard(callback func(*StreamChunk) error, bufferTokens int) *StreamGuard {
+	return &StreamGuard{
+		callback: callback,
+		buffer:   bufferTokens,
+	}
+}
+
+// Send is the callback for an attempt. Errors from the client's callback
+// are returned as a ClientError.
+func (g *StreamGuard) Send(chunk *StreamChunk) error {
+	if g.committed {
+		return g.send(chunk)
+	}
+	g.pending = append(g.pending, chunk)
+	g.tokens += chunkTokens(chunk)
+	if g.tokens == 0 || g.tokens < g.buffer {
+		return nil
+	}
+	return g.Flush()
+}
+
+// Flush commits to the stream and passes on the chunks held back. It ends a
+// successful attempt.
+func (g *StreamGuard) Flush() error {
+	g.committed = true
+	pending := g.pending
+	g.pending = nil
+	for _, chunk := range pending {
+		if err := g.send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *StreamGuard) send(chunk *StreamChunk) error {
+	err := g.callback(chunk)
+	if err == nil || IsClientError(err) {
+		return err
+	}
+	return &ClientError{Err: err}
+}
+
+// Committed reports whether chunks have been passed to the callback, after
+// which the request cannot be sent again.
+func (g *StreamGuard) Committed() bool {
+	return g.committed
+}
+
+// Fail returns what to make of an attempt that failed with err. Before the
+// stream is committed the chunks held back are dropped and err is returned
+// for the caller to retry or fail over; after, the stream_interrupted error
+// that ends it.
+func (g *StreamGuard) Fail(err error) error {
+	if g.committed {
+		return NewStreamInterruptedError(err)
+	}
+	g.pending = nil
+	g.tokens = 0
+	return err
+}
+
+// ClientError is an error from the callback that writes a stream to the
+// client, such as a write to a client that has gone away. It says nothing
+// about the provider, so does not count against its health.
+type ClientError struct {
+	Err error
+}
+
+func (e *ClientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// IsClientError reports whether err came from the client's stream callback.
+func IsClientError(err error) bool {
+	var ce *ClientError
+	return errors.As(err, &ce)
+}
+
+// chunkTokens estimates the tokens of content and tool call arguments in
+// chunk, counting any content as at least one.
+func chunkTokens(chunk *StreamChunk) int {
+	chars := 0
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != nil {
+			chars += len(*choice.Delta.Content)
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+		}
+	}
+	if chars == 0 {
+		return 0
+	}
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// NewStreamInterruptedError is the error for a stream that failed after
+// output reached the client. It is neither retried nor failed over, and
+// handlers send it as the stream's final event.
+func NewStreamInterruptedError(err error) *ProviderError {
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.Code == "stream_interrupted" {
+		return pe
+	}
+
+	statusCode, message := 502, err.Error()
+	if pe != nil {
+		statusCode, message = pe.StatusCode, pe.Message
+	}
+	return &ProviderError{
+		StatusCode: statusCode,
+		Message:    "stream interrupted after output was sent: " + message,
+		Type:       "stream_error",
+		Code:       "stream_interrupted",
+	}
+}
+
+// IsStreamInterrupted reports whether err ended a stream that had already
+// sent output.
+func IsStreamInterrupted(err error) bool {
+	var pe *ProviderError
+	return errors.As(err, &pe) && pe.Code == "stream_interrupted"
+}
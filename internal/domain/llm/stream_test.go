@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func contentChunk(text string) *StreamChunk {
+	return &StreamChunk{Choices: []StreamChoice{{Delta: StreamDelta{Content: &text}}}}
+}
+
+func roleChunk() *StreamChunk {
+	role := "assistant"
+	return &StreamChunk{Choices: []StreamChoice{{Delta: StreamDelta{Role: &role}}}}
+}
+
+// recorder collects the chunks a guard passes on.
+type recorder struct {
+	chunks []*StreamChunk
+}
+
+func (r *recorder) callback(chunk *StreamChunk) error {
+	r.chunks = append(r.chunks, chunk)
+	return nil
+}
+
+func TestStreamGuardCommitsAtFirstContent(t *testing.T) {
+	var r recorder
+	g := NewStreamGuard(r.callback, 0)
+
+	g.Send(roleChunk())
+	if g.Committed() || len(r.chunks) != 0 {
+		t.Fatalf("chunk without content passed on (%d chunks), want it held back", len(r.chunks))
+	}
+
+	g.Send(contentChunk("Hi"))
+	if !g.Committed() || len(r.chunks) != 2 {
+		t.Fatalf("after first content: committed %v with %d chunks, want true with 2", g.Committed(), len(r.chunks))
+	}
+
+	g.Send(contentChunk(" there"))
+	if len(r.chunks) != 3 {
+		t.Errorf("chunk after commit not passed on: %d chunks, want 3", len(r.chunks))
+	}
+}
+
+func TestStreamGuardBuffersTokens(t *testing.T) {
+	var r recorder
+	g := NewStreamGuard(r.callback, 3)
+
+	// Eight characters are two tokens, short of the buffer.
+	g.Send(contentChunk("abcd"))
+	g.Send(contentChunk("efgh"))
+	if g.Committed() || len(r.chunks) != 0 {
+		t.Fatalf("committed %v with %d chunks before the buffer filled, want false with 0", g.Committed(), len(r.chunks))
+	}
+
+	g.Send(contentChunk("i"))
+	if !g.Committed() || len(r.chunks) != 3 {
+		t.Errorf("committed %v with %d chunks once the buffer filled, want true with 3", g.Committed(), len(r.chunks))
+	}
+}
+
+func TestStreamGuardFlush(t *testing.T) {
+	var r recorder
+	g := NewStreamGuard(r.callback, 100)
+
+	g.Send(contentChunk("short"))
+	if err := g.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !g.Committed() || len(r.chunks) != 1 {
+		t.Errorf("after Flush: committed %v with %d chunks, want true with 1", g.Committed(), len(r.chunks))
+	}
+}
+
+func TestStreamGuardFailBeforeCommit(t *testing.T) {
+	var r recorder
+	g := NewStreamGuard(r.callback, 100)
+	upstream := NewProviderError(503, "unavailable", "api_error", "")
+
+	g.Send(contentChunk("from the first attempt"))
+	if err := g.Fail(upstream); err != upstream {
+		t.Fatalf("Fail = %v, want the upstream error for a retry", err)
+	}
+	if g.Committed() {
+		t.Fatal("stream committed by a failure")
+	}
+
+	// The next attempt's output is all the client sees.
+	g.Send(contentChunk("second"))
+	g.Flush()
+	if len(r.chunks) != 1 || *r.chunks[0].Choices[0].Delta.Content != "second" {
+		t.Errorf("client got %d chunks, want only the second attempt's", len(r.chunks))
+	}
+}
+
+func TestStreamGuardFailAfterCommit(t *testing.T) {
+	var r recorder
+	g := NewStreamGuard(r.callback, 0)
+	upstream := NewProviderError(503, "unavailable", "api_error", "")
+
+	g.Send(contentChunk("Hi"))
+	err := g.Fail(upstream)
+
+	var pe *ProviderError
+	if !errors.As(err, &pe) || pe.Code != "stream_interrupted" || pe.StatusCode != 503 {
+		t.Fatalf("Fail = %v, want a 503 stream_interrupted error", err)
+	}
+	if !IsStreamInterrupted(err) {
+		t.Error("IsStreamInterrupted = false")
+	}
+	if IsStreamInterrupted(upstream) {
+		t.Error("IsStreamInterrupted = true for the upstream error")
+	}
+
+	// An interruption from an inner guard is passed through as it is.
+	if again := g.Fail(err); again != err {
+		t.Errorf("Fail of an interruption = %v, want it unchanged", again)
+	}
+}
+
+func TestStreamInterruptedErrorWithoutStatus(t *testing.T) {
+	err := NewStreamInterruptedError(errors.New("connection reset"))
+	if err.StatusCode != 502 || err.Message != "stream interrupted after output was sent: connection reset" {
+		t.Errorf("error = %d %q, want a 502 naming the cause", err.StatusCode, err.Message)
+	}
+}
+
+func TestStreamGuardMarksCallbackErrors(t *testing.T) {
+	errBrokenPipe := errors.New("write: broken pipe")
+	g := NewStreamGuard(func(*StreamChunk) error { return errBrokenPipe }, 0)
+
+	err := g.Send(contentChunk("Hi"))
+	if !IsClientError(err) || !errors.Is(err, errBrokenPipe) {
+		t.Errorf("Send = %#v, want a ClientError wrapping the callback's error", err)
+	}
+
+	// Guards nest, as when a pool is retried; the error is wrapped once.
+	outer := NewStreamGuard(g.Send, 0)
+	err = outer.Send(contentChunk("Hi"))
+	var ce *ClientError
+	if !errors.As(err, &ce) || ce.Err != errBrokenPipe {
+		t.Errorf("Send through nested guards = %#v, want one ClientError", err)
+	}
+}
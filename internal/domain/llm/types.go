@@ -10,13 +10,78 @@ type ChatRequest struct {
 	Messages []Message
 	Options  ChatOptions
 	APIKey   string // API key to use for this request (overrides provider's default)
+
+	// Credentials holds upstream API keys by provider name when the caller
+	// authenticated with a gateway-issued key. Nil for pass-through callers.
+	Credentials map[string]string
+}
+
+// EstimatePromptTokens roughly sizes the prompt of req from its message and
+// tool text, for decisions made before it is sent.
+func (r ChatRequest) EstimatePromptTokens() int {
+	chars := 0
+	for _, m := range r.Messages {
+		chars += len(m.Text())
+	}
+	for _, t := range r.Options.Tools {
+		if t.Function != nil {
+			chars += len(t.Function.Name) + len(t.Function.Description) + len(t.Function.Parameters)
+		}
+	}
+	return chars / charsPerToken
 }
 
 type ChatResponse struct {
-	ID      string
-	Model   string
-	Content string
-	Raw     json.RawMessage // Raw response from provider
+	ID           string
+	Model        string
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason string          // OpenAI finish reason, e.g. "stop" or "tool_calls"
+	Usage        *Usage          // Nil if the provider did not report usage
+	Provider     string          // Provider that served the request, set by failover
+	Raw          json.RawMessage // Raw response from provider
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"` // "function"
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// EmbeddingRequest asks for one embedding vector per input text.
+type EmbeddingRequest struct {
+	Model      string
+	Input      []string
+	Dimensions *int // Output size, for models that support shortening
+	User       *string
+	APIKey     string // API key to use for this request (overrides provider's default)
+
+	// Credentials holds upstream API keys by provider name when the caller
+	// authenticated with a gateway-issued key. Nil for pass-through callers.
+	Credentials map[string]string
+}
+
+// EstimatePromptTokens roughly sizes the input of req, for decisions made
+// before it is sent.
+func (r EmbeddingRequest) EstimatePromptTokens() int {
+	chars := 0
+	for _, text := range r.Input {
+		chars += len(text)
+	}
+	return chars / charsPerToken
+}
+
+type EmbeddingResponse struct {
+	Model      string
+	Embeddings [][]float32 // One vector per input, in input order
+	Usage      Usage
+	Provider   string // Provider that served the request, set by pools and failover
 }
 
 type Role string
@@ -28,11 +93,6 @@ const (
 	RoleTool      Role = "tool"
 )
 
-type Message struct {
-	Role    Role   `json:"role"`
-	Content string `json:"content"`
-}
-
 type Verbosity string
 
 const (
@@ -70,6 +130,7 @@ type ChatOptions struct {
 	// AWS credentials for Bedrock
 	AWSAccessKeyID         *string
 	AWSSecretAccessKey     *string
+	AWSSessionToken        *string
 	AWSRegion              *string
 	AWSInferenceProfileARN *string
 
@@ -104,6 +165,31 @@ type ToolChoice struct {
 	} `json:"function,omitempty"`
 }
 
+// ResolveToolChoice reduces the polymorphic ToolChoice option to a mode
+// ("none", "auto", "required" or "function") and, for "function", the name of
+// the function to call. mode is empty when raw is nil or not understood.
+func ResolveToolChoice(raw interface{}) (mode, name string) {
+	switch tc := raw.(type) {
+	case string:
+		return tc, ""
+	case ToolChoice:
+		if tc.Function != nil {
+			return "function", tc.Function.Name
+		}
+	case *ToolChoice:
+		if tc != nil && tc.Function != nil {
+			return "function", tc.Function.Name
+		}
+	case map[string]interface{}:
+		if fn, ok := tc["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return "function", name
+			}
+		}
+	}
+	return "", ""
+}
+
 type ToolResolution struct {
 	Type string `json:"type"` // "auto" or "required"
 }
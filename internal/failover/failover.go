@@ -2,10 +2,13 @@ package failover
 
 import (
 	"context"
+	"io"
 	"strings"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/metrics"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 )
 
 type ProviderWithConfig struct {
@@ -18,6 +21,9 @@ type ProviderWithConfig struct {
 type FallbackConfig struct {
 	EnableRetries bool
 	MaxRetries    int
+	// StreamBufferTokens holds back the first tokens of a stream so a failure
+	// within them can still fall back. Zero commits at the first content.
+	StreamBufferTokens int
 }
 
 var DefaultConfig = FallbackConfig{
@@ -28,6 +34,7 @@ var DefaultConfig = FallbackConfig{
 type failoverProvider struct {
 	providers []ProviderWithConfig
 	name      string
+	config    FallbackConfig
 }
 
 func ParseModelWithFallbacks(qualifiedModel string) []string {
@@ -37,7 +44,7 @@ func ParseModelWithFallbacks(qualifiedModel string) []string {
 	return strings.Split(qualifiedModel, "|")
 }
 
-func NewFailoverProvider(providers []ProviderWithConfig) llm.Provider {
+func NewFailoverProvider(providers []ProviderWithConfig, config FallbackConfig) llm.Provider {
 	names := make([]string, len(providers))
 	for i, p := range providers {
 		names[i] = p.Provider.Name()
@@ -46,6 +53,7 @@ func NewFailoverProvider(providers []ProviderWithConfig) llm.Provider {
 	return &failoverProvider{
 		providers: providers,
 		name:      "failover(" + strings.Join(names, "->") + ")",
+		config:    config,
 	}
 }
 
@@ -53,6 +61,13 @@ func (f *failoverProvider) Name() string {
 	return f.name
 }
 
+// recordHop counts the move from the i-th provider to the next, if any.
+func (f *failoverProvider) recordHop(i int) {
+	if i+1 < len(f.providers) {
+		metrics.RecordFallback(f.providers[i].Provider.Name(), f.providers[i+1].Provider.Name())
+	}
+}
+
 func (f *failoverProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
 	var lastErr error
 
@@ -62,8 +77,13 @@ func (f *failoverProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.
 			Int("fallback_index", i).
 			Msg("Attempting provider")
 
-		resp, err := p.Provider.Chat(ctx, req)
+		hopCtx, span := tracing.StartFallback(ctx, i, p.Provider.Name())
+		resp, err := p.Provider.Chat(hopCtx, req)
+		tracing.End(span, err)
 		if err == nil {
+			if resp.Provider == "" {
+				resp.Provider = p.Provider.Name()
+			}
 			if i > 0 {
 				logger.Log.Info().
 					Str("provider", p.Provider.Name()).
@@ -79,6 +99,7 @@ func (f *failoverProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.
 			Err(err).
 			Int("fallback_index", i).
 			Msg("Provider failed, trying next fallback")
+		f.recordHop(i)
 	}
 
 	logger.Log.Error().
@@ -89,8 +110,12 @@ func (f *failoverProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.
 	return nil, lastErr
 }
 
+// ChatStream falls back only while nothing has reached the client, so its
+// output never mixes two providers. A failure after that ends the stream with
+// a stream_interrupted error.
 func (f *failoverProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
 	var lastErr error
+	guard := llm.NewStreamGuard(callback, f.config.StreamBufferTokens)
 
 	for i, p := range f.providers {
 		logger.Log.Info().
@@ -98,25 +123,43 @@ func (f *failoverProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 			Int("fallback_index", i).
 			Msg("Attempting streaming provider")
 
-		err := p.Provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		hopCtx, span := tracing.StartFallback(ctx, i, p.Provider.Name())
+		err := p.Provider.ChatStream(hopCtx, req, func(chunk *llm.StreamChunk) error {
+			if chunk.Provider == "" {
+				chunk.Provider = p.Provider.Name()
+			}
+			return guard.Send(chunk)
+		})
+		if err == nil {
+			err = guard.Flush()
+		}
+		tracing.End(span, err)
+
+		if err == nil {
 			if i > 0 {
 				logger.Log.Info().
 					Str("provider", p.Provider.Name()).
+					Str("fallback_chain", f.name).
 					Msg("Streaming fallback succeeded")
 			}
-			return callback(chunk)
-		})
-
-		if err == nil {
 			return nil
 		}
 
-		lastErr = err
+		lastErr = guard.Fail(err)
+		if guard.Committed() {
+			logger.Log.Warn().
+				Str("provider", p.Provider.Name()).
+				Err(err).
+				Int("fallback_index", i).
+				Msg("Stream failed after output was sent, not falling back")
+			return lastErr
+		}
 		logger.Log.Warn().
 			Str("provider", p.Provider.Name()).
 			Err(err).
 			Int("fallback_index", i).
 			Msg("Streaming provider failed, trying next fallback")
+		f.recordHop(i)
 	}
 
 	logger.Log.Error().
@@ -126,3 +169,77 @@ func (f *failoverProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 
 	return lastErr
 }
+
+func (f *failoverProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	var lastErr error
+
+	for i, p := range f.providers {
+		hopCtx, span := tracing.StartFallback(ctx, i, p.Provider.Name())
+		resp, err := llm.Embed(hopCtx, p.Provider, req)
+		tracing.End(span, err)
+		if err == nil {
+			if resp.Provider == "" {
+				resp.Provider = p.Provider.Name()
+			}
+			if i > 0 {
+				logger.Log.Info().
+					Str("provider", p.Provider.Name()).
+					Str("fallback_chain", f.name).
+					Msg("Embeddings fallback succeeded")
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		logger.Log.Warn().
+			Str("provider", p.Provider.Name()).
+			Err(err).
+			Int("fallback_index", i).
+			Msg("Embeddings provider failed, trying next fallback")
+		f.recordHop(i)
+	}
+
+	logger.Log.Error().
+		Str("fallback_chain", f.name).
+		Err(lastErr).
+		Msg("All embeddings fallback providers failed")
+
+	return nil, lastErr
+}
+
+// Respond forwards a Responses API request down the chain like Embed. Only
+// the request itself falls back; once a provider has answered, its body is
+// the caller's.
+func (f *failoverProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	var lastErr error
+
+	for i, p := range f.providers {
+		hopCtx, span := tracing.StartFallback(ctx, i, p.Provider.Name())
+		body, err := llm.Respond(hopCtx, p.Provider, req)
+		tracing.End(span, err)
+		if err == nil {
+			if i > 0 {
+				logger.Log.Info().
+					Str("provider", p.Provider.Name()).
+					Str("fallback_chain", f.name).
+					Msg("Responses fallback succeeded")
+			}
+			return body, nil
+		}
+
+		lastErr = err
+		logger.Log.Warn().
+			Str("provider", p.Provider.Name()).
+			Err(err).
+			Int("fallback_index", i).
+			Msg("Responses provider failed, trying next fallback")
+		f.recordHop(i)
+	}
+
+	logger.Log.Error().
+		Str("fallback_chain", f.name).
+		Err(lastErr).
+		Msg("All responses fallback providers failed")
+
+	return nil, lastErr
+}
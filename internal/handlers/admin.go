@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/config"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminHandler struct {
+	configs *config.Manager
+	store   *keys.Store
+	budgets *budget.Tracker
+}
+
+func NewAdminHandler(configs *config.Manager, store *keys.Store, budgets *budget.Tracker) *AdminHandler {
+	return &AdminHandler{
+		configs: configs,
+		store:   store,
+		budgets: budgets,
+	}
+}
+
+type CreateKeyPayload struct {
+	Name        string            `json:"name"`
+	Team        string            `json:"team,omitempty"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+type KeyPayload struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Team      string     `json:"team,omitempty"`
+	Key       string     `json:"key,omitempty"` // Plaintext secret, only returned on creation
+	Hint      string     `json:"hint"`
+	Providers []string   `json:"providers"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type KeyListPayload struct {
+	Object string       `json:"object"`
+	Data   []KeyPayload `json:"data"`
+}
+
+// BudgetPayload is a budget together with its subject's spend in the current
+// window.
+type BudgetPayload struct {
+	budget.Budget
+	Spent budget.Spend `json:"spent"`
+}
+
+type BudgetListPayload struct {
+	Object string          `json:"object"`
+	Data   []BudgetPayload `json:"data"`
+}
+
+// ConfigPayload describes the active configuration. Secrets are omitted.
+type ConfigPayload struct {
+	Version  int64          `json:"version"`
+	LoadedAt time.Time      `json:"loadedAt"`
+	Path     string         `json:"path,omitempty"`
+	Checksum string         `json:"checksum,omitempty"`
+	Config   *config.Config `json:"config"`
+}
+
+func toConfigPayload(s *config.Snapshot) ConfigPayload {
+	return ConfigPayload{
+		Version:  s.Version,
+		LoadedAt: s.LoadedAt,
+		Path:     s.Path,
+		Checksum: s.Checksum,
+		Config:   s.Config,
+	}
+}
+
+func toKeyPayload(k *keys.VirtualKey) KeyPayload {
+	return KeyPayload{
+		ID:        k.ID,
+		Name:      k.Name,
+		Team:      k.Team,
+		Hint:      k.Hint,
+		Providers: k.Providers(),
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+// requireAdmin rejects requests that do not carry the configured admin key.
+// The admin API is disabled entirely when no admin key is configured.
+func (h *AdminHandler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminKey := h.configs.Current().Config.Auth.AdminKey
+		if adminKey == "" {
+			writeError(w, r.Context(), llm.NewProviderError(http.StatusForbidden, "admin API is disabled; set ADMIN_API_KEY to enable it", "permission_error", "admin_disabled"))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminKey)) != 1 {
+			writeError(w, r.Context(), llm.NewUnauthorizedError("invalid admin API key"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *AdminHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	var payload CreateKeyPayload
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, r.Context(), llm.NewValidationError("Invalid request body", "invalid_json"))
+		return
+	}
+
+	if payload.Name == "" {
+		writeError(w, r.Context(), llm.NewValidationError("name is required", "missing_name"))
+		return
+	}
+	if len(payload.Credentials) == 0 {
+		writeError(w, r.Context(), llm.NewValidationError("credentials are required", "missing_credentials"))
+		return
+	}
+	for provider, apiKey := range payload.Credentials {
+		if provider == "" || apiKey == "" {
+			writeError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("credentials for provider %q must be non-empty", provider), "invalid_credentials"))
+			return
+		}
+	}
+
+	key, secret, err := h.store.Create(payload.Name, payload.Team, payload.Credentials)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create virtual key")
+		writeError(w, r.Context(), llm.NewInternalError("failed to create key"))
+		return
+	}
+
+	log.Info().
+		Str("key_id", key.ID).
+		Str("name", key.Name).
+		Str("team", key.Team).
+		Strs("providers", key.Providers()).
+		Msg("Virtual key created")
+
+	resp := toKeyPayload(key)
+	resp.Key = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *AdminHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	list := h.store.List()
+
+	data := make([]KeyPayload, len(list))
+	for i := range list {
+		data[i] = toKeyPayload(&list[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(KeyListPayload{
+		Object: "list",
+		Data:   data,
+	})
+}
+
+func (h *AdminHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id := chi.URLParam(r, "id")
+	key, err := h.store.Revoke(id)
+	if errors.Is(err, keys.ErrKeyNotFound) {
+		writeError(w, r.Context(), llm.NewProviderError(http.StatusNotFound, fmt.Sprintf("key %q not found", id), "invalid_request_error", "key_not_found"))
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("key_id", id).Msg("Failed to revoke virtual key")
+		writeError(w, r.Context(), llm.NewInternalError("failed to revoke key"))
+		return
+	}
+
+	log.Info().Str("key_id", key.ID).Msg("Virtual key revoked")
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(toKeyPayload(key))
+}
+
+func (h *AdminHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	var payload budget.Budget
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, r.Context(), llm.NewValidationError("Invalid request body", "invalid_json"))
+		return
+	}
+	if err := payload.Validate(); err != nil {
+		writeError(w, r.Context(), llm.NewValidationError(err.Error(), "invalid_budget"))
+		return
+	}
+
+	b, err := h.budgets.Create(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create budget")
+		writeError(w, r.Context(), llm.NewInternalError("failed to create budget"))
+		return
+	}
+
+	log.Info().
+		Str("budget_id", b.ID).
+		Str("scope", string(b.Scope)).
+		Str("subject", b.Subject).
+		Str("period", string(b.Period)).
+		Msg("Budget created")
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(BudgetPayload{Budget: b})
+}
+
+func (h *AdminHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	list := h.budgets.List()
+
+	data := make([]BudgetPayload, len(list))
+	for i, b := range list {
+		spent, err := h.budgets.Spent(r.Context(), b)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read budget spend")
+			writeError(w, r.Context(), llm.NewInternalError("failed to read budget spend"))
+			return
+		}
+		data[i] = BudgetPayload{Budget: b, Spent: spent}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(BudgetListPayload{
+		Object: "list",
+		Data:   data,
+	})
+}
+
+func (h *AdminHandler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id := chi.URLParam(r, "id")
+	err := h.budgets.Delete(id)
+	if errors.Is(err, budget.ErrBudgetNotFound) {
+		writeError(w, r.Context(), llm.NewProviderError(http.StatusNotFound, fmt.Sprintf("budget %q not found", id), "invalid_request_error", "budget_not_found"))
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("budget_id", id).Msg("Failed to delete budget")
+		writeError(w, r.Context(), llm.NewInternalError("failed to delete budget"))
+		return
+	}
+
+	log.Info().Str("budget_id", id).Msg("Budget deleted")
+
+	setRequestIDHeader(w, r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(toConfigPayload(h.configs.Current()))
+}
+
+// ReloadConfig re-reads the config file, as SIGHUP does. An invalid file is
+// rejected and the active configuration stays in place.
+func (h *AdminHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	snapshot, err := h.configs.Reload()
+	if err != nil {
+		log.Warn().Err(err).Msg("Configuration reload rejected")
+		writeError(w, r.Context(), llm.NewValidationError(err.Error(), "invalid_config"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(toConfigPayload(snapshot))
+}
+
+func (h *AdminHandler) RegisterRoutes(r chi.Router) {
+	r.Use(h.requireAdmin)
+	r.Post("/keys", h.CreateKey)
+	r.Get("/keys", h.ListKeys)
+	r.Delete("/keys/{id}", h.RevokeKey)
+	r.Post("/budgets", h.CreateBudget)
+	r.Get("/budgets", h.ListBudgets)
+	r.Delete("/budgets/{id}", h.DeleteBudget)
+	r.Get("/config", h.GetConfig)
+	r.Post("/config/reload", h.ReloadConfig)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atozi-ai/gateway/internal/config"
+	"github.com/atozi-ai/gateway/internal/providers"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAdminReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("auth:\n  adminKey: adm\n"+contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("")
+	configs, err := config.NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs.AddValidator(providers.ValidateConfig)
+
+	r := chi.NewRouter()
+	r.Route("/admin", NewAdminHandler(configs, nil, nil).RegisterRoutes)
+	reload := func(adminKey string) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+adminKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	write("aliases:\n  fast: openai/gpt-4o-mini\n")
+	w, body := reload("adm")
+	if w.Code != http.StatusOK || body["version"] != float64(2) {
+		t.Fatalf("reload = %d %v, want 200 and version 2", w.Code, body)
+	}
+
+	// A file the providers reject is reported and not activated.
+	write("aliases:\n  fast: nope/gpt-4o-mini\n")
+	w, body = reload("adm")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reload of an invalid file = %d, want 400", w.Code)
+	}
+	if e, _ := body["error"].(map[string]any); e["code"] != "invalid_config" {
+		t.Errorf("error = %v, want code invalid_config", body["error"])
+	}
+	if got := configs.Current(); got.Version != 2 || got.Config.Aliases["fast"] != "openai/gpt-4o-mini" {
+		t.Errorf("active config = version %d with alias %q, want version 2 kept", got.Version, got.Config.Aliases["fast"])
+	}
+
+	if w, _ := reload("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("reload with a wrong admin key = %d, want 401", w.Code)
+	}
+}
+
+func TestAdminRequiresKey(t *testing.T) {
+	serve := func(file, authorization string) int {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "gateway.yaml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		configs, err := config.NewManager(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := chi.NewRouter()
+		r.Route("/admin", NewAdminHandler(configs, nil, nil).RegisterRoutes)
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	const withKey = "auth:\n  adminKey: adm-secret\n"
+	tests := []struct {
+		name          string
+		file          string
+		authorization string
+		want          int
+	}{
+		{"admin key", withKey, "Bearer adm-secret", http.StatusOK},
+		{"no key", withKey, "", http.StatusUnauthorized},
+		{"wrong key", withKey, "Bearer adm-wrong", http.StatusUnauthorized},
+		{"prefix of the key", withKey, "Bearer adm", http.StatusUnauthorized},
+		{"key with a suffix", withKey, "Bearer adm-secret2", http.StatusUnauthorized},
+		{"disabled", "", "Bearer ", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.file, tt.authorization); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,22 +5,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/cache"
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
-	"github.com/atozi-ai/gateway/internal/providers"
+	"github.com/atozi-ai/gateway/internal/pricing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 )
 
-type ChatHandler struct{}
+type ChatHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+}
 
-func NewChatHandler() *ChatHandler {
-	return &ChatHandler{}
+func NewChatHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker) *ChatHandler {
+	return &ChatHandler{auth: auth, prices: prices, budgets: budgets}
 }
 
 type ChatRequestPayload struct {
@@ -61,6 +68,7 @@ type ChatOptionsPayload struct {
 	// AWS credentials for Bedrock
 	AWSAccessKeyID         *string `json:"awsAccessKeyID,omitempty"`
 	AWSSecretAccessKey     *string `json:"awsSecretAccessKey,omitempty"`
+	AWSSessionToken        *string `json:"awsSessionToken,omitempty"`
 	AWSRegion              *string `json:"awsRegion,omitempty"`
 	AWSInferenceProfileARN *string `json:"awsInferenceProfileARN,omitempty"`
 
@@ -109,6 +117,8 @@ type ChatResponsePayload struct {
 	Content           string          `json:"content,omitempty"`
 	Parsed            json.RawMessage `json:"parsed,omitempty"`
 	Raw               json.RawMessage `json:"raw,omitempty"`
+	// PromptFilterResults is Azure's content filtering verdict on the prompt.
+	PromptFilterResults json.RawMessage `json:"promptFilterResults,omitempty"`
 }
 
 type ChoicePayload struct {
@@ -116,6 +126,8 @@ type ChoicePayload struct {
 	Message      MessagePayload   `json:"message"`
 	FinishReason string           `json:"finishReason"`
 	Logprobs     *LogprobsPayload `json:"logprobs,omitempty"`
+	// ContentFilterResults is Azure's content filtering verdict on the choice.
+	ContentFilterResults json.RawMessage `json:"contentFilterResults,omitempty"`
 }
 
 type MessagePayload struct {
@@ -127,14 +139,18 @@ type MessagePayload struct {
 	ToolCalls          []ToolCallPayload `json:"toolCalls,omitempty"`
 }
 
+// ToolCallPayload is a complete tool call, or in a stream chunk a fragment of
+// one: the first delta for a call carries Index, ID, Type and Name, later
+// deltas only Index and the next piece of Arguments.
 type ToolCallPayload struct {
-	ID       string              `json:"id"`
-	Type     string              `json:"type"`
+	Index    *int                `json:"index,omitempty"`
+	ID       string              `json:"id,omitempty"`
+	Type     string              `json:"type,omitempty"`
 	Function FunctionCallPayload `json:"function"`
 }
 
 type FunctionCallPayload struct {
-	Name      string `json:"name"`
+	Name      string `json:"name,omitempty"`
 	Arguments string `json:"arguments"`
 }
 
@@ -161,16 +177,42 @@ type UsagePayload struct {
 	TotalTokens             int                   `json:"totalTokens"`
 	PromptTokensDetails     *TokensDetailsPayload `json:"promptTokensDetails,omitempty"`
 	CompletionTokensDetails *TokensDetailsPayload `json:"completionTokensDetails,omitempty"`
+	Cost                    *float64              `json:"cost,omitempty"` // USD, when the model has a price
 }
 
 type TokensDetailsPayload struct {
 	CachedTokens             *int `json:"cachedTokens,omitempty"`
+	CacheWriteTokens         *int `json:"cacheWriteTokens,omitempty"`
 	AudioTokens              *int `json:"audioTokens,omitempty"`
 	ReasoningTokens          *int `json:"reasoningTokens,omitempty"`
 	AcceptedPredictionTokens *int `json:"acceptedPredictionTokens,omitempty"`
 	RejectedPredictionTokens *int `json:"rejectedPredictionTokens,omitempty"`
 }
 
+// toUsagePayload converts normalized provider usage. Token breakdowns are
+// only included when they are non-zero.
+func toUsagePayload(u *llm.Usage) *UsagePayload {
+	usage := &UsagePayload{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+
+	if u.CachedTokens > 0 || u.CacheWriteTokens > 0 {
+		usage.PromptTokensDetails = &TokensDetailsPayload{}
+		if u.CachedTokens > 0 {
+			usage.PromptTokensDetails.CachedTokens = &u.CachedTokens
+		}
+		if u.CacheWriteTokens > 0 {
+			usage.PromptTokensDetails.CacheWriteTokens = &u.CacheWriteTokens
+		}
+	}
+	if u.ReasoningTokens > 0 {
+		usage.CompletionTokensDetails = &TokensDetailsPayload{ReasoningTokens: &u.ReasoningTokens}
+	}
+	return usage
+}
+
 func writeError(w http.ResponseWriter, ctx context.Context, err error) {
 	var pe *llm.ProviderError
 	if providerErr, ok := err.(*llm.ProviderError); ok {
@@ -208,20 +250,9 @@ func setRequestIDHeader(w http.ResponseWriter, ctx context.Context) {
 func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		writeError(w, r.Context(), llm.NewUnauthorizedError("missing Authorization header"))
-		return
-	}
-
-	const bearerPrefix = "Bearer "
-	if !strings.HasPrefix(authHeader, bearerPrefix) {
-		writeError(w, r.Context(), llm.NewValidationError("invalid Authorization header format", "invalid_auth_format"))
-		return
-	}
-	apiKey := strings.TrimPrefix(authHeader, bearerPrefix)
-	if apiKey == "" {
-		writeError(w, r.Context(), llm.NewUnauthorizedError("missing API key in Authorization header"))
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeError(w, r.Context(), err)
 		return
 	}
 
@@ -231,7 +262,7 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		log.Error().Err(err).Msg("Failed to decode request")
-		writeError(w, r.Context(), llm.NewValidationError("Invalid request body", "invalid_json"))
+		writeError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json"))
 		return
 	}
 
@@ -250,10 +281,16 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for i, msg := range payload.Messages {
+		if msg.Role == llm.RoleTool && msg.ToolCallID == "" {
+			writeError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("messages[%d]: tool messages require toolCallId", i), "missing_tool_call_id"))
+			return
+		}
+	}
+
 	req := llm.ChatRequest{
 		Model:    payload.Model,
 		Messages: payload.Messages,
-		APIKey:   apiKey,
 	}
 
 	streamQueryParam := r.URL.Query().Get("stream")
@@ -302,6 +339,7 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 			// AWS credentials for Bedrock
 			AWSAccessKeyID:         payload.Options.AWSAccessKeyID,
 			AWSSecretAccessKey:     payload.Options.AWSSecretAccessKey,
+			AWSSessionToken:        payload.Options.AWSSessionToken,
 			AWSRegion:              payload.Options.AWSRegion,
 			AWSInferenceProfileARN: payload.Options.AWSInferenceProfileARN,
 
@@ -354,35 +392,50 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	provider, model, err := providers.Get(req.Model, apiKey, payload.Endpoint)
+	d, r, err := dispatchChat(w, r, h.prices, h.budgets, principal, &req, payload.Endpoint)
 	if err != nil {
-		log.Error().Err(err).Str("model", req.Model).Msg("Invalid provider/model")
 		writeError(w, r.Context(), err)
 		return
 	}
-	req.Model = model
+	provider, model, subjects := d.provider, req.Model, d.subjects
 
 	log.Info().
 		Str("provider", provider.Name()).
 		Str("model", req.Model).
+		Str("key_id", principal.KeyID).
 		Bool("structured", req.Options.ResponseFormat != nil).
 		Bool("stream", req.Options.Stream != nil && *req.Options.Stream).
 		Msg("Processing chat request")
 
 	isStreaming := req.Options.Stream != nil && *req.Options.Stream
 
+	// Spend is recorded from the final usage chunk, so always ask for one. It
+	// is only passed on unless the client has opted out.
+	includeUsage := true
+	if isStreaming {
+		if req.Options.StreamOptions == nil {
+			req.Options.StreamOptions = &llm.StreamOptions{}
+		}
+		if req.Options.StreamOptions.IncludeUsage != nil {
+			includeUsage = *req.Options.StreamOptions.IncludeUsage
+		}
+		always := true
+		req.Options.StreamOptions.IncludeUsage = &always
+	}
+
 	var ctx context.Context
 	var cancel context.CancelFunc
 
 	if isStreaming {
-		ctx, cancel = h.createIdleTimeoutContext(r.Context(), 180*time.Second)
+		ctx, cancel = newIdleTimeoutContext(r.Context(), 180*time.Second)
 	} else {
 		ctx, cancel = context.WithTimeout(r.Context(), 180*time.Second)
 	}
 	defer cancel()
 
 	if isStreaming {
-		h.handleStreamingChat(w, ctx, provider, req, log, includeRaw, includeAccumulated)
+		usage, cost := h.handleStreamingChat(w, ctx, provider, req, log, includeRaw, includeAccumulated, includeUsage)
+		recordSpend(r.Context(), h.budgets, log, subjects, usage, cost)
 		return
 	}
 
@@ -428,6 +481,7 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 					} `json:"top_logprobs,omitempty"`
 				} `json:"content,omitempty"`
 			} `json:"logprobs,omitempty"`
+			ContentFilterResults json.RawMessage `json:"content_filter_results,omitempty"`
 		} `json:"choices"`
 		Usage *struct {
 			PromptTokens        int `json:"prompt_tokens"`
@@ -448,7 +502,8 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 				RejectedPredictionTokens *int `json:"rejected_prediction_tokens,omitempty"`
 			} `json:"completion_tokens_details,omitempty"`
 		} `json:"usage,omitempty"`
-		ServiceTier *string `json:"service_tier,omitempty"`
+		ServiceTier         *string         `json:"service_tier,omitempty"`
+		PromptFilterResults json.RawMessage `json:"prompt_filter_results,omitempty"`
 	}
 
 	var parsedResponse rawResponse
@@ -459,7 +514,7 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 			parsedResponse.Model = resp.Model
 			parsedResponse.Object = "chat.completion"
 		}
-		if len(parsedResponse.Choices) == 0 && resp.Content != "" {
+		if len(parsedResponse.Choices) == 0 && (resp.Content != "" || len(resp.ToolCalls) > 0) {
 			parsedResponse.ID = resp.ID
 			parsedResponse.Model = resp.Model
 			parsedResponse.Object = "chat.completion"
@@ -509,9 +564,10 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 		}
 
 		choices[i] = ChoicePayload{
-			Index:        choice.Index,
-			FinishReason: choice.FinishReason,
-			Logprobs:     logprobs,
+			Index:                choice.Index,
+			FinishReason:         choice.FinishReason,
+			Logprobs:             logprobs,
+			ContentFilterResults: choice.ContentFilterResults,
 			Message: MessagePayload{
 				Role:        choice.Message.Role,
 				Content:     choice.Message.Content,
@@ -522,14 +578,31 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if len(choices) == 0 && resp.Content != "" {
-		finishReason := "stop"
+	if len(choices) == 0 && (resp.Content != "" || len(resp.ToolCalls) > 0) {
+		finishReason := resp.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+
+		var toolCalls []ToolCallPayload
+		for _, tc := range resp.ToolCalls {
+			toolCalls = append(toolCalls, ToolCallPayload{
+				ID:   tc.ID,
+				Type: tc.Type,
+				Function: FunctionCallPayload{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+
 		choices = []ChoicePayload{{
 			Index:        0,
 			FinishReason: finishReason,
 			Message: MessagePayload{
-				Role:    "assistant",
-				Content: resp.Content,
+				Role:      "assistant",
+				Content:   resp.Content,
+				ToolCalls: toolCalls,
 			},
 		}}
 	}
@@ -563,17 +636,43 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 			PromptTokensDetails:     promptDetails,
 			CompletionTokensDetails: completionDetails,
 		}
+	} else if resp.Usage != nil {
+		usage = toUsagePayload(resp.Usage)
 	}
 
+	setCacheHeaders(w, ctx)
+
+	// Cache hits cost nothing, so they are neither priced nor charged.
+	var cost float64
+	if resp.Usage != nil && cache.StatusFromContext(ctx) != cache.Hit {
+		servedBy := resp.Provider
+		if servedBy == "" {
+			servedBy = provider.Name()
+		}
+		var ok bool
+		if cost, ok = h.prices.Cost(servedBy, *resp.Usage, model, resp.Model); ok {
+			usage.Cost = &cost
+			w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+			log.Info().
+				Str("provider", servedBy).
+				Int("prompt_tokens", resp.Usage.PromptTokens).
+				Int("completion_tokens", resp.Usage.CompletionTokens).
+				Float64("cost_usd", cost).
+				Msg("Chat request priced")
+		}
+	}
+	recordSpend(r.Context(), h.budgets, log, subjects, resp.Usage, cost)
+
 	response := ChatResponsePayload{
-		ID:                parsedResponse.ID,
-		Object:            parsedResponse.Object,
-		Created:           parsedResponse.Created,
-		Model:             parsedResponse.Model,
-		SystemFingerprint: parsedResponse.SystemFingerprint,
-		Choices:           choices,
-		Usage:             usage,
-		ServiceTier:       parsedResponse.ServiceTier,
+		ID:                  parsedResponse.ID,
+		Object:              parsedResponse.Object,
+		Created:             parsedResponse.Created,
+		Model:               parsedResponse.Model,
+		SystemFingerprint:   parsedResponse.SystemFingerprint,
+		Choices:             choices,
+		Usage:               usage,
+		ServiceTier:         parsedResponse.ServiceTier,
+		PromptFilterResults: parsedResponse.PromptFilterResults,
 	}
 
 	if includeAccumulated {
@@ -653,7 +752,7 @@ func (i *idleTimeoutContext) RecordActivity() {
 	i.mu.Unlock()
 }
 
-func (h *ChatHandler) createIdleTimeoutContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+func newIdleTimeoutContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	baseCtx, cancel := context.WithCancel(ctx)
 	return &idleTimeoutContext{
 		Context:      baseCtx,
@@ -671,7 +770,8 @@ func (h *ChatHandler) handleStreamingChat(
 	log zerolog.Logger,
 	includeRaw bool,
 	includeAccumulated bool,
-) {
+	includeUsage bool,
+) (*llm.Usage, float64) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -682,7 +782,7 @@ func (h *ChatHandler) handleStreamingChat(
 	if !ok {
 		log.Error().Msg("Streaming not supported by response writer")
 		writeError(w, ctx, llm.NewInternalError("Streaming not supported"))
-		return
+		return nil, 0
 	}
 
 	includeAccumulatedInMessage := req.Options.StreamOptions != nil &&
@@ -696,11 +796,23 @@ func (h *ChatHandler) handleStreamingChat(
 		idleCtx.RecordActivity()
 	}
 
+	// The last usage a provider reports covers the whole stream.
+	var finalUsage *llm.Usage
+	var finalCost float64
+
+	wroteHeader := false
+
 	err := provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
 		if ok {
 			idleCtx.RecordActivity()
 		}
 
+		// The cache status is only known once the provider has been called.
+		if !wroteHeader {
+			setCacheHeaders(w, ctx)
+			wroteHeader = true
+		}
+
 		choices := make([]ChoicePayload, len(chunk.Choices))
 		for i, choice := range chunk.Choices {
 			message := MessagePayload{
@@ -712,6 +824,19 @@ func (h *ChatHandler) handleStreamingChat(
 				message.Role = *choice.Delta.Role
 			}
 
+			for _, tc := range choice.Delta.ToolCalls {
+				index := tc.Index
+				message.ToolCalls = append(message.ToolCalls, ToolCallPayload{
+					Index: &index,
+					ID:    tc.ID,
+					Type:  tc.Type,
+					Function: FunctionCallPayload{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				})
+			}
+
 			if choice.Delta.Content != nil {
 				deltaContent := *choice.Delta.Content
 				message.Content = deltaContent
@@ -737,11 +862,26 @@ func (h *ChatHandler) handleStreamingChat(
 
 		var usage *UsagePayload
 		if chunk.Usage != nil {
-			usage = &UsagePayload{
-				PromptTokens:     chunk.Usage.PromptTokens,
-				CompletionTokens: chunk.Usage.CompletionTokens,
-				TotalTokens:      chunk.Usage.TotalTokens,
+			usage = toUsagePayload(chunk.Usage)
+			finalUsage, finalCost = chunk.Usage, 0
+			// A replay from the cache costs nothing.
+			if cache.StatusFromContext(ctx) != cache.Hit {
+				servedBy := chunk.Provider
+				if servedBy == "" {
+					servedBy = provider.Name()
+				}
+				if cost, ok := h.prices.Cost(servedBy, *chunk.Usage, req.Model, chunk.Model); ok {
+					usage.Cost = &cost
+					finalCost = cost
+				}
+			}
+		}
+		if !includeUsage {
+			// The usage was only requested for the budgets.
+			if len(chunk.Choices) == 0 {
+				return nil
 			}
+			usage = nil
 		}
 
 		var content string
@@ -812,11 +952,35 @@ func (h *ChatHandler) handleStreamingChat(
 		jsonData, _ := json.Marshal(errorResponse)
 		fmt.Fprintf(w, "data: %s\n\n", jsonData)
 		flusher.Flush()
-		return
+		return finalUsage, finalCost
 	}
 
 	fmt.Fprintf(w, "data: [DONE]\n\n")
 	flusher.Flush()
+	return finalUsage, finalCost
+}
+
+// setCacheHeaders reports how the response cache handled the request. The
+// similarity is only known for semantic hits.
+func setCacheHeaders(w http.ResponseWriter, ctx context.Context) {
+	if status := cache.StatusFromContext(ctx); status != "" {
+		w.Header().Set("X-Cache", string(status))
+	}
+	if similarity, ok := cache.SimilarityFromContext(ctx); ok {
+		w.Header().Set("X-Cache-Similarity", strconv.FormatFloat(similarity, 'f', 4, 64))
+	}
+}
+
+// recordSpend charges a finished request to its budget subjects. It runs after
+// the response is written, so failures are logged rather than returned.
+func recordSpend(ctx context.Context, budgets *budget.Tracker, log zerolog.Logger, subjects budget.Subjects, usage *llm.Usage, cost float64) {
+	if usage == nil || cache.StatusFromContext(ctx) == cache.Hit {
+		return
+	}
+	spend := budget.Spend{Cost: cost, Tokens: int64(usage.TotalTokens)}
+	if err := budgets.Record(context.WithoutCancel(ctx), subjects, spend); err != nil {
+		log.Error().Err(err).Str("key_id", subjects.KeyID).Msg("Failed to record spend")
+	}
 }
 
 func (h *ChatHandler) RegisterRoutes(r chi.Router) {
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers/openai_compat"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// CompletionsHandler serves chat completions in the OpenAI wire format, so
+// OpenAI SDKs and the frameworks built on them can use any provider. Unlike
+// ChatHandler it takes parameters at the top level in snake_case and answers
+// with OpenAI's objects and error envelope. A bare model name is served by
+// OpenAI.
+type CompletionsHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+}
+
+func NewCompletionsHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker) *CompletionsHandler {
+	return &CompletionsHandler{auth: auth, prices: prices, budgets: budgets}
+}
+
+func writeCompletionsError(w http.ResponseWriter, ctx context.Context, err error) {
+	status, body := openaicompat.EncodeError(err)
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, ctx)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (h *CompletionsHandler) Completions(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+	if err != nil {
+		writeCompletionsError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_body"))
+		return
+	}
+
+	req, err := openaicompat.ParseRequest(body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to decode chat completions request")
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	req.Model = qualifyModel(req.Model, "openai")
+
+	d, r, err := dispatchChat(w, r, h.prices, h.budgets, principal, &req, "")
+	if err != nil {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	isStreaming := req.Options.Stream != nil && *req.Options.Stream
+
+	log.Info().
+		Str("provider", d.provider.Name()).
+		Str("model", req.Model).
+		Str("key_id", principal.KeyID).
+		Bool("stream", isStreaming).
+		Msg("Processing chat completions request")
+
+	if isStreaming {
+		// Spend is recorded from the usage chunk, which is only passed on if
+		// the client asked for it.
+		includeUsage := req.Options.StreamOptions != nil &&
+			req.Options.StreamOptions.IncludeUsage != nil &&
+			*req.Options.StreamOptions.IncludeUsage
+		always := true
+		req.Options.StreamOptions = &llm.StreamOptions{IncludeUsage: &always}
+
+		ctx, cancel := newIdleTimeoutContext(r.Context(), 180*time.Second)
+		defer cancel()
+
+		usage, cost := h.stream(w, ctx, d, req, includeUsage, log)
+		recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 180*time.Second)
+	defer cancel()
+
+	resp, err := d.provider.Chat(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("Chat completions request failed")
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+
+	setCacheHeaders(w, ctx)
+
+	cost, ok := d.price(ctx, h.prices, resp.Provider, resp.Usage, req.Model, resp.Model)
+	if ok {
+		w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+	}
+	recordSpend(r.Context(), h.budgets, log, d.subjects, resp.Usage, cost)
+
+	out, err := openaicompat.EncodeResponse(resp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode chat completions response")
+		writeCompletionsError(w, r.Context(), llm.NewInternalError("failed to encode response"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	w.Write(out)
+}
+
+// stream relays a streamed chat as chat.completion.chunk events and returns
+// the final usage and its cost. Errors before the first event get an error
+// response; later ones are sent as an error event, as the OpenAI API does.
+func (h *CompletionsHandler) stream(w http.ResponseWriter, ctx context.Context, d *chatDispatch, req llm.ChatRequest, includeUsage bool, log zerolog.Logger) (*llm.Usage, float64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Streaming not supported by response writer")
+		writeCompletionsError(w, ctx, llm.NewInternalError("Streaming not supported"))
+		return nil, 0
+	}
+
+	idleCtx, _ := ctx.(*idleTimeoutContext)
+	started := false
+
+	var finalUsage *llm.Usage
+	var finalCost float64
+
+	err := d.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if idleCtx != nil {
+			idleCtx.RecordActivity()
+		}
+
+		if !started {
+			startEventStream(w, ctx)
+			started = true
+		}
+
+		if chunk.Usage != nil {
+			finalUsage = chunk.Usage
+			finalCost, _ = d.price(ctx, h.prices, chunk.Provider, chunk.Usage, req.Model, chunk.Model)
+		}
+
+		data, err := openaicompat.EncodeChunk(chunk, includeUsage)
+		if err != nil {
+			return llm.NewInternalError(fmt.Sprintf("failed to marshal stream chunk: %v", err))
+		}
+		if data == nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("Chat completions stream failed")
+		if !started {
+			writeCompletionsError(w, ctx, err)
+			return finalUsage, finalCost
+		}
+		_, body := openaicompat.EncodeError(err)
+		fmt.Fprintf(w, "data: %s\n\n", body)
+		flusher.Flush()
+		return finalUsage, finalCost
+	}
+
+	if !started {
+		startEventStream(w, ctx)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+	return finalUsage, finalCost
+}
+
+func (h *CompletionsHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/chat/completions", h.Completions)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/cache"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// chatDispatch is a decoded chat request that has been routed to a provider
+// and cleared by the caller's budgets.
+type chatDispatch struct {
+	provider llm.Provider
+	subjects budget.Subjects // Charged once the request has finished
+}
+
+// dispatchChat resolves req.Model to a provider, replacing it with the model
+// name within that provider, and checks the caller's budgets against an
+// estimate of the request's cost at prices. Every chat API the gateway
+// speaks goes through it after decoding its own wire format.
+//
+// The returned request carries the client's cache policy in its context for
+// the caching providers; handlers must use it from then on.
+func dispatchChat(w http.ResponseWriter, r *http.Request, prices *pricing.Catalog, budgets *budget.Tracker, principal *keys.Principal, req *llm.ChatRequest, endpoint string) (*chatDispatch, *http.Request, error) {
+	log := logger.FromContext(r.Context())
+	requested := req.Model
+
+	provider, model, err := providers.Get(req.Model, principal.APIKey, endpoint)
+	if err != nil {
+		log.Error().Err(err).Str("model", req.Model).Msg("Invalid provider/model")
+		return nil, r, err
+	}
+	req.Model = model
+	req.APIKey = principal.APIKey
+	req.Credentials = principal.Credentials
+
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String("gen_ai.request.model", requested),
+		attribute.String("gateway.provider", provider.Name()),
+		attribute.String("gateway.key_id", principal.KeyID),
+	)
+
+	subjects := budget.Subjects{KeyID: principal.KeyID, Team: principal.Team, Model: requested}
+	estimate := estimateSpend(prices, requested, func(price pricing.Price, priced bool) budget.Spend {
+		return budget.Estimate(*req, price, priced)
+	})
+	warnings, err := budgets.Check(r.Context(), subjects, estimate)
+	if err != nil {
+		log.Warn().Err(err).Str("key_id", principal.KeyID).Str("team", principal.Team).Msg("Chat request rejected by budget")
+		return nil, r, err
+	}
+	if len(warnings) > 0 {
+		w.Header().Set("X-Budget-Warning", strings.Join(warnings, "; "))
+	}
+
+	// The caching providers read the client's policy and identity from, and
+	// record the cache status in, the request context.
+	control := cache.ParseControl(r.Header.Get("Cache-Control"))
+	r = r.WithContext(cache.WithControl(r.Context(), control, principal.ID()))
+
+	return &chatDispatch{provider: provider, subjects: subjects}, r, nil
+}
+
+// estimateSpend estimates a request for the requested model with estimate
+// at the price of every provider and model that may serve it, and returns
+// the dearest. It is unpriced if any of them has no price.
+func estimateSpend(prices *pricing.Catalog, requested string, estimate func(pricing.Price, bool) budget.Spend) budget.Spend {
+	var dearest budget.Spend
+	for i, target := range providers.Targets(requested) {
+		name, model, _ := strings.Cut(target, "/")
+		spend := estimate(prices.Lookup(name, model))
+		if i > 0 {
+			spend.Cost = max(spend.Cost, dearest.Cost)
+			spend.Unpriced = spend.Unpriced || dearest.Unpriced
+		}
+		dearest = spend
+	}
+	return dearest
+}
+
+// price returns the cost of usage reported for model, or false if the model
+// has no price. Responses served from the cache cost nothing and are never
+// priced.
+func (d *chatDispatch) price(ctx context.Context, prices *pricing.Catalog, servedBy string, usage *llm.Usage, model, respModel string) (float64, bool) {
+	if usage == nil || cache.StatusFromContext(ctx) == cache.Hit {
+		return 0, false
+	}
+	if servedBy == "" {
+		servedBy = d.provider.Name()
+	}
+	return prices.Cost(servedBy, *usage, model, respModel)
+}
+
+// startEventStream sets the headers of a server-sent event response. It is
+// called with the first chunk, once the cache status is known.
+func startEventStream(w http.ResponseWriter, ctx context.Context) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	setRequestIDHeader(w, ctx)
+	setCacheHeaders(w, ctx)
+}
+
+// qualifyModel prefixes a bare model name, as sent by a provider's own SDK,
+// with that provider. Aliases and provider/model specs are left alone.
+func qualifyModel(model, provider string) string {
+	if strings.Contains(model, "/") || providers.GetProviderManager().IsAlias(model) {
+		return model
+	}
+	return provider + "/" + model
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxEmbeddingInputs = 2048
+
+type EmbeddingsHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+}
+
+func NewEmbeddingsHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker) *EmbeddingsHandler {
+	return &EmbeddingsHandler{auth: auth, prices: prices, budgets: budgets}
+}
+
+// EmbeddingRequestPayload and the response payloads follow the OpenAI
+// embeddings API, snake_case names included, so OpenAI SDKs can call it.
+type EmbeddingRequestPayload struct {
+	Model          string          `json:"model"`
+	Endpoint       string          `json:"endpoint,omitempty"`
+	Input          json.RawMessage `json:"input"` // A string or an array of strings
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"` // "float" (default) or "base64"
+	User           *string         `json:"user,omitempty"`
+}
+
+type EmbeddingResponsePayload struct {
+	Object string                 `json:"object"`
+	Data   []EmbeddingDataPayload `json:"data"`
+	Model  string                 `json:"model"`
+	Usage  EmbeddingsUsagePayload `json:"usage"`
+}
+
+type EmbeddingDataPayload struct {
+	Object    string      `json:"object"`
+	Index     int         `json:"index"`
+	Embedding interface{} `json:"embedding"` // []float32, or a base64 string
+}
+
+type EmbeddingsUsagePayload struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// parseEmbeddingInput accepts a single string or an array of strings.
+func parseEmbeddingInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, llm.NewValidationError("input is required", "missing_input")
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, llm.NewValidationError("input must not be empty", "invalid_input")
+		}
+		return []string{single}, nil
+	}
+
+	var batch []string
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return nil, llm.NewValidationError("input must be a string or an array of strings", "invalid_input")
+	}
+	if len(batch) == 0 {
+		return nil, llm.NewValidationError("input must not be empty", "invalid_input")
+	}
+	if len(batch) > maxEmbeddingInputs {
+		return nil, llm.NewValidationError(fmt.Sprintf("too many inputs (max %d)", maxEmbeddingInputs), "too_many_inputs")
+	}
+	for i, text := range batch {
+		if text == "" {
+			return nil, llm.NewValidationError(fmt.Sprintf("input[%d] must not be empty", i), "invalid_input")
+		}
+	}
+	return batch, nil
+}
+
+// encodeBase64 packs a vector as little-endian float32 values, the format
+// OpenAI clients expect for encoding_format=base64.
+func encodeBase64(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func (h *EmbeddingsHandler) Embeddings(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeError(w, r.Context(), err)
+		return
+	}
+
+	var payload EmbeddingRequestPayload
+	r.Body = http.MaxBytesReader(w, r.Body, 10*1024*1024)
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Error().Err(err).Msg("Failed to decode request")
+		writeError(w, r.Context(), llm.NewValidationError("Invalid request body", "invalid_json"))
+		return
+	}
+
+	if payload.Model == "" {
+		writeError(w, r.Context(), llm.NewValidationError("model is required", "missing_model"))
+		return
+	}
+
+	input, err := parseEmbeddingInput(payload.Input)
+	if err != nil {
+		writeError(w, r.Context(), err)
+		return
+	}
+
+	if payload.Dimensions != nil && *payload.Dimensions <= 0 {
+		writeError(w, r.Context(), llm.NewValidationError("dimensions must be positive", "invalid_dimensions"))
+		return
+	}
+
+	switch payload.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		writeError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("unsupported encoding_format %q (expected float or base64)", payload.EncodingFormat), "invalid_encoding_format"))
+		return
+	}
+
+	provider, model, err := providers.Get(payload.Model, principal.APIKey, payload.Endpoint)
+	if err != nil {
+		log.Error().Err(err).Str("model", payload.Model).Msg("Invalid provider/model")
+		writeError(w, r.Context(), err)
+		return
+	}
+
+	req := llm.EmbeddingRequest{
+		Model:       model,
+		Input:       input,
+		Dimensions:  payload.Dimensions,
+		User:        payload.User,
+		APIKey:      principal.APIKey,
+		Credentials: principal.Credentials,
+	}
+
+	subjects := budget.Subjects{KeyID: principal.KeyID, Team: principal.Team, Model: payload.Model}
+	estimate := estimateSpend(h.prices, payload.Model, func(price pricing.Price, priced bool) budget.Spend {
+		return budget.EstimateEmbeddings(req, price, priced)
+	})
+	warnings, err := h.budgets.Check(r.Context(), subjects, estimate)
+	if err != nil {
+		log.Warn().Err(err).Str("key_id", principal.KeyID).Str("team", principal.Team).Msg("Embeddings request rejected by budget")
+		writeError(w, r.Context(), err)
+		return
+	}
+	if len(warnings) > 0 {
+		w.Header().Set("X-Budget-Warning", strings.Join(warnings, "; "))
+	}
+
+	log.Info().
+		Str("provider", provider.Name()).
+		Str("model", model).
+		Str("key_id", principal.KeyID).
+		Int("inputs", len(input)).
+		Msg("Processing embeddings request")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 180*time.Second)
+	defer cancel()
+
+	resp, err := llm.Embed(ctx, provider, req)
+	if err != nil {
+		log.Error().Err(err).Msg("Embeddings request failed")
+		writeError(w, r.Context(), err)
+		return
+	}
+
+	servedBy := resp.Provider
+	if servedBy == "" {
+		servedBy = provider.Name()
+	}
+	cost, ok := h.prices.Cost(servedBy, resp.Usage, model, resp.Model)
+	if ok {
+		w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+	}
+	recordSpend(r.Context(), h.budgets, log, subjects, &resp.Usage, cost)
+
+	data := make([]EmbeddingDataPayload, len(resp.Embeddings))
+	for i, vector := range resp.Embeddings {
+		data[i] = EmbeddingDataPayload{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: vector,
+		}
+		if payload.EncodingFormat == "base64" {
+			data[i].Embedding = encodeBase64(vector)
+		}
+	}
+
+	respModel := resp.Model
+	if respModel == "" {
+		respModel = model
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(EmbeddingResponsePayload{
+		Object: "list",
+		Data:   data,
+		Model:  respModel,
+		Usage: EmbeddingsUsagePayload{
+			PromptTokens: resp.Usage.PromptTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+	})
+}
+
+func (h *EmbeddingsHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/embeddings", h.Embeddings)
+}
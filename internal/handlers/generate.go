@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers/gemini_compat"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// GenerateContentHandler serves the Gemini generateContent and
+// streamGenerateContent methods, so Google SDKs can use any provider. The
+// model in the path is a provider/model spec or an alias; a bare model name
+// is served by Gemini.
+type GenerateContentHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+}
+
+func NewGenerateContentHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker) *GenerateContentHandler {
+	return &GenerateContentHandler{auth: auth, prices: prices, budgets: budgets}
+}
+
+func writeGenerateError(w http.ResponseWriter, ctx context.Context, err error) {
+	status, body := gemini_compat.EncodeError(err)
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, ctx)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// GenerateContent handles POST /models/{model}:{method}. The model may
+// contain slashes, so the whole path tail is matched and split at the last
+// colon.
+func (h *GenerateContentHandler) GenerateContent(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	tail, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil {
+		writeGenerateError(w, r.Context(), llm.NewValidationError("invalid model path", "invalid_model"))
+		return
+	}
+	model, method, ok := cutLast(tail, ":")
+	if !ok || model == "" || (method != "generateContent" && method != "streamGenerateContent") {
+		writeGenerateError(w, r.Context(), &llm.ProviderError{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("unknown method %q; expected models/{model}:generateContent or :streamGenerateContent", tail),
+			Type:       "invalid_request_error",
+			Code:       "unknown_method",
+		})
+		return
+	}
+	isStreaming := method == "streamGenerateContent"
+
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeGenerateError(w, r.Context(), err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+	if err != nil {
+		writeGenerateError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_body"))
+		return
+	}
+
+	req, err := gemini_compat.ParseRequest(qualifyModel(model, "gemini"), body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to decode generateContent request")
+		writeGenerateError(w, r.Context(), err)
+		return
+	}
+
+	d, r, err := dispatchChat(w, r, h.prices, h.budgets, principal, &req, "")
+	if err != nil {
+		writeGenerateError(w, r.Context(), err)
+		return
+	}
+
+	log.Info().
+		Str("provider", d.provider.Name()).
+		Str("model", req.Model).
+		Str("key_id", principal.KeyID).
+		Bool("stream", isStreaming).
+		Msg("Processing generateContent request")
+
+	if isStreaming {
+		stream := true
+		includeUsage := true
+		req.Options.Stream = &stream
+		req.Options.StreamOptions = &llm.StreamOptions{IncludeUsage: &includeUsage}
+
+		ctx, cancel := newIdleTimeoutContext(r.Context(), 180*time.Second)
+		defer cancel()
+
+		usage, cost := h.stream(w, ctx, d, req, r.URL.Query().Get("alt") == "sse", log)
+		recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 180*time.Second)
+	defer cancel()
+
+	resp, err := d.provider.Chat(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("generateContent request failed")
+		writeGenerateError(w, r.Context(), err)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+
+	setCacheHeaders(w, ctx)
+
+	cost, ok := d.price(ctx, h.prices, resp.Provider, resp.Usage, req.Model, resp.Model)
+	if ok {
+		w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+	}
+	recordSpend(r.Context(), h.budgets, log, d.subjects, resp.Usage, cost)
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	json.NewEncoder(w).Encode(gemini_compat.EncodeResponse(resp))
+}
+
+// stream relays a streamed chat as streamGenerateContent responses and
+// returns the final usage and its cost. With sse the responses are sent as
+// server-sent events, as the Google SDKs request with alt=sse; otherwise
+// they form one JSON array that is written as it grows.
+func (h *GenerateContentHandler) stream(w http.ResponseWriter, ctx context.Context, d *chatDispatch, req llm.ChatRequest, sse bool, log zerolog.Logger) (*llm.Usage, float64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Streaming not supported by response writer")
+		writeGenerateError(w, ctx, llm.NewInternalError("Streaming not supported"))
+		return nil, 0
+	}
+
+	idleCtx, _ := ctx.(*idleTimeoutContext)
+	encoder := gemini_compat.NewStreamEncoder()
+	started := false
+	sent := 0
+
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		if sse {
+			startEventStream(w, ctx)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		setRequestIDHeader(w, ctx)
+		setCacheHeaders(w, ctx)
+	}
+
+	send := func(v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case sse:
+			_, err = fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+		case sent == 0:
+			_, err = fmt.Fprintf(w, "[%s", data)
+		default:
+			_, err = fmt.Fprintf(w, ",\r\n%s", data)
+		}
+		sent++
+		flusher.Flush()
+		return err
+	}
+
+	var finalUsage *llm.Usage
+	var finalCost float64
+
+	err := d.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if idleCtx != nil {
+			idleCtx.RecordActivity()
+		}
+		start()
+
+		if chunk.Usage != nil {
+			finalUsage = chunk.Usage
+			finalCost, _ = d.price(ctx, h.prices, chunk.Provider, chunk.Usage, req.Model, chunk.Model)
+		}
+
+		if resp := encoder.Chunk(chunk); resp != nil {
+			return send(resp)
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("generateContent stream failed")
+		if !started {
+			writeGenerateError(w, ctx, err)
+			return finalUsage, finalCost
+		}
+		// The status line is gone; report the error in the stream as the
+		// Gemini API does.
+		_, body := gemini_compat.EncodeError(err)
+		send(json.RawMessage(body))
+	} else {
+		start()
+		send(encoder.Finish())
+	}
+
+	if !sse {
+		fmt.Fprint(w, "]")
+		flusher.Flush()
+	}
+	return finalUsage, finalCost
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func (h *GenerateContentHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/models/*", h.GenerateContent)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers/anthropic_compat"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// MessagesHandler serves the Anthropic Messages API, so Anthropic SDKs can
+// use any provider. Requests name models as provider/model or by alias, like
+// the chat API; a bare model name is served by Anthropic.
+type MessagesHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+}
+
+func NewMessagesHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker) *MessagesHandler {
+	return &MessagesHandler{auth: auth, prices: prices, budgets: budgets}
+}
+
+func writeMessagesError(w http.ResponseWriter, ctx context.Context, err error) {
+	status, body := anthropic_compat.EncodeError(err)
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, ctx)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (h *MessagesHandler) Messages(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeMessagesError(w, r.Context(), err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+	if err != nil {
+		writeMessagesError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_body"))
+		return
+	}
+
+	req, err := anthropic_compat.ParseRequest(body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to decode messages request")
+		writeMessagesError(w, r.Context(), err)
+		return
+	}
+
+	req.Model = qualifyModel(req.Model, "anthropic")
+
+	d, r, err := dispatchChat(w, r, h.prices, h.budgets, principal, &req, "")
+	if err != nil {
+		writeMessagesError(w, r.Context(), err)
+		return
+	}
+
+	isStreaming := req.Options.Stream != nil && *req.Options.Stream
+
+	log.Info().
+		Str("provider", d.provider.Name()).
+		Str("model", req.Model).
+		Str("key_id", principal.KeyID).
+		Bool("stream", isStreaming).
+		Msg("Processing messages request")
+
+	if isStreaming {
+		includeUsage := true
+		req.Options.StreamOptions = &llm.StreamOptions{IncludeUsage: &includeUsage}
+
+		ctx, cancel := newIdleTimeoutContext(r.Context(), 180*time.Second)
+		defer cancel()
+
+		usage, cost := h.stream(w, ctx, d, req, log)
+		recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 180*time.Second)
+	defer cancel()
+
+	resp, err := d.provider.Chat(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("Messages request failed")
+		writeMessagesError(w, r.Context(), err)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+
+	setCacheHeaders(w, ctx)
+
+	cost, ok := d.price(ctx, h.prices, resp.Provider, resp.Usage, req.Model, resp.Model)
+	if ok {
+		w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+	}
+	recordSpend(r.Context(), h.budgets, log, d.subjects, resp.Usage, cost)
+
+	out, err := anthropic_compat.EncodeResponse(resp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode messages response")
+		writeMessagesError(w, r.Context(), llm.NewInternalError("failed to encode response"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	w.Write(out)
+}
+
+// stream relays a streamed chat as Messages API events and returns the final
+// usage and its cost. Errors before the first event get an error response;
+// later ones end the stream with an error event.
+func (h *MessagesHandler) stream(w http.ResponseWriter, ctx context.Context, d *chatDispatch, req llm.ChatRequest, log zerolog.Logger) (*llm.Usage, float64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Streaming not supported by response writer")
+		writeMessagesError(w, ctx, llm.NewInternalError("Streaming not supported"))
+		return nil, 0
+	}
+
+	idleCtx, _ := ctx.(*idleTimeoutContext)
+	encoder := anthropic_compat.NewStreamEncoder()
+	started := false
+
+	var finalUsage *llm.Usage
+	var finalCost float64
+
+	err := d.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if idleCtx != nil {
+			idleCtx.RecordActivity()
+		}
+
+		if !started {
+			startEventStream(w, ctx)
+			started = true
+		}
+
+		if chunk.Usage != nil {
+			finalUsage = chunk.Usage
+			finalCost, _ = d.price(ctx, h.prices, chunk.Provider, chunk.Usage, req.Model, chunk.Model)
+		}
+
+		if _, err := w.Write(encoder.Chunk(chunk)); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("Messages stream failed")
+		if !started {
+			writeMessagesError(w, ctx, err)
+			return finalUsage, finalCost
+		}
+		w.Write(encoder.Error(err))
+		flusher.Flush()
+		return finalUsage, finalCost
+	}
+
+	if !started {
+		startEventStream(w, ctx)
+	}
+	w.Write(encoder.Finish())
+	flusher.Flush()
+	return finalUsage, finalCost
+}
+
+func (h *MessagesHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/messages", h.Messages)
+}
@@ -352,6 +352,15 @@ func (h *ModelsHandler) ListModels(w http.ResponseWriter, r *http.Request) {
 		{ID: "cloudflare/llama-3.2-1b", Object: "model", OwnedBy: "meta", Provider: "cloudflare", Name: "Llama 3.2 1B", ContextLen: 128000},
 		{ID: "cloudflare/qwen-2.5-7b", Object: "model", OwnedBy: "qwen", Provider: "cloudflare", Name: "Qwen 2.5 7B", ContextLen: 128000},
 		{ID: "cloudflare/gemma-2-2b", Object: "model", OwnedBy: "google", Provider: "cloudflare", Name: "Gemma 2 2B", ContextLen: 128000},
+
+		// Canonical open-weight models, routed to the cheapest capable host
+		{ID: "meta/llama-3.3-70b", Object: "model", OwnedBy: "meta", Provider: "auto", Name: "Llama 3.3 70B", ContextLen: 131072, Description: "Cheapest of Groq, Together, Fireworks, DeepInfra and Nebius", Category: []string{"general", "open_weights"}},
+		{ID: "meta/llama-3.1-8b", Object: "model", OwnedBy: "meta", Provider: "auto", Name: "Llama 3.1 8B", ContextLen: 131072, Description: "Cheapest of Groq, Together, Fireworks, DeepInfra and Nebius", Category: []string{"general", "open_weights"}},
+		{ID: "meta/llama-3.1-405b", Object: "model", OwnedBy: "meta", Provider: "auto", Name: "Llama 3.1 405B", ContextLen: 131072, Description: "Cheapest of Together, Fireworks, DeepInfra and Nebius", Category: []string{"general", "open_weights"}},
+		{ID: "qwen/qwen-2.5-72b-instruct", Object: "model", OwnedBy: "qwen", Provider: "auto", Name: "Qwen 2.5 72B Instruct", ContextLen: 131072, Description: "Cheapest of Together, Fireworks, DeepInfra and Nebius", Category: []string{"general", "open_weights"}},
+		{ID: "qwen/qwen-2.5-coder-32b-instruct", Object: "model", OwnedBy: "qwen", Provider: "auto", Name: "Qwen 2.5 Coder 32B Instruct", ContextLen: 131072, Description: "Cheapest of Together, DeepInfra and Nebius", Category: []string{"coding", "open_weights"}},
+		{ID: "deepseek-ai/deepseek-v3", Object: "model", OwnedBy: "deepseek", Provider: "auto", Name: "DeepSeek V3", ContextLen: 163840, Description: "Cheapest of Together, Fireworks, DeepInfra and Nebius", Category: []string{"general", "coding", "open_weights"}},
+		{ID: "deepseek-ai/deepseek-r1", Object: "model", OwnedBy: "deepseek", Provider: "auto", Name: "DeepSeek R1", ContextLen: 163840, Description: "Cheapest of Together, Fireworks, DeepInfra and Nebius", Category: []string{"reasoning", "open_weights"}},
 	}
 
 	response := ModelsListResponse{
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/budget"
+	"github.com/atozi-ai/gateway/internal/conversation"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/pricing"
+	"github.com/atozi-ai/gateway/internal/providers"
+	"github.com/atozi-ai/gateway/internal/providers/openai_compat"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// ResponsesHandler serves the OpenAI Responses API. Requests only OpenAI can
+// serve are forwarded unchanged, so every feature of the API works for them.
+// Other providers are served through chat, with conversations kept in the store
+// for previous_response_id. A bare model name is served by OpenAI.
+type ResponsesHandler struct {
+	auth    *keys.Authenticator
+	prices  *pricing.Catalog
+	budgets *budget.Tracker
+	store   *conversation.Store
+}
+
+func NewResponsesHandler(auth *keys.Authenticator, prices *pricing.Catalog, budgets *budget.Tracker, store *conversation.Store) *ResponsesHandler {
+	return &ResponsesHandler{auth: auth, prices: prices, budgets: budgets, store: store}
+}
+
+func (h *ResponsesHandler) Responses(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	principal, err := h.auth.Authenticate(r)
+	if err != nil {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+	if err != nil {
+		writeCompletionsError(w, r.Context(), llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_body"))
+		return
+	}
+
+	parsed, err := openaicompat.ParseResponsesRequest(body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to decode responses request")
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	var prev *conversation.Conversation
+	found := false
+	if parsed.PreviousResponseID != "" {
+		prev, found = h.store.Get(parsed.PreviousResponseID, principal.ID())
+	}
+
+	// OpenAI keeps its own conversations; only ones the gateway stored
+	// need translating.
+	model := qualifyModel(parsed.Model, "openai")
+	forward := servedByOpenAI(model) && !found
+
+	// The input and output limit are only here for the budget estimate; the
+	// chat request is built once the provider is known. Forwarded input is
+	// OpenAI's to validate, and may hold items the gateway cannot translate.
+	req := llm.ChatRequest{
+		Model:   model,
+		Options: llm.ChatOptions{MaxTokens: parsed.MaxOutputTokens},
+	}
+	req.Messages, err = parsed.Input()
+	if err != nil && !forward {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+	d, r, err := dispatchChat(w, r, h.prices, h.budgets, principal, &req, "")
+	if err != nil {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+
+	log.Info().
+		Str("provider", d.provider.Name()).
+		Str("model", req.Model).
+		Str("key_id", principal.KeyID).
+		Bool("stream", parsed.Stream).
+		Bool("previous_response", parsed.PreviousResponseID != "").
+		Msg("Processing responses request")
+
+	if forward {
+		h.forward(w, r, d, req, parsed, body, log)
+		return
+	}
+
+	if parsed.PreviousResponseID != "" && !found {
+		writeCompletionsError(w, r.Context(), &llm.ProviderError{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Previous response with id '%s' not found.", parsed.PreviousResponseID),
+			Type:       "invalid_request_error",
+			Code:       "previous_response_not_found",
+			Param:      "previous_response_id",
+		})
+		return
+	}
+
+	var history []llm.Message
+	if prev != nil {
+		history = prev.Messages
+	}
+	input := req.Messages
+	chatReq, err := parsed.ChatRequest(history, input)
+	if err != nil {
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+	chatReq.Model, chatReq.APIKey, chatReq.Credentials = req.Model, req.APIKey, req.Credentials
+
+	parsed.Store = parsed.Store && h.store.Enabled()
+	id := openaicompat.NewResponseID()
+
+	// save stores the conversation up to resp under the new response ID.
+	save := func(resp *llm.ChatResponse) {
+		if !parsed.Store {
+			return
+		}
+		messages := make([]llm.Message, 0, len(history)+len(input)+1)
+		messages = append(messages, history...)
+		messages = append(messages, input...)
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		h.store.Put(id, &conversation.Conversation{Owner: principal.ID(), Messages: messages, CreatedAt: time.Now()})
+	}
+
+	if parsed.Stream {
+		includeUsage := true
+		chatReq.Options.StreamOptions = &llm.StreamOptions{IncludeUsage: &includeUsage}
+
+		ctx, cancel := newIdleTimeoutContext(r.Context(), 180*time.Second)
+		defer cancel()
+
+		usage, cost := h.stream(w, ctx, d, chatReq, parsed.NewStreamEncoder(id), save, log)
+		recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 180*time.Second)
+	defer cancel()
+
+	resp, err := d.provider.Chat(ctx, chatReq)
+	if err != nil {
+		log.Error().Err(err).Msg("Responses request failed")
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = chatReq.Model
+	}
+
+	setCacheHeaders(w, ctx)
+
+	cost, ok := d.price(ctx, h.prices, resp.Provider, resp.Usage, chatReq.Model, resp.Model)
+	if ok {
+		w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+	}
+	recordSpend(r.Context(), h.budgets, log, d.subjects, resp.Usage, cost)
+
+	out, err := parsed.EncodeResponse(id, resp)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode responses response")
+		writeCompletionsError(w, r.Context(), llm.NewInternalError("failed to encode response"))
+		return
+	}
+	save(resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	setRequestIDHeader(w, r.Context())
+	w.Write(out)
+}
+
+// stream relays a streamed chat as Responses API events and returns the
+// final usage and its cost. Errors before the first event get an error
+// response; later ones end the stream with an error event.
+func (h *ResponsesHandler) stream(w http.ResponseWriter, ctx context.Context, d *chatDispatch, req llm.ChatRequest, encoder *openaicompat.ResponsesStreamEncoder, save func(*llm.ChatResponse), log zerolog.Logger) (*llm.Usage, float64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Streaming not supported by response writer")
+		writeCompletionsError(w, ctx, llm.NewInternalError("Streaming not supported"))
+		return nil, 0
+	}
+
+	idleCtx, _ := ctx.(*idleTimeoutContext)
+	started := false
+
+	var finalUsage *llm.Usage
+	var finalCost float64
+
+	err := d.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if idleCtx != nil {
+			idleCtx.RecordActivity()
+		}
+
+		if !started {
+			startEventStream(w, ctx)
+			started = true
+		}
+
+		if chunk.Usage != nil {
+			finalUsage = chunk.Usage
+			finalCost, _ = d.price(ctx, h.prices, chunk.Provider, chunk.Usage, req.Model, chunk.Model)
+		}
+
+		if _, err := w.Write(encoder.Chunk(chunk)); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("Responses stream failed")
+		if !started {
+			writeCompletionsError(w, ctx, err)
+			return finalUsage, finalCost
+		}
+		w.Write(encoder.Error(err))
+		flusher.Flush()
+		return finalUsage, finalCost
+	}
+
+	if !started {
+		startEventStream(w, ctx)
+	}
+	events, resp := encoder.Finish()
+	w.Write(events)
+	flusher.Flush()
+	save(resp)
+	return finalUsage, finalCost
+}
+
+// forward sends the request to OpenAI unchanged and relays its answer. Usage
+// is read from the response, or from the response.completed event of a
+// stream, so the request is charged like any other.
+func (h *ResponsesHandler) forward(w http.ResponseWriter, r *http.Request, d *chatDispatch, req llm.ChatRequest, parsed *openaicompat.ResponsesRequest, body []byte, log zerolog.Logger) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if parsed.Stream {
+		ctx, cancel = newIdleTimeoutContext(r.Context(), 180*time.Second)
+	} else {
+		ctx, cancel = context.WithTimeout(r.Context(), 180*time.Second)
+	}
+	defer cancel()
+
+	upstream, err := llm.Respond(ctx, d.provider, llm.ResponsesRequest{
+		Model:       req.Model,
+		Body:        body,
+		Stream:      parsed.Stream,
+		APIKey:      req.APIKey,
+		Credentials: req.Credentials,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Responses request failed")
+		writeCompletionsError(w, r.Context(), err)
+		return
+	}
+	defer upstream.Close()
+
+	if !parsed.Stream {
+		data, err := io.ReadAll(io.LimitReader(upstream, 10*1024*1024))
+		if err != nil {
+			writeCompletionsError(w, r.Context(), llm.NewInternalError(fmt.Sprintf("failed to read response: %v", err)))
+			return
+		}
+		usage := openaicompat.ParseResponsesUsage(data)
+		cost, ok := d.price(ctx, h.prices, "", usage, req.Model, "")
+		if ok {
+			w.Header().Set("X-Request-Cost", strconv.FormatFloat(cost, 'f', -1, 64))
+		}
+		recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+
+		w.Header().Set("Content-Type", "application/json")
+		setRequestIDHeader(w, r.Context())
+		w.Write(data)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("Streaming not supported by response writer")
+		writeCompletionsError(w, r.Context(), llm.NewInternalError("Streaming not supported"))
+		return
+	}
+	startEventStream(w, r.Context())
+
+	idleCtx, _ := ctx.(*idleTimeoutContext)
+	var usage *llm.Usage
+	events := 0
+	open := false // An event is partly relayed
+
+	scanner := bufio.NewScanner(upstream)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 10*1024*1024)
+	for scanner.Scan() {
+		if idleCtx != nil {
+			idleCtx.RecordActivity()
+		}
+		line := scanner.Bytes()
+		if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			events++
+			if bytes.Contains(data, []byte(`"response.completed"`)) {
+				usage = openaicompat.ParseResponsesUsage(data)
+			}
+		}
+		w.Write(line)
+		w.Write([]byte("\n"))
+		open = len(line) != 0
+		if !open {
+			flusher.Flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Msg("Responses stream failed")
+		if open {
+			w.Write([]byte("\n"))
+		}
+		w.Write(openaicompat.ResponsesStreamError(llm.NewInternalError(fmt.Sprintf("failed to read stream: %v", err)), events))
+	}
+	flusher.Flush()
+
+	cost, _ := d.price(ctx, h.prices, "", usage, req.Model, "")
+	recordSpend(r.Context(), h.budgets, log, d.subjects, usage, cost)
+}
+
+// servedByOpenAI reports whether every deployment that may serve model is
+// OpenAI's, whether it is named directly or through an alias, pool or
+// fallback chain. Only then can a request be forwarded unchanged.
+func servedByOpenAI(model string) bool {
+	targets := providers.Targets(model)
+	for _, target := range targets {
+		if !strings.HasPrefix(target, "openai/") {
+			return false
+		}
+	}
+	return len(targets) > 0
+}
+
+func (h *ResponsesHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/responses", h.Responses)
+}
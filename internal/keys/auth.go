@@ -0,0 +1,109 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Principal is the authenticated caller of a gateway request.
+type Principal struct {
+	KeyID       string            // ID of the virtual key; empty for pass-through callers
+	Name        string            // Name of the virtual key
+	Team        string            // Team of the virtual key, if any
+	APIKey      string            // Raw upstream key in pass-through mode
+	Credentials map[string]string // Upstream keys by provider name for virtual keys
+}
+
+// Passthrough reports whether the caller supplied a raw provider key.
+func (p *Principal) Passthrough() bool {
+	return p.KeyID == ""
+}
+
+// ID identifies the caller for data kept on its behalf: the virtual key ID,
+// or a hash of the raw key of a pass-through caller.
+func (p *Principal) ID() string {
+	if !p.Passthrough() {
+		return "key:" + p.KeyID
+	}
+	sum := sha256.Sum256([]byte(p.APIKey))
+	return "passthrough:" + hex.EncodeToString(sum[:])
+}
+
+// Authenticator resolves bearer tokens into principals.
+type Authenticator struct {
+	store            *Store
+	allowPassthrough atomic.Bool
+}
+
+// NewAuthenticator creates an Authenticator. When allowPassthrough is set, tokens
+// that are not gateway keys are forwarded to providers unchanged.
+func NewAuthenticator(store *Store, allowPassthrough bool) *Authenticator {
+	a := &Authenticator{store: store}
+	a.allowPassthrough.Store(allowPassthrough)
+	return a
+}
+
+// SetAllowPassthrough changes whether raw provider keys are accepted.
+func (a *Authenticator) SetAllowPassthrough(allow bool) {
+	a.allowPassthrough.Store(allow)
+}
+
+// Authenticate extracts the bearer token from r and resolves it. Clients
+// that cannot send one may use the key headers of the Anthropic and Google
+// SDKs instead.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if token := SDKKeyHeader(r); token != "" {
+			return a.Resolve(token)
+		}
+		return nil, llm.NewUnauthorizedError("missing Authorization header")
+	}
+
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		return nil, llm.NewValidationError("invalid Authorization header format", "invalid_auth_format")
+	}
+	token := strings.TrimPrefix(authHeader, bearerPrefix)
+	if token == "" {
+		return nil, llm.NewUnauthorizedError("missing API key in Authorization header")
+	}
+
+	return a.Resolve(token)
+}
+
+// SDKKeyHeader returns the key sent in an x-api-key (Anthropic) or
+// x-goog-api-key (Google) header, if any.
+func SDKKeyHeader(r *http.Request) string {
+	if token := r.Header.Get("x-api-key"); token != "" {
+		return token
+	}
+	return r.Header.Get("x-goog-api-key")
+}
+
+// Resolve maps a raw token to a principal.
+func (a *Authenticator) Resolve(token string) (*Principal, error) {
+	if strings.HasPrefix(token, KeyPrefix) {
+		key, ok := a.store.Lookup(token)
+		if !ok {
+			return nil, llm.NewUnauthorizedError("invalid or revoked gateway API key")
+		}
+		return &Principal{
+			KeyID:       key.ID,
+			Name:        key.Name,
+			Team:        key.Team,
+			Credentials: key.Credentials,
+		}, nil
+	}
+
+	if !a.allowPassthrough.Load() {
+		return nil, llm.NewUnauthorizedError("invalid gateway API key; provider keys are not accepted")
+	}
+
+	return &Principal{APIKey: token}, nil
+}
@@ -0,0 +1,91 @@
+package keys
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+func TestAuthenticate(t *testing.T) {
+	s, err := NewStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, secret, err := s.Create("search", "team-a", map[string]string{"openai": "sk-openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthenticator(s, false)
+
+	authenticate := func(header, value string) (*Principal, error) {
+		r := httptest.NewRequest("POST", "/api/v1/chat/completions", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return auth.Authenticate(r)
+	}
+
+	// A gateway key, in any of the headers SDKs send it in.
+	for _, h := range []struct{ header, value string }{
+		{"Authorization", "Bearer " + secret},
+		{"x-api-key", secret},
+		{"x-goog-api-key", secret},
+	} {
+		p, err := authenticate(h.header, h.value)
+		if err != nil {
+			t.Fatalf("%s: %v", h.header, err)
+		}
+		if p.KeyID != key.ID || p.Team != "team-a" || p.Credentials["openai"] != "sk-openai" || p.Passthrough() {
+			t.Errorf("%s: principal = %+v, want the key's", h.header, p)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		header, value string
+		status        int
+	}{
+		{"no header", "", "", 401},
+		{"not bearer", "Authorization", "Basic " + secret, 400},
+		{"empty bearer", "Authorization", "Bearer ", 401},
+		{"unknown gateway key", "Authorization", "Bearer " + KeyPrefix + "0000", 401},
+		{"provider key without pass-through", "Authorization", "Bearer sk-openai", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticate(tt.header, tt.value)
+			var pe *llm.ProviderError
+			if !errors.As(err, &pe) || pe.StatusCode != tt.status {
+				t.Errorf("Authenticate = %v, want a %d", err, tt.status)
+			}
+		})
+	}
+
+	// With pass-through, other tokens are forwarded as they are, but gateway
+	// keys must still be valid.
+	auth.SetAllowPassthrough(true)
+	p, err := authenticate("Authorization", "Bearer sk-openai")
+	if err != nil || !p.Passthrough() || p.APIKey != "sk-openai" {
+		t.Errorf("pass-through = %+v, %v; want the raw key", p, err)
+	}
+	if _, err := authenticate("Authorization", "Bearer "+KeyPrefix+"0000"); err == nil {
+		t.Error("unknown gateway key passed through")
+	}
+}
+
+func TestPrincipalID(t *testing.T) {
+	key := &Principal{KeyID: "key_1"}
+	if got := key.ID(); got != "key:key_1" {
+		t.Errorf("ID = %q, want key:key_1", got)
+	}
+
+	a, b := &Principal{APIKey: "sk-a"}, &Principal{APIKey: "sk-b"}
+	if a.ID() == b.ID() || a.ID() != (&Principal{APIKey: "sk-a"}).ID() {
+		t.Error("pass-through IDs do not identify the raw key")
+	}
+	if len(a.ID()) != len("passthrough:")+64 {
+		t.Errorf("pass-through ID = %q, want a hash of the key", a.ID())
+	}
+}
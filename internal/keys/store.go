@@ -0,0 +1,246 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyPrefix marks tokens issued by the gateway.
+const KeyPrefix = "atz-"
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// VirtualKey is a gateway-issued API key mapped to upstream provider credentials.
+// Only a hash of the secret is stored; the plaintext is returned once on creation.
+type VirtualKey struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Team        string            `json:"team,omitempty"` // Budget group the key is charged to
+	Hash        string            `json:"hash"`
+	Hint        string            `json:"hint"`
+	Credentials map[string]string `json:"credentials"` // provider name -> upstream API key
+	CreatedAt   time.Time         `json:"createdAt"`
+	RevokedAt   *time.Time        `json:"revokedAt,omitempty"`
+}
+
+func (k *VirtualKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Providers returns the sorted provider names this key holds credentials for.
+func (k *VirtualKey) Providers() []string {
+	names := make([]string, 0, len(k.Credentials))
+	for name := range k.Credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Store holds virtual keys in memory and optionally persists them to a JSON file.
+type Store struct {
+	mu     sync.RWMutex
+	keys   map[string]*VirtualKey // by ID
+	byHash map[string]*VirtualKey
+	path   string
+}
+
+// NewStore creates a Store backed by the file at path. An empty path keeps keys
+// in memory only.
+//
+// The file holds the upstream credentials of every key in plaintext, so it is
+// written with mode 0600 and refused if anyone but its owner can access it.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		keys:   make(map[string]*VirtualKey),
+		byHash: make(map[string]*VirtualKey),
+		path:   path,
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store %s: %w", path, err)
+	}
+	// Windows has no permission bits to check.
+	if perm := info.Mode().Perm(); perm&0o077 != 0 && runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("key store %s holds upstream credentials in plaintext but is accessible by other users (mode %04o); run chmod 600 %s", path, perm, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store %s: %w", path, err)
+	}
+
+	var stored []*VirtualKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse key store %s: %w", path, err)
+	}
+	for _, k := range stored {
+		s.keys[k.ID] = k
+		s.byHash[k.Hash] = k
+	}
+
+	return s, nil
+}
+
+// Create issues a new virtual key and returns it together with its plaintext secret.
+func (s *Store) Create(name, team string, credentials map[string]string) (*VirtualKey, string, error) {
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret = KeyPrefix + secret
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	creds := make(map[string]string, len(credentials))
+	for provider, apiKey := range credentials {
+		creds[provider] = apiKey
+	}
+
+	key := &VirtualKey{
+		ID:          "key_" + id,
+		Name:        name,
+		Team:        team,
+		Hash:        hashSecret(secret),
+		Hint:        secret[:len(KeyPrefix)+4] + "..." + secret[len(secret)-4:],
+		Credentials: creds,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+
+	if err := s.persistLocked(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.Hash)
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+// List returns copies of all keys, including revoked ones, ordered by creation time.
+func (s *Store) List() []VirtualKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]VirtualKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		list = append(list, *k)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Revoke marks the key with the given ID as revoked. Revoking twice is a no-op.
+func (s *Store) Revoke(id string) (*VirtualKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if k.Revoked() {
+		copied := *k
+		return &copied, nil
+	}
+
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+
+	if err := s.persistLocked(); err != nil {
+		k.RevokedAt = nil
+		return nil, err
+	}
+
+	copied := *k
+	return &copied, nil
+}
+
+// Lookup finds an active key by its plaintext secret.
+func (s *Store) Lookup(secret string) (*VirtualKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.byHash[hashSecret(secret)]
+	if !ok || k.Revoked() {
+		return nil, false
+	}
+	copied := *k
+	return &copied, true
+}
+
+func (s *Store) persistLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	list := make([]*VirtualKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key store: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create key store directory: %w", err)
+		}
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated store.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace key store: %w", err)
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
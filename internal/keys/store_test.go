@@ -0,0 +1,150 @@
+package keys
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndLookup(t *testing.T) {
+	s, err := NewStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, secret, err := s.Create("search", "team-a", map[string]string{"openai": "sk-openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(secret, KeyPrefix) || len(secret) != len(KeyPrefix)+48 {
+		t.Errorf("secret = %q, want %s and 48 hex digits", secret, KeyPrefix)
+	}
+	if key.Hash != hashSecret(secret) || strings.Contains(key.Hash, secret[len(KeyPrefix):]) {
+		t.Errorf("hash = %q, want the SHA-256 of the secret", key.Hash)
+	}
+	if want := secret[:8] + "..." + secret[len(secret)-4:]; key.Hint != want {
+		t.Errorf("hint = %q, want %q", key.Hint, want)
+	}
+
+	got, ok := s.Lookup(secret)
+	if !ok || got.ID != key.ID || got.Team != "team-a" || got.Credentials["openai"] != "sk-openai" {
+		t.Errorf("Lookup = %+v, %v; want the key", got, ok)
+	}
+	for _, wrong := range []string{"", secret[:len(secret)-1], secret + "0", key.Hash} {
+		if _, ok := s.Lookup(wrong); ok {
+			t.Errorf("Lookup(%q) found a key", wrong)
+		}
+	}
+
+	// Keys are independent of the maps they were created from.
+	other, _, err := s.Create("other", "", map[string]string{"openai": "sk-other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == key.ID || other.Hash == key.Hash {
+		t.Error("two keys share an ID or hash")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, err := NewStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, secret, err := s.Create("search", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := s.Revoke(key.ID)
+	if err != nil || !revoked.Revoked() {
+		t.Fatalf("Revoke = %+v, %v; want a revoked key", revoked, err)
+	}
+	if _, ok := s.Lookup(secret); ok {
+		t.Error("revoked key still valid")
+	}
+
+	// Revoking again keeps the first revocation time.
+	again, err := s.Revoke(key.ID)
+	if err != nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("second Revoke = %+v, %v; want the first revocation", again, err)
+	}
+	if _, err := s.Revoke("key_missing"); err != ErrKeyNotFound {
+		t.Errorf("Revoke of an unknown key = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	// Revoked keys are still listed.
+	if list := s.List(); len(list) != 1 || !list[0].Revoked() {
+		t.Errorf("List = %+v, want the revoked key", list)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "keys.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, keptSecret, err := s.Create("kept", "", map[string]string{"openai": "sk-openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := s.Create("revoked", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), keptSecret) {
+		t.Error("key file contains a plaintext gateway key")
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Errorf("key file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Lookup(keptSecret); !ok || got.ID != kept.ID || got.Credentials["openai"] != "sk-openai" {
+		t.Errorf("Lookup after reopening = %+v, %v; want the key", got, ok)
+	}
+	if _, ok := reopened.Lookup(revokedSecret); ok {
+		t.Error("revoked key valid after reopening")
+	}
+	if list := reopened.List(); len(list) != 2 || list[0].ID != kept.ID {
+		t.Errorf("List after reopening = %+v, want both keys in creation order", list)
+	}
+}
+
+func TestStoreRefusesSharedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte("[]"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStore(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("NewStore of a world-readable file = %v, want an error", err)
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(path); err != nil {
+		t.Errorf("NewStore of an owner-only file = %v", err)
+	}
+}
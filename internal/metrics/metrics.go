@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sony/gobreaker"
+)
+
+const namespace = "gateway"
+
+// Registry holds every gateway metric plus the Go runtime and process
+// collectors. It is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Upstream requests by provider, model, operation and status.",
+	}, []string{"provider", "model", "operation", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Total upstream request latency, including retries. For streams, until the last chunk.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 180},
+	}, []string{"provider", "model", "operation"})
+
+	timeToFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "time_to_first_token_seconds",
+		Help:      "Time from sending a streaming request to the first content or tool-call delta.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30},
+	}, []string{"provider", "model"})
+
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens reported by providers, by direction (prompt or completion).",
+	}, []string{"provider", "model", "direction"})
+
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retries_total",
+		Help:      "Retry attempts made after a retryable provider error.",
+	}, []string{"provider"})
+
+	fallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fallbacks_total",
+		Help:      "Hops from a failed provider to the next one in a fallback chain.",
+	}, []string{"from", "to"})
+
+	ejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pool_ejections_total",
+		Help:      "Deployments taken out of a pool's rotation after repeated 429 or 5xx errors.",
+	}, []string{"pool", "deployment"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the rate limiter, by the window that was exhausted.",
+	}, []string{"window"})
+
+	breakerStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "circuit_breaker_state"),
+		"Circuit breaker state per provider: 0 closed, 1 half-open, 2 open.",
+		[]string{"provider"}, nil,
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests,
+		requestDuration,
+		timeToFirstToken,
+		tokens,
+		retries,
+		fallbacks,
+		ejections,
+		rateLimited,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RecordRetry counts a retry of a request to provider.
+func RecordRetry(provider string) {
+	retries.WithLabelValues(provider).Inc()
+}
+
+// RecordFallback counts a hop along a fallback chain.
+func RecordFallback(from, to string) {
+	fallbacks.WithLabelValues(from, to).Inc()
+}
+
+// RecordEjection counts a deployment ejected from pool.
+func RecordEjection(pool, deployment string) {
+	ejections.WithLabelValues(pool, deployment).Inc()
+}
+
+// RecordRateLimited counts a request rejected by the rate limiter.
+func RecordRateLimited(window string) {
+	rateLimited.WithLabelValues(window).Inc()
+}
+
+// RegisterCircuitBreakers exports the breaker states returned by states on
+// every scrape. states is called again each time, so breakers rebuilt on a
+// config reload are picked up.
+func RegisterCircuitBreakers(states func() map[string]gobreaker.State) {
+	Registry.MustRegister(prometheus.CollectorFunc(func(ch chan<- prometheus.Metric) {
+		for provider, state := range states() {
+			ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(state), provider)
+		}
+	}))
+}
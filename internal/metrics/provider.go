@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/circuitbreaker"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// otherModel is the model label of requests for models that known does not
+// recognize.
+const otherModel = "other"
+
+type instrumentedProvider struct {
+	provider llm.Provider
+	known    func(model string) bool
+}
+
+// NewInstrumentedProvider records request counts, latency and token usage for
+// every call to provider. Wrap it outside retries so one client request is
+// counted once per provider. Model names come from clients, so only those
+// known reports are used as labels, and the rest are counted as "other".
+func NewInstrumentedProvider(provider llm.Provider, known func(model string) bool) llm.Provider {
+	return &instrumentedProvider{provider: provider, known: known}
+}
+
+// model returns the model label for a request for model.
+func (p *instrumentedProvider) model(model string) string {
+	if p.known(model) {
+		return model
+	}
+	return otherModel
+}
+
+func (p *instrumentedProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *instrumentedProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	model := p.model(req.Model)
+	start := time.Now()
+	resp, err := p.provider.Chat(ctx, req)
+	p.observe("chat", model, start, err)
+	if err == nil {
+		p.observeUsage(model, resp.Usage)
+	}
+	return resp, err
+}
+
+func (p *instrumentedProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	model := p.model(req.Model)
+	start := time.Now()
+	firstToken := false
+	var usage *llm.Usage
+
+	err := p.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if !firstToken && hasDelta(chunk) {
+			firstToken = true
+			timeToFirstToken.WithLabelValues(p.provider.Name(), model).Observe(time.Since(start).Seconds())
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		return callback(chunk)
+	})
+
+	p.observe("stream", model, start, err)
+	p.observeUsage(model, usage)
+	return err
+}
+
+func (p *instrumentedProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	model := p.model(req.Model)
+	start := time.Now()
+	resp, err := llm.Embed(ctx, p.provider, req)
+	p.observe("embeddings", model, start, err)
+	if err == nil {
+		p.observeUsage(model, &resp.Usage)
+	}
+	return resp, err
+}
+
+// Respond counts a forwarded Responses API request. Its usage is in the
+// upstream body, which is not parsed here.
+func (p *instrumentedProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	start := time.Now()
+	body, err := llm.Respond(ctx, p.provider, req)
+	p.observe("responses", p.model(req.Model), start, err)
+	return body, err
+}
+
+func (p *instrumentedProvider) observe(operation, model string, start time.Time, err error) {
+	name := p.provider.Name()
+	requests.WithLabelValues(name, model, operation, status(err)).Inc()
+	requestDuration.WithLabelValues(name, model, operation).Observe(time.Since(start).Seconds())
+}
+
+func (p *instrumentedProvider) observeUsage(model string, usage *llm.Usage) {
+	if usage == nil {
+		return
+	}
+	name := p.provider.Name()
+	tokens.WithLabelValues(name, model, "prompt").Add(float64(usage.PromptTokens))
+	tokens.WithLabelValues(name, model, "completion").Add(float64(usage.CompletionTokens))
+}
+
+// hasDelta reports whether chunk carries generated content rather than only a
+// role, finish reason or usage.
+func hasDelta(chunk *llm.StreamChunk) bool {
+	for _, choice := range chunk.Choices {
+		if (choice.Delta.Content != nil && *choice.Delta.Content != "") || len(choice.Delta.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// status is the status label for err: the HTTP status of provider errors, or
+// a short reason for failures that never reached the provider.
+func status(err error) string {
+	if err == nil {
+		return "200"
+	}
+	var pe *llm.ProviderError
+	switch {
+	case errors.As(err, &pe):
+		return strconv.Itoa(pe.StatusCode)
+	case circuitbreaker.IsCircuitOpen(err):
+		return "circuit_open"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "error"
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var Log zerolog.Logger
@@ -36,16 +37,19 @@ func Init() {
 	}
 }
 
-// FromContext returns a logger with request ID from context if available
+// FromContext returns a logger with the request ID and trace ID from context
+// if available
 func FromContext(ctx context.Context) zerolog.Logger {
 	if ctx == nil {
 		return Log
 	}
 
-	requestID := middleware.GetReqID(ctx)
-	if requestID != "" {
-		return Log.With().Str("request_id", requestID).Logger()
+	log := Log.With()
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		log = log.Str("request_id", requestID)
 	}
-
-	return Log
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		log = log.Str("trace_id", sc.TraceID().String())
+	}
+	return log.Logger()
 }
@@ -0,0 +1,212 @@
+// Package sigv4 signs HTTP requests with AWS Signature Version 4.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials are an AWS access key pair. SessionToken and Expires are set
+// for temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+// Signer signs requests for one service in one region.
+type Signer struct {
+	Service string
+	Region  string
+	// DisableURIPathEscaping signs the path as sent instead of escaping it a
+	// second time. S3 and the AWS test suite expect this; other services,
+	// Bedrock included, expect the second escape.
+	DisableURIPathEscaping bool
+}
+
+// ignoredHeaders are left unsigned because proxies and tracing may add or
+// change them after signing.
+var ignoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"content-length":  true,
+	"traceparent":     true,
+	"tracestate":      true,
+	"baggage":         true,
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to
+// req. body must be the exact request body. Every header already on req is
+// signed, so set them all before calling Sign. An X-Amz-Content-Sha256
+// header, such as UNSIGNED-PAYLOAD, is signed in place of the body's hash.
+func (s Signer) Sign(req *http.Request, body []byte, creds Credentials, now time.Time) {
+	s.sign(req, body, creds, now)
+}
+
+// sign does the work of Sign and returns the canonical request and string
+// to sign it derived the signature from.
+func (s Signer) sign(req *http.Request, body []byte, creds Credentials, now time.Time) (canonicalRequest, stringToSign string) {
+	now = now.UTC()
+	amzDate := now.Format(timeFormat)
+	date := now.Format(dateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = hashHex(body)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest = strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign = strings.Join([]string{algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return canonicalRequest, stringToSign
+}
+
+func (s Signer) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.DisableURIPathEscaping {
+		return path
+	}
+	return escape(path, false)
+}
+
+// canonicalQuery sorts the query parameters by key, then value, and escapes
+// both.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders returns the canonical header block, one "name:value\n"
+// line per header, and the list of signed header names.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": host(req)}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if ignoredHeaders[name] || name == "host" {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(values[name])
+		b.WriteByte('\n')
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// host returns the Host header as sent, without a default port.
+func host(req *http.Request) string {
+	h := req.Host
+	if h == "" {
+		h = req.URL.Host
+	}
+	name, port, err := net.SplitHostPort(h)
+	if err != nil {
+		return h
+	}
+	if (port == "443" && req.URL.Scheme == "https") || (port == "80" && req.URL.Scheme == "http") {
+		return name
+	}
+	return h
+}
+
+// escape percent-encodes every byte outside the RFC 3986 unreserved set.
+// Slashes are kept unless encodeSlash is set.
+func escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
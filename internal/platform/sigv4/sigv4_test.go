@@ -0,0 +1,191 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cases from the AWS Signature Version 4 test suite, which signs for service
+// "service" in us-east-1 unless noted, with the suite's example credentials.
+// The unsigned-payload and escaped-path cases are not in the suite; their
+// values come from an independent implementation of the algorithm.
+func TestSign(t *testing.T) {
+	creds := Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		header  map[string]string
+		body    string
+		service string // Defaults to "service"
+		escape  bool   // Escape the path a second time, as for Bedrock
+
+		// The canonical request and string to sign are only compared when
+		// set.
+		canonicalRequest string
+		stringToSign     string
+		signature        string
+	}{
+		{
+			name:   "get-vanilla",
+			method: "GET",
+			url:    "https://example.amazonaws.com/",
+			canonicalRequest: "GET\n/\n\n" +
+				"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"host;x-amz-date\n" + emptyHash,
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"bb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			// An empty path is signed as "/", like get-vanilla.
+			name:   "empty path",
+			method: "GET",
+			url:    "https://example.amazonaws.com",
+			canonicalRequest: "GET\n/\n\n" +
+				"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"host;x-amz-date\n" + emptyHash,
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-vanilla-query-order-key-case",
+			method: "GET",
+			url:    "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			canonicalRequest: "GET\n/\nParam1=value1&Param2=value2\n" +
+				"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"host;x-amz-date\n" + emptyHash,
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"816cd5b414d056048ba4f7c5386d6e0533120fb1fcfa93762cf0fc39e2cf19e0",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:      "get-vanilla-empty-query-key",
+			method:    "GET",
+			url:       "https://example.amazonaws.com/?Param1=value1",
+			signature: "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb",
+		},
+		{
+			name:   "get-vanilla-query-unreserved",
+			method: "GET",
+			url: "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz" +
+				"=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+			signature: "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197",
+		},
+		{
+			name:      "get-utf8",
+			method:    "GET",
+			url:       "https://example.amazonaws.com/%E1%88%B4",
+			signature: "8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85",
+		},
+		{
+			name:      "escaped path",
+			method:    "GET",
+			url:       "https://example.amazonaws.com/%E1%88%B4",
+			escape:    true,
+			signature: "697b34846207a3f72246f99d74ae1ee4fe54f44bb06730c58a0d339eb079596d",
+		},
+		{
+			name:      "post-vanilla",
+			method:    "POST",
+			url:       "https://example.amazonaws.com/",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:   "post-x-www-form-urlencoded",
+			method: "POST",
+			url:    "https://example.amazonaws.com/",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:   "Param1=value1",
+			canonicalRequest: "POST\n/\n\n" +
+				"content-type:application/x-www-form-urlencoded\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-date\n9095672bbd1f56dfc5b65f3e153adc8731a4a654192329106275f4c7b24d0b6e",
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"42a5e5bb34198acb3e84da4f085bb7927f2bc277ca766e6d19c73c2154021281",
+			signature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name:   "unsigned payload",
+			method: "GET",
+			url:    "https://example.amazonaws.com/",
+			header: map[string]string{"X-Amz-Content-Sha256": "UNSIGNED-PAYLOAD"},
+			body:   "ignored",
+			canonicalRequest: "GET\n/\n\n" +
+				"host:example.amazonaws.com\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:20150830T123600Z\n\n" +
+				"host;x-amz-content-sha256;x-amz-date\nUNSIGNED-PAYLOAD",
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"f3effcc3779858bbdbf0a9746397a6595bf5d554bbedf960e0b812fc47014919",
+			signature: "9b02fb7b5d0076fa47a0adda28c71e74ba4588334bc0139b8cd6bb87f16afe16",
+		},
+		{
+			// The IAM ListUsers example from the AWS documentation.
+			name:    "iam ListUsers",
+			method:  "GET",
+			url:     "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			header:  map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+			service: "iam",
+			canonicalRequest: "GET\n/\nAction=ListUsers&Version=2010-05-08\n" +
+				"content-type:application/x-www-form-urlencoded; charset=utf-8\nhost:iam.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-date\n" + emptyHash,
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/iam/aws4_request\n" +
+				"f536975d06c0309214f805bb90ccff089219ecd68b2577efef23edd43b7e1a59",
+			signature: "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			service := tt.service
+			if service == "" {
+				service = "service"
+			}
+			signer := Signer{Service: service, Region: "us-east-1", DisableURIPathEscaping: !tt.escape}
+
+			canonicalRequest, stringToSign := signer.sign(req, []byte(tt.body), creds, now)
+
+			if tt.canonicalRequest != "" && canonicalRequest != tt.canonicalRequest {
+				t.Errorf("canonical request:\n%s\nwant:\n%s", canonicalRequest, tt.canonicalRequest)
+			}
+			if tt.stringToSign != "" && stringToSign != tt.stringToSign {
+				t.Errorf("string to sign:\n%s\nwant:\n%s", stringToSign, tt.stringToSign)
+			}
+			signedHeaders := strings.Split(canonicalRequest, "\n")
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/" + service + "/aws4_request, " +
+				"SignedHeaders=" + signedHeaders[len(signedHeaders)-2] + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}
+
+	canonicalRequest, _ := Signer{Service: "service", Region: "us-east-1"}.sign(req, nil, creds, time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token = %q, want %q", got, "token")
+	}
+	if !strings.Contains(canonicalRequest, "\nx-amz-security-token:token\n") {
+		t.Errorf("session token is not signed:\n%s", canonicalRequest)
+	}
+}
@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedProvider struct {
+	provider llm.Provider
+}
+
+// NewTracedProvider wraps provider so every call gets a span carrying the
+// GenAI semantic-convention attributes: model, token usage and finish
+// reasons. The upstream HTTP call becomes its child.
+func NewTracedProvider(provider llm.Provider) llm.Provider {
+	return &tracedProvider{provider: provider}
+}
+
+func (p *tracedProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *tracedProvider) start(ctx context.Context, operation attribute.KeyValue, model string, stream bool) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, operation.Value.AsString()+" "+model,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			operation,
+			semconv.GenAIProviderNameKey.String(p.provider.Name()),
+			semconv.GenAIRequestModel(model),
+			attribute.Bool("gen_ai.request.stream", stream),
+		),
+	)
+}
+
+func (p *tracedProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	ctx, span := p.start(ctx, semconv.GenAIOperationNameChat, req.Model, false)
+	defer span.End()
+	setRequestAttributes(span, req.Options)
+
+	resp, err := p.provider.Chat(ctx, req)
+	if err != nil {
+		RecordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.GenAIResponseID(resp.ID), semconv.GenAIResponseModel(resp.Model))
+	if resp.FinishReason != "" {
+		span.SetAttributes(semconv.GenAIResponseFinishReasons(resp.FinishReason))
+	}
+	setUsageAttributes(span, resp.Usage)
+	return resp, nil
+}
+
+func (p *tracedProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	ctx, span := p.start(ctx, semconv.GenAIOperationNameChat, req.Model, true)
+	defer span.End()
+	setRequestAttributes(span, req.Options)
+
+	var id, model string
+	var finishReasons []string
+	var usage *llm.Usage
+
+	err := p.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if id == "" {
+			id, model = chunk.ID, chunk.Model
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReasons = append(finishReasons, *choice.FinishReason)
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		return callback(chunk)
+	})
+
+	if id != "" {
+		span.SetAttributes(semconv.GenAIResponseID(id))
+	}
+	if model != "" {
+		span.SetAttributes(semconv.GenAIResponseModel(model))
+	}
+	if len(finishReasons) > 0 {
+		span.SetAttributes(semconv.GenAIResponseFinishReasons(finishReasons...))
+	}
+	setUsageAttributes(span, usage)
+	RecordError(span, err)
+	return err
+}
+
+func (p *tracedProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	ctx, span := p.start(ctx, semconv.GenAIOperationNameEmbeddings, req.Model, false)
+	defer span.End()
+
+	resp, err := llm.Embed(ctx, p.provider, req)
+	if err != nil {
+		RecordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.GenAIResponseModel(resp.Model))
+	if len(resp.Embeddings) > 0 {
+		span.SetAttributes(semconv.GenAIEmbeddingsDimensionCount(len(resp.Embeddings[0])))
+	}
+	setUsageAttributes(span, &resp.Usage)
+	return resp, nil
+}
+
+// Respond traces a forwarded Responses API request until the upstream has
+// answered; the body is read after the span ends.
+func (p *tracedProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	ctx, span := p.start(ctx, semconv.GenAIOperationNameChat, req.Model, req.Stream)
+	defer span.End()
+
+	body, err := llm.Respond(ctx, p.provider, req)
+	RecordError(span, err)
+	return body, err
+}
+
+func setRequestAttributes(span trace.Span, opts llm.ChatOptions) {
+	if opts.MaxTokens != nil {
+		span.SetAttributes(semconv.GenAIRequestMaxTokens(*opts.MaxTokens))
+	}
+	if opts.Temperature != nil {
+		span.SetAttributes(semconv.GenAIRequestTemperature(float64(*opts.Temperature)))
+	}
+	if opts.TopP != nil {
+		span.SetAttributes(semconv.GenAIRequestTopP(float64(*opts.TopP)))
+	}
+}
+
+func setUsageAttributes(span trace.Span, usage *llm.Usage) {
+	if usage == nil {
+		return
+	}
+	span.SetAttributes(
+		semconv.GenAIUsageInputTokens(usage.PromptTokens),
+		semconv.GenAIUsageOutputTokens(usage.CompletionTokens),
+	)
+	if usage.CachedTokens > 0 {
+		span.SetAttributes(semconv.GenAIUsageCacheReadInputTokens(usage.CachedTokens))
+	}
+	if usage.CacheWriteTokens > 0 {
+		span.SetAttributes(semconv.GenAIUsageCacheCreationInputTokens(usage.CacheWriteTokens))
+	}
+}
@@ -0,0 +1,140 @@
+package pricing
+
+// defaultPrices are published list prices in USD per million tokens. Models
+// without an entry are not priced; add or correct entries with "pricing" in
+// the config file rather than editing this table.
+var defaultPrices = map[string]Price{
+	// OpenAI
+	"openai/gpt-5.2":       {Input: 1.75, Output: 14, CachedInput: 0.175},
+	"openai/gpt-4.5":       {Input: 75, Output: 150, CachedInput: 37.5},
+	"openai/gpt-4.1":       {Input: 2, Output: 8, CachedInput: 0.5},
+	"openai/gpt-4.1-mini":  {Input: 0.4, Output: 1.6, CachedInput: 0.1},
+	"openai/gpt-4o":        {Input: 2.5, Output: 10, CachedInput: 1.25},
+	"openai/gpt-4o-mini":   {Input: 0.15, Output: 0.6, CachedInput: 0.075},
+	"openai/o1":            {Input: 15, Output: 60, CachedInput: 7.5},
+	"openai/o1-mini":       {Input: 1.1, Output: 4.4, CachedInput: 0.55},
+	"openai/o3":            {Input: 2, Output: 8, CachedInput: 0.5},
+	"openai/o3-mini":       {Input: 1.1, Output: 4.4, CachedInput: 0.55},
+	"openai/o4-mini":       {Input: 1.1, Output: 4.4, CachedInput: 0.275},
+	"openai/gpt-4-turbo":   {Input: 10, Output: 30},
+	"openai/gpt-4":         {Input: 30, Output: 60},
+	"openai/gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+	"azure/gpt-4o":         {Input: 2.5, Output: 10, CachedInput: 1.25},
+	"azure/gpt-4o-mini":    {Input: 0.15, Output: 0.6, CachedInput: 0.075},
+	"azure/gpt-4-turbo":    {Input: 10, Output: 30},
+	"azure/gpt-4":          {Input: 30, Output: 60},
+	"azure/gpt-35-turbo":   {Input: 0.5, Output: 1.5},
+
+	// Anthropic: cache writes cost 1.25x input, cache reads 0.1x
+	"anthropic/claude-opus-4-6":   {Input: 5, Output: 25, CachedInput: 0.5, CacheWrite: 6.25},
+	"anthropic/claude-opus-4-5":   {Input: 5, Output: 25, CachedInput: 0.5, CacheWrite: 6.25},
+	"anthropic/claude-opus-4-1":   {Input: 15, Output: 75, CachedInput: 1.5, CacheWrite: 18.75},
+	"anthropic/claude-sonnet-4-6": {Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75},
+	"anthropic/claude-sonnet-4-5": {Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75},
+	"anthropic/claude-sonnet-4":   {Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75},
+	"anthropic/claude-sonnet-3-7": {Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75},
+	"anthropic/claude-haiku-4-5":  {Input: 1, Output: 5, CachedInput: 0.1, CacheWrite: 1.25},
+	"anthropic/claude-haiku-3-5":  {Input: 0.8, Output: 4, CachedInput: 0.08, CacheWrite: 1},
+
+	// AWS Bedrock (on-demand, us-east-1)
+	"bedrock/anthropic.claude-3-opus-20240229":   {Input: 15, Output: 75},
+	"bedrock/anthropic.claude-3-sonnet-20240229": {Input: 3, Output: 15},
+	"bedrock/anthropic.claude-3-haiku-20240307":  {Input: 0.25, Output: 1.25},
+	"bedrock/anthropic.claude-sonnet-4-20250514": {Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75},
+	"bedrock/meta.llama3-3-70b-instruct":         {Input: 0.72, Output: 0.72},
+	"bedrock/meta.llama3-1-405b-instruct":        {Input: 2.4, Output: 2.4},
+	"bedrock/meta.llama3-1-70b-instruct":         {Input: 0.72, Output: 0.72},
+	"bedrock/meta.llama3-1-8b-instruct":          {Input: 0.22, Output: 0.22},
+	"bedrock/mistral.mistral-large-2407":         {Input: 2, Output: 6},
+	"bedrock/mistral.mixtral-8x7b-instruct":      {Input: 0.45, Output: 0.7},
+	"bedrock/amazon.titan-text-express":          {Input: 0.2, Output: 0.6},
+	"bedrock/ai21.jamba-1-5-large":               {Input: 2, Output: 8},
+
+	// Google (prompts up to 200k tokens)
+	"gemini/gemini-3-pro-preview":   {Input: 2, Output: 12, CachedInput: 0.2},
+	"gemini/gemini-3-flash-preview": {Input: 0.5, Output: 3, CachedInput: 0.05},
+	"gemini/gemini-2.5-pro":         {Input: 1.25, Output: 10, CachedInput: 0.125},
+	"gemini/gemini-2.5-flash":       {Input: 0.3, Output: 2.5, CachedInput: 0.03},
+	"gemini/gemini-2.0-flash":       {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"gemini/gemini-2.0-flash-lite":  {Input: 0.075, Output: 0.3},
+	"gemini/gemini-1.5-pro":         {Input: 1.25, Output: 5},
+	"gemini/gemini-1.5-flash":       {Input: 0.075, Output: 0.3},
+	"vertex/gemini-2.5-pro":         {Input: 1.25, Output: 10, CachedInput: 0.125},
+	"vertex/gemini-2.5-flash":       {Input: 0.3, Output: 2.5, CachedInput: 0.03},
+	"vertex/gemini-2.0-flash":       {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"vertex/gemini-1.5-pro":         {Input: 1.25, Output: 5},
+
+	// xAI
+	"xai/grok-4":                  {Input: 3, Output: 15, CachedInput: 0.75},
+	"xai/grok-4-fast":             {Input: 0.2, Output: 0.5, CachedInput: 0.05},
+	"xai/grok-4.1-fast-reasoning": {Input: 0.2, Output: 0.5, CachedInput: 0.05},
+	"xai/grok-3":                  {Input: 3, Output: 15, CachedInput: 0.75},
+	"xai/grok-3-mini":             {Input: 0.3, Output: 0.5, CachedInput: 0.075},
+	"xai/grok-2":                  {Input: 2, Output: 10},
+	"xai/grok-2-vision":           {Input: 2, Output: 10},
+
+	// DeepSeek
+	"deepseek/deepseek-chat":     {Input: 0.28, Output: 0.42, CachedInput: 0.028},
+	"deepseek/deepseek-reasoner": {Input: 0.28, Output: 0.42, CachedInput: 0.028},
+
+	// Mistral
+	"mistral/mistral-large-3":    {Input: 0.5, Output: 1.5},
+	"mistral/mistral-medium-3-1": {Input: 0.4, Output: 2},
+	"mistral/mistral-small-3-2":  {Input: 0.1, Output: 0.3},
+	"mistral/codestral-2508":     {Input: 0.3, Output: 0.9},
+	"mistral/ministral-8b":       {Input: 0.1, Output: 0.1},
+	"mistral/ministral-3b":       {Input: 0.04, Output: 0.04},
+	"mistral/pixtral-large":      {Input: 2, Output: 6},
+
+	// Groq
+	"groq/llama-3.3-70b-versatile": {Input: 0.59, Output: 0.79},
+	"groq/llama-3.1-8b-instant":    {Input: 0.05, Output: 0.08},
+	"groq/openai-gpt-oss-120b":     {Input: 0.15, Output: 0.75},
+	"groq/openai-gpt-oss-20b":      {Input: 0.075, Output: 0.3},
+
+	// Together AI
+	"together/meta-llama/Llama-3.3-70B-Instruct-Turbo":       {Input: 0.88, Output: 0.88},
+	"together/meta-llama/Meta-Llama-3.1-8B-Instruct-Turbo":   {Input: 0.18, Output: 0.18},
+	"together/meta-llama/Meta-Llama-3.1-405B-Instruct-Turbo": {Input: 3.5, Output: 3.5},
+	"together/Qwen/Qwen2.5-72B-Instruct-Turbo":               {Input: 1.2, Output: 1.2},
+	"together/Qwen/Qwen2.5-Coder-32B-Instruct":               {Input: 0.8, Output: 0.8},
+	"together/deepseek-ai/DeepSeek-V3":                       {Input: 1.25, Output: 1.25},
+	"together/deepseek-ai/DeepSeek-R1":                       {Input: 3, Output: 7},
+
+	// Fireworks AI
+	"fireworks/accounts/fireworks/models/llama-v3p3-70b-instruct":  {Input: 0.9, Output: 0.9},
+	"fireworks/accounts/fireworks/models/llama-v3p1-8b-instruct":   {Input: 0.2, Output: 0.2},
+	"fireworks/accounts/fireworks/models/llama-v3p1-405b-instruct": {Input: 3, Output: 3},
+	"fireworks/accounts/fireworks/models/qwen2p5-72b-instruct":     {Input: 0.9, Output: 0.9},
+	"fireworks/accounts/fireworks/models/deepseek-v3":              {Input: 0.9, Output: 0.9},
+	"fireworks/accounts/fireworks/models/deepseek-r1":              {Input: 3, Output: 8},
+
+	// DeepInfra
+	"deepinfra/meta-llama/Llama-3.3-70B-Instruct-Turbo": {Input: 0.13, Output: 0.39},
+	"deepinfra/meta-llama/Meta-Llama-3.1-8B-Instruct":   {Input: 0.03, Output: 0.05},
+	"deepinfra/meta-llama/Meta-Llama-3.1-405B-Instruct": {Input: 0.8, Output: 0.8},
+	"deepinfra/Qwen/Qwen2.5-72B-Instruct":               {Input: 0.23, Output: 0.4},
+	"deepinfra/Qwen/Qwen2.5-Coder-32B-Instruct":         {Input: 0.07, Output: 0.16},
+	"deepinfra/deepseek-ai/DeepSeek-V3":                 {Input: 0.49, Output: 0.89},
+	"deepinfra/deepseek-ai/DeepSeek-R1":                 {Input: 0.75, Output: 2.4},
+
+	// Nebius AI Studio
+	"nebius/meta-llama/Llama-3.3-70B-Instruct":       {Input: 0.13, Output: 0.4},
+	"nebius/meta-llama/Meta-Llama-3.1-8B-Instruct":   {Input: 0.02, Output: 0.06},
+	"nebius/meta-llama/Meta-Llama-3.1-405B-Instruct": {Input: 1, Output: 3},
+	"nebius/Qwen/Qwen2.5-72B-Instruct":               {Input: 0.13, Output: 0.4},
+	"nebius/Qwen/Qwen2.5-Coder-32B-Instruct":         {Input: 0.06, Output: 0.18},
+	"nebius/deepseek-ai/DeepSeek-V3":                 {Input: 0.5, Output: 1.5},
+	"nebius/deepseek-ai/DeepSeek-R1":                 {Input: 0.8, Output: 2.4},
+
+	// Perplexity (token prices only; per-request search fees are not included)
+	"perplexity/sonar":               {Input: 1, Output: 1},
+	"perplexity/sonar-pro":           {Input: 3, Output: 15},
+	"perplexity/sonar-reasoning":     {Input: 1, Output: 5},
+	"perplexity/sonar-reasoning-pro": {Input: 2, Output: 8},
+
+	// Cohere
+	"cohere/command-a":      {Input: 2.5, Output: 10},
+	"cohere/command-r-plus": {Input: 2.5, Output: 10},
+	"cohere/command-r":      {Input: 0.15, Output: 0.6},
+}
@@ -0,0 +1,116 @@
+package pricing
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Price is the list price of a model in USD per million tokens. A zero
+// CachedInput or CacheWrite price means those tokens cost the same as Input.
+type Price struct {
+	Input       float64
+	Output      float64
+	CachedInput float64
+	CacheWrite  float64
+}
+
+// Cost returns the price of usage in USD.
+func (p Price) Cost(u llm.Usage) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	writePrice := p.CacheWrite
+	if writePrice == 0 {
+		writePrice = p.Input
+	}
+
+	uncached := u.PromptTokens - u.CachedTokens - u.CacheWriteTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+
+	total := float64(uncached)*p.Input +
+		float64(u.CachedTokens)*cachedPrice +
+		float64(u.CacheWriteTokens)*writePrice +
+		float64(u.CompletionTokens)*p.Output
+	return total / 1_000_000
+}
+
+// Catalog maps "provider/model" IDs, as listed by GET /api/v1/models, to
+// prices. It is safe for concurrent use and can be replaced on config reload.
+type Catalog struct {
+	prices atomic.Pointer[map[string]Price]
+}
+
+// NewCatalog returns the built-in prices with overrides applied on top.
+func NewCatalog(overrides map[string]Price) *Catalog {
+	c := &Catalog{}
+	c.Update(overrides)
+	return c
+}
+
+// Update replaces the overrides applied on top of the built-in prices.
+func (c *Catalog) Update(overrides map[string]Price) {
+	prices := make(map[string]Price, len(defaultPrices)+len(overrides))
+	for id, p := range defaultPrices {
+		prices[id] = p
+	}
+	for id, p := range overrides {
+		prices[id] = p
+	}
+	c.prices.Store(&prices)
+}
+
+// Lookup finds the price for a model served by provider. Versions of a model
+// ("gpt-4o-2024-08-06", "claude-3-haiku-20240307-v1:0") use the price of the
+// longest catalog ID they extend with a version suffix.
+func (c *Catalog) Lookup(provider, model string) (Price, bool) {
+	prices := *c.prices.Load()
+
+	id := provider + "/" + strings.TrimPrefix(model, provider+"/")
+	if p, ok := prices[id]; ok {
+		return p, true
+	}
+
+	var best string
+	for candidate := range prices {
+		if len(candidate) > len(best) && extends(id, candidate) {
+			best = candidate
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return prices[best], true
+}
+
+// Cost prices usage for the first of models that is in the catalog.
+func (c *Catalog) Cost(provider string, usage llm.Usage, models ...string) (float64, bool) {
+	for _, model := range models {
+		if model == "" {
+			continue
+		}
+		if p, ok := c.Lookup(provider, model); ok {
+			return p.Cost(usage), true
+		}
+	}
+	return 0, false
+}
+
+// versionSuffix matches what providers append to a model ID to pin a
+// version of it: dates ("-2024-08-06", "-20240307"), snapshot numbers
+// ("-0613", "-001"), Bedrock versions ("-v1:0") and tags after "@" or ":".
+// Other suffixes, as in "o1-pro" or "gpt-4.1-nano", name different models.
+var versionSuffix = regexp.MustCompile(`^(-\d{4}-\d{2}-\d{2}|-\d{3,}|-v\d+)*([@:].*)?$`)
+
+// extends reports whether id is base followed by a version suffix.
+func extends(id, base string) bool {
+	if !strings.HasPrefix(id, base) || len(id) == len(base) {
+		return false
+	}
+	return versionSuffix.MatchString(id[len(base):])
+}
@@ -0,0 +1,137 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+func TestLookup(t *testing.T) {
+	c := NewCatalog(map[string]Price{
+		"bedrock/anthropic.claude-3-5-haiku": {Input: 0.8, Output: 4},
+		"vertex/claude-sonnet-4":             {Input: 3, Output: 15},
+		"ollama/llama3":                      {Input: 0.01, Output: 0.01},
+	})
+
+	tests := []struct {
+		provider string
+		model    string
+		want     string // Catalog ID whose price is used, or "" for none
+	}{
+		{"openai", "gpt-4o", "openai/gpt-4o"},
+		{"openai", "openai/gpt-4o", "openai/gpt-4o"},
+		{"openai", "gpt-4o-mini", "openai/gpt-4o-mini"},
+
+		// Versions of a model.
+		{"openai", "gpt-4o-2024-08-06", "openai/gpt-4o"},
+		{"openai", "gpt-4o-mini-2024-07-18", "openai/gpt-4o-mini"},
+		{"openai", "gpt-4-0613", "openai/gpt-4"},
+		{"anthropic", "claude-sonnet-4-5-20250929", "anthropic/claude-sonnet-4-5"},
+		{"bedrock", "anthropic.claude-3-haiku-20240307-v1:0", "bedrock/anthropic.claude-3-haiku-20240307"},
+		{"bedrock", "anthropic.claude-3-5-haiku-20241022-v1:0", "bedrock/anthropic.claude-3-5-haiku"},
+		{"bedrock", "meta.llama3-3-70b-instruct-v1:0", "bedrock/meta.llama3-3-70b-instruct"},
+		{"vertex", "claude-sonnet-4@20250514", "vertex/claude-sonnet-4"},
+		{"vertex", "gemini-2.0-flash-001", "vertex/gemini-2.0-flash"},
+		{"ollama", "llama3:8b", "ollama/llama3"},
+
+		// Different models that share a prefix.
+		{"openai", "o1-pro", ""},
+		{"openai", "gpt-4.1-nano", ""},
+		{"openai", "gpt-5.2-pro", ""},
+		{"openai", "gpt-4o-audio-preview", ""},
+		{"anthropic", "claude-sonnet-4-1", ""},
+		{"xai", "grok-4-heavy", ""},
+		{"openai", "gpt-4ox", ""},
+
+		// Unknown models and providers.
+		{"openai", "gpt-99", ""},
+		{"nope", "gpt-4o", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			got, ok := c.Lookup(tt.provider, tt.model)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("Lookup = %+v, want unpriced", got)
+				}
+				return
+			}
+			want := (*c.prices.Load())[tt.want]
+			if !ok || got != want {
+				t.Errorf("Lookup = %+v, %v; want the price of %s, %+v", got, ok, tt.want, want)
+			}
+		})
+	}
+}
+
+func TestLookupPrefersLongestBase(t *testing.T) {
+	c := NewCatalog(nil)
+
+	// gpt-4o-mini-2024-07-18 also extends gpt-4 and gpt-4o by prefix alone.
+	got, ok := c.Lookup("openai", "gpt-4o-mini-2024-07-18")
+	if want := defaultPrices["openai/gpt-4o-mini"]; !ok || got != want {
+		t.Errorf("Lookup = %+v, %v; want %+v", got, ok, want)
+	}
+}
+
+func TestCost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, CachedInput: 0.3, CacheWrite: 3.75}
+	usage := llm.Usage{
+		PromptTokens:     1_000_000, // Including cached and written tokens
+		CachedTokens:     200_000,
+		CacheWriteTokens: 100_000,
+		CompletionTokens: 100_000,
+	}
+
+	// 700k uncached at $3, 200k cached at $0.30, 100k written at $3.75 and
+	// 100k completion at $15 per million.
+	want := 2.1 + 0.06 + 0.375 + 1.5
+	if got := p.Cost(usage); !near(got, want) {
+		t.Errorf("Cost = %v, want %v", got, want)
+	}
+
+	// Without cache prices, cached and written tokens cost as much as input.
+	p = Price{Input: 3, Output: 15}
+	if got, want := p.Cost(usage), 3+1.5; !near(got, want) {
+		t.Errorf("Cost without cache prices = %v, want %v", got, want)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog(map[string]Price{
+		"openai/gpt-4o": {Input: 1, Output: 1},
+		"custom/model":  {Input: 2, Output: 2},
+	})
+	usage := llm.Usage{PromptTokens: 1_000_000}
+
+	// Overrides replace built-in prices and add new ones.
+	if got, ok := c.Cost("openai", usage, "gpt-4o"); !ok || !near(got, 1) {
+		t.Errorf("overridden price: Cost = %v, %v; want 1", got, ok)
+	}
+	if got, ok := c.Cost("custom", usage, "model"); !ok || !near(got, 2) {
+		t.Errorf("added price: Cost = %v, %v; want 2", got, ok)
+	}
+
+	// The first model with a price is used, skipping empty ones.
+	if got, ok := c.Cost("custom", usage, "", "unknown", "model"); !ok || !near(got, 2) {
+		t.Errorf("Cost with fallback models = %v, %v; want 2", got, ok)
+	}
+	if _, ok := c.Cost("custom", usage, "unknown"); ok {
+		t.Error("Cost of an unknown model is priced")
+	}
+
+	// Update drops earlier overrides and keeps the built-in prices.
+	c.Update(nil)
+	if _, ok := c.Lookup("custom", "model"); ok {
+		t.Error("override kept after Update")
+	}
+	if got, _ := c.Lookup("openai", "gpt-4o"); got != defaultPrices["openai/gpt-4o"] {
+		t.Errorf("built-in price after Update = %+v, want %+v", got, defaultPrices["openai/gpt-4o"])
+	}
+}
+
+func near(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
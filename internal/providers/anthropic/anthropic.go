@@ -14,11 +14,7 @@ type Provider struct {
 }
 
 func New() *Provider {
-	return &Provider{
-		client: anthropic_compat.NewClient(anthropic_compat.Config{
-			APIKey: "",
-		}),
-	}
+	return NewWithConfig(anthropic_compat.Config{})
 }
 
 func NewWithBaseURL(apiKey string, baseURL string) *Provider {
@@ -30,6 +26,12 @@ func NewWithBaseURL(apiKey string, baseURL string) *Provider {
 	}
 }
 
+func NewWithConfig(cfg anthropic_compat.Config) *Provider {
+	return &Provider{
+		client: anthropic_compat.NewClient(cfg),
+	}
+}
+
 func (p *Provider) Name() string { return "anthropic" }
 
 func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
@@ -20,7 +20,10 @@ const (
 )
 
 func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
-	body := toRequest(req)
+	body, err := toRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -56,26 +59,30 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatRespon
 		return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
 	}
 
-	content := ""
-	for _, block := range raw.Content {
-		if block.Type == contentTypeText {
-			content += block.Text
-		}
-	}
+	content, toolCalls := fromResponseBlocks(raw.Content)
 
-	return &llm.ChatResponse{
-		ID:      raw.ID,
-		Model:   raw.Model,
-		Content: content,
-		Raw:     respBody,
-	}, nil
+	chatResp := &llm.ChatResponse{
+		ID:        raw.ID,
+		Model:     raw.Model,
+		Content:   content,
+		ToolCalls: toolCalls,
+		Usage:     raw.Usage.toUsage(),
+		Raw:       respBody,
+	}
+	if raw.StopReason != nil {
+		chatResp.FinishReason = mapStopReason(*raw.StopReason)
+	}
+	return chatResp, nil
 }
 
 func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
 	stream := true
 	req.Options.Stream = &stream
 
-	body := toRequest(req)
+	body, err := toRequest(req)
+	if err != nil {
+		return err
+	}
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -151,6 +158,8 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 	messageID := ""
 	model := ""
 	var contentIndex int
+	toolIndexes := make(map[int]int) // content block index -> tool call index
+	var usage messageUsage
 
 	for scanner.Scan() {
 		select {
@@ -179,7 +188,7 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 			}
 			finishReason := "stop"
 			chunk.Choices = append(chunk.Choices, llm.StreamChoice{
-				Index:        contentIndex,
+				Index:        0,
 				FinishReason: &finishReason,
 			})
 			return callback(chunk)
@@ -202,6 +211,9 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 				if m, ok := msg["model"].(string); ok {
 					model = m
 				}
+				if u, ok := msg["usage"]; ok {
+					usage = decodeUsage(u)
+				}
 			}
 
 			chunk := &llm.StreamChunk{
@@ -221,30 +233,63 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 			}
 
 		case "content_block_start":
-			if cb, ok := event["content_block"].(map[string]interface{}); ok {
-				if idx, ok := event["index"].(float64); ok {
-					contentIndex = int(idx)
-				}
-				_ = cb
+			if idx, ok := event["index"].(float64); ok {
+				contentIndex = int(idx)
+			}
+			cb, ok := event["content_block"].(map[string]interface{})
+			if !ok || cb["type"] != string(contentTypeToolUse) {
+				continue
+			}
+
+			// Each tool_use block becomes the next OpenAI tool call; its
+			// arguments follow as input_json_delta fragments.
+			toolIndex := len(toolIndexes)
+			toolIndexes[contentIndex] = toolIndex
+			id, _ := cb["id"].(string)
+			name, _ := cb["name"].(string)
+			if err := callback(toolCallChunk(messageID, model, llm.ToolCallDelta{
+				Index:    toolIndex,
+				ID:       id,
+				Type:     "function",
+				Function: llm.FunctionCall{Name: name},
+			})); err != nil {
+				return err
 			}
 
 		case "content_block_delta":
-			if delta, ok := event["delta"].(map[string]interface{}); ok {
-				if text, ok := delta["text"].(string); ok {
-					chunk := &llm.StreamChunk{
-						ID:      messageID,
-						Model:   model,
-						Choices: []llm.StreamChoice{},
-					}
-					chunk.Choices = append(chunk.Choices, llm.StreamChoice{
-						Index: contentIndex,
-						Delta: llm.StreamDelta{
-							Content: &text,
-						},
-					})
-					if err := callback(chunk); err != nil {
-						return err
-					}
+			delta, ok := event["delta"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if partial, ok := delta["partial_json"].(string); ok {
+				toolIndex, ok := toolIndexes[contentIndex]
+				if !ok || partial == "" {
+					continue
+				}
+				if err := callback(toolCallChunk(messageID, model, llm.ToolCallDelta{
+					Index:    toolIndex,
+					Function: llm.FunctionCall{Arguments: partial},
+				})); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if text, ok := delta["text"].(string); ok {
+				chunk := &llm.StreamChunk{
+					ID:      messageID,
+					Model:   model,
+					Choices: []llm.StreamChoice{},
+				}
+				chunk.Choices = append(chunk.Choices, llm.StreamChoice{
+					Index: 0,
+					Delta: llm.StreamDelta{
+						Content: &text,
+					},
+				})
+				if err := callback(chunk); err != nil {
+					return err
 				}
 			}
 
@@ -252,29 +297,38 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 			contentIndex++
 
 		case "message_delta":
-			var usage *llm.Usage
-			if u, ok := event["usage"].(map[string]interface{}); ok {
-				if outputTokens, ok := u["output_tokens"].(float64); ok {
-					usage = &llm.Usage{
-						CompletionTokens: int(outputTokens),
-					}
+			// Input and cache counts arrive with message_start; message_delta
+			// carries the cumulative output count.
+			var chunkUsage *llm.Usage
+			if u, ok := event["usage"]; ok {
+				delta := decodeUsage(u)
+				usage.OutputTokens = delta.OutputTokens
+				if delta.InputTokens > 0 {
+					usage.InputTokens = delta.InputTokens
 				}
+				chunkUsage = usage.toUsage()
 			}
 
 			var finishReason *string
-			if sr, ok := event["stop_reason"].(string); ok {
-				finishReason = &sr
+			stopReason, _ := event["stop_reason"].(string)
+			if delta, ok := event["delta"].(map[string]interface{}); ok {
+				if sr, ok := delta["stop_reason"].(string); ok {
+					stopReason = sr
+				}
+			}
+			if reason := mapStopReason(stopReason); reason != "" {
+				finishReason = &reason
 			}
 
 			chunk := &llm.StreamChunk{
 				ID:      messageID,
 				Model:   model,
-				Usage:   usage,
+				Usage:   chunkUsage,
 				Choices: []llm.StreamChoice{},
 			}
 			if finishReason != nil {
 				chunk.Choices = append(chunk.Choices, llm.StreamChoice{
-					Index:        contentIndex,
+					Index:        0,
 					FinishReason: finishReason,
 				})
 			}
@@ -295,3 +349,14 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 
 	return nil
 }
+
+func toolCallChunk(id, model string, delta llm.ToolCallDelta) *llm.StreamChunk {
+	return &llm.StreamChunk{
+		ID:    id,
+		Model: model,
+		Choices: []llm.StreamChoice{{
+			Index: 0,
+			Delta: llm.StreamDelta{ToolCalls: []llm.ToolCallDelta{delta}},
+		}},
+	}
+}
@@ -1,12 +1,19 @@
 package anthropic_compat
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/atozi-ai/gateway/internal/domain/llm"
 )
 
-func toRequest(req llm.ChatRequest) messageRequest {
+func toRequest(req llm.ChatRequest) (messageRequest, error) {
 	opts := req.Options
 
+	if err := llm.CheckParts("anthropic", req.Messages, llm.PartImage, llm.PartFile); err != nil {
+		return messageRequest{}, err
+	}
+
 	maxTokens := defaultMaxTokens
 	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
 		maxTokens = *opts.MaxTokens
@@ -20,51 +27,63 @@ func toRequest(req llm.ChatRequest) messageRequest {
 		case llm.RoleSystem:
 			systemBlocks = append(systemBlocks, contentBlock{
 				Type: contentTypeText,
-				Text: msg.Content,
+				Text: msg.Text(),
 			})
 		case llm.RoleUser:
+			blocks, err := toContentBlocks(msg)
+			if err != nil {
+				return messageRequest{}, err
+			}
 			userMessages = append(userMessages, message{
-				Role: roleUser,
-				Content: []contentBlock{
-					{
-						Type: contentTypeText,
-						Text: msg.Content,
-					},
-				},
+				Role:    roleUser,
+				Content: blocks,
 			})
 		case llm.RoleAssistant:
+			blocks, err := toAssistantBlocks(msg)
+			if err != nil {
+				return messageRequest{}, err
+			}
 			userMessages = append(userMessages, message{
-				Role: roleAssistant,
-				Content: []contentBlock{
-					{
-						Type: contentTypeText,
-						Text: msg.Content,
-					},
-				},
+				Role:    roleAssistant,
+				Content: blocks,
 			})
 		case llm.RoleTool:
+			if msg.ToolCallID == "" {
+				return messageRequest{}, llm.NewValidationError("tool messages require tool_call_id", "missing_tool_call_id")
+			}
+			result := contentBlock{
+				Type:      contentTypeToolResult,
+				Content:   msg.Text(),
+				ToolUseID: msg.ToolCallID,
+			}
+			// Results for parallel tool calls go back in a single user turn.
+			if n := len(userMessages); n > 0 && isToolResultTurn(userMessages[n-1]) {
+				userMessages[n-1].Content = append(userMessages[n-1].Content, result)
+				continue
+			}
 			userMessages = append(userMessages, message{
-				Role: roleUser,
-				Content: []contentBlock{
-					{
-						Type:      contentTypeToolResult,
-						Content:   msg.Content,
-						ToolUseID: "",
-					},
-				},
+				Role:    roleUser,
+				Content: []contentBlock{result},
 			})
 		}
 	}
 
 	var tools []tool
 	if len(opts.Tools) > 0 {
-		tools = make([]tool, len(opts.Tools))
-		for i, t := range opts.Tools {
-			tools[i] = tool{
+		tools = make([]tool, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			if t.Function == nil {
+				continue
+			}
+			schema := t.Function.Parameters
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object"}`)
+			}
+			tools = append(tools, tool{
 				Name:        t.Function.Name,
 				Description: t.Function.Description,
-				Parameters:  t.Function.Parameters,
-			}
+				InputSchema: schema,
+			})
 		}
 	}
 
@@ -100,25 +119,150 @@ func toRequest(req llm.ChatRequest) messageRequest {
 		Stream:        opts.Stream,
 		StopSequences: opts.Stop,
 		OutputConfig:  outputCfg,
+	}, nil
+}
+
+// toContentBlocks maps message content to text, image and document blocks.
+func toContentBlocks(msg llm.Message) ([]contentBlock, error) {
+	if len(msg.Parts) == 0 {
+		return []contentBlock{{Type: contentTypeText, Text: msg.Content}}, nil
+	}
+
+	blocks := make([]contentBlock, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case llm.PartText:
+			blocks = append(blocks, contentBlock{Type: contentTypeText, Text: part.Text})
+		case llm.PartImage, llm.PartFile:
+			if part.FileID != "" {
+				return nil, llm.NewValidationError("anthropic does not accept file IDs; send the file as base64 data", "unsupported_content")
+			}
+			blockType := contentTypeImage
+			if part.Type == llm.PartFile {
+				blockType = contentTypeDocument
+			}
+			blocks = append(blocks, contentBlock{Type: blockType, Source: toMediaSource(part)})
+		default:
+			return nil, llm.NewValidationError(fmt.Sprintf("anthropic does not accept %s content", part.Type), "unsupported_content")
+		}
+	}
+	return blocks, nil
+}
+
+func toMediaSource(part llm.ContentPart) *mediaSource {
+	if part.Data != "" {
+		return &mediaSource{Type: "base64", MediaType: part.MediaType, Data: part.Data}
 	}
+	return &mediaSource{Type: "url", URL: part.URL}
 }
 
 func resolveToolChoice(raw interface{}) *toolChoice {
-	if raw == nil {
-		return nil
+	switch mode, name := llm.ResolveToolChoice(raw); mode {
+	case "none", "auto":
+		return &toolChoice{Type: mode}
+	case "required":
+		return &toolChoice{Type: "any"}
+	case "function":
+		return &toolChoice{Type: "tool", Name: name}
 	}
-	if s, ok := raw.(string); ok {
-		if s == "none" || s == "auto" {
-			return &toolChoice{Type: s}
+	return nil
+}
+
+// toAssistantBlocks maps an assistant message to text and tool_use blocks.
+func toAssistantBlocks(msg llm.Message) ([]contentBlock, error) {
+	var blocks []contentBlock
+	if text := msg.Text(); text != "" || len(msg.ToolCalls) == 0 {
+		blocks = append(blocks, contentBlock{Type: contentTypeText, Text: text})
+	}
+
+	for _, tc := range msg.ToolCalls {
+		input, err := toolInput(tc.Function.Arguments)
+		if err != nil {
+			return nil, err
 		}
+		blocks = append(blocks, contentBlock{
+			Type:  contentTypeToolUse,
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		})
 	}
-	if tc, ok := raw.(llm.ToolChoice); ok {
-		if tc.Function != nil {
-			return &toolChoice{
-				Type: "tool",
-				Name: tc.Function.Name,
+	return blocks, nil
+}
+
+// toolInput converts OpenAI-style JSON-encoded arguments to a tool_use input.
+func toolInput(arguments string) (json.RawMessage, error) {
+	if arguments == "" {
+		return json.RawMessage(`{}`), nil
+	}
+	if !json.Valid([]byte(arguments)) {
+		return nil, llm.NewValidationError("tool call arguments must be valid JSON", "invalid_tool_arguments")
+	}
+	return json.RawMessage(arguments), nil
+}
+
+func isToolResultTurn(m message) bool {
+	return m.Role == roleUser && len(m.Content) > 0 && m.Content[0].Type == contentTypeToolResult
+}
+
+// fromResponseBlocks collects the text and tool calls of a response.
+func fromResponseBlocks(blocks []contentBlock) (string, []llm.ToolCall) {
+	var content string
+	var toolCalls []llm.ToolCall
+	for _, block := range blocks {
+		switch block.Type {
+		case contentTypeText:
+			content += block.Text
+		case contentTypeToolUse:
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
 			}
+			toolCalls = append(toolCalls, llm.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: llm.FunctionCall{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+			})
 		}
 	}
-	return nil
+	return content, toolCalls
+}
+
+// toUsage normalizes Anthropic usage. input_tokens excludes cached tokens, so
+// they are added back to match the OpenAI prompt_tokens count.
+func (u messageUsage) toUsage() *llm.Usage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return &llm.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+}
+
+// decodeUsage reads a usage object from a decoded stream event.
+func decodeUsage(v interface{}) messageUsage {
+	var u messageUsage
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &u)
+	}
+	return u
+}
+
+// mapStopReason maps an Anthropic stop_reason to the OpenAI finish reason.
+func mapStopReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "":
+		return ""
+	default:
+		return "stop"
+	}
 }
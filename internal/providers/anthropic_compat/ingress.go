@@ -0,0 +1,447 @@
+package anthropic_compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// This file translates in the opposite direction to the rest of the package:
+// it accepts Anthropic Messages API requests from clients and renders gateway
+// responses in Anthropic's shape, so any provider can serve Anthropic SDKs.
+
+// ParseRequest decodes a Messages API request body into a chat request. The
+// model is returned as sent; it still has to be resolved to a provider.
+func ParseRequest(body []byte) (llm.ChatRequest, error) {
+	var raw messageRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json")
+	}
+
+	if raw.Model == "" {
+		return llm.ChatRequest{}, llm.NewValidationError("model is required", "missing_model")
+	}
+	if raw.MaxTokens <= 0 {
+		return llm.ChatRequest{}, llm.NewValidationError("max_tokens must be positive", "invalid_max_tokens")
+	}
+	if len(raw.Messages) == 0 {
+		return llm.ChatRequest{}, llm.NewValidationError("messages are required", "missing_messages")
+	}
+
+	var messages []llm.Message
+	if len(raw.System) > 0 {
+		var system string
+		for _, block := range raw.System {
+			system += block.Text
+		}
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: system})
+	}
+
+	for i, msg := range raw.Messages {
+		var converted []llm.Message
+		var err error
+		switch msg.Role {
+		case roleUser:
+			converted, err = fromUserBlocks(msg.Content)
+		case roleAssistant:
+			converted, err = fromAssistantBlocks(msg.Content)
+		default:
+			err = fmt.Errorf("role must be user or assistant, got %q", msg.Role)
+		}
+		if err != nil {
+			return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("messages[%d]: %v", i, err), "invalid_message")
+		}
+		messages = append(messages, converted...)
+	}
+
+	maxTokens := raw.MaxTokens
+	opts := llm.ChatOptions{
+		MaxTokens:   &maxTokens,
+		Temperature: raw.Temperature,
+		TopP:        raw.TopP,
+		Stop:        raw.StopSequences,
+		Stream:      raw.Stream,
+	}
+
+	for _, t := range raw.Tools {
+		if t.Type != "" && t.Type != "custom" {
+			return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("tool %q: server tools of type %q are not supported", t.Name, t.Type), "unsupported_tool")
+		}
+		opts.Tools = append(opts.Tools, llm.Tool{
+			Type: "function",
+			Function: &llm.FunctionTool{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		})
+	}
+
+	if raw.ToolChoice != nil {
+		switch raw.ToolChoice.Type {
+		case "auto", "none":
+			opts.ToolChoice = raw.ToolChoice.Type
+		case "any":
+			opts.ToolChoice = "required"
+		case "tool":
+			choice := llm.ToolChoice{Type: "function"}
+			choice.Function = &struct {
+				Name string `json:"name"`
+			}{Name: raw.ToolChoice.Name}
+			opts.ToolChoice = choice
+		}
+	}
+
+	if raw.OutputConfig != nil && raw.OutputConfig.Format != nil && raw.OutputConfig.Format.Type == "json_schema" {
+		opts.ResponseFormat = &llm.ResponseFormat{
+			Type:   "json_schema",
+			Schema: raw.OutputConfig.Format.Schema,
+		}
+	}
+
+	if raw.Metadata != nil && raw.Metadata.UserID != "" {
+		opts.User = &raw.Metadata.UserID
+	}
+
+	return llm.ChatRequest{
+		Model:    raw.Model,
+		Messages: messages,
+		Options:  opts,
+	}, nil
+}
+
+// fromUserBlocks converts a user turn. Each tool_result block becomes a tool
+// message; they come first, as the API requires them to lead the turn.
+func fromUserBlocks(blocks []contentBlock) ([]llm.Message, error) {
+	var messages []llm.Message
+	var parts []llm.ContentPart
+
+	for _, block := range blocks {
+		switch block.Type {
+		case contentTypeText:
+			parts = append(parts, llm.ContentPart{Type: llm.PartText, Text: block.Text})
+		case contentTypeImage, contentTypeDocument:
+			if block.Source == nil {
+				return nil, fmt.Errorf("%s block requires a source", block.Type)
+			}
+			part := llm.ContentPart{Type: llm.PartImage}
+			if block.Type == contentTypeDocument {
+				part.Type = llm.PartFile
+			}
+			switch block.Source.Type {
+			case "base64":
+				part.Data, part.MediaType = block.Source.Data, block.Source.MediaType
+			case "url":
+				part.URL = block.Source.URL
+			default:
+				return nil, fmt.Errorf("unsupported %s source type %q", block.Type, block.Source.Type)
+			}
+			parts = append(parts, part)
+		case contentTypeToolResult:
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
+				Content:    block.Content,
+				ToolCallID: block.ToolUseID,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported content block type %q in user message", block.Type)
+		}
+	}
+
+	if len(parts) == 0 {
+		return messages, nil
+	}
+	msg := llm.Message{Role: llm.RoleUser}
+	if len(parts) == 1 && parts[0].Type == llm.PartText {
+		msg.Content = parts[0].Text
+	} else {
+		msg.Parts = parts
+	}
+	return append(messages, msg), nil
+}
+
+// fromAssistantBlocks converts an assistant turn to a message with its text
+// and tool calls.
+func fromAssistantBlocks(blocks []contentBlock) ([]llm.Message, error) {
+	msg := llm.Message{Role: llm.RoleAssistant}
+	for _, block := range blocks {
+		switch block.Type {
+		case contentTypeText:
+			msg.Content += block.Text
+		case contentTypeToolUse:
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: llm.FunctionCall{Name: block.Name, Arguments: arguments},
+			})
+		case "thinking", "redacted_thinking":
+			// Thinking is provider-specific and cannot be passed to other
+			// providers.
+		default:
+			return nil, fmt.Errorf("unsupported content block type %q in assistant message", block.Type)
+		}
+	}
+	return []llm.Message{msg}, nil
+}
+
+// EncodeResponse renders resp as a Messages API response.
+func EncodeResponse(resp *llm.ChatResponse) ([]byte, error) {
+	content := []contentBlock{}
+	if resp.Content != "" {
+		content = append(content, contentBlock{Type: contentTypeText, Text: resp.Content})
+	}
+	for _, tc := range resp.ToolCalls {
+		input := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage(`{}`)
+		}
+		content = append(content, contentBlock{
+			Type:  contentTypeToolUse,
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		})
+	}
+
+	stopReason := toStopReason(resp.FinishReason)
+	return json.Marshal(messageResponse{
+		ID:         resp.ID,
+		Type:       "message",
+		Role:       roleAssistant,
+		Content:    content,
+		Model:      resp.Model,
+		StopReason: &stopReason,
+		Usage:      fromUsage(resp.Usage),
+	})
+}
+
+// toStopReason maps an OpenAI finish reason to an Anthropic stop_reason.
+func toStopReason(finishReason string) string {
+	switch finishReason {
+	case "tool_calls":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// fromUsage converts normalized usage back to Anthropic's, where input_tokens
+// excludes cached tokens.
+func fromUsage(u *llm.Usage) messageUsage {
+	if u == nil {
+		return messageUsage{}
+	}
+	return messageUsage{
+		InputTokens:              u.PromptTokens - u.CachedTokens - u.CacheWriteTokens,
+		OutputTokens:             u.CompletionTokens,
+		CacheReadInputTokens:     u.CachedTokens,
+		CacheCreationInputTokens: u.CacheWriteTokens,
+	}
+}
+
+// EncodeError renders err as a Messages API error and returns it with the
+// HTTP status to send.
+func EncodeError(err error) (int, []byte) {
+	status := http.StatusInternalServerError
+	message := "Internal server error"
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) {
+		status, message = pe.StatusCode, pe.Message
+	}
+
+	errorType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusPaymentRequired:
+		errorType = "billing_error"
+	case http.StatusForbidden:
+		errorType = "permission_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errorType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		errorType = "overloaded_error"
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": errorResponse{Type: errorType, Message: message},
+	})
+	return status, body
+}
+
+// StreamEncoder renders stream chunks as Messages API server-sent events. It
+// opens a content block for each run of text and for each tool call.
+type StreamEncoder struct {
+	started    bool
+	id         string
+	model      string
+	block      int         // Index of the open content block, or -1
+	blockTool  int         // Tool call index of the open block, or -1 for text
+	blocks     int         // Content blocks started so far
+	toolBlocks map[int]int // Tool call index -> content block index
+	stopReason string
+	usage      *llm.Usage
+	buf        bytes.Buffer
+}
+
+func NewStreamEncoder() *StreamEncoder {
+	return &StreamEncoder{
+		block:      -1,
+		blockTool:  -1,
+		toolBlocks: make(map[int]int),
+	}
+}
+
+// Chunk returns the events for chunk, which may be none.
+func (e *StreamEncoder) Chunk(chunk *llm.StreamChunk) []byte {
+	e.buf.Reset()
+
+	if chunk.ID != "" && e.id == "" {
+		e.id = chunk.ID
+	}
+	if chunk.Model != "" && e.model == "" {
+		e.model = chunk.Model
+	}
+	e.start()
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			if e.block < 0 || e.blockTool >= 0 {
+				e.openBlock(-1, contentBlock{Type: contentTypeText})
+			}
+			e.event("content_block_delta", map[string]interface{}{
+				"index": e.block,
+				"delta": map[string]string{"type": "text_delta", "text": *choice.Delta.Content},
+			})
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			index, ok := e.toolBlocks[tc.Index]
+			if !ok {
+				index = e.openBlock(tc.Index, contentBlock{
+					Type:  contentTypeToolUse,
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: json.RawMessage(`{}`),
+				})
+			}
+			if tc.Function.Arguments != "" {
+				e.event("content_block_delta", map[string]interface{}{
+					"index": index,
+					"delta": map[string]string{"type": "input_json_delta", "partial_json": tc.Function.Arguments},
+				})
+			}
+		}
+		if choice.FinishReason != nil {
+			e.stopReason = toStopReason(*choice.FinishReason)
+		}
+	}
+
+	if chunk.Usage != nil {
+		e.usage = chunk.Usage
+	}
+	return e.buf.Bytes()
+}
+
+// Finish returns the events that end the message: the close of the open
+// block, the stop reason with final usage, and message_stop.
+func (e *StreamEncoder) Finish() []byte {
+	e.buf.Reset()
+	e.start()
+	e.closeBlock()
+
+	stopReason := e.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	e.event("message_delta", map[string]interface{}{
+		"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": fromUsage(e.usage),
+	})
+	e.event("message_stop", map[string]interface{}{})
+	return e.buf.Bytes()
+}
+
+// Error returns an error event, for failures after the stream has started.
+func (e *StreamEncoder) Error(err error) []byte {
+	e.buf.Reset()
+	_, body := EncodeError(err)
+	fmt.Fprintf(&e.buf, "event: error\ndata: %s\n\n", body)
+	return e.buf.Bytes()
+}
+
+func (e *StreamEncoder) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.event("message_start", map[string]interface{}{
+		"message": map[string]interface{}{
+			"id":            e.id,
+			"type":          "message",
+			"role":          roleAssistant,
+			"content":       []contentBlock{},
+			"model":         e.model,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         messageUsage{},
+		},
+	})
+}
+
+func (e *StreamEncoder) openBlock(toolIndex int, block contentBlock) int {
+	e.closeBlock()
+	e.block, e.blockTool = e.blocks, toolIndex
+	e.blocks++
+	if toolIndex >= 0 {
+		e.toolBlocks[toolIndex] = e.block
+	}
+
+	start := map[string]interface{}{"type": block.Type}
+	switch block.Type {
+	case contentTypeText:
+		start["text"] = ""
+	case contentTypeToolUse:
+		start["id"], start["name"], start["input"] = block.ID, block.Name, block.Input
+	}
+	e.event("content_block_start", map[string]interface{}{
+		"index":         e.block,
+		"content_block": start,
+	})
+	return e.block
+}
+
+func (e *StreamEncoder) closeBlock() {
+	if e.block < 0 {
+		return
+	}
+	e.event("content_block_stop", map[string]interface{}{"index": e.block})
+	e.block, e.blockTool = -1, -1
+}
+
+func (e *StreamEncoder) event(name string, payload map[string]interface{}) {
+	payload["type"] = name
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(&e.buf, "event: %s\ndata: %s\n\n", name, data)
+}
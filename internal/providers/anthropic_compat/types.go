@@ -18,6 +18,8 @@ type contentBlockType string
 
 const (
 	contentTypeText       contentBlockType = "text"
+	contentTypeImage      contentBlockType = "image"
+	contentTypeDocument   contentBlockType = "document"
 	contentTypeToolUse    contentBlockType = "tool_use"
 	contentTypeToolResult contentBlockType = "tool_result"
 )
@@ -30,6 +32,15 @@ type contentBlock struct {
 	Input     json.RawMessage  `json:"input,omitempty"`
 	ToolUseID string           `json:"tool_use_id,omitempty"`
 	Content   string           `json:"content,omitempty"`
+	Source    *mediaSource     `json:"source,omitempty"`
+}
+
+// mediaSource is the source of an image or document block.
+type mediaSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type message struct {
@@ -38,9 +49,10 @@ type message struct {
 }
 
 type tool struct {
+	Type        string          `json:"type,omitempty"` // Empty or "custom" for client tools
 	Name        string          `json:"name"`
 	Description string          `json:"description,omitempty"`
-	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
 }
 
 type outputFormat struct {
@@ -64,6 +76,11 @@ type messageRequest struct {
 	Stream        *bool          `json:"stream,omitempty"`
 	StopSequences []string       `json:"stop_sequences,omitempty"`
 	OutputConfig  *outputConfig  `json:"output_config,omitempty"`
+	Metadata      *metadata      `json:"metadata,omitempty"`
+}
+
+type metadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 type toolChoice struct {
@@ -83,8 +100,88 @@ type messageResponse struct {
 }
 
 type messageUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// UnmarshalJSON accepts content given as a plain string, which the API
+// treats as a single text block.
+func (m *message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    messageRole     `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	blocks, err := textOrBlocks(raw.Content)
+	if err != nil {
+		return err
+	}
+	m.Role, m.Content = raw.Role, blocks
+	return nil
+}
+
+// UnmarshalJSON accepts a system prompt given as a plain string.
+func (r *messageRequest) UnmarshalJSON(data []byte) error {
+	type plain messageRequest
+	var raw struct {
+		plain
+		System json.RawMessage `json:"system,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	system, err := textOrBlocks(raw.System)
+	if err != nil {
+		return err
+	}
+	*r = messageRequest(raw.plain)
+	r.System = system
+	return nil
+}
+
+// UnmarshalJSON accepts tool_result content given as a list of text blocks,
+// which is flattened into Content.
+func (b *contentBlock) UnmarshalJSON(data []byte) error {
+	type plain contentBlock
+	var raw struct {
+		plain
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	blocks, err := textOrBlocks(raw.Content)
+	if err != nil {
+		return err
+	}
+	*b = contentBlock(raw.plain)
+	for _, block := range blocks {
+		b.Content += block.Text
+	}
+	return nil
+}
+
+// textOrBlocks decodes a field that may be a string or a list of blocks.
+func textOrBlocks(data json.RawMessage) ([]contentBlock, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	if data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return nil, err
+		}
+		return []contentBlock{{Type: contentTypeText, Text: text}}, nil
+	}
+	var blocks []contentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
 }
 
 type errorResponse struct {
@@ -16,11 +16,17 @@ type Provider struct {
 
 // New creates an Arcee AI provider.
 func New() *Provider {
+	return NewWithConfig(openaicompat.Config{})
+}
+
+// NewWithConfig creates an Arcee AI provider with explicit client settings.
+// An empty BaseURL falls back to the provider default.
+func NewWithConfig(cfg openaicompat.Config) *Provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = baseURL
+	}
 	return &Provider{
-		client: openaicompat.NewClient(openaicompat.Config{
-			BaseURL: baseURL,
-			APIKey:  "",
-		}),
+		client: openaicompat.NewClient(cfg),
 	}
 }
 
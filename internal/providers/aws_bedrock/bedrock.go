@@ -3,17 +3,18 @@ package aws_bedrock
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/sigv4"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 )
 
 const (
@@ -22,271 +23,209 @@ const (
 )
 
 type Provider struct {
-	awsAccessKey string
-	awsSecretKey string
-	awsRegion    string
-	httpClient   *http.Client
+	credentials credentialSource
+	awsRegion   string
+	baseURL     string
+	httpClient  *http.Client
+}
+
+// Config holds default credentials and client settings for Bedrock.
+// Request options override the credentials per call. Without keys, the
+// provider looks for credentials the way the AWS SDKs do; see
+// newCredentialChain.
+type Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Profile names the shared config profile to use instead of AWS_PROFILE.
+	Profile string
+	// RoleARN is assumed with STS using the credentials found.
+	RoleARN string
+	Region  string
+	// BaseURL replaces the regional bedrock-runtime endpoint, e.g. with a
+	// VPC endpoint.
+	BaseURL string
+	Timeout time.Duration
 }
 
 func New(accessKey, secretKey, region string) *Provider {
+	return NewWithConfig(Config{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		Region:          region,
+	})
+}
+
+func NewWithConfig(cfg Config) *Provider {
+	region := cfg.Region
 	if region == "" {
 		region = awsRegion
 	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 120 * time.Second
+	}
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: tracing.Transport(nil),
+	}
 	return &Provider{
-		awsAccessKey: accessKey,
-		awsSecretKey: secretKey,
-		awsRegion:    region,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		credentials: newCredentialChain(cfg, &stsClient{region: region, httpClient: httpClient}),
+		awsRegion:   region,
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient:  httpClient,
 	}
 }
 
 func (p *Provider) Name() string { return "bedrock" }
 
 func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
-	accessKey := p.awsAccessKey
-	secretKey := p.awsSecretKey
-	region := p.awsRegion
-	inferenceProfileARN := ""
+	resp, err := p.converse(ctx, req, "converse", "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-	if req.Options.AWSAccessKeyID != nil && *req.Options.AWSAccessKeyID != "" {
-		accessKey = *req.Options.AWSAccessKeyID
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to read response: %v", err))
 	}
-	if req.Options.AWSSecretAccessKey != nil && *req.Options.AWSSecretAccessKey != "" {
-		secretKey = *req.Options.AWSSecretAccessKey
+
+	var bedrockResp ConverseResponse
+	if err := json.Unmarshal(respBody, &bedrockResp); err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
 	}
-	if req.Options.AWSRegion != nil && *req.Options.AWSRegion != "" {
-		region = *req.Options.AWSRegion
+
+	return convertFromBedrockResponse(bedrockResp), nil
+}
+
+func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	resp, err := p.converse(ctx, req, "converse-stream", "application/vnd.amazon.eventstream")
+	if err != nil {
+		return err
 	}
-	if req.Options.AWSInferenceProfileARN != nil && *req.Options.AWSInferenceProfileARN != "" {
-		inferenceProfileARN = *req.Options.AWSInferenceProfileARN
-		if region == "" {
-			parts := strings.Split(inferenceProfileARN, ":")
-			if len(parts) >= 4 {
-				region = parts[3]
-			}
+	defer resp.Body.Close()
+
+	state := newStreamState()
+	decoder := newEventStreamDecoder(resp.Body)
+	for {
+		msg, err := decoder.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return llm.NewProviderError(502, fmt.Sprintf("failed to decode stream: %v", err), "api_error", "stream_decode_failed")
 		}
-	}
 
-	if accessKey == "" || secretKey == "" {
-		return nil, &llm.ProviderError{
-			StatusCode: 400,
-			Message:    "AWS credentials required. Provide aws_access_key_id and aws_secret_access_key in request options",
-			Type:       "invalid_request_error",
-			Code:       "missing_aws_credentials",
+		chunk, err := toConverseChunk(msg)
+		if err != nil {
+			return err
 		}
-	}
 
-	if region == "" {
-		return nil, &llm.ProviderError{
-			StatusCode: 400,
-			Message:    "AWS region required. Provide aws_region in request options or use aws_inference_profile_arn which includes region info",
-			Type:       "invalid_request_error",
-			Code:       "missing_aws_region",
+		if streamChunk := state.toStreamChunk(chunk); streamChunk != nil {
+			streamChunk.Model = req.Model
+			if err := callback(streamChunk); err != nil {
+				return err
+			}
 		}
 	}
+}
 
-	bedrockReq := convertToBedrockRequest(req)
-	bedrockReq.Model = req.Model
-
-	body, err := json.Marshal(bedrockReq)
+// converse sends req to the Converse API action ("converse" or
+// "converse-stream").
+func (p *Provider) converse(ctx context.Context, req llm.ChatRequest, action, accept string) (*http.Response, error) {
+	creds, err := p.requestCredentials(ctx, req.Options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
+	region := p.awsRegion
 	modelPath := req.Model
-	if inferenceProfileARN != "" {
-		modelPath = inferenceProfileARN
+	if arn := req.Options.AWSInferenceProfileARN; arn != nil && *arn != "" {
+		modelPath = *arn
+		// arn:aws:bedrock:REGION:ACCOUNT:inference-profile/ID
+		if parts := strings.Split(modelPath, ":"); len(parts) >= 4 && parts[3] != "" {
+			region = parts[3]
+		}
 	}
-
-	url := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/converse", region, modelPath)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if req.Options.AWSRegion != nil && *req.Options.AWSRegion != "" {
+		region = *req.Options.AWSRegion
 	}
 
-	p.signRequest(httpReq, body, accessKey, secretKey, region)
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Accept", "application/json")
-
-	resp, err := p.httpClient.Do(httpReq)
+	bedrockReq, err := convertToBedrockRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	bedrockReq.Model = req.Model
 
-	respBody, err := io.ReadAll(resp.Body)
+	body, err := json.Marshal(bedrockReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("bedrock API error (%d): %s", resp.StatusCode, string(respBody))
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to marshal request: %v", err))
 	}
 
-	var bedrockResp ConverseResponse
-	if err := json.Unmarshal(respBody, &bedrockResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return convertFromBedrockResponse(bedrockResp), nil
+	return p.post(ctx, creds, region, modelPath, action, body, accept)
 }
 
-func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
-	accessKey := p.awsAccessKey
-	secretKey := p.awsSecretKey
-	region := p.awsRegion
-	inferenceProfileARN := ""
-
-	if req.Options.AWSAccessKeyID != nil && *req.Options.AWSAccessKeyID != "" {
-		accessKey = *req.Options.AWSAccessKeyID
-	}
-	if req.Options.AWSSecretAccessKey != nil && *req.Options.AWSSecretAccessKey != "" {
-		secretKey = *req.Options.AWSSecretAccessKey
-	}
-	if req.Options.AWSRegion != nil && *req.Options.AWSRegion != "" {
-		region = *req.Options.AWSRegion
-	}
-	if req.Options.AWSInferenceProfileARN != nil && *req.Options.AWSInferenceProfileARN != "" {
-		inferenceProfileARN = *req.Options.AWSInferenceProfileARN
-		if region == "" {
-			parts := strings.Split(inferenceProfileARN, ":")
-			if len(parts) >= 4 {
-				region = parts[3]
-			}
+// requestCredentials returns the keys given in the request options, or else
+// those of the provider's credential chain.
+func (p *Provider) requestCredentials(ctx context.Context, opts llm.ChatOptions) (sigv4.Credentials, error) {
+	if opts.AWSAccessKeyID != nil && *opts.AWSAccessKeyID != "" &&
+		opts.AWSSecretAccessKey != nil && *opts.AWSSecretAccessKey != "" {
+		creds := sigv4.Credentials{
+			AccessKeyID:     *opts.AWSAccessKeyID,
+			SecretAccessKey: *opts.AWSSecretAccessKey,
 		}
-	}
-
-	if accessKey == "" || secretKey == "" {
-		return &llm.ProviderError{
-			StatusCode: 400,
-			Message:    "AWS credentials required. Provide aws_access_key_id and aws_secret_access_key in request options",
-			Type:       "invalid_request_error",
-			Code:       "missing_aws_credentials",
+		if opts.AWSSessionToken != nil {
+			creds.SessionToken = *opts.AWSSessionToken
 		}
+		return creds, nil
 	}
+	return p.defaultCredentials(ctx)
+}
 
-	if region == "" {
-		return &llm.ProviderError{
+func (p *Provider) defaultCredentials(ctx context.Context) (sigv4.Credentials, error) {
+	creds, err := p.credentials.Retrieve(ctx)
+	if errors.Is(err, errNoCredentials) {
+		return creds, &llm.ProviderError{
 			StatusCode: 400,
-			Message:    "AWS region required. Provide aws_region in request options or use aws_inference_profile_arn which includes region info",
+			Message:    "AWS credentials required. Provide awsAccessKeyID and awsSecretAccessKey in request options, or configure credentials for the aws_bedrock provider",
 			Type:       "invalid_request_error",
-			Code:       "missing_aws_region",
+			Code:       "missing_aws_credentials",
 		}
 	}
+	return creds, err
+}
 
-	bedrockReq := convertToBedrockRequest(req)
-	bedrockReq.Model = req.Model
-
-	body, err := json.Marshal(bedrockReq)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	modelPath := req.Model
-	if inferenceProfileARN != "" {
-		modelPath = inferenceProfileARN
+// post signs body and sends it to a model action, returning the response if
+// its status is 200.
+func (p *Provider) post(ctx context.Context, creds sigv4.Credentials, region, model, action string, body []byte, accept string) (*http.Response, error) {
+	endpoint := p.baseURL
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
 	}
+	target := fmt.Sprintf("%s/model/%s/%s", endpoint, url.PathEscape(model), action)
 
-	url := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/converse-stream", region, modelPath)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
 	}
-
-	p.signRequest(httpReq, body, accessKey, secretKey, region)
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Accept", accept)
+	sigv4.Signer{Service: awsService, Region: region}.Sign(httpReq, body, creds, time.Now())
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, llm.NewProviderError(503, fmt.Sprintf("failed to execute request: %v", err), "service_unavailable", "request_failed")
 	}
-	defer resp.Body.Close()
-
-	decoder := json.NewDecoder(resp.Body)
-	for {
-		var chunk ConverseStreamChunk
-		if err := decoder.Decode(&chunk); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return fmt.Errorf("failed to decode stream: %w", err)
-		}
 
-		if chunk.Delta != nil && chunk.Delta.Text != "" {
-			content := chunk.Delta.Text
-			streamChunk := &llm.StreamChunk{
-				Choices: []llm.StreamChoice{
-					{
-						Delta: llm.StreamDelta{
-							Content: &content,
-						},
-					},
-				},
-			}
-			if err := callback(streamChunk); err != nil {
-				return err
-			}
-		}
-
-		if chunk.StopReason != "" {
-			break
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return nil, &llm.ProviderError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("bedrock API error: %s", strings.TrimSpace(string(respBody))),
+			Type:       "api_error",
 		}
 	}
-
-	return nil
-}
-
-func (p *Provider) signRequest(req *http.Request, body []byte, accessKey, secretKey, region string) {
-	now := time.Now().UTC()
-	date := now.Format("20060102T150405Z")
-	shortDate := now.Format("20060102")
-
-	req.Header.Set("X-Amz-Date", date)
-	req.Header.Set("X-Amz-Target", "AmazonBedrockRuntime.Converse")
-
-	host := req.URL.Host
-	contentHash := sha256Hash(body)
-	req.Header.Set("X-Amz-Content-Sha256", contentHash)
-
-	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\nx-amz-target:%s\n",
-		req.Header.Get("Content-Type"), host, contentHash, date, req.Header.Get("X-Amz-Target"))
-
-	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date;x-amz-target"
-	canonicalRequest := fmt.Sprintf("POST\n%s\n/model/%s/converse\n\n%s\n\n%s\n%s",
-		canonicalHeaders, strings.Split(req.URL.Path, "/")[3], signedHeaders, contentHash, "aws4_request")
-
-	hashedCanonicalRequest := sha256Hash([]byte(canonicalRequest))
-	credentialScope := fmt.Sprintf("%s/%s/%s/aws4_request", shortDate, region, awsService)
-	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", date, credentialScope, hashedCanonicalRequest)
-
-	signingKey := getSignatureKey(secretKey, shortDate, region, awsService)
-	signature := hmacSHA256(signingKey, []byte(stringToSign))
-
-	authHeader := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
-		accessKey, credentialScope, signedHeaders, hex.EncodeToString(signature))
-	req.Header.Set("Authorization", authHeader)
-}
-
-func sha256Hash(data []byte) string {
-	h := sha256.Sum256(data)
-	return hex.EncodeToString(h[:])
-}
-
-func hmacSHA256(key, data []byte) []byte {
-	h := hmac.New(sha256.New, key)
-	h.Write(data)
-	return h.Sum(nil)
-}
-
-func getSignatureKey(secretKey, dateStamp, regionName, serviceName string) []byte {
-	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(dateStamp))
-	kRegion := hmacSHA256(kDate, []byte(regionName))
-	kService := hmacSHA256(kRegion, []byte(serviceName))
-	kSigning := hmacSHA256(kService, []byte("aws4_request"))
-	return kSigning
+	return resp, nil
 }
@@ -1,14 +1,39 @@
 package aws_bedrock
 
 import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
 	"github.com/atozi-ai/gateway/internal/domain/llm"
 )
 
+var imageFormats = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+var documentFormats = map[string]string{
+	"application/pdf":    "pdf",
+	"text/csv":           "csv",
+	"application/msword": "doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": "docx",
+	"application/vnd.ms-excel": "xls",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "xlsx",
+	"text/html":     "html",
+	"text/plain":    "txt",
+	"text/markdown": "md",
+}
+
 type ConverseRequest struct {
 	Model           string           `json:"model"`
 	Messages        []BedrockMessage `json:"messages"`
 	System          []SystemContent  `json:"system,omitempty"`
 	InferenceConfig InferenceConfig  `json:"inferenceConfig,omitempty"`
+	ToolConfig      *ToolConfig      `json:"toolConfig,omitempty"`
 }
 
 type BedrockMessage struct {
@@ -17,7 +42,74 @@ type BedrockMessage struct {
 }
 
 type ContentBlock struct {
-	Text string `json:"text,omitempty"`
+	Text       string           `json:"text,omitempty"`
+	Image      *ImageBlock      `json:"image,omitempty"`
+	Document   *DocumentBlock   `json:"document,omitempty"`
+	ToolUse    *ToolUseBlock    `json:"toolUse,omitempty"`
+	ToolResult *ToolResultBlock `json:"toolResult,omitempty"`
+}
+
+type ToolUseBlock struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type ToolResultBlock struct {
+	ToolUseID string              `json:"toolUseId"`
+	Content   []ToolResultContent `json:"content"`
+}
+
+// ToolResultContent holds either Text or a JSON value.
+type ToolResultContent struct {
+	Text string          `json:"text,omitempty"`
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+type ToolConfig struct {
+	Tools      []ToolSpecBlock `json:"tools"`
+	ToolChoice *ToolChoice     `json:"toolChoice,omitempty"`
+}
+
+type ToolSpecBlock struct {
+	ToolSpec ToolSpec `json:"toolSpec"`
+}
+
+type ToolSpec struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema InputSchema `json:"inputSchema"`
+}
+
+type InputSchema struct {
+	JSON json.RawMessage `json:"json"`
+}
+
+// ToolChoice sets exactly one of its fields.
+type ToolChoice struct {
+	Auto *struct{}      `json:"auto,omitempty"`
+	Any  *struct{}      `json:"any,omitempty"`
+	Tool *ToolChoiceFor `json:"tool,omitempty"`
+}
+
+type ToolChoiceFor struct {
+	Name string `json:"name"`
+}
+
+type ImageBlock struct {
+	Format string      `json:"format"` // png, jpeg, gif or webp
+	Source BytesSource `json:"source"`
+}
+
+type DocumentBlock struct {
+	Format string      `json:"format"`
+	Name   string      `json:"name"`
+	Source BytesSource `json:"source"`
+}
+
+// BytesSource carries base64-encoded media bytes.
+type BytesSource struct {
+	Bytes string `json:"bytes"`
 }
 
 type SystemContent struct {
@@ -49,40 +141,182 @@ type Message struct {
 }
 
 type ContentBlockResponse struct {
-	Text string `json:"text"`
+	Text    string        `json:"text"`
+	ToolUse *ToolUseBlock `json:"toolUse,omitempty"`
 }
 
 type Usage struct {
-	InputTokens  int `json:"inputTokens"`
-	OutputTokens int `json:"outputTokens"`
-	TotalTokens  int `json:"totalTokens"`
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens"`
+}
+
+// toUsage normalizes Converse usage. inputTokens excludes cached tokens, so
+// they are added back to match the OpenAI prompt_tokens count.
+func (u Usage) toUsage() *llm.Usage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheWriteInputTokens
+	return &llm.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheWriteInputTokens,
+	}
 }
 
+// ConverseStreamChunk holds the payload of any ConverseStream event. Type is
+// the event's name from its :event-type header.
 type ConverseStreamChunk struct {
-	Type       string `json:"type,omitempty"`
-	Delta      *Delta `json:"delta,omitempty"`
-	StopReason string `json:"stopReason,omitempty"`
+	Type              string             `json:"-"`
+	Role              string             `json:"role,omitempty"` // messageStart event
+	ContentBlockIndex int                `json:"contentBlockIndex"`
+	Start             *ContentBlockStart `json:"start,omitempty"`
+	Delta             *Delta             `json:"delta,omitempty"`
+	StopReason        string             `json:"stopReason,omitempty"`
+	Usage             *Usage             `json:"usage,omitempty"` // metadata event
+}
+
+type ContentBlockStart struct {
+	ToolUse *ToolUseStart `json:"toolUse,omitempty"`
+}
+
+type ToolUseStart struct {
+	ToolUseID string `json:"toolUseId"`
+	Name      string `json:"name"`
 }
 
 type Delta struct {
-	Text string `json:"text,omitempty"`
+	Text    string        `json:"text,omitempty"`
+	ToolUse *ToolUseDelta `json:"toolUse,omitempty"`
 }
 
-func convertToBedrockRequest(req llm.ChatRequest) ConverseRequest {
-	messages := make([]BedrockMessage, 0, len(req.Messages))
-	for _, msg := range req.Messages {
-		content := ContentBlock{Text: msg.Content}
-		messages = append(messages, BedrockMessage{
-			Role:    string(msg.Role),
-			Content: []ContentBlock{content},
-		})
+// ToolUseDelta is a fragment of a tool call's JSON input.
+type ToolUseDelta struct {
+	Input string `json:"input"`
+}
+
+// streamState maps Converse stream events to OpenAI-style chunks, numbering
+// tool calls in the order their content blocks start.
+type streamState struct {
+	toolIndexes map[int]int // content block index -> tool call index
+}
+
+func newStreamState() *streamState {
+	return &streamState{toolIndexes: make(map[int]int)}
+}
+
+// toStreamChunk returns nil for events that carry nothing to forward.
+func (s *streamState) toStreamChunk(chunk ConverseStreamChunk) *llm.StreamChunk {
+	var choice llm.StreamChoice
+
+	switch chunk.Type {
+	case "messageStart":
+		role := chunk.Role
+		if role == "" {
+			role = string(llm.RoleAssistant)
+		}
+		choice.Delta.Role = &role
+
+	case "contentBlockStart":
+		if chunk.Start == nil || chunk.Start.ToolUse == nil {
+			return nil
+		}
+		toolIndex := len(s.toolIndexes)
+		s.toolIndexes[chunk.ContentBlockIndex] = toolIndex
+		choice.Delta.ToolCalls = []llm.ToolCallDelta{{
+			Index:    toolIndex,
+			ID:       chunk.Start.ToolUse.ToolUseID,
+			Type:     "function",
+			Function: llm.FunctionCall{Name: chunk.Start.ToolUse.Name},
+		}}
+
+	case "contentBlockDelta":
+		switch {
+		case chunk.Delta == nil:
+			return nil
+		case chunk.Delta.ToolUse != nil:
+			toolIndex, ok := s.toolIndexes[chunk.ContentBlockIndex]
+			if !ok || chunk.Delta.ToolUse.Input == "" {
+				return nil
+			}
+			choice.Delta.ToolCalls = []llm.ToolCallDelta{{
+				Index:    toolIndex,
+				Function: llm.FunctionCall{Arguments: chunk.Delta.ToolUse.Input},
+			}}
+		case chunk.Delta.Text != "":
+			content := chunk.Delta.Text
+			choice.Delta.Content = &content
+		default:
+			return nil
+		}
+
+	case "messageStop":
+		reason := finishReason(chunk.StopReason)
+		if reason == "" {
+			reason = "stop"
+		}
+		choice.FinishReason = &reason
+
+	case "metadata":
+		if chunk.Usage == nil {
+			return nil
+		}
+		return &llm.StreamChunk{Choices: []llm.StreamChoice{}, Usage: chunk.Usage.toUsage()}
+
+	default:
+		return nil
+	}
+
+	return &llm.StreamChunk{Choices: []llm.StreamChoice{choice}}
+}
+
+func convertToBedrockRequest(req llm.ChatRequest) (ConverseRequest, error) {
+	if err := llm.CheckParts("bedrock", req.Messages, llm.PartImage, llm.PartFile); err != nil {
+		return ConverseRequest{}, err
 	}
 
+	messages := make([]BedrockMessage, 0, len(req.Messages))
 	system := make([]SystemContent, 0)
 	for _, msg := range req.Messages {
-		if msg.Role == llm.RoleSystem {
-			system = append(system, SystemContent{Text: msg.Content})
+		switch msg.Role {
+		case llm.RoleSystem:
+			system = append(system, SystemContent{Text: msg.Text()})
+			continue
+
+		case llm.RoleTool:
+			result, err := toToolResult(msg)
+			if err != nil {
+				return ConverseRequest{}, err
+			}
+			// Results for parallel tool calls go back in a single user turn.
+			if n := len(messages); n > 0 && isToolResultTurn(messages[n-1]) {
+				messages[n-1].Content = append(messages[n-1].Content, result)
+				continue
+			}
+			messages = append(messages, BedrockMessage{
+				Role:    string(llm.RoleUser),
+				Content: []ContentBlock{result},
+			})
+			continue
 		}
+
+		content, err := toContentBlocks(msg)
+		if err != nil {
+			return ConverseRequest{}, err
+		}
+		for _, tc := range msg.ToolCalls {
+			block, err := toToolUse(tc)
+			if err != nil {
+				return ConverseRequest{}, err
+			}
+			content = append(content, block)
+		}
+		messages = append(messages, BedrockMessage{
+			Role:    string(msg.Role),
+			Content: content,
+		})
 	}
 
 	infConfig := InferenceConfig{}
@@ -105,18 +339,191 @@ func convertToBedrockRequest(req llm.ChatRequest) ConverseRequest {
 		Messages:        messages,
 		System:          system,
 		InferenceConfig: infConfig,
+		ToolConfig:      toToolConfig(req.Options),
+	}, nil
+}
+
+func isToolResultTurn(m BedrockMessage) bool {
+	return m.Role == string(llm.RoleUser) && len(m.Content) > 0 && m.Content[0].ToolResult != nil
+}
+
+// toToolConfig declares the request's tools. Converse has no equivalent of
+// tool_choice "none", so in that case the tools are left out entirely.
+func toToolConfig(opts llm.ChatOptions) *ToolConfig {
+	if len(opts.Tools) == 0 {
+		return nil
+	}
+
+	cfg := &ToolConfig{}
+	switch mode, name := llm.ResolveToolChoice(opts.ToolChoice); mode {
+	case "none":
+		return nil
+	case "auto":
+		cfg.ToolChoice = &ToolChoice{Auto: &struct{}{}}
+	case "required":
+		cfg.ToolChoice = &ToolChoice{Any: &struct{}{}}
+	case "function":
+		cfg.ToolChoice = &ToolChoice{Tool: &ToolChoiceFor{Name: name}}
+	}
+
+	for _, t := range opts.Tools {
+		if t.Function == nil {
+			continue
+		}
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		cfg.Tools = append(cfg.Tools, ToolSpecBlock{ToolSpec: ToolSpec{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: InputSchema{JSON: schema},
+		}})
 	}
+	return cfg
+}
+
+func toToolUse(tc llm.ToolCall) (ContentBlock, error) {
+	input := json.RawMessage(tc.Function.Arguments)
+	if len(input) == 0 {
+		input = json.RawMessage(`{}`)
+	} else if !json.Valid(input) {
+		return ContentBlock{}, llm.NewValidationError("tool call arguments must be valid JSON", "invalid_tool_arguments")
+	}
+	return ContentBlock{ToolUse: &ToolUseBlock{
+		ToolUseID: tc.ID,
+		Name:      tc.Function.Name,
+		Input:     input,
+	}}, nil
+}
+
+// toToolResult maps a tool message to a toolResult block. Results that are
+// JSON objects are passed as json content, anything else as text.
+func toToolResult(msg llm.Message) (ContentBlock, error) {
+	if msg.ToolCallID == "" {
+		return ContentBlock{}, llm.NewValidationError("tool messages require tool_call_id", "missing_tool_call_id")
+	}
+
+	text := msg.Text()
+	content := ToolResultContent{Text: text}
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		content = ToolResultContent{JSON: json.RawMessage(trimmed)}
+	}
+
+	return ContentBlock{ToolResult: &ToolResultBlock{
+		ToolUseID: msg.ToolCallID,
+		Content:   []ToolResultContent{content},
+	}}, nil
+}
+
+// toContentBlocks maps message content to Converse text, image and document
+// blocks. Converse only takes media as inline bytes.
+func toContentBlocks(msg llm.Message) ([]ContentBlock, error) {
+	if len(msg.Parts) == 0 {
+		// An assistant turn that only calls tools has no text block.
+		if msg.Content == "" && len(msg.ToolCalls) > 0 {
+			return nil, nil
+		}
+		return []ContentBlock{{Text: msg.Content}}, nil
+	}
+
+	blocks := make([]ContentBlock, 0, len(msg.Parts))
+	for i, part := range msg.Parts {
+		if part.Type == llm.PartText {
+			blocks = append(blocks, ContentBlock{Text: part.Text})
+			continue
+		}
+
+		if part.Data == "" {
+			return nil, llm.NewValidationError(fmt.Sprintf("bedrock requires %s content as base64 data, not a URL or file ID", part.Type), "unsupported_content")
+		}
+
+		if part.Type == llm.PartImage {
+			format, ok := imageFormats[part.MediaType]
+			if !ok {
+				return nil, llm.NewValidationError(fmt.Sprintf("bedrock does not accept images of type %q", part.MediaType), "unsupported_content")
+			}
+			blocks = append(blocks, ContentBlock{Image: &ImageBlock{Format: format, Source: BytesSource{Bytes: part.Data}}})
+			continue
+		}
+
+		format, ok := documentFormats[part.MediaType]
+		if !ok {
+			return nil, llm.NewValidationError(fmt.Sprintf("bedrock does not accept documents of type %q", part.MediaType), "unsupported_content")
+		}
+		blocks = append(blocks, ContentBlock{Document: &DocumentBlock{
+			Format: format,
+			Name:   documentName(part.Filename, i),
+			Source: BytesSource{Bytes: part.Data},
+		}})
+	}
+	return blocks, nil
+}
+
+// documentName derives a document name Bedrock accepts: alphanumerics,
+// whitespace, hyphens, parentheses and square brackets only.
+func documentName(filename string, index int) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == ' ', r == '-', r == '(', r == ')', r == '[', r == ']':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSuffix(filename, path.Ext(filename)))
+
+	if strings.Trim(name, "- ") == "" {
+		return fmt.Sprintf("document-%d", index+1)
+	}
+	return name
 }
 
 func convertFromBedrockResponse(resp ConverseResponse) *llm.ChatResponse {
 	content := ""
-	if len(resp.Output.Message.Content) > 0 {
-		content = resp.Output.Message.Content[0].Text
+	var toolCalls []llm.ToolCall
+	for _, block := range resp.Output.Message.Content {
+		if block.ToolUse == nil {
+			content += block.Text
+			continue
+		}
+		args := string(block.ToolUse.Input)
+		if args == "" {
+			args = "{}"
+		}
+		toolCalls = append(toolCalls, llm.ToolCall{
+			ID:   block.ToolUse.ToolUseID,
+			Type: "function",
+			Function: llm.FunctionCall{
+				Name:      block.ToolUse.Name,
+				Arguments: args,
+			},
+		})
 	}
 
 	return &llm.ChatResponse{
-		ID:      resp.ID,
-		Model:   resp.Model,
-		Content: content,
+		ID:           resp.ID,
+		Model:        resp.Model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason(resp.StopReason),
+		Usage:        resp.Usage.toUsage(),
+	}
+}
+
+// finishReason maps a Converse stopReason to the OpenAI finish reason.
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "":
+		return ""
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "guardrail_intervened", "content_filtered":
+		return "content_filter"
+	default:
+		return "stop"
 	}
 }
@@ -0,0 +1,317 @@
+package aws_bedrock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/sigv4"
+)
+
+// refreshWindow is how long before expiry temporary credentials are renewed.
+const refreshWindow = 5 * time.Minute
+
+// errNoCredentials is returned by a credential source that is not set up, so
+// the chain moves on to the next one.
+var errNoCredentials = errors.New("no credentials")
+
+type credentialSource interface {
+	Retrieve(ctx context.Context) (sigv4.Credentials, error)
+}
+
+func credentialsError(message string) *llm.ProviderError {
+	return &llm.ProviderError{
+		StatusCode: 401,
+		Message:    message,
+		Type:       "authentication_error",
+		Code:       "aws_credentials_failed",
+	}
+}
+
+// newCredentialChain builds the default credential chain: configured keys
+// (including AWS_ACCESS_KEY_ID and friends), then a web identity token from
+// AWS_WEB_IDENTITY_TOKEN_FILE, then the shared credentials and config files.
+// With roleARN set, whatever the chain finds is used to assume that role.
+// Results are cached until shortly before they expire.
+func newCredentialChain(cfg Config, sts *stsClient) credentialSource {
+	var chain chainCredentials
+	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
+		chain = append(chain, staticCredentials{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+		})
+	}
+	chain = append(chain,
+		&webIdentityCredentials{sts: sts},
+		&profileCredentials{name: cfg.Profile, sts: sts},
+	)
+
+	var source credentialSource = chain
+	if cfg.RoleARN != "" {
+		source = &assumeRoleCredentials{base: chain, roleARN: cfg.RoleARN, sts: sts}
+	}
+	return &cachedCredentials{source: source}
+}
+
+type staticCredentials sigv4.Credentials
+
+func (s staticCredentials) Retrieve(context.Context) (sigv4.Credentials, error) {
+	return sigv4.Credentials(s), nil
+}
+
+// chainCredentials returns the credentials of the first source that has any.
+type chainCredentials []credentialSource
+
+func (c chainCredentials) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	for _, source := range c {
+		creds, err := source.Retrieve(ctx)
+		if errors.Is(err, errNoCredentials) {
+			continue
+		}
+		return creds, err
+	}
+	return sigv4.Credentials{}, errNoCredentials
+}
+
+// cachedCredentials keeps the last credentials from source until they are
+// within refreshWindow of expiring. Credentials without an expiry are kept
+// for good.
+type cachedCredentials struct {
+	source credentialSource
+
+	mu    sync.Mutex
+	creds sigv4.Credentials
+}
+
+func (c *cachedCredentials) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds.AccessKeyID != "" && (c.creds.Expires.IsZero() || time.Until(c.creds.Expires) > refreshWindow) {
+		return c.creds, nil
+	}
+	creds, err := c.source.Retrieve(ctx)
+	if err != nil {
+		return sigv4.Credentials{}, err
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+// assumeRoleCredentials uses the credentials from base to assume roleARN.
+type assumeRoleCredentials struct {
+	base        credentialSource
+	roleARN     string
+	sessionName string
+	externalID  string
+	sts         *stsClient
+}
+
+func (a *assumeRoleCredentials) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	base, err := a.base.Retrieve(ctx)
+	if err != nil {
+		return sigv4.Credentials{}, err
+	}
+	sessionName := a.sessionName
+	if sessionName == "" {
+		sessionName = defaultSessionName
+	}
+	return a.sts.assumeRole(ctx, base, a.roleARN, sessionName, a.externalID)
+}
+
+// webIdentityCredentials exchanges the token in tokenFile for credentials of
+// roleARN. Empty fields are read from AWS_WEB_IDENTITY_TOKEN_FILE,
+// AWS_ROLE_ARN and AWS_ROLE_SESSION_NAME, as on EKS and other OIDC hosts.
+// The file is read on every call because the token is rotated.
+type webIdentityCredentials struct {
+	tokenFile   string
+	roleARN     string
+	sessionName string
+	sts         *stsClient
+}
+
+func (w *webIdentityCredentials) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	tokenFile, roleARN, sessionName := w.tokenFile, w.roleARN, w.sessionName
+	if tokenFile == "" {
+		tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		roleARN = os.Getenv("AWS_ROLE_ARN")
+		sessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+	}
+	if tokenFile == "" || roleARN == "" {
+		return sigv4.Credentials{}, errNoCredentials
+	}
+	if sessionName == "" {
+		sessionName = defaultSessionName
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("failed to read web identity token: %v", err))
+	}
+	return w.sts.assumeRoleWithWebIdentity(ctx, roleARN, sessionName, strings.TrimSpace(string(token)))
+}
+
+// profileCredentials reads a profile from the shared credentials and config
+// files (~/.aws/credentials and ~/.aws/config, or AWS_SHARED_CREDENTIALS_FILE
+// and AWS_CONFIG_FILE). A profile may hold keys, or a role_arn to assume with
+// a source_profile or a web_identity_token_file. The profile is name, else
+// AWS_PROFILE, else "default".
+type profileCredentials struct {
+	name string
+	sts  *stsClient
+}
+
+// maxSourceProfiles bounds source_profile chains, which may loop.
+const maxSourceProfiles = 5
+
+func (p *profileCredentials) Retrieve(ctx context.Context) (sigv4.Credentials, error) {
+	name := p.name
+	if name == "" {
+		name = os.Getenv("AWS_PROFILE")
+	}
+	explicit := name != ""
+	if !explicit {
+		name = "default"
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("failed to read AWS profiles: %v", err))
+	}
+	if _, ok := profiles[name]; !ok {
+		if !explicit {
+			return sigv4.Credentials{}, errNoCredentials
+		}
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("AWS profile %q not found", name))
+	}
+	return p.resolve(ctx, profiles, name, 0)
+}
+
+func (p *profileCredentials) resolve(ctx context.Context, profiles map[string]map[string]string, name string, depth int) (sigv4.Credentials, error) {
+	profile, ok := profiles[name]
+	if !ok {
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("AWS profile %q not found", name))
+	}
+
+	static := sigv4.Credentials{
+		AccessKeyID:     profile["aws_access_key_id"],
+		SecretAccessKey: profile["aws_secret_access_key"],
+		SessionToken:    profile["aws_session_token"],
+	}
+
+	roleARN := profile["role_arn"]
+	if roleARN == "" {
+		if static.AccessKeyID == "" || static.SecretAccessKey == "" {
+			return sigv4.Credentials{}, credentialsError(fmt.Sprintf("AWS profile %q has no credentials", name))
+		}
+		return static, nil
+	}
+
+	if tokenFile := profile["web_identity_token_file"]; tokenFile != "" {
+		w := &webIdentityCredentials{tokenFile: tokenFile, roleARN: roleARN, sessionName: profile["role_session_name"], sts: p.sts}
+		return w.Retrieve(ctx)
+	}
+
+	source := profile["source_profile"]
+	var base credentialSource
+	switch {
+	case source == "":
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("AWS profile %q has role_arn but no source_profile or web_identity_token_file", name))
+	case source == name:
+		base = staticCredentials(static)
+	case depth >= maxSourceProfiles:
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("AWS profile %q: source_profile chain is too long", name))
+	default:
+		creds, err := p.resolve(ctx, profiles, source, depth+1)
+		if err != nil {
+			return sigv4.Credentials{}, err
+		}
+		base = staticCredentials(creds)
+	}
+
+	assume := &assumeRoleCredentials{
+		base:        base,
+		roleARN:     roleARN,
+		sessionName: profile["role_session_name"],
+		externalID:  profile["external_id"],
+		sts:         p.sts,
+	}
+	return assume.Retrieve(ctx)
+}
+
+// loadProfiles merges the config and credentials files into settings by
+// profile name. Keys in the credentials file win. Missing files are skipped.
+func loadProfiles() (map[string]map[string]string, error) {
+	home, _ := os.UserHomeDir()
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+
+	profiles := make(map[string]map[string]string)
+	if err := readProfileFile(configFile, true, profiles); err != nil {
+		return nil, err
+	}
+	if err := readProfileFile(credentialsFile, false, profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// readProfileFile parses an INI-style AWS profile file into profiles. In the
+// config file, sections other than [default] are named [profile NAME].
+func readProfileFile(path string, isConfig bool, profiles map[string]map[string]string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if isConfig && name != "default" {
+				var ok bool
+				if name, ok = strings.CutPrefix(name, "profile "); !ok {
+					current = nil // sso-session and other non-profile sections
+					continue
+				}
+				name = strings.TrimSpace(name)
+			}
+			if profiles[name] == nil {
+				profiles[name] = make(map[string]string)
+			}
+			current = profiles[name]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return scanner.Err()
+}
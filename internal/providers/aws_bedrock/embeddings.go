@@ -0,0 +1,113 @@
+package aws_bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// cohereMaxTexts is the most texts Cohere embed models accept per call.
+const cohereMaxTexts = 96
+
+type titanEmbedRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions *int   `json:"dimensions,omitempty"`
+}
+
+type titanEmbedResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+type cohereEmbedRequest struct {
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+}
+
+type cohereEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed supports the Amazon Titan and Cohere embedding models. Titan embeds one
+// text per call, so batches are sent sequentially.
+func (p *Provider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	switch {
+	case strings.Contains(req.Model, "amazon.titan-embed"):
+		return p.embedTitan(ctx, req)
+	case strings.Contains(req.Model, "cohere.embed"):
+		return p.embedCohere(ctx, req)
+	default:
+		return nil, llm.NewValidationError(fmt.Sprintf("model %q is not a supported Bedrock embedding model", req.Model), "embeddings_not_supported")
+	}
+}
+
+func (p *Provider) embedTitan(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	resp := &llm.EmbeddingResponse{
+		Model:      req.Model,
+		Embeddings: make([][]float32, len(req.Input)),
+	}
+
+	for i, text := range req.Input {
+		var out titanEmbedResponse
+		if err := p.invoke(ctx, req.Model, titanEmbedRequest{InputText: text, Dimensions: req.Dimensions}, &out); err != nil {
+			return nil, err
+		}
+		resp.Embeddings[i] = out.Embedding
+		resp.Usage.PromptTokens += out.InputTextTokenCount
+	}
+
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	return resp, nil
+}
+
+func (p *Provider) embedCohere(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	resp := &llm.EmbeddingResponse{Model: req.Model}
+
+	for start := 0; start < len(req.Input); start += cohereMaxTexts {
+		end := min(start+cohereMaxTexts, len(req.Input))
+
+		var out cohereEmbedResponse
+		if err := p.invoke(ctx, req.Model, cohereEmbedRequest{Texts: req.Input[start:end], InputType: "search_document"}, &out); err != nil {
+			return nil, err
+		}
+		if len(out.Embeddings) != end-start {
+			return nil, llm.NewProviderError(502, fmt.Sprintf("provider returned %d embeddings for %d inputs", len(out.Embeddings), end-start), "api_error", "invalid_response")
+		}
+		resp.Embeddings = append(resp.Embeddings, out.Embeddings...)
+	}
+
+	return resp, nil
+}
+
+// invoke calls the InvokeModel API with a JSON body and decodes the response into out.
+func (p *Provider) invoke(ctx context.Context, model string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return llm.NewInternalError(fmt.Sprintf("failed to marshal request: %v", err))
+	}
+
+	creds, err := p.defaultCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.post(ctx, creds, p.awsRegion, model, "invoke", body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return llm.NewInternalError(fmt.Sprintf("failed to read response: %v", err))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package aws_bedrock
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// ConverseStream answers with application/vnd.amazon.eventstream: a sequence
+// of binary frames, each laid out as
+//
+//	total length (4) | headers length (4) | prelude CRC (4) | headers | payload | message CRC (4)
+//
+// with big-endian integers and CRC32 (IEEE) checksums. The headers name the
+// message type and event; the payload is the event's JSON.
+
+const (
+	preludeLen     = 12
+	frameCRCLen    = 4
+	maxFrameLength = 16 * 1024 * 1024
+)
+
+// Header value types defined by the event-stream encoding.
+const (
+	headerTrue byte = iota
+	headerFalse
+	headerByte
+	headerShort
+	headerInt
+	headerLong
+	headerBytes
+	headerString
+	headerTimestamp
+	headerUUID
+)
+
+// eventMessage is one decoded frame. Only string headers are kept, which is
+// all Bedrock sends.
+type eventMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+type eventStreamDecoder struct {
+	r io.Reader
+}
+
+func newEventStreamDecoder(r io.Reader) *eventStreamDecoder {
+	return &eventStreamDecoder{r: r}
+}
+
+// Next reads the next frame, validating both checksums. It returns io.EOF at
+// a clean end of stream and io.ErrUnexpectedEOF if a frame is cut short.
+func (d *eventStreamDecoder) Next() (*eventMessage, error) {
+	var prelude [preludeLen]byte
+	if _, err := io.ReadFull(d.r, prelude[:]); err != nil {
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc := crc32.ChecksumIEEE(prelude[:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream prelude checksum mismatch")
+	}
+	if totalLen > maxFrameLength || uint64(totalLen) < uint64(preludeLen)+uint64(headersLen)+frameCRCLen {
+		return nil, fmt.Errorf("invalid event stream frame length %d", totalLen)
+	}
+
+	frame := make([]byte, totalLen)
+	copy(frame, prelude[:])
+	if _, err := io.ReadFull(d.r, frame[preludeLen:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	crcAt := totalLen - frameCRCLen
+	if crc := crc32.ChecksumIEEE(frame[:crcAt]); crc != binary.BigEndian.Uint32(frame[crcAt:]) {
+		return nil, fmt.Errorf("event stream message checksum mismatch")
+	}
+
+	headersEnd := preludeLen + headersLen
+	headers, err := parseHeaders(frame[preludeLen:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+	return &eventMessage{Headers: headers, Payload: frame[headersEnd:crcAt]}, nil
+}
+
+func parseHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, fmt.Errorf("truncated event stream header")
+		}
+		name := string(b[1 : 1+nameLen])
+		valueType := b[1+nameLen]
+		b = b[2+nameLen:]
+
+		var size int
+		switch valueType {
+		case headerTrue, headerFalse:
+			size = 0
+		case headerByte:
+			size = 1
+		case headerShort:
+			size = 2
+		case headerInt:
+			size = 4
+		case headerLong, headerTimestamp:
+			size = 8
+		case headerUUID:
+			size = 16
+		case headerBytes, headerString:
+			if len(b) < 2 {
+				return nil, fmt.Errorf("truncated event stream header %q", name)
+			}
+			size = int(binary.BigEndian.Uint16(b[:2]))
+			b = b[2:]
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d for %q", valueType, name)
+		}
+		if len(b) < size {
+			return nil, fmt.Errorf("truncated event stream header %q", name)
+		}
+		if valueType == headerString {
+			headers[name] = string(b[:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
+
+// exceptionStatus maps Bedrock exception types to the HTTP status the same
+// error has outside a stream.
+var exceptionStatus = map[string]int{
+	"validationException":           http.StatusBadRequest,
+	"accessDeniedException":         http.StatusForbidden,
+	"resourceNotFoundException":     http.StatusNotFound,
+	"modelTimeoutException":         http.StatusRequestTimeout,
+	"throttlingException":           http.StatusTooManyRequests,
+	"modelNotReadyException":        http.StatusTooManyRequests,
+	"modelStreamErrorException":     http.StatusFailedDependency,
+	"internalServerException":       http.StatusInternalServerError,
+	"serviceUnavailableException":   http.StatusServiceUnavailable,
+	"serviceQuotaExceededException": http.StatusBadRequest,
+}
+
+// toConverseChunk decodes a ConverseStream event into a chunk tagged with its
+// event type. Exception and error messages become a *llm.ProviderError.
+func toConverseChunk(msg *eventMessage) (ConverseStreamChunk, error) {
+	switch msg.Headers[":message-type"] {
+	case "event":
+		var chunk ConverseStreamChunk
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				return chunk, llm.NewInternalError(fmt.Sprintf("failed to decode %s event: %v", msg.Headers[":event-type"], err))
+			}
+		}
+		chunk.Type = msg.Headers[":event-type"]
+		return chunk, nil
+
+	case "exception":
+		exceptionType := msg.Headers[":exception-type"]
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(msg.Payload, &body)
+		status, ok := exceptionStatus[exceptionType]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		return ConverseStreamChunk{}, &llm.ProviderError{
+			StatusCode: status,
+			Message:    fmt.Sprintf("bedrock %s: %s", exceptionType, body.Message),
+			Type:       "api_error",
+			Code:       exceptionType,
+		}
+
+	default:
+		return ConverseStreamChunk{}, &llm.ProviderError{
+			StatusCode: http.StatusBadGateway,
+			Message:    fmt.Sprintf("bedrock stream error %s: %s", msg.Headers[":error-code"], msg.Headers[":error-message"]),
+			Type:       "api_error",
+			Code:       msg.Headers[":error-code"],
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package aws_bedrock
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// deltaFrame is a contentBlockDelta frame laid out as Bedrock sends it,
+// with the padding field "p" it adds to obscure payload sizes.
+const deltaFrame = "000000c500000057b1d87c950b3a6576656e742d74797065070011636f6e7465" +
+	"6e74426c6f636b44656c74610d3a636f6e74656e742d74797065070010617070" +
+	"6c69636174696f6e2f6a736f6e0d3a6d6573736167652d747970650700056576" +
+	"656e747b22636f6e74656e74426c6f636b496e646578223a302c2264656c7461" +
+	"223a7b2274657874223a2248656c6c6f227d2c2270223a226162636465666768" +
+	"696a6b6c6d6e6f707172737475767778797a4142434445464748494a4b4c4d22" +
+	"7d3aa35337"
+
+func frameBytes(t *testing.T) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(deltaFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEventStreamDecoderFrame(t *testing.T) {
+	d := newEventStreamDecoder(bytes.NewReader(frameBytes(t)))
+
+	msg, err := d.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	want := map[string]string{
+		":event-type":   "contentBlockDelta",
+		":content-type": "application/json",
+		":message-type": "event",
+	}
+	for name, value := range want {
+		if msg.Headers[name] != value {
+			t.Errorf("header %s = %q, want %q", name, msg.Headers[name], value)
+		}
+	}
+
+	chunk, err := toConverseChunk(msg)
+	if err != nil {
+		t.Fatalf("toConverseChunk: %v", err)
+	}
+	if chunk.Type != "contentBlockDelta" || chunk.Delta == nil || chunk.Delta.Text != "Hello" {
+		t.Errorf("chunk = %+v, want a contentBlockDelta with text %q", chunk, "Hello")
+	}
+
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("Next at end of stream = %v, want io.EOF", err)
+	}
+}
+
+func TestEventStreamDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		want    string // Substring of the error, or "" for io.ErrUnexpectedEOF
+	}{
+		{
+			name:    "bad prelude CRC",
+			corrupt: func(b []byte) []byte { b[8] ^= 0xff; return b },
+			want:    "prelude checksum mismatch",
+		},
+		{
+			name:    "bad message CRC",
+			corrupt: func(b []byte) []byte { b[len(b)-10] ^= 0xff; return b },
+			want:    "message checksum mismatch",
+		},
+		{
+			name:    "truncated frame",
+			corrupt: func(b []byte) []byte { return b[:len(b)-20] },
+		},
+		{
+			name:    "truncated prelude",
+			corrupt: func(b []byte) []byte { return b[:6] },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newEventStreamDecoder(bytes.NewReader(tt.corrupt(frameBytes(t))))
+
+			_, err := d.Next()
+			if tt.want == "" {
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("Next = %v, want io.ErrUnexpectedEOF", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Next = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
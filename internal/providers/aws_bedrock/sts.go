@@ -0,0 +1,130 @@
+package aws_bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/sigv4"
+)
+
+const defaultSessionName = "atozi-gateway"
+
+// stsClient calls the STS actions that exchange credentials for temporary
+// ones. AWS_ENDPOINT_URL_STS overrides the regional endpoint, as in the AWS
+// SDKs.
+type stsClient struct {
+	region     string
+	httpClient *http.Client
+}
+
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// stsResponse matches both AssumeRoleResponse and
+// AssumeRoleWithWebIdentityResponse.
+type stsResponse struct {
+	AssumeRole      stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	WebIdentityRole stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+type stsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (c *stsClient) endpoint() string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_STS"); endpoint != "" {
+		return endpoint
+	}
+	return fmt.Sprintf("https://sts.%s.amazonaws.com/", c.region)
+}
+
+// assumeRole exchanges creds for credentials of roleARN.
+func (c *stsClient) assumeRole(ctx context.Context, creds sigv4.Credentials, roleARN, sessionName, externalID string) (sigv4.Credentials, error) {
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleARN},
+		"RoleSessionName": {sessionName},
+	}
+	if externalID != "" {
+		form.Set("ExternalId", externalID)
+	}
+	return c.call(ctx, form, &creds)
+}
+
+// assumeRoleWithWebIdentity exchanges an OIDC token for credentials of
+// roleARN. The call is not signed; the token is the proof of identity.
+func (c *stsClient) assumeRoleWithWebIdentity(ctx context.Context, roleARN, sessionName, token string) (sigv4.Credentials, error) {
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {token},
+	}
+	return c.call(ctx, form, nil)
+}
+
+func (c *stsClient) call(ctx context.Context, form url.Values, creds *sigv4.Credentials) (sigv4.Credentials, error) {
+	body := []byte(form.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return sigv4.Credentials{}, llm.NewInternalError(fmt.Sprintf("failed to create STS request: %v", err))
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if creds != nil {
+		sigv4.Signer{Service: "sts", Region: c.region}.Sign(httpReq, body, *creds, time.Now())
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return sigv4.Credentials{}, llm.NewProviderError(503, fmt.Sprintf("STS %s failed: %v", form.Get("Action"), err), "service_unavailable", "request_failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return sigv4.Credentials{}, llm.NewInternalError(fmt.Sprintf("failed to read STS response: %v", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsErrorResponse
+		if xml.Unmarshal(respBody, &stsErr) != nil || stsErr.Message == "" {
+			stsErr.Message = strings.TrimSpace(string(respBody))
+		}
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("STS %s failed (%d %s): %s", form.Get("Action"), resp.StatusCode, stsErr.Code, stsErr.Message))
+	}
+
+	var out stsResponse
+	if err := xml.Unmarshal(respBody, &out); err != nil {
+		return sigv4.Credentials{}, llm.NewInternalError(fmt.Sprintf("failed to decode STS response: %v", err))
+	}
+	result := out.AssumeRole
+	if result.AccessKeyID == "" {
+		result = out.WebIdentityRole
+	}
+	if result.AccessKeyID == "" {
+		return sigv4.Credentials{}, credentialsError(fmt.Sprintf("STS %s returned no credentials", form.Get("Action")))
+	}
+
+	return sigv4.Credentials{
+		AccessKeyID:     result.AccessKeyID,
+		SecretAccessKey: result.SecretAccessKey,
+		SessionToken:    result.SessionToken,
+		Expires:         result.Expiration,
+	}, nil
+}
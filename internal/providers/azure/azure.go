@@ -2,51 +2,184 @@ package azure
 
 import (
 	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 	openaicompat "github.com/atozi-ai/gateway/internal/providers/openai_compat"
 )
 
-// Provider implements llm.Provider for the OpenAI API.
+// defaultAPIVersion is the latest generally available Azure OpenAI data plane
+// API version.
+const defaultAPIVersion = "2024-10-21"
+
+// Provider implements llm.Provider for Azure OpenAI.
 type Provider struct {
-	apiKey   string
-	endpoint string
+	endpoint    string
+	apiVersion  string
+	deployments map[string]string
+
+	keyClient   *openaicompat.Client // api-key header
+	tokenClient *openaicompat.Client // Entra ID bearer token
+	tokens      *entraTokenSource    // nil without Entra ID credentials
+}
+
+// Config holds the Azure OpenAI resource and how to authenticate with it.
+type Config struct {
+	// Endpoint is the resource URL, such as https://NAME.openai.azure.com. A
+	// full deployment URL is accepted too, and used as it is.
+	Endpoint   string
+	APIVersion string
+	// Deployments maps gateway model names to deployment names. Models
+	// without an entry are sent to the deployment of the same name.
+	Deployments map[string]string
+
+	APIKey string
+	// TenantID, ClientID and ClientSecret enable Microsoft Entra ID
+	// authentication. An API key sent with a request still takes precedence.
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+
+	Timeout time.Duration
 }
 
 func New(apiKey string, endpoint string) *Provider {
-	return &Provider{
-		apiKey:   apiKey,
-		endpoint: endpoint,
+	return NewWithConfig(Config{
+		Endpoint: endpoint,
+		APIKey:   apiKey,
+	})
+}
+
+func NewWithConfig(cfg Config) *Provider {
+	apiVersion := cfg.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAPIVersion
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 120 * time.Second
+	}
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: tracing.Transport(nil),
+	}
+
+	p := &Provider{
+		endpoint:    strings.TrimRight(cfg.Endpoint, "/"),
+		apiVersion:  apiVersion,
+		deployments: cfg.Deployments,
+	}
+
+	parts := []llm.ContentPartType{llm.PartImage, llm.PartAudio, llm.PartFile}
+	p.keyClient = openaicompat.NewClientWithCustomHTTP(openaicompat.Config{
+		APIKey:  cfg.APIKey,
+		Headers: map[string]string{"api-key": cfg.APIKey},
+		Parts:   parts,
+		ChatURL: p.chatURL,
+	}, httpClient)
+	p.tokenClient = openaicompat.NewClientWithCustomHTTP(openaicompat.Config{
+		Parts:   parts,
+		ChatURL: p.chatURL,
+	}, httpClient)
+
+	if cfg.TenantID != "" && cfg.ClientID != "" && cfg.ClientSecret != "" {
+		p.tokens = &entraTokenSource{
+			tenantID:     cfg.TenantID,
+			clientID:     cfg.ClientID,
+			clientSecret: cfg.ClientSecret,
+			httpClient:   httpClient,
+		}
 	}
+	return p
 }
 
 func (p *Provider) Name() string { return "azure" }
 
 func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
-	client := p.getClient(req)
+	client, req, err := p.getClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	return client.Chat(ctx, req)
 }
 
 func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
-	client := p.getClient(req)
+	client, req, err := p.getClient(ctx, req)
+	if err != nil {
+		return err
+	}
 	return client.ChatStream(ctx, req, callback)
 }
 
-func (p *Provider) getClient(req llm.ChatRequest) *openaicompat.Client {
-	endpoint := p.endpoint
+// getClient picks API key or Entra ID authentication for req. An API key
+// sent with the request wins; otherwise Entra ID is used when configured,
+// and the configured API key when not. The configured credentials are only
+// ever sent to the configured endpoint, so an endpoint chosen by the caller
+// needs a key of the caller's own.
+func (p *Provider) getClient(ctx context.Context, req llm.ChatRequest) (*openaicompat.Client, llm.ChatRequest, error) {
+	endpoint := p.resourceEndpoint(req)
+	if endpoint == "" {
+		return nil, req, &llm.ProviderError{
+			StatusCode: 400,
+			Message:    "Azure endpoint is required. Configure baseURL for the azure provider or provide azureEndpoint in request options",
+			Type:       "invalid_request_error",
+			Code:       "missing_endpoint",
+		}
+	}
+	if req.APIKey == "" && endpoint != p.endpoint {
+		return nil, req, &llm.ProviderError{
+			StatusCode: 400,
+			Message:    "azureEndpoint requires an Azure API key with the request; the gateway's credentials are only sent to the configured endpoint",
+			Type:       "invalid_request_error",
+			Code:       "invalid_endpoint",
+			Param:      "azureEndpoint",
+		}
+	}
+
+	if req.APIKey != "" || p.tokens == nil {
+		return p.keyClient, req, nil
+	}
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return nil, req, err
+	}
+	req.APIKey = token
+	return p.tokenClient, req, nil
+}
+
+func (p *Provider) resourceEndpoint(req llm.ChatRequest) string {
 	if req.Options.AzureEndpoint != nil && *req.Options.AzureEndpoint != "" {
-		endpoint = *req.Options.AzureEndpoint
+		return strings.TrimRight(*req.Options.AzureEndpoint, "/")
 	}
+	return p.endpoint
+}
 
-	if endpoint == "" {
-		panic("Azure endpoint is required. Provide azure_endpoint in request options")
+// chatURL returns the chat completions URL of the deployment for req.Model,
+// with the api-version query parameter.
+func (p *Provider) chatURL(req llm.ChatRequest) string {
+	endpoint := p.resourceEndpoint(req)
+
+	target := endpoint
+	if !strings.Contains(endpoint, "/openai/deployments/") {
+		deployment := req.Model
+		if d, ok := p.deployments[req.Model]; ok {
+			deployment = d
+		}
+		target = endpoint + "/openai/deployments/" + url.PathEscape(deployment) + "/chat/completions"
 	}
 
-	return openaicompat.NewClient(openaicompat.Config{
-		BaseURL: endpoint,
-		APIKey:  p.apiKey,
-		Headers: map[string]string{
-			"api-key": p.apiKey,
-		},
-	})
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := u.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", p.apiVersion)
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
 }
@@ -0,0 +1,155 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+const (
+	cognitiveServicesScope = "https://cognitiveservices.azure.com/.default"
+	defaultAuthorityHost   = "https://login.microsoftonline.com"
+
+	// refreshWindow is how long before expiry a token is renewed.
+	refreshWindow = 5 * time.Minute
+)
+
+// entraTokenSource gets Microsoft Entra ID tokens for Azure OpenAI with the
+// client credentials flow and caches them until shortly before they expire.
+// AZURE_AUTHORITY_HOST overrides the authority for sovereign clouds, as in
+// the Azure SDKs.
+//
+// Concurrent callers share one token request, and none of them holds the
+// lock while it runs.
+type entraTokenSource struct {
+	tenantID     string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	refresh *tokenRefresh // In flight, or nil
+}
+
+// tokenRefresh is one token request. done is closed once the rest is set.
+type tokenRefresh struct {
+	done    chan struct{}
+	token   string
+	expires time.Time
+	err     error
+}
+
+type entraTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func authError(message string) *llm.ProviderError {
+	return &llm.ProviderError{
+		StatusCode: 401,
+		Message:    message,
+		Type:       "authentication_error",
+		Code:       "azure_credentials_failed",
+	}
+}
+
+// Token returns a valid access token.
+func (t *entraTokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	if t.token != "" && time.Until(t.expires) > refreshWindow {
+		token := t.token
+		t.mu.Unlock()
+		return token, nil
+	}
+	r := t.refresh
+	if r == nil {
+		r = &tokenRefresh{done: make(chan struct{})}
+		t.refresh = r
+		// The request is shared, so it must not end with the caller that
+		// started it; the HTTP client's timeout bounds it instead.
+		go t.run(context.WithoutCancel(ctx), r)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// run performs the request r and caches its token.
+func (t *entraTokenSource) run(ctx context.Context, r *tokenRefresh) {
+	r.token, r.expires, r.err = t.fetch(ctx)
+
+	t.mu.Lock()
+	if r.err == nil {
+		t.token, t.expires = r.token, r.expires
+	}
+	t.refresh = nil
+	t.mu.Unlock()
+	close(r.done)
+}
+
+// fetch requests a new access token from Entra ID.
+func (t *entraTokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	authority := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authority == "" {
+		authority = defaultAuthorityHost
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimRight(authority, "/"), url.PathEscape(t.tenantID))
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.clientID},
+		"client_secret": {t.clientSecret},
+		"scope":         {cognitiveServicesScope},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, llm.NewInternalError(fmt.Sprintf("failed to create token request: %v", err))
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return "", time.Time{}, llm.NewProviderError(503, fmt.Sprintf("token request failed: %v", err), "service_unavailable", "request_failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, llm.NewInternalError(fmt.Sprintf("failed to read token response: %v", err))
+	}
+
+	var out entraTokenResponse
+	if err := json.Unmarshal(body, &out); err != nil && resp.StatusCode == http.StatusOK {
+		return "", time.Time{}, llm.NewInternalError(fmt.Sprintf("failed to decode token response: %v", err))
+	}
+	if resp.StatusCode != http.StatusOK || out.AccessToken == "" {
+		message := out.ErrorDescription
+		if message == "" {
+			message = out.Error
+		}
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		return "", time.Time{}, authError(fmt.Sprintf("Entra ID token request failed (%d): %s", resp.StatusCode, message))
+	}
+
+	return out.AccessToken, time.Now().Add(time.Duration(out.ExpiresIn) * time.Second), nil
+}
@@ -0,0 +1,215 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// authority serves the Entra ID token endpoint with handle, as the
+// authority host of the test, and counts the requests it gets.
+func authority(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AZURE_AUTHORITY_HOST", srv.URL+"/")
+	return &calls
+}
+
+func grant(token string, expiresIn int) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"token_type": "Bearer", "access_token": token, "expires_in": expiresIn})
+	}
+}
+
+func newTestSource() *entraTokenSource {
+	return &entraTokenSource{
+		tenantID:     "tenant-1",
+		clientID:     "client-1",
+		clientSecret: "secret-1",
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func TestEntraToken(t *testing.T) {
+	calls := authority(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant-1/oauth2/v2.0/token" {
+			t.Errorf("path = %s, want the tenant's v2.0 token endpoint", r.URL.Path)
+		}
+		want := map[string]string{
+			"grant_type":    "client_credentials",
+			"client_id":     "client-1",
+			"client_secret": "secret-1",
+			"scope":         cognitiveServicesScope,
+		}
+		for k, v := range want {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+		grant("eyJ.entra", 3600)(w, r)
+	})
+	ts := newTestSource()
+
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil || token != "eyJ.entra" {
+			t.Fatalf("Token = %q, %v; want eyJ.entra", token, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1 while the token is fresh", n)
+	}
+}
+
+func TestEntraTokenRenewsNearExpiry(t *testing.T) {
+	calls := authority(t, grant("eyJ.short", int(refreshWindow/time.Second)-1))
+	ts := newTestSource()
+
+	for i := 0; i < 2; i++ {
+		if _, err := ts.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("token endpoint called %d times, want a renewal for a token within the refresh window", n)
+	}
+}
+
+func TestEntraTokenErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  func(http.ResponseWriter, *http.Request)
+		status  int
+		code    string
+		message string
+	}{
+		{
+			name: "OAuth error",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret provided."}`))
+			},
+			status: 401, code: "azure_credentials_failed", message: "Entra ID token request failed (401): AADSTS7000215",
+		},
+		{
+			name: "error without a description",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_request"}`))
+			},
+			status: 401, code: "azure_credentials_failed", message: "invalid_request",
+		},
+		{
+			name: "plain text error",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+			},
+			status: 401, code: "azure_credentials_failed", message: "(504): gateway timeout",
+		},
+		{
+			name: "no access token",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"expires_in":3600}`))
+			},
+			status: 401, code: "azure_credentials_failed", message: "Entra ID token request failed (200)",
+		},
+		{
+			name:    "malformed response",
+			handle:  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) },
+			status:  500,
+			message: "failed to decode token response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authority(t, tt.handle)
+			_, err := newTestSource().Token(context.Background())
+			var pe *llm.ProviderError
+			if !errors.As(err, &pe) {
+				t.Fatalf("err = %v, want a provider error", err)
+			}
+			if pe.StatusCode != tt.status || (tt.code != "" && pe.Code != tt.code) || !strings.Contains(pe.Message, tt.message) {
+				t.Errorf("err = %v, want %d %s containing %q", pe, tt.status, tt.code, tt.message)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		t.Setenv("AZURE_AUTHORITY_HOST", closed.URL)
+		var pe *llm.ProviderError
+		if _, err := newTestSource().Token(context.Background()); !errors.As(err, &pe) || pe.StatusCode != 503 || pe.Code != "request_failed" {
+			t.Errorf("err = %v, want 503 request_failed", err)
+		}
+	})
+
+	// A failed request is not cached.
+	calls := authority(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+	})
+	ts := newTestSource()
+	ts.Token(context.Background())
+	ts.Token(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Errorf("token endpoint called %d times after failures, want 2", n)
+	}
+}
+
+func TestEntraTokenSharesRefresh(t *testing.T) {
+	release := make(chan struct{})
+	calls := authority(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		grant("eyJ.shared", 3600)(w, r)
+	})
+	ts := newTestSource()
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _ = ts.Token(context.Background())
+		}()
+	}
+
+	// While the request is stuck, a caller that gives up is not held back
+	// by it, and does not end it for the others.
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := ts.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Token with an expired context = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, token := range tokens {
+		if token != "eyJ.shared" {
+			t.Errorf("caller %d got %q, want eyJ.shared", i, token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1 for concurrent callers", n)
+	}
+}
@@ -17,11 +17,17 @@ type Provider struct {
 
 // New creates a Baseten provider.
 func New() *Provider {
+	return NewWithConfig(openaicompat.Config{})
+}
+
+// NewWithConfig creates a Baseten provider with explicit client settings.
+// An empty BaseURL falls back to the provider default.
+func NewWithConfig(cfg openaicompat.Config) *Provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.baseten.co"
+	}
 	return &Provider{
-		client: openaicompat.NewClient(openaicompat.Config{
-			BaseURL: "https://api.baseten.co",
-			APIKey:  "",
-		}),
+		client: openaicompat.NewClient(cfg),
 	}
 }
 
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"github.com/atozi-ai/gateway/internal/balancer"
+	"github.com/atozi-ai/gateway/internal/config"
+)
+
+// canonicalModels are open-weight models served by several hosts under IDs
+// of their own. A request for the canonical name goes to the cheapest
+// healthy host that supports it and that the caller has credentials for,
+// with the others as fallbacks. Host prices
+// are in the pricing catalog and can be corrected with "pricing" in the
+// config file.
+var canonicalModels = map[string]config.PoolConfig{
+	"meta/llama-3.3-70b": lowestCost(
+		host("groq/llama-3.3-70b-versatile", 131072, true, true),
+		host("together/meta-llama/Llama-3.3-70B-Instruct-Turbo", 131072, true, true),
+		host("fireworks/accounts/fireworks/models/llama-v3p3-70b-instruct", 131072, true, true),
+		host("deepinfra/meta-llama/Llama-3.3-70B-Instruct-Turbo", 131072, true, true),
+		host("nebius/meta-llama/Llama-3.3-70B-Instruct", 131072, true, true),
+	),
+	"meta/llama-3.1-8b": lowestCost(
+		host("groq/llama-3.1-8b-instant", 131072, true, true),
+		host("together/meta-llama/Meta-Llama-3.1-8B-Instruct-Turbo", 131072, true, true),
+		host("fireworks/accounts/fireworks/models/llama-v3p1-8b-instruct", 131072, true, true),
+		host("deepinfra/meta-llama/Meta-Llama-3.1-8B-Instruct", 131072, true, true),
+		host("nebius/meta-llama/Meta-Llama-3.1-8B-Instruct", 131072, true, true),
+	),
+	"meta/llama-3.1-405b": lowestCost(
+		host("together/meta-llama/Meta-Llama-3.1-405B-Instruct-Turbo", 130815, true, true),
+		host("fireworks/accounts/fireworks/models/llama-v3p1-405b-instruct", 131072, true, true),
+		host("deepinfra/meta-llama/Meta-Llama-3.1-405B-Instruct", 32768, true, true),
+		host("nebius/meta-llama/Meta-Llama-3.1-405B-Instruct", 131072, false, true),
+	),
+	"qwen/qwen-2.5-72b-instruct": lowestCost(
+		host("together/Qwen/Qwen2.5-72B-Instruct-Turbo", 32768, true, true),
+		host("fireworks/accounts/fireworks/models/qwen2p5-72b-instruct", 32768, true, true),
+		host("deepinfra/Qwen/Qwen2.5-72B-Instruct", 32768, true, true),
+		host("nebius/Qwen/Qwen2.5-72B-Instruct", 131072, false, true),
+	),
+	"qwen/qwen-2.5-coder-32b-instruct": lowestCost(
+		host("together/Qwen/Qwen2.5-Coder-32B-Instruct", 32768, false, true),
+		host("deepinfra/Qwen/Qwen2.5-Coder-32B-Instruct", 32768, true, true),
+		host("nebius/Qwen/Qwen2.5-Coder-32B-Instruct", 131072, false, true),
+	),
+	"deepseek-ai/deepseek-v3": lowestCost(
+		host("together/deepseek-ai/DeepSeek-V3", 131072, true, true),
+		host("fireworks/accounts/fireworks/models/deepseek-v3", 131072, true, true),
+		host("deepinfra/deepseek-ai/DeepSeek-V3", 163840, true, true),
+		host("nebius/deepseek-ai/DeepSeek-V3", 163840, false, true),
+	),
+	"deepseek-ai/deepseek-r1": lowestCost(
+		host("together/deepseek-ai/DeepSeek-R1", 163840, false, true),
+		host("fireworks/accounts/fireworks/models/deepseek-r1", 163840, false, true),
+		host("deepinfra/deepseek-ai/DeepSeek-R1", 163840, false, true),
+		host("nebius/deepseek-ai/DeepSeek-R1", 163840, false, false),
+	),
+}
+
+func lowestCost(hosts ...config.DeploymentConfig) config.PoolConfig {
+	return config.PoolConfig{
+		Deployments: hosts,
+		Strategy:    balancer.StrategyLowestCost,
+	}
+}
+
+// host is a deployment of a canonical model with the given context length
+// and tool and JSON mode support.
+func host(model string, contextLength int, tools, jsonMode bool) config.DeploymentConfig {
+	return config.DeploymentConfig{
+		Model:         model,
+		ContextLength: contextLength,
+		Tools:         &tools,
+		JSONMode:      &jsonMode,
+	}
+}
@@ -17,11 +17,17 @@ type Provider struct {
 
 // New creates a Cloudflare Workers AI provider.
 func New() *Provider {
+	return NewWithConfig(openaicompat.Config{})
+}
+
+// NewWithConfig creates a Cloudflare Workers AI provider with explicit client settings.
+// An empty BaseURL falls back to the provider default.
+func NewWithConfig(cfg openaicompat.Config) *Provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.cloudflare.com/client/v4/accounts/-/ai/v1"
+	}
 	return &Provider{
-		client: openaicompat.NewClient(openaicompat.Config{
-			BaseURL: "https://api.cloudflare.com/client/v4/accounts/-/ai/v1",
-			APIKey:  "",
-		}),
+		client: openaicompat.NewClient(cfg),
 	}
 }
 
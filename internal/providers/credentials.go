@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/atozi-ai/gateway/internal/config"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// credentialProvider picks the upstream API key for a provider from the
+// credentials attached to a gateway virtual key.
+type credentialProvider struct {
+	provider   llm.Provider
+	name       string // registry name, e.g. "openai"
+	hasDefault bool   // provider has a server-side default key in the config
+}
+
+func withCredentials(name string, provider llm.Provider, hasDefault bool) llm.Provider {
+	return &credentialProvider{
+		provider:   provider,
+		name:       name,
+		hasDefault: hasDefault,
+	}
+}
+
+func (c *credentialProvider) Name() string {
+	return c.provider.Name()
+}
+
+func (c *credentialProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	req, err := c.bind(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.provider.Chat(ctx, req)
+}
+
+func (c *credentialProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	req, err := c.bind(req)
+	if err != nil {
+		return err
+	}
+	return c.provider.ChatStream(ctx, req, callback)
+}
+
+func (c *credentialProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	apiKey, err := c.apiKey(req.APIKey, req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	req.APIKey = apiKey
+	return llm.Embed(ctx, c.provider, req)
+}
+
+func (c *credentialProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	apiKey, err := c.apiKey(req.APIKey, req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	req.APIKey = apiKey
+	return llm.Respond(ctx, c.provider, req)
+}
+
+func (c *credentialProvider) bind(req llm.ChatRequest) (llm.ChatRequest, error) {
+	apiKey, err := c.apiKey(req.APIKey, req.Credentials)
+	if err != nil {
+		return req, err
+	}
+	req.APIKey = apiKey
+	return req, nil
+}
+
+// apiKey returns the upstream key to use. Pass-through callers (nil
+// credentials) keep their own key.
+func (c *credentialProvider) apiKey(apiKey string, credentials map[string]string) (string, error) {
+	if credentials == nil {
+		return apiKey, nil
+	}
+
+	key, ok := credentials[c.name]
+	if !ok && c.hasDefault {
+		return apiKey, nil
+	}
+	if !ok {
+		return "", &llm.ProviderError{
+			StatusCode: 403,
+			Message:    fmt.Sprintf("gateway API key has no credentials for provider %q", c.name),
+			Type:       "permission_error",
+			Code:       "missing_provider_credentials",
+		}
+	}
+
+	return key, nil
+}
+
+// hasDefaultCredentials reports whether pc holds server-side credentials
+// for provider name that gateway keys without their own may use.
+func hasDefaultCredentials(name string, pc config.ProviderConfig) bool {
+	// Azure with Entra ID has server-side credentials without a key.
+	return pc.APIKey != "" || (name == "azure" && pc.ClientSecret != "")
+}
+
+// deploymentServes returns which callers a pool deployment of provider name
+// may be called for. Deployments with credentials of their own, and Bedrock
+// and Vertex ones, which use cloud credentials, serve every caller. A
+// pass-through key is only sent to deployments of keyProvider, the provider
+// it is for, so it never reaches another provider; empty keyProvider turns
+// pass-through callers away. A gateway key needs credentials for the
+// deployment's provider unless the server has some.
+func deploymentServes(name string, pc config.ProviderConfig, ownCredentials bool, keyProvider string) func(map[string]string) bool {
+	if ownCredentials || name == "aws_bedrock" || name == "vertex" {
+		return nil
+	}
+	hasDefault := hasDefaultCredentials(name, pc)
+	return func(credentials map[string]string) bool {
+		if credentials == nil {
+			return name == keyProvider
+		}
+		_, ok := credentials[name]
+		return ok || hasDefault
+	}
+}
+
+// ownCredentialsProvider serves a pool deployment configured with
+// credentials of its own. The caller's key and virtual-key credentials are
+// dropped so the deployment's are always used.
+type ownCredentialsProvider struct {
+	provider llm.Provider
+}
+
+func withOwnCredentials(provider llm.Provider) llm.Provider {
+	return &ownCredentialsProvider{provider: provider}
+}
+
+func (c *ownCredentialsProvider) Name() string {
+	return c.provider.Name()
+}
+
+func (c *ownCredentialsProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	req.APIKey, req.Credentials = "", nil
+	return c.provider.Chat(ctx, req)
+}
+
+func (c *ownCredentialsProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	req.APIKey, req.Credentials = "", nil
+	return c.provider.ChatStream(ctx, req, callback)
+}
+
+func (c *ownCredentialsProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	req.APIKey, req.Credentials = "", nil
+	return llm.Embed(ctx, c.provider, req)
+}
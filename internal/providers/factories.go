@@ -0,0 +1,244 @@
+package providers
+
+import (
+	"github.com/atozi-ai/gateway/internal/config"
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/providers/ai21"
+	"github.com/atozi-ai/gateway/internal/providers/anthropic"
+	"github.com/atozi-ai/gateway/internal/providers/anthropic_compat"
+	"github.com/atozi-ai/gateway/internal/providers/anyscale"
+	"github.com/atozi-ai/gateway/internal/providers/arcee"
+	"github.com/atozi-ai/gateway/internal/providers/aws_bedrock"
+	"github.com/atozi-ai/gateway/internal/providers/azure"
+	"github.com/atozi-ai/gateway/internal/providers/baseten"
+	"github.com/atozi-ai/gateway/internal/providers/cerebras"
+	"github.com/atozi-ai/gateway/internal/providers/chutes"
+	"github.com/atozi-ai/gateway/internal/providers/cloudflare"
+	"github.com/atozi-ai/gateway/internal/providers/cohere"
+	"github.com/atozi-ai/gateway/internal/providers/deepinfra"
+	"github.com/atozi-ai/gateway/internal/providers/deepseek"
+	"github.com/atozi-ai/gateway/internal/providers/fireworks"
+	"github.com/atozi-ai/gateway/internal/providers/friendli"
+	"github.com/atozi-ai/gateway/internal/providers/gemini"
+	"github.com/atozi-ai/gateway/internal/providers/gemini_compat"
+	"github.com/atozi-ai/gateway/internal/providers/groq"
+	"github.com/atozi-ai/gateway/internal/providers/hyperbolic"
+	"github.com/atozi-ai/gateway/internal/providers/liquid"
+	"github.com/atozi-ai/gateway/internal/providers/minimax"
+	"github.com/atozi-ai/gateway/internal/providers/mistral"
+	"github.com/atozi-ai/gateway/internal/providers/modelrun"
+	"github.com/atozi-ai/gateway/internal/providers/moonshot"
+	"github.com/atozi-ai/gateway/internal/providers/morph"
+	"github.com/atozi-ai/gateway/internal/providers/nebius"
+	"github.com/atozi-ai/gateway/internal/providers/nextbit"
+	"github.com/atozi-ai/gateway/internal/providers/novita"
+	"github.com/atozi-ai/gateway/internal/providers/nvidia"
+	"github.com/atozi-ai/gateway/internal/providers/ollama"
+	"github.com/atozi-ai/gateway/internal/providers/openai"
+	openaicompat "github.com/atozi-ai/gateway/internal/providers/openai_compat"
+	"github.com/atozi-ai/gateway/internal/providers/ovhcloud"
+	"github.com/atozi-ai/gateway/internal/providers/parasail"
+	"github.com/atozi-ai/gateway/internal/providers/perplexity"
+	"github.com/atozi-ai/gateway/internal/providers/phala"
+	"github.com/atozi-ai/gateway/internal/providers/replicate"
+	"github.com/atozi-ai/gateway/internal/providers/sambanova"
+	"github.com/atozi-ai/gateway/internal/providers/scaleway"
+	"github.com/atozi-ai/gateway/internal/providers/siliconflow"
+	"github.com/atozi-ai/gateway/internal/providers/stepfun"
+	"github.com/atozi-ai/gateway/internal/providers/together"
+	"github.com/atozi-ai/gateway/internal/providers/upstage"
+	"github.com/atozi-ai/gateway/internal/providers/venice"
+	"github.com/atozi-ai/gateway/internal/providers/vertex"
+	"github.com/atozi-ai/gateway/internal/providers/xai"
+	"github.com/atozi-ai/gateway/internal/providers/xiaomi"
+	"github.com/atozi-ai/gateway/internal/providers/zai"
+)
+
+// factory builds an unwrapped provider from its configured overrides.
+type factory func(pc config.ProviderConfig) llm.Provider
+
+// factories lists every provider the gateway can route to, keyed by the name
+// used in "provider/model" strings.
+var factories = map[string]factory{
+	"anthropic": func(pc config.ProviderConfig) llm.Provider {
+		return anthropic.NewWithConfig(anthropic_compat.Config{
+			BaseURL: pc.BaseURL,
+			APIKey:  pc.APIKey,
+			Headers: pc.Headers,
+			Timeout: pc.Timeout,
+		})
+	},
+	"aws_bedrock": func(pc config.ProviderConfig) llm.Provider {
+		return aws_bedrock.NewWithConfig(aws_bedrock.Config{
+			AccessKeyID:     pc.AccessKeyID,
+			SecretAccessKey: pc.SecretAccessKey,
+			SessionToken:    pc.SessionToken,
+			Profile:         pc.Profile,
+			RoleARN:         pc.RoleARN,
+			Region:          pc.Region,
+			BaseURL:         pc.BaseURL,
+			Timeout:         pc.Timeout,
+		})
+	},
+	"azure": func(pc config.ProviderConfig) llm.Provider {
+		return azure.NewWithConfig(azure.Config{
+			Endpoint:     pc.BaseURL,
+			APIVersion:   pc.APIVersion,
+			Deployments:  pc.Deployments,
+			APIKey:       pc.APIKey,
+			TenantID:     pc.TenantID,
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			Timeout:      pc.Timeout,
+		})
+	},
+	"gemini": func(pc config.ProviderConfig) llm.Provider {
+		return gemini.NewWithConfig(gemini_compat.Config{
+			BaseURL: pc.BaseURL,
+			APIKey:  pc.APIKey,
+			Headers: pc.Headers,
+			Timeout: pc.Timeout,
+		})
+	},
+	"vertex": func(pc config.ProviderConfig) llm.Provider {
+		return vertex.NewWithConfig(vertex.Config{
+			ProjectID:       pc.ProjectID,
+			Location:        pc.Location,
+			CredentialsFile: pc.CredentialsFile,
+			BaseURL:         pc.BaseURL,
+			Timeout:         pc.Timeout,
+		})
+	},
+	"ai21": func(pc config.ProviderConfig) llm.Provider {
+		return ai21.NewWithConfig(openAICompatConfig(pc))
+	},
+	"anyscale": func(pc config.ProviderConfig) llm.Provider {
+		return anyscale.NewWithConfig(openAICompatConfig(pc))
+	},
+	"arcee": func(pc config.ProviderConfig) llm.Provider {
+		return arcee.NewWithConfig(openAICompatConfig(pc))
+	},
+	"baseten": func(pc config.ProviderConfig) llm.Provider {
+		return baseten.NewWithConfig(openAICompatConfig(pc))
+	},
+	"cerebras": func(pc config.ProviderConfig) llm.Provider {
+		return cerebras.NewWithConfig(openAICompatConfig(pc))
+	},
+	"chutes": func(pc config.ProviderConfig) llm.Provider {
+		return chutes.NewWithConfig(openAICompatConfig(pc))
+	},
+	"cloudflare": func(pc config.ProviderConfig) llm.Provider {
+		return cloudflare.NewWithConfig(openAICompatConfig(pc))
+	},
+	"cohere": func(pc config.ProviderConfig) llm.Provider {
+		return cohere.NewWithConfig(openAICompatConfig(pc))
+	},
+	"deepinfra": func(pc config.ProviderConfig) llm.Provider {
+		return deepinfra.NewWithConfig(openAICompatConfig(pc))
+	},
+	"deepseek": func(pc config.ProviderConfig) llm.Provider {
+		return deepseek.NewWithConfig(openAICompatConfig(pc))
+	},
+	"fireworks": func(pc config.ProviderConfig) llm.Provider {
+		return fireworks.NewWithConfig(openAICompatConfig(pc))
+	},
+	"friendli": func(pc config.ProviderConfig) llm.Provider {
+		return friendli.NewWithConfig(openAICompatConfig(pc))
+	},
+	"groq": func(pc config.ProviderConfig) llm.Provider {
+		return groq.NewWithConfig(openAICompatConfig(pc))
+	},
+	"hyperbolic": func(pc config.ProviderConfig) llm.Provider {
+		return hyperbolic.NewWithConfig(openAICompatConfig(pc))
+	},
+	"liquid": func(pc config.ProviderConfig) llm.Provider {
+		return liquid.NewWithConfig(openAICompatConfig(pc))
+	},
+	"minimax": func(pc config.ProviderConfig) llm.Provider {
+		return minimax.NewWithConfig(openAICompatConfig(pc))
+	},
+	"mistral": func(pc config.ProviderConfig) llm.Provider {
+		return mistral.NewWithConfig(openAICompatConfig(pc))
+	},
+	"modelrun": func(pc config.ProviderConfig) llm.Provider {
+		return modelrun.NewWithConfig(openAICompatConfig(pc))
+	},
+	"moonshot": func(pc config.ProviderConfig) llm.Provider {
+		return moonshot.NewWithConfig(openAICompatConfig(pc))
+	},
+	"morph": func(pc config.ProviderConfig) llm.Provider {
+		return morph.NewWithConfig(openAICompatConfig(pc))
+	},
+	"nebius": func(pc config.ProviderConfig) llm.Provider {
+		return nebius.NewWithConfig(openAICompatConfig(pc))
+	},
+	"nextbit": func(pc config.ProviderConfig) llm.Provider {
+		return nextbit.NewWithConfig(openAICompatConfig(pc))
+	},
+	"novita": func(pc config.ProviderConfig) llm.Provider {
+		return novita.NewWithConfig(openAICompatConfig(pc))
+	},
+	"nvidia": func(pc config.ProviderConfig) llm.Provider {
+		return nvidia.NewWithConfig(openAICompatConfig(pc))
+	},
+	"ollama": func(pc config.ProviderConfig) llm.Provider {
+		return ollama.NewWithConfig(openAICompatConfig(pc))
+	},
+	"openai": func(pc config.ProviderConfig) llm.Provider {
+		return openai.NewWithConfig(openAICompatConfig(pc))
+	},
+	"ovhcloud": func(pc config.ProviderConfig) llm.Provider {
+		return ovhcloud.NewWithConfig(openAICompatConfig(pc))
+	},
+	"parasail": func(pc config.ProviderConfig) llm.Provider {
+		return parasail.NewWithConfig(openAICompatConfig(pc))
+	},
+	"perplexity": func(pc config.ProviderConfig) llm.Provider {
+		return perplexity.NewWithConfig(openAICompatConfig(pc))
+	},
+	"phala": func(pc config.ProviderConfig) llm.Provider {
+		return phala.NewWithConfig(openAICompatConfig(pc))
+	},
+	"replicate": func(pc config.ProviderConfig) llm.Provider {
+		return replicate.NewWithConfig(openAICompatConfig(pc))
+	},
+	"sambanova": func(pc config.ProviderConfig) llm.Provider {
+		return sambanova.NewWithConfig(openAICompatConfig(pc))
+	},
+	"scaleway": func(pc config.ProviderConfig) llm.Provider {
+		return scaleway.NewWithConfig(openAICompatConfig(pc))
+	},
+	"siliconflow": func(pc config.ProviderConfig) llm.Provider {
+		return siliconflow.NewWithConfig(openAICompatConfig(pc))
+	},
+	"stepfun": func(pc config.ProviderConfig) llm.Provider {
+		return stepfun.NewWithConfig(openAICompatConfig(pc))
+	},
+	"together": func(pc config.ProviderConfig) llm.Provider {
+		return together.NewWithConfig(openAICompatConfig(pc))
+	},
+	"upstage": func(pc config.ProviderConfig) llm.Provider {
+		return upstage.NewWithConfig(openAICompatConfig(pc))
+	},
+	"venice": func(pc config.ProviderConfig) llm.Provider {
+		return venice.NewWithConfig(openAICompatConfig(pc))
+	},
+	"xai": func(pc config.ProviderConfig) llm.Provider {
+		return xai.NewWithConfig(openAICompatConfig(pc))
+	},
+	"xiaomi": func(pc config.ProviderConfig) llm.Provider {
+		return xiaomi.NewWithConfig(openAICompatConfig(pc))
+	},
+	"zai": func(pc config.ProviderConfig) llm.Provider {
+		return zai.NewWithConfig(openAICompatConfig(pc))
+	},
+}
+
+func openAICompatConfig(pc config.ProviderConfig) openaicompat.Config {
+	return openaicompat.Config{
+		BaseURL: pc.BaseURL,
+		APIKey:  pc.APIKey,
+		Headers: pc.Headers,
+		Timeout: pc.Timeout,
+	}
+}
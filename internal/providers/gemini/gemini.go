@@ -12,11 +12,7 @@ type Provider struct {
 }
 
 func New() *Provider {
-	return &Provider{
-		client: gemini_compat.NewClient(gemini_compat.Config{
-			APIKey: "",
-		}),
-	}
+	return NewWithConfig(gemini_compat.Config{})
 }
 
 func NewWithBaseURL(apiKey string, baseURL string) *Provider {
@@ -28,6 +24,12 @@ func NewWithBaseURL(apiKey string, baseURL string) *Provider {
 	}
 }
 
+func NewWithConfig(cfg gemini_compat.Config) *Provider {
+	return &Provider{
+		client: gemini_compat.NewClient(cfg),
+	}
+}
+
 func (p *Provider) Name() string { return "gemini" }
 
 func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
@@ -37,3 +39,7 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResp
 func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
 	return p.client.ChatStream(ctx, req, callback)
 }
+
+func (p *Provider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	return p.client.Embed(ctx, req)
+}
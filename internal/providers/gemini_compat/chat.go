@@ -18,7 +18,10 @@ const (
 )
 
 func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
-	body := toRequest(req)
+	body, err := toRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -64,7 +67,10 @@ func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, callback f
 	stream := true
 	req.Options.Stream = &stream
 
-	body := toRequest(req)
+	body, err := toRequest(req)
+	if err != nil {
+		return err
+	}
 	body.GenerationConfig.StopSequences = nil
 
 	jsonBody, err := json.Marshal(body)
@@ -145,6 +151,8 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 	buf := make([]byte, 0, bufio.MaxScanTokenSize)
 	scanner.Buffer(buf, maxScanTokenSize)
 
+	toolCount := 0
+
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
@@ -168,55 +176,65 @@ func readSSEStream(ctx context.Context, r io.Reader, callback func(*llm.StreamCh
 			return nil
 		}
 
-		var chunk map[string]interface{}
+		var chunk GenerateContentResponse
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 			continue
 		}
-
-		candidates, ok := chunk["candidates"].([]interface{})
-		if !ok || len(candidates) == 0 {
+		if len(chunk.Candidates) == 0 {
 			continue
 		}
-
-		candidate, ok := candidates[0].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		content, ok := candidate["content"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		parts, ok := content["parts"].([]interface{})
-		if !ok || len(parts) == 0 {
-			continue
-		}
-
-		part, ok := parts[0].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		text, ok := part["text"].(string)
-		if !ok || text == "" {
+		candidate := chunk.Candidates[0]
+
+		var delta llm.StreamDelta
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall != nil {
+				// Gemini sends each call whole, so one delta carries the ID,
+				// name and complete arguments.
+				tc := toToolCall(*part.FunctionCall, toolCount)
+				delta.ToolCalls = append(delta.ToolCalls, llm.ToolCallDelta{
+					Index:    toolCount,
+					ID:       tc.ID,
+					Type:     tc.Type,
+					Function: tc.Function,
+				})
+				toolCount++
+				continue
+			}
+			if part.Text != "" {
+				text := part.Text
+				if delta.Content != nil {
+					text = *delta.Content + text
+				}
+				delta.Content = &text
+			}
+		}
+
+		var finish *string
+		if reason := finishReason(candidate.FinishReason); reason != "" {
+			if toolCount > 0 {
+				reason = "tool_calls"
+			}
+			finish = &reason
+		}
+
+		if delta.Content == nil && len(delta.ToolCalls) == 0 && finish == nil {
 			continue
 		}
 
 		streamChunk := &llm.StreamChunk{
-			ID:      "",
-			Model:   "",
-			Choices: []llm.StreamChoice{},
+			ID:    chunk.ResponseId,
+			Model: chunk.ModelVersion,
+			Choices: []llm.StreamChoice{{
+				Index:        0,
+				Delta:        delta,
+				FinishReason: finish,
+			}},
+		}
+		// usageMetadata is cumulative; report it once, with the final chunk.
+		if finish != nil {
+			streamChunk.Usage = chunk.UsageMetadata.toUsage()
 		}
 
-		textPtr := text
-		streamChunk.Choices = append(streamChunk.Choices, llm.StreamChoice{
-			Index: 0,
-			Delta: llm.StreamDelta{
-				Content: &textPtr,
-			},
-		})
-
 		if err := callback(streamChunk); err != nil {
 			return err
 		}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 )
 
 const (
@@ -24,7 +26,7 @@ func initSharedClient() {
 	}
 	sharedHTTPClient = &http.Client{
 		Timeout:   120 * time.Second,
-		Transport: transport,
+		Transport: tracing.Transport(transport),
 	}
 }
 
@@ -36,6 +38,8 @@ func getSharedClient() *http.Client {
 type Config struct {
 	BaseURL string
 	APIKey  string
+	Headers map[string]string // Extra headers sent with every request
+	Timeout time.Duration     // Overrides the shared client timeout when non-zero
 }
 
 type Client struct {
@@ -44,6 +48,12 @@ type Client struct {
 }
 
 func NewClient(cfg Config) *Client {
+	if cfg.Timeout > 0 {
+		return NewClientWithCustomHTTP(cfg, &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: getSharedClient().Transport,
+		})
+	}
 	return &Client{
 		cfg:        cfg,
 		httpClient: getSharedClient(),
@@ -65,4 +75,7 @@ func (c *Client) setHeaders(req *http.Request, apiKey string) {
 
 	req.Header.Set("x-goog-api-key", key)
 	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, v)
+	}
 }
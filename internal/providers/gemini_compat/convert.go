@@ -1,10 +1,17 @@
 package gemini_compat
 
 import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
 	"github.com/atozi-ai/gateway/internal/domain/llm"
 )
 
-func toRequest(req llm.ChatRequest) GenerateContentRequest {
+func toRequest(req llm.ChatRequest) (GenerateContentRequest, error) {
 	opts := req.Options
 
 	maxTokens := defaultMaxTokens
@@ -20,35 +27,57 @@ func toRequest(req llm.ChatRequest) GenerateContentRequest {
 		case llm.RoleSystem:
 			systemInstruction = &Content{
 				Role:  "system",
-				Parts: []Part{{Text: msg.Content}},
+				Parts: []Part{{Text: msg.Text()}},
 			}
 		case llm.RoleUser:
+			parts, err := toParts(msg)
+			if err != nil {
+				return GenerateContentRequest{}, err
+			}
 			contents = append(contents, Content{
 				Role:  "user",
-				Parts: []Part{{Text: msg.Content}},
+				Parts: parts,
 			})
 		case llm.RoleAssistant:
+			parts, err := toModelParts(msg)
+			if err != nil {
+				return GenerateContentRequest{}, err
+			}
 			contents = append(contents, Content{
 				Role:  "model",
-				Parts: []Part{{Text: msg.Content}},
+				Parts: parts,
+			})
+		case llm.RoleTool:
+			part, err := toFunctionResponse(msg, req.Messages)
+			if err != nil {
+				return GenerateContentRequest{}, err
+			}
+			// Responses to parallel calls go back together in one turn.
+			if n := len(contents); n > 0 && contents[n-1].Role == "user" && contents[n-1].Parts[0].FunctionResponse != nil {
+				contents[n-1].Parts = append(contents[n-1].Parts, part)
+				continue
+			}
+			contents = append(contents, Content{
+				Role:  "user",
+				Parts: []Part{part},
 			})
 		}
 	}
 
 	var tools []Tool
 	if len(opts.Tools) > 0 {
-		tools = make([]Tool, len(opts.Tools))
-		for i, t := range opts.Tools {
-			tools[i] = Tool{
-				FunctionDeclarations: []FunctionDeclaration{
-					{
-						Name:        t.Function.Name,
-						Description: t.Function.Description,
-						Parameters:  t.Function.Parameters,
-					},
-				},
+		declarations := make([]FunctionDeclaration, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			if t.Function == nil {
+				continue
 			}
+			declarations = append(declarations, FunctionDeclaration{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			})
 		}
+		tools = []Tool{{FunctionDeclarations: declarations}}
 	}
 
 	genConfig := &GenerationConfig{
@@ -70,18 +99,146 @@ func toRequest(req llm.ChatRequest) GenerateContentRequest {
 		SystemInstruction: systemInstruction,
 		GenerationConfig:  genConfig,
 		Tools:             tools,
+		ToolConfig:        toToolConfig(opts.ToolChoice),
+	}, nil
+}
+
+func toToolConfig(raw interface{}) *ToolConfig {
+	var cfg FunctionCallingConfig
+	switch mode, name := llm.ResolveToolChoice(raw); mode {
+	case "auto":
+		cfg.Mode = "AUTO"
+	case "none":
+		cfg.Mode = "NONE"
+	case "required":
+		cfg.Mode = "ANY"
+	case "function":
+		cfg.Mode = "ANY"
+		cfg.AllowedFunctionNames = []string{name}
+	default:
+		return nil
 	}
+	return &ToolConfig{FunctionCallingConfig: cfg}
+}
+
+// toModelParts maps an assistant message to text and functionCall parts.
+func toModelParts(msg llm.Message) ([]Part, error) {
+	var parts []Part
+	if text := msg.Text(); text != "" || len(msg.ToolCalls) == 0 {
+		parts = append(parts, Part{Text: text})
+	}
+
+	for _, tc := range msg.ToolCalls {
+		args := json.RawMessage(tc.Function.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage(`{}`)
+		} else if !json.Valid(args) {
+			return nil, llm.NewValidationError("tool call arguments must be valid JSON", "invalid_tool_arguments")
+		}
+		parts = append(parts, Part{FunctionCall: &FunctionCall{Name: tc.Function.Name, Args: args}})
+	}
+	return parts, nil
+}
+
+// toFunctionResponse maps a tool message to a functionResponse part. Gemini
+// matches responses to calls by function name rather than by ID, so the name
+// is looked up from the assistant message that made the call.
+func toFunctionResponse(msg llm.Message, history []llm.Message) (Part, error) {
+	if msg.ToolCallID == "" {
+		return Part{}, llm.NewValidationError("tool messages require tool_call_id", "missing_tool_call_id")
+	}
+
+	name := toolCallName(history, msg.ToolCallID)
+	if name == "" {
+		return Part{}, llm.NewValidationError(fmt.Sprintf("no assistant tool call matches tool_call_id %q", msg.ToolCallID), "unknown_tool_call_id")
+	}
+
+	// The response must be an object; wrap anything else.
+	result := msg.Text()
+	response := json.RawMessage(result)
+	if !isJSONObject(result) {
+		response, _ = json.Marshal(map[string]string{"content": result})
+	}
+
+	return Part{FunctionResponse: &FunctionResponse{Name: name, Response: response}}, nil
+}
+
+func toolCallName(history []llm.Message, id string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, tc := range history[i].ToolCalls {
+			if tc.ID == id {
+				return tc.Function.Name
+			}
+		}
+	}
+	return ""
+}
+
+func isJSONObject(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{") && json.Valid([]byte(s))
+}
+
+// toParts maps message content to text, inlineData and fileData parts. Gemini
+// accepts images, audio and documents alike; only the MIME type differs.
+func toParts(msg llm.Message) ([]Part, error) {
+	if len(msg.Parts) == 0 {
+		return []Part{{Text: msg.Content}}, nil
+	}
+
+	parts := make([]Part, 0, len(msg.Parts))
+	for _, p := range msg.Parts {
+		switch {
+		case p.Type == llm.PartText:
+			parts = append(parts, Part{Text: p.Text})
+		case p.Data != "":
+			parts = append(parts, Part{InlineData: &Blob{MimeType: p.MediaType, Data: p.Data}})
+		default:
+			uri := p.URL
+			if uri == "" {
+				uri = p.FileID
+			}
+			mimeType := p.MediaType
+			if mimeType == "" {
+				mimeType = mimeTypeFromURI(uri)
+			}
+			if mimeType == "" {
+				return nil, llm.NewValidationError(fmt.Sprintf("gemini needs a media type for %s content at %q", p.Type, uri), "unsupported_content")
+			}
+			parts = append(parts, Part{FileData: &FileData{MimeType: mimeType, FileURI: uri}})
+		}
+	}
+	return parts, nil
+}
+
+// mimeTypeFromURI guesses a MIME type from the file extension in uri.
+func mimeTypeFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(u.Path)), ";")
+	return mimeType
 }
 
 func toChatResponse(resp GenerateContentResponse, model string) *llm.ChatResponse {
-	var content string
+	var content, reason string
+	var toolCalls []llm.ToolCall
 
 	if len(resp.Candidates) > 0 {
 		candidate := resp.Candidates[0]
 
-		if len(candidate.Content.Parts) > 0 {
-			content = candidate.Content.Parts[0].Text
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall != nil {
+				toolCalls = append(toolCalls, toToolCall(*part.FunctionCall, len(toolCalls)))
+				continue
+			}
+			content += part.Text
 		}
+		reason = finishReason(candidate.FinishReason)
+	}
+	if len(toolCalls) > 0 {
+		reason = "tool_calls"
 	}
 
 	id := resp.ResponseId
@@ -90,8 +247,60 @@ func toChatResponse(resp GenerateContentResponse, model string) *llm.ChatRespons
 	}
 
 	return &llm.ChatResponse{
-		ID:      id,
-		Model:   "gemini/" + model,
-		Content: content,
+		ID:           id,
+		Model:        "gemini/" + model,
+		Content:      content,
+		ToolCalls:    toolCalls,
+		FinishReason: reason,
+		Usage:        resp.UsageMetadata.toUsage(),
+	}
+}
+
+// toUsage normalizes Gemini usage. Thinking tokens are billed as output, so
+// they count towards CompletionTokens as reasoning tokens do for OpenAI.
+func (u *UsageMetadata) toUsage() *llm.Usage {
+	if u == nil {
+		return nil
+	}
+	completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	return &llm.Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: completion,
+		TotalTokens:      u.PromptTokenCount + completion,
+		CachedTokens:     u.CachedContentTokenCount,
+		ReasoningTokens:  u.ThoughtsTokenCount,
+	}
+}
+
+// toToolCall maps a functionCall part to a tool call. Gemini does not assign
+// call IDs, so one is derived from the call's position in the response.
+func toToolCall(fc FunctionCall, index int) llm.ToolCall {
+	args := string(fc.Args)
+	if args == "" {
+		args = "{}"
+	}
+	return llm.ToolCall{
+		ID:   fmt.Sprintf("call_%d_%s", index, fc.Name),
+		Type: "function",
+		Function: llm.FunctionCall{
+			Name:      fc.Name,
+			Arguments: args,
+		},
+	}
+}
+
+// finishReason maps a Gemini finishReason to the OpenAI finish reason.
+func finishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return "stop"
 	}
 }
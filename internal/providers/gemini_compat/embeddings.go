@@ -0,0 +1,104 @@
+package gemini_compat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Embed uses embedContent for a single input and batchEmbedContents otherwise.
+func (c *Client) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	model := strings.TrimPrefix(req.Model, "gemini/")
+
+	requests := make([]EmbedContentRequest, len(req.Input))
+	for i, text := range req.Input {
+		requests[i] = EmbedContentRequest{
+			Model:                "models/" + model,
+			Content:              Content{Parts: []Part{{Text: text}}},
+			OutputDimensionality: req.Dimensions,
+		}
+	}
+
+	var body interface{} = BatchEmbedContentsRequest{Requests: requests}
+	method := "batchEmbedContents"
+	if len(requests) == 1 {
+		body = requests[0]
+		method = "embedContent"
+	}
+
+	respBody, err := c.post(ctx, c.embedEndpoint(model, method), body, req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var embeddings []ContentEmbedding
+	if len(requests) == 1 {
+		var raw EmbedContentResponse
+		if err := json.Unmarshal(respBody, &raw); err != nil {
+			return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
+		}
+		embeddings = []ContentEmbedding{raw.Embedding}
+	} else {
+		var raw BatchEmbedContentsResponse
+		if err := json.Unmarshal(respBody, &raw); err != nil {
+			return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
+		}
+		embeddings = raw.Embeddings
+	}
+
+	if len(embeddings) != len(req.Input) {
+		return nil, llm.NewProviderError(502, fmt.Sprintf("provider returned %d embeddings for %d inputs", len(embeddings), len(req.Input)), "api_error", "invalid_response")
+	}
+
+	resp := &llm.EmbeddingResponse{
+		Model:      model,
+		Embeddings: make([][]float32, len(embeddings)),
+	}
+	for i, e := range embeddings {
+		resp.Embeddings[i] = e.Values
+	}
+	return resp, nil
+}
+
+func (c *Client) embedEndpoint(model, method string) string {
+	baseURL := c.cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com"
+	}
+	return fmt.Sprintf("%s/v1beta/models/%s:%s", baseURL, model, method)
+}
+
+func (c *Client) post(ctx context.Context, url string, body interface{}, apiKey string) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to marshal request: %v", err))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
+	}
+	c.setHeaders(httpReq, apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, llm.NewProviderError(503, fmt.Sprintf("failed to execute request: %v", err), "service_unavailable", "request_failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to read response: %v", err))
+	}
+
+	if err := checkError(resp.StatusCode, respBody); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
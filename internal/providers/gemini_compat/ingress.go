@@ -0,0 +1,359 @@
+package gemini_compat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// This file translates in the opposite direction to the rest of the package:
+// it accepts generateContent requests from Google SDK clients and renders
+// gateway responses in Gemini's shape, so any provider can serve them.
+
+// ParseRequest decodes a generateContent request body into a chat request
+// for model.
+func ParseRequest(model string, body []byte) (llm.ChatRequest, error) {
+	var raw GenerateContentRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json")
+	}
+	if len(raw.Contents) == 0 {
+		return llm.ChatRequest{}, llm.NewValidationError("contents are required", "missing_contents")
+	}
+
+	var messages []llm.Message
+	if raw.SystemInstruction != nil {
+		var texts []string
+		for _, part := range raw.SystemInstruction.Parts {
+			texts = append(texts, part.Text)
+		}
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: strings.Join(texts, "\n")})
+	}
+
+	// Gemini pairs function responses with calls by name. The gateway uses
+	// call IDs, so calls are given IDs here and responses take the ID of the
+	// oldest unanswered call with the same name.
+	var pending []llm.ToolCall
+	calls := 0
+
+	for i, content := range raw.Contents {
+		switch content.Role {
+		case "", "user":
+			converted, err := fromUserParts(content.Parts, &pending)
+			if err != nil {
+				return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("contents[%d]: %v", i, err), "invalid_content")
+			}
+			messages = append(messages, converted...)
+		case "model":
+			msg := llm.Message{Role: llm.RoleAssistant}
+			for _, part := range content.Parts {
+				if part.FunctionCall != nil {
+					tc := toToolCall(*part.FunctionCall, calls)
+					calls++
+					msg.ToolCalls = append(msg.ToolCalls, tc)
+					pending = append(pending, tc)
+					continue
+				}
+				msg.Content += part.Text
+			}
+			messages = append(messages, msg)
+		default:
+			return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("contents[%d]: role must be user or model, got %q", i, content.Role), "invalid_content")
+		}
+	}
+
+	var opts llm.ChatOptions
+	if cfg := raw.GenerationConfig; cfg != nil {
+		opts.Temperature = cfg.Temperature
+		opts.MaxTokens = cfg.MaxOutputTokens
+		opts.TopP = cfg.TopP
+		opts.Stop = cfg.StopSequences
+		if cfg.ResponseMimeType == "application/json" {
+			opts.ResponseFormat = &llm.ResponseFormat{Type: "json_object"}
+			if len(cfg.ResponseSchema) > 0 {
+				opts.ResponseFormat = &llm.ResponseFormat{Type: "json_schema", Schema: cfg.ResponseSchema}
+			}
+		}
+	}
+
+	for _, t := range raw.Tools {
+		for _, fd := range t.FunctionDeclarations {
+			opts.Tools = append(opts.Tools, llm.Tool{
+				Type: "function",
+				Function: &llm.FunctionTool{
+					Name:        fd.Name,
+					Description: fd.Description,
+					Parameters:  fd.Parameters,
+				},
+			})
+		}
+	}
+
+	if raw.ToolConfig != nil {
+		cfg := raw.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(cfg.Mode) {
+		case "AUTO":
+			opts.ToolChoice = "auto"
+		case "NONE":
+			opts.ToolChoice = "none"
+		case "ANY":
+			opts.ToolChoice = "required"
+			if len(cfg.AllowedFunctionNames) == 1 {
+				choice := llm.ToolChoice{Type: "function"}
+				choice.Function = &struct {
+					Name string `json:"name"`
+				}{Name: cfg.AllowedFunctionNames[0]}
+				opts.ToolChoice = choice
+			}
+		}
+	}
+
+	return llm.ChatRequest{
+		Model:    model,
+		Messages: messages,
+		Options:  opts,
+	}, nil
+}
+
+// fromUserParts converts a user turn. Function responses become tool
+// messages, which come first as they answer the previous model turn.
+func fromUserParts(parts []Part, pending *[]llm.ToolCall) ([]llm.Message, error) {
+	var messages []llm.Message
+	var content []llm.ContentPart
+
+	for _, part := range parts {
+		switch {
+		case part.FunctionResponse != nil:
+			id, err := answerCall(pending, part.FunctionResponse.Name)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
+				Content:    string(part.FunctionResponse.Response),
+				ToolCallID: id,
+			})
+		case part.InlineData != nil:
+			content = append(content, llm.ContentPart{
+				Type:      partType(part.InlineData.MimeType),
+				Data:      part.InlineData.Data,
+				MediaType: part.InlineData.MimeType,
+			})
+		case part.FileData != nil:
+			content = append(content, llm.ContentPart{
+				Type:      partType(part.FileData.MimeType),
+				URL:       part.FileData.FileURI,
+				MediaType: part.FileData.MimeType,
+			})
+		case part.FunctionCall != nil:
+			return nil, errors.New("functionCall parts belong in model turns")
+		default:
+			content = append(content, llm.ContentPart{Type: llm.PartText, Text: part.Text})
+		}
+	}
+
+	if len(content) == 0 {
+		return messages, nil
+	}
+	msg := llm.Message{Role: llm.RoleUser}
+	if len(content) == 1 && content[0].Type == llm.PartText {
+		msg.Content = content[0].Text
+	} else {
+		msg.Parts = content
+	}
+	return append(messages, msg), nil
+}
+
+// answerCall removes the oldest pending call named name and returns its ID.
+func answerCall(pending *[]llm.ToolCall, name string) (string, error) {
+	for i, tc := range *pending {
+		if tc.Function.Name == name {
+			*pending = append((*pending)[:i], (*pending)[i+1:]...)
+			return tc.ID, nil
+		}
+	}
+	return "", fmt.Errorf("functionResponse %q does not answer a functionCall", name)
+}
+
+// partType classifies inline media by MIME type.
+func partType(mimeType string) llm.ContentPartType {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return llm.PartImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return llm.PartAudio
+	default:
+		return llm.PartFile
+	}
+}
+
+// EncodeResponse renders resp as a generateContent response.
+func EncodeResponse(resp *llm.ChatResponse) GenerateContentResponse {
+	parts := []Part{}
+	if resp.Content != "" {
+		parts = append(parts, Part{Text: resp.Content})
+	}
+	parts = append(parts, functionCallParts(resp.ToolCalls)...)
+
+	return GenerateContentResponse{
+		Candidates: []Candidate{{
+			Content:      Content{Role: "model", Parts: parts},
+			FinishReason: toFinishReason(resp.FinishReason),
+		}},
+		UsageMetadata: fromUsage(resp.Usage),
+		ModelVersion:  resp.Model,
+		ResponseId:    resp.ID,
+	}
+}
+
+func functionCallParts(toolCalls []llm.ToolCall) []Part {
+	var parts []Part
+	for _, tc := range toolCalls {
+		args := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage(`{}`)
+		}
+		parts = append(parts, Part{FunctionCall: &FunctionCall{Name: tc.Function.Name, Args: args}})
+	}
+	return parts
+}
+
+// toFinishReason maps an OpenAI finish reason to a Gemini finishReason.
+// Gemini ends function calls with STOP.
+func toFinishReason(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func fromUsage(u *llm.Usage) *UsageMetadata {
+	if u == nil {
+		return nil
+	}
+	return &UsageMetadata{
+		PromptTokenCount:        u.PromptTokens,
+		CandidatesTokenCount:    u.CompletionTokens - u.ReasoningTokens,
+		TotalTokenCount:         u.TotalTokens,
+		CachedContentTokenCount: u.CachedTokens,
+		ThoughtsTokenCount:      u.ReasoningTokens,
+	}
+}
+
+// EncodeError renders err as a Gemini API error and returns it with the HTTP
+// status to send.
+func EncodeError(err error) (int, []byte) {
+	status := http.StatusInternalServerError
+	message := "Internal server error"
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) {
+		status, message = pe.StatusCode, pe.Message
+	}
+
+	code := "INTERNAL"
+	switch status {
+	case http.StatusBadRequest:
+		code = "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		code = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		code = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		code = "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		code = "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		code = "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		code = "DEADLINE_EXCEEDED"
+	}
+
+	body, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: status, Message: message, Status: code}})
+	return status, body
+}
+
+// StreamEncoder turns stream chunks into streamGenerateContent responses.
+// Text is passed on as it arrives. Gemini sends function calls whole, so
+// their arguments are collected and sent with the final response, together
+// with the finish reason and usage.
+type StreamEncoder struct {
+	id           string
+	model        string
+	toolCalls    []llm.ToolCall
+	finishReason string
+	usage        *llm.Usage
+}
+
+func NewStreamEncoder() *StreamEncoder {
+	return &StreamEncoder{}
+}
+
+// Chunk returns the response for chunk, or nil if it carries no text.
+func (e *StreamEncoder) Chunk(chunk *llm.StreamChunk) *GenerateContentResponse {
+	if e.id == "" {
+		e.id = chunk.ID
+	}
+	if e.model == "" {
+		e.model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		e.usage = chunk.Usage
+	}
+
+	var text string
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Content != nil {
+			text += *choice.Delta.Content
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			for len(e.toolCalls) <= tc.Index {
+				e.toolCalls = append(e.toolCalls, llm.ToolCall{Type: "function"})
+			}
+			call := &e.toolCalls[tc.Index]
+			if tc.Function.Name != "" {
+				call.Function.Name = tc.Function.Name
+			}
+			call.Function.Arguments += tc.Function.Arguments
+		}
+		if choice.FinishReason != nil {
+			e.finishReason = *choice.FinishReason
+		}
+	}
+
+	if text == "" {
+		return nil
+	}
+	return &GenerateContentResponse{
+		Candidates:   []Candidate{{Content: Content{Role: "model", Parts: []Part{{Text: text}}}}},
+		ModelVersion: e.model,
+		ResponseId:   e.id,
+	}
+}
+
+// Finish returns the last response of the stream.
+func (e *StreamEncoder) Finish() GenerateContentResponse {
+	parts := functionCallParts(e.toolCalls)
+	if parts == nil {
+		parts = []Part{}
+	}
+	return GenerateContentResponse{
+		Candidates: []Candidate{{
+			Content:      Content{Role: "model", Parts: parts},
+			FinishReason: toFinishReason(e.finishReason),
+		}},
+		UsageMetadata: fromUsage(e.usage),
+		ModelVersion:  e.model,
+		ResponseId:    e.id,
+	}
+}
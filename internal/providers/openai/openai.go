@@ -2,6 +2,7 @@ package openai
 
 import (
 	"context"
+	"io"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
 	"github.com/atozi-ai/gateway/internal/providers/openai_compat"
@@ -9,6 +10,9 @@ import (
 
 const baseURL = "https://api.openai.com/v1"
 
+// parts are the non-text content types the OpenAI API accepts.
+var parts = []llm.ContentPartType{llm.PartImage, llm.PartAudio, llm.PartFile}
+
 // Provider implements llm.Provider for the OpenAI API.
 type Provider struct {
 	client *openaicompat.Client
@@ -16,11 +20,18 @@ type Provider struct {
 
 // New creates an OpenAI provider.
 func New() *Provider {
+	return NewWithConfig(openaicompat.Config{})
+}
+
+// NewWithConfig creates an OpenAI provider with explicit client settings.
+// An empty BaseURL falls back to the provider default.
+func NewWithConfig(cfg openaicompat.Config) *Provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = baseURL
+	}
+	cfg.Parts = parts
 	return &Provider{
-		client: openaicompat.NewClient(openaicompat.Config{
-			BaseURL: baseURL,
-			APIKey:  "",
-		}),
+		client: openaicompat.NewClient(cfg),
 	}
 }
 
@@ -33,3 +44,11 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResp
 func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
 	return p.client.ChatStream(ctx, req, callback)
 }
+
+func (p *Provider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	return p.client.Embed(ctx, req)
+}
+
+func (p *Provider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	return p.client.Respond(ctx, req)
+}
@@ -18,6 +18,10 @@ const maxResponseBytes = 10 * 1024 * 1024 // 10 MB
 
 // Chat sends a non-streaming chat completions request and returns the parsed response.
 func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if err := c.checkParts(req.Messages); err != nil {
+		return nil, err
+	}
+
 	body := toRequest(req)
 
 	jsonBody, err := json.Marshal(body)
@@ -28,7 +32,7 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatRespon
 	log := logger.FromContext(ctx)
 	log.Info().Str("model", req.Model).Msg("Using Model")
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
 	}
@@ -54,17 +58,31 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatRespon
 		return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
 	}
 
-	content := ""
+	chatResp := &llm.ChatResponse{
+		ID:    raw.ID,
+		Model: raw.Model,
+		Usage: raw.Usage.toUsage(),
+		Raw:   respBody,
+	}
 	if len(raw.Choices) > 0 {
-		content = raw.Choices[0].Message.Content
+		msg := raw.Choices[0].Message
+		if msg.Content != nil {
+			chatResp.Content = *msg.Content
+		}
+		chatResp.FinishReason = raw.Choices[0].FinishReason
+		for _, tc := range msg.ToolCalls {
+			chatResp.ToolCalls = append(chatResp.ToolCalls, llm.ToolCall{
+				ID:   tc.ID,
+				Type: tc.Type,
+				Function: llm.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
 	}
 
-	return &llm.ChatResponse{
-		ID:      raw.ID,
-		Model:   raw.Model,
-		Content: content,
-		Raw:     respBody,
-	}, nil
+	return chatResp, nil
 }
 
 // ChatStream sends a streaming chat completions request and invokes callback for each chunk.
@@ -78,6 +96,10 @@ func (c *Client) ChatStream(
 	stream := true
 	req.Options.Stream = &stream
 
+	if err := c.checkParts(req.Messages); err != nil {
+		return err
+	}
+
 	body := toRequest(req)
 
 	jsonBody, err := json.Marshal(body)
@@ -88,7 +110,7 @@ func (c *Client) ChatStream(
 	log := logger.FromContext(ctx)
 	log.Info().Str("model", req.Model).Msg("Using Model for streaming")
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(req), bytes.NewReader(jsonBody))
 	if err != nil {
 		return llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
 	}
@@ -113,13 +135,12 @@ func (c *Client) ChatStream(
 	return readSSEStream(ctx, resp.Body, callback)
 }
 
-// endpoint returns the full chat completions URL.
-func (c *Client) endpoint() string {
-	baseURL := strings.TrimRight(c.cfg.BaseURL, "/")
-	if strings.Contains(baseURL, "openai.azure.com") {
-		return baseURL
+// endpoint returns the full chat completions URL for req.
+func (c *Client) endpoint(req llm.ChatRequest) string {
+	if c.cfg.ChatURL != nil {
+		return c.cfg.ChatURL(req)
 	}
-	return baseURL + "/chat/completions"
+	return strings.TrimRight(c.cfg.BaseURL, "/") + "/chat/completions"
 }
 
 // checkError inspects the status code and tries to parse an API error.
@@ -148,6 +169,9 @@ func checkError(statusCode int, body []byte) error {
 }
 
 // readSSEStream reads an SSE stream and dispatches parsed chunks to callback.
+// An error event, or a stream that ends with neither [DONE] nor a finish
+// reason, is returned as a 502 error so it can be told apart from a complete
+// response.
 func readSSEStream(
 	ctx context.Context,
 	r io.Reader,
@@ -160,6 +184,7 @@ func readSSEStream(
 	scanner.Buffer(buf, maxScanTokenSize)
 
 	log := logger.FromContext(ctx)
+	finished := false
 
 	for scanner.Scan() {
 		// Check context cancellation
@@ -185,6 +210,20 @@ func readSSEStream(
 			return nil
 		}
 
+		if strings.Contains(data, `"error"`) {
+			var apiErr errorResponse
+			if err := json.Unmarshal([]byte(data), &apiErr); err == nil && apiErr.Error.Message != "" {
+				return &llm.ProviderError{
+					StatusCode: 502,
+					Message:    apiErr.Error.Message,
+					Type:       apiErr.Error.Type,
+					Code:       apiErr.Error.Code,
+					Param:      apiErr.Error.Param,
+					Raw:        []byte(data),
+				}
+			}
+		}
+
 		chunk, err := parseStreamChunk([]byte(data))
 		if err != nil {
 			// Log parse errors but continue processing stream
@@ -192,6 +231,12 @@ func readSSEStream(
 			continue
 		}
 
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil {
+				finished = true
+			}
+		}
+
 		if err := callback(chunk); err != nil {
 			return err
 		}
@@ -201,6 +246,14 @@ func readSSEStream(
 		return llm.NewInternalError(fmt.Sprintf("failed to read stream: %v", err))
 	}
 
+	if !finished {
+		return &llm.ProviderError{
+			StatusCode: 502,
+			Message:    "stream ended before the response was complete",
+			Type:       "server_error",
+			Code:       "incomplete_stream",
+		}
+	}
 	return nil
 }
 
@@ -233,9 +286,13 @@ func parseStreamChunk(data []byte) (*llm.StreamChunk, error) {
 		if c.Delta.Content != nil {
 			sc.Delta.Content = c.Delta.Content
 		}
-		if len(c.Delta.ToolCalls) > 0 {
-			sc.Delta.ToolCalls = make([]interface{}, len(c.Delta.ToolCalls))
-			copy(sc.Delta.ToolCalls, c.Delta.ToolCalls)
+		for _, tc := range c.Delta.ToolCalls {
+			sc.Delta.ToolCalls = append(sc.Delta.ToolCalls, llm.ToolCallDelta{
+				Index:    tc.Index,
+				ID:       tc.ID,
+				Type:     tc.Type,
+				Function: llm.FunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+			})
 		}
 		if c.FinishReason != nil {
 			sc.FinishReason = c.FinishReason
@@ -247,13 +304,7 @@ func parseStreamChunk(data []byte) (*llm.StreamChunk, error) {
 		chunk.Choices[i] = sc
 	}
 
-	if raw.Usage != nil {
-		chunk.Usage = &llm.Usage{
-			PromptTokens:     raw.Usage.PromptTokens,
-			CompletionTokens: raw.Usage.CompletionTokens,
-			TotalTokens:      raw.Usage.TotalTokens,
-		}
-	}
+	chunk.Usage = raw.Usage.toUsage()
 
 	return chunk, nil
 }
@@ -2,8 +2,12 @@ package openaicompat
 
 import (
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 )
 
 var (
@@ -20,7 +24,7 @@ func initSharedClient() {
 	}
 	sharedHTTPClient = &http.Client{
 		Timeout:   120 * time.Second,
-		Transport: transport,
+		Transport: tracing.Transport(transport),
 	}
 }
 
@@ -34,6 +38,13 @@ type Config struct {
 	BaseURL string
 	APIKey  string
 	Headers map[string]string // Extra headers beyond Authorization and Content-Type.
+	Timeout time.Duration     // Overrides the shared client timeout when non-zero.
+	// Parts lists the non-text content part types the API accepts. Nil means
+	// images only, which is what most OpenAI-compatible APIs support.
+	Parts []llm.ContentPartType
+	// ChatURL replaces BaseURL + "/chat/completions" for APIs that put the
+	// model in the path, such as Azure OpenAI.
+	ChatURL func(req llm.ChatRequest) string
 }
 
 // Client performs HTTP calls against an OpenAI-compatible chat completions API.
@@ -45,6 +56,12 @@ type Client struct {
 // NewClient returns a ready-to-use Client for the given configuration.
 // Uses a shared HTTP client with connection pooling for better performance.
 func NewClient(cfg Config) *Client {
+	if cfg.Timeout > 0 {
+		return NewClientWithCustomHTTP(cfg, &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: getSharedClient().Transport,
+		})
+	}
 	return &Client{
 		cfg:        cfg,
 		httpClient: getSharedClient(),
@@ -82,3 +99,17 @@ func (c *Client) setHeaders(req *http.Request, apiKey string) {
 		}
 	}
 }
+
+// checkParts rejects message content the API cannot take.
+func (c *Client) checkParts(messages []llm.Message) error {
+	parts := c.cfg.Parts
+	if parts == nil {
+		parts = []llm.ContentPartType{llm.PartImage}
+	}
+
+	name := c.cfg.BaseURL
+	if u, err := url.Parse(c.cfg.BaseURL); err == nil && u.Host != "" {
+		name = u.Host
+	}
+	return llm.CheckParts(name, messages, parts...)
+}
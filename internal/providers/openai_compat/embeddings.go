@@ -0,0 +1,77 @@
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Embed sends an embeddings request. Vectors are always requested as floats;
+// callers that want base64 encode them afterwards.
+func (c *Client) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	jsonBody, err := json.Marshal(embeddingRequest{
+		Model:          req.Model,
+		Input:          req.Input,
+		Dimensions:     req.Dimensions,
+		EncodingFormat: "float",
+		User:           req.User,
+	})
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to marshal request: %v", err))
+	}
+
+	url := strings.TrimRight(c.cfg.BaseURL, "/") + "/embeddings"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
+	}
+	c.setHeaders(httpReq, req.APIKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, llm.NewProviderError(503, fmt.Sprintf("failed to execute request: %v", err), "service_unavailable", "request_failed")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to read response: %v", err))
+	}
+
+	if err := checkError(resp.StatusCode, respBody); err != nil {
+		return nil, err
+	}
+
+	var raw embeddingResponse
+	if err := json.Unmarshal(respBody, &raw); err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to unmarshal response: %v", err))
+	}
+
+	if len(raw.Data) != len(req.Input) {
+		return nil, llm.NewProviderError(502, fmt.Sprintf("provider returned %d embeddings for %d inputs", len(raw.Data), len(req.Input)), "api_error", "invalid_response")
+	}
+
+	// Providers are not required to return data in input order.
+	embeddings := make([][]float32, len(req.Input))
+	for _, d := range raw.Data {
+		if d.Index < 0 || d.Index >= len(embeddings) {
+			return nil, llm.NewProviderError(502, fmt.Sprintf("provider returned embedding index %d out of range", d.Index), "api_error", "invalid_response")
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+
+	return &llm.EmbeddingResponse{
+		Model:      raw.Model,
+		Embeddings: embeddings,
+		Usage: llm.Usage{
+			PromptTokens: raw.Usage.PromptTokens,
+			TotalTokens:  raw.Usage.TotalTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,249 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// This file translates in the opposite direction to the rest of the package:
+// it accepts chat completions requests from OpenAI SDK clients and renders
+// gateway responses exactly as the OpenAI API would, so any provider can
+// serve them.
+
+// ParseRequest decodes a chat completions request body into a chat request.
+// The model is returned as sent; it still has to be resolved to a provider.
+func ParseRequest(body []byte) (llm.ChatRequest, error) {
+	var raw completionRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return llm.ChatRequest{}, llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json")
+	}
+
+	if raw.Model == "" {
+		return llm.ChatRequest{}, paramError("you must provide a model parameter", "model", "missing_model")
+	}
+	if len(raw.Messages) == 0 {
+		return llm.ChatRequest{}, paramError("messages must not be empty", "messages", "missing_messages")
+	}
+	for i, msg := range raw.Messages {
+		if msg.Role == llm.RoleTool && msg.ToolCallID == "" {
+			return llm.ChatRequest{}, paramError("tool messages require tool_call_id", fmt.Sprintf("messages[%d].tool_call_id", i), "missing_tool_call_id")
+		}
+	}
+
+	stop, err := parseStop(raw.Stop)
+	if err != nil {
+		return llm.ChatRequest{}, paramError(err.Error(), "stop", "invalid_stop")
+	}
+
+	maxTokens := raw.MaxTokens
+	if raw.MaxCompletionTokens != nil {
+		maxTokens = raw.MaxCompletionTokens
+	}
+
+	opts := llm.ChatOptions{
+		Temperature:       raw.Temperature,
+		MaxTokens:         maxTokens,
+		TopP:              raw.TopP,
+		Stop:              stop,
+		Verbosity:         raw.Verbosity,
+		FrequencyPenalty:  raw.FrequencyPenalty,
+		PresencePenalty:   raw.PresencePenalty,
+		LogitBias:         raw.LogitBias,
+		Logprobs:          raw.Logprobs,
+		TopLogprobs:       raw.TopLogprobs,
+		N:                 raw.N,
+		Seed:              raw.Seed,
+		User:              raw.User,
+		ToolChoice:        raw.ToolChoice,
+		ParallelToolCalls: raw.ParallelToolCalls,
+		Stream:            raw.Stream,
+	}
+
+	if rf := raw.ResponseFormat; rf != nil {
+		opts.ResponseFormat = &llm.ResponseFormat{Type: rf.Type}
+		if rf.JSONSchema != nil {
+			opts.ResponseFormat.Schema = rf.JSONSchema.Schema
+		}
+	}
+
+	for _, t := range raw.Tools {
+		tool := llm.Tool{Type: t.Type}
+		if t.Function != nil {
+			tool.Function = &llm.FunctionTool{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			}
+		}
+		opts.Tools = append(opts.Tools, tool)
+	}
+
+	if raw.StreamOptions != nil {
+		opts.StreamOptions = &llm.StreamOptions{IncludeUsage: raw.StreamOptions.IncludeUsage}
+	}
+
+	return llm.ChatRequest{
+		Model:    raw.Model,
+		Messages: raw.Messages,
+		Options:  opts,
+	}, nil
+}
+
+// parseStop accepts stop as a single string or an array of strings.
+func parseStop(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var stop []string
+	if err := json.Unmarshal(raw, &stop); err != nil {
+		return nil, errors.New("stop must be a string or an array of strings")
+	}
+	return stop, nil
+}
+
+func paramError(message, param, code string) *llm.ProviderError {
+	pe := llm.NewValidationError(message, code)
+	pe.Param = param
+	return pe
+}
+
+// EncodeResponse renders resp as a chat.completion object. Responses from
+// OpenAI-compatible providers are passed on with all their choices and
+// logprobs; others are built from the normalized response.
+func EncodeResponse(resp *llm.ChatResponse) ([]byte, error) {
+	var out chatResponse
+	if len(resp.Raw) == 0 || json.Unmarshal(resp.Raw, &out) != nil || len(out.Choices) == 0 {
+		out = chatResponse{
+			Choices: []choice{{
+				Message:      message{Role: string(llm.RoleAssistant)},
+				FinishReason: resp.FinishReason,
+			}},
+		}
+		msg := &out.Choices[0].Message
+		if resp.Content != "" || len(resp.ToolCalls) == 0 {
+			msg.Content = &resp.Content
+		}
+		for _, tc := range resp.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, toolCall{
+				ID:       tc.ID,
+				Type:     tc.Type,
+				Function: function{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+			})
+		}
+		if out.Choices[0].FinishReason == "" {
+			out.Choices[0].FinishReason = "stop"
+		}
+	}
+
+	out.Object = "chat.completion"
+	if out.ID == "" {
+		out.ID = resp.ID
+	}
+	if out.Model == "" {
+		out.Model = resp.Model
+	}
+	if out.Created == 0 {
+		out.Created = time.Now().Unix()
+	}
+	if out.Usage == nil {
+		out.Usage = fromUsage(resp.Usage)
+	}
+	return json.Marshal(out)
+}
+
+// fromUsage is the inverse of usage.toUsage.
+func fromUsage(u *llm.Usage) *usage {
+	if u == nil {
+		return nil
+	}
+	out := &usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if u.CachedTokens > 0 {
+		cached := u.CachedTokens
+		out.PromptTokensDetails = &tokensDetails{CachedTokens: &cached}
+	}
+	if u.ReasoningTokens > 0 {
+		reasoning := u.ReasoningTokens
+		out.CompletionTokensDetails = &tokensDetails{ReasoningTokens: &reasoning}
+	}
+	return out
+}
+
+// EncodeChunk renders chunk as a chat.completion.chunk object. Usage is only
+// included if the client asked for it with stream_options.include_usage; a
+// chunk that carries nothing else is then dropped and EncodeChunk returns
+// nil.
+func EncodeChunk(chunk *llm.StreamChunk, includeUsage bool) ([]byte, error) {
+	if len(chunk.Choices) == 0 && (chunk.Usage == nil || !includeUsage) {
+		return nil, nil
+	}
+
+	out := streamChunk{
+		ID:                chunk.ID,
+		Object:            "chat.completion.chunk",
+		Created:           chunk.Created,
+		Model:             chunk.Model,
+		Choices:           make([]streamChoice, len(chunk.Choices)),
+		SystemFingerprint: chunk.SystemFingerprint,
+		ServiceTier:       chunk.ServiceTier,
+	}
+	if out.Created == 0 {
+		out.Created = time.Now().Unix()
+	}
+	if includeUsage {
+		out.Usage = fromUsage(chunk.Usage)
+	}
+
+	for i, c := range chunk.Choices {
+		out.Choices[i] = streamChoice{
+			Index:        c.Index,
+			Delta:        streamDelta{Role: c.Delta.Role, Content: c.Delta.Content},
+			FinishReason: c.FinishReason,
+			Logprobs:     c.Logprobs,
+		}
+		for _, tc := range c.Delta.ToolCalls {
+			delta := toolCallDelta{Index: tc.Index, ID: tc.ID, Type: tc.Type}
+			delta.Function.Name = tc.Function.Name
+			delta.Function.Arguments = tc.Function.Arguments
+			out.Choices[i].Delta.ToolCalls = append(out.Choices[i].Delta.ToolCalls, delta)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// EncodeError renders err in the OpenAI error envelope and returns it with
+// the HTTP status to send.
+func EncodeError(err error) (int, []byte) {
+	pe := llm.NewInternalError("Internal server error")
+	errors.As(err, &pe)
+
+	detail := apiError{Message: pe.Message, Type: pe.Type}
+	if detail.Type == "" {
+		detail.Type = "api_error"
+	}
+	if pe.Param != "" {
+		detail.Param = &pe.Param
+	}
+	if pe.Code != "" {
+		detail.Code = &pe.Code
+	}
+
+	status := pe.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	body, _ := json.Marshal(map[string]apiError{"error": detail})
+	return status, body
+}
@@ -0,0 +1,55 @@
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// Respond forwards a Responses API request to the API's /responses endpoint.
+// Only the model in the body is changed.
+func (c *Client) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		return nil, llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json")
+	}
+	model, err := json.Marshal(req.Model)
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to marshal model: %v", err))
+	}
+	body["model"] = model
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to marshal request: %v", err))
+	}
+
+	url := strings.TrimRight(c.cfg.BaseURL, "/") + "/responses"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, llm.NewInternalError(fmt.Sprintf("failed to create request: %v", err))
+	}
+	c.setHeaders(httpReq, req.APIKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, llm.NewProviderError(503, fmt.Sprintf("failed to execute request: %v", err), "service_unavailable", "request_failed")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		if err != nil {
+			return nil, llm.NewInternalError(fmt.Sprintf("failed to read error response: %v", err))
+		}
+		return nil, checkError(resp.StatusCode, respBody)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,613 @@
+package openaicompat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+// This file serves the OpenAI Responses API on top of chat: it turns input
+// items into chat messages and renders chat responses and streams as response
+// objects and typed events. Requests for OpenAI itself are forwarded with
+// Client.Respond instead.
+
+// ResponsesRequest is a decoded Responses API request.
+type ResponsesRequest struct {
+	Model              string
+	Stream             bool
+	PreviousResponseID string
+	Store              bool // The client allows the response to be stored
+	MaxOutputTokens    *int
+
+	raw responsesRequest
+}
+
+// ParseResponsesRequest decodes a Responses API request body. Input items
+// and tools are only checked by ChatRequest, so requests that use features
+// only OpenAI has can still be forwarded to it.
+func ParseResponsesRequest(body []byte) (*ResponsesRequest, error) {
+	var raw responsesRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, llm.NewValidationError(fmt.Sprintf("Invalid request body: %v", err), "invalid_json")
+	}
+	if raw.Model == "" {
+		return nil, paramError("you must provide a model parameter", "model", "missing_model")
+	}
+	if len(raw.Input) == 0 || string(raw.Input) == "null" {
+		return nil, paramError("input is required", "input", "missing_input")
+	}
+
+	req := &ResponsesRequest{
+		Model:           raw.Model,
+		Stream:          raw.Stream,
+		Store:           raw.Store == nil || *raw.Store,
+		MaxOutputTokens: raw.MaxOutputTokens,
+		raw:             raw,
+	}
+	if raw.PreviousResponseID != nil {
+		req.PreviousResponseID = *raw.PreviousResponseID
+	}
+	return req, nil
+}
+
+// Input returns the request's input items as chat messages.
+func (r *ResponsesRequest) Input() ([]llm.Message, error) {
+	var text string
+	if err := json.Unmarshal(r.raw.Input, &text); err == nil {
+		return []llm.Message{{Role: llm.RoleUser, Content: text}}, nil
+	}
+
+	var items []inputItem
+	if err := json.Unmarshal(r.raw.Input, &items); err != nil {
+		return nil, paramError("input must be a string or an array of input items", "input", "invalid_input")
+	}
+
+	var messages []llm.Message
+	for i, item := range items {
+		switch item.Type {
+		case "", "message":
+			msg, err := fromInputMessage(item)
+			if err != nil {
+				return nil, paramError(err.Error(), fmt.Sprintf("input[%d]", i), "invalid_input")
+			}
+			messages = append(messages, msg)
+		case "function_call":
+			call := llm.ToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: llm.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+			}
+			// Parallel calls are separate items but one assistant turn.
+			if n := len(messages); n > 0 && messages[n-1].Role == llm.RoleAssistant {
+				messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+				continue
+			}
+			messages = append(messages, llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call}})
+		case "function_call_output":
+			if item.CallID == "" {
+				return nil, paramError("function_call_output requires call_id", fmt.Sprintf("input[%d].call_id", i), "missing_call_id")
+			}
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
+				Content:    contentText(item.Output),
+				ToolCallID: item.CallID,
+			})
+		case "reasoning":
+			// Reasoning from an earlier turn cannot be replayed to other
+			// providers.
+		default:
+			return nil, paramError(fmt.Sprintf("input items of type %q are not supported for this model", item.Type), fmt.Sprintf("input[%d].type", i), "unsupported_input")
+		}
+	}
+	return messages, nil
+}
+
+func fromInputMessage(item inputItem) (llm.Message, error) {
+	var msg llm.Message
+	switch item.Role {
+	case "user":
+		msg.Role = llm.RoleUser
+	case "assistant":
+		msg.Role = llm.RoleAssistant
+	case "system", "developer":
+		msg.Role = llm.RoleSystem
+	default:
+		return msg, fmt.Errorf("role must be user, assistant, system or developer, got %q", item.Role)
+	}
+
+	var text string
+	if err := json.Unmarshal(item.Content, &text); err == nil {
+		msg.Content = text
+		return msg, nil
+	}
+
+	var parts []inputContent
+	if err := json.Unmarshal(item.Content, &parts); err != nil {
+		return msg, errors.New("content must be a string or an array of content parts")
+	}
+
+	for _, p := range parts {
+		switch p.Type {
+		case "input_text", "output_text":
+			msg.Parts = append(msg.Parts, llm.ContentPart{Type: llm.PartText, Text: p.Text})
+		case "refusal":
+		case "input_image":
+			part := llm.ContentPart{Type: llm.PartImage, Detail: p.Detail, FileID: p.FileID}
+			if mediaType, data, ok := llm.ParseDataURL(p.ImageURL); ok {
+				part.MediaType, part.Data = mediaType, data
+			} else {
+				part.URL = p.ImageURL
+			}
+			if part.URL == "" && part.Data == "" && part.FileID == "" {
+				return msg, errors.New("input_image requires image_url or file_id")
+			}
+			msg.Parts = append(msg.Parts, part)
+		case "input_file":
+			part := llm.ContentPart{Type: llm.PartFile, Filename: p.Filename, FileID: p.FileID, URL: p.FileURL}
+			if p.FileData != "" {
+				mediaType, data, ok := llm.ParseDataURL(p.FileData)
+				if !ok {
+					return msg, errors.New("input_file file_data must be a base64 data URL")
+				}
+				part.MediaType, part.Data = mediaType, data
+			}
+			if part.URL == "" && part.Data == "" && part.FileID == "" {
+				return msg, errors.New("input_file requires file_data, file_url or file_id")
+			}
+			msg.Parts = append(msg.Parts, part)
+		default:
+			return msg, fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+	}
+
+	// Text-only content is sent as plain text, which every provider takes.
+	textOnly := true
+	for _, p := range msg.Parts {
+		textOnly = textOnly && p.Type == llm.PartText
+	}
+	if textOnly {
+		msg.Content, msg.Parts = msg.Text(), nil
+	}
+	return msg, nil
+}
+
+// contentText returns a string, or the joined text parts of a content array.
+func contentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []inputContent
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return string(raw)
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ChatRequest builds the chat request for the given conversation: the
+// instructions, then history and input, which Input returns.
+func (r *ResponsesRequest) ChatRequest(history []llm.Message, input []llm.Message) (llm.ChatRequest, error) {
+	raw := r.raw
+
+	var messages []llm.Message
+	if raw.Instructions != nil && *raw.Instructions != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: *raw.Instructions})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, input...)
+
+	stream := raw.Stream
+	opts := llm.ChatOptions{
+		Temperature:       raw.Temperature,
+		MaxTokens:         raw.MaxOutputTokens,
+		TopP:              raw.TopP,
+		User:              raw.User,
+		ParallelToolCalls: raw.ParallelToolCalls,
+		Stream:            &stream,
+	}
+
+	if raw.Text != nil {
+		opts.Verbosity = raw.Text.Verbosity
+		if f := raw.Text.Format; f != nil && f.Type != "text" {
+			opts.ResponseFormat = &llm.ResponseFormat{Type: f.Type, Schema: f.Schema}
+		}
+	}
+
+	for i, t := range raw.Tools {
+		if t.Type != "function" {
+			return llm.ChatRequest{}, paramError(fmt.Sprintf("tools of type %q are only supported by OpenAI models", t.Type), fmt.Sprintf("tools[%d].type", i), "unsupported_tool")
+		}
+		opts.Tools = append(opts.Tools, llm.Tool{
+			Type: "function",
+			Function: &llm.FunctionTool{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	if len(raw.ToolChoice) > 0 {
+		var mode string
+		var named struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		}
+		switch {
+		case json.Unmarshal(raw.ToolChoice, &mode) == nil:
+			opts.ToolChoice = mode
+		case json.Unmarshal(raw.ToolChoice, &named) == nil && named.Type == "function":
+			choice := llm.ToolChoice{Type: "function"}
+			choice.Function = &struct {
+				Name string `json:"name"`
+			}{Name: named.Name}
+			opts.ToolChoice = choice
+		default:
+			return llm.ChatRequest{}, paramError("tool_choice must be none, auto, required or a function", "tool_choice", "unsupported_tool_choice")
+		}
+	}
+
+	return llm.ChatRequest{
+		Model:    r.Model,
+		Messages: messages,
+		Options:  opts,
+	}, nil
+}
+
+// NewResponseID returns a new response ID.
+func NewResponseID() string {
+	return newID("resp")
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
+
+// response returns the response object for id as of status, echoing the
+// request's settings as the OpenAI API does.
+func (r *ResponsesRequest) response(id string, createdAt int64, model, status string) responseObject {
+	raw := r.raw
+
+	text := responsesText{Format: &textFormat{Type: "text"}}
+	if raw.Text != nil && raw.Text.Format != nil {
+		text = *raw.Text
+	}
+	toolChoice := raw.ToolChoice
+	if len(toolChoice) == 0 {
+		toolChoice = json.RawMessage(`"auto"`)
+	}
+	tools := raw.Tools
+	if tools == nil {
+		tools = []responsesTool{}
+	}
+	metadata := raw.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	return responseObject{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          createdAt,
+		Status:             status,
+		Instructions:       raw.Instructions,
+		MaxOutputTokens:    raw.MaxOutputTokens,
+		Model:              model,
+		Output:             []outputItem{},
+		ParallelToolCalls:  raw.ParallelToolCalls == nil || *raw.ParallelToolCalls,
+		PreviousResponseID: raw.PreviousResponseID,
+		Store:              r.Store,
+		Temperature:        raw.Temperature,
+		Text:               text,
+		ToolChoice:         toolChoice,
+		Tools:              tools,
+		TopP:               raw.TopP,
+		User:               raw.User,
+		Metadata:           metadata,
+	}
+}
+
+// finish sets the status, output and usage of a finished response.
+func finish(out *responseObject, items []outputItem, finishReason string, usage *llm.Usage) {
+	out.Status = "completed"
+	switch finishReason {
+	case "length":
+		out.Status = "incomplete"
+		out.IncompleteDetails = &incompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		out.Status = "incomplete"
+		out.IncompleteDetails = &incompleteDetails{Reason: "content_filter"}
+	}
+	if items != nil {
+		out.Output = items
+	}
+	out.Usage = fromResponsesUsage(usage)
+}
+
+// EncodeResponse renders resp as the response object id.
+func (r *ResponsesRequest) EncodeResponse(id string, resp *llm.ChatResponse) ([]byte, error) {
+	var items []outputItem
+	if resp.Content != "" {
+		items = append(items, messageItem(newID("msg"), resp.Content, "completed"))
+	}
+	for _, tc := range resp.ToolCalls {
+		items = append(items, functionCallItem(newID("fc"), tc.ID, tc.Function.Name, tc.Function.Arguments, "completed"))
+	}
+
+	out := r.response(id, time.Now().Unix(), resp.Model, "completed")
+	finish(&out, items, resp.FinishReason, resp.Usage)
+	return json.Marshal(out)
+}
+
+func messageItem(id, text, status string) outputItem {
+	content := []outputContent{}
+	if status == "completed" {
+		content = append(content, outputContent{Type: "output_text", Text: text, Annotations: []interface{}{}})
+	}
+	return outputItem{Type: "message", ID: id, Status: status, Role: "assistant", Content: &content}
+}
+
+func functionCallItem(id, callID, name, arguments, status string) outputItem {
+	return outputItem{Type: "function_call", ID: id, Status: status, CallID: callID, Name: name, Arguments: &arguments}
+}
+
+func fromResponsesUsage(u *llm.Usage) *responsesUsage {
+	if u == nil {
+		return nil
+	}
+	out := &responsesUsage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	}
+	out.InputTokensDetails.CachedTokens = u.CachedTokens
+	out.OutputTokensDetails.ReasoningTokens = u.ReasoningTokens
+	return out
+}
+
+// ParseResponsesUsage returns the usage in a response object, or in the
+// response of a response.completed event. It returns nil if there is none.
+func ParseResponsesUsage(data []byte) *llm.Usage {
+	var v struct {
+		Usage    *responsesUsage `json:"usage"`
+		Response *struct {
+			Usage *responsesUsage `json:"usage"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	if v.Response != nil {
+		return v.Response.Usage.toUsage()
+	}
+	return v.Usage.toUsage()
+}
+
+// ResponsesStreamError returns the error event that ends a forwarded stream
+// whose upstream failed part way. seq is the sequence number it takes: the
+// number of events already relayed.
+func ResponsesStreamError(err error, seq int) []byte {
+	e := &ResponsesStreamEncoder{seq: seq}
+	return e.Error(err)
+}
+
+// ResponsesStreamEncoder renders stream chunks as Responses API events. Each
+// run of text becomes a message item and each tool call a function_call
+// item; only one item is open at a time.
+type ResponsesStreamEncoder struct {
+	req       *ResponsesRequest
+	id        string
+	createdAt int64
+	model     string
+	started   bool
+	seq       int
+
+	items    []outputItem
+	open     int         // Index of the open item, or -1
+	toolItem map[int]int // Tool call index -> item index
+	text     strings.Builder
+
+	// Text, finish reason and usage so far, for the chat response Finish
+	// returns.
+	resp llm.ChatResponse
+	buf  bytes.Buffer
+}
+
+// NewStreamEncoder returns an encoder for the response id.
+func (r *ResponsesRequest) NewStreamEncoder(id string) *ResponsesStreamEncoder {
+	return &ResponsesStreamEncoder{
+		req:       r,
+		id:        id,
+		createdAt: time.Now().Unix(),
+		model:     r.Model,
+		open:      -1,
+		toolItem:  make(map[int]int),
+	}
+}
+
+// Chunk returns the events for chunk, which may be none.
+func (e *ResponsesStreamEncoder) Chunk(chunk *llm.StreamChunk) []byte {
+	e.buf.Reset()
+	if chunk.Model != "" && !e.started {
+		e.model = chunk.Model
+	}
+	e.start()
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			delta := *choice.Delta.Content
+			if e.open < 0 || e.items[e.open].Type != "message" {
+				e.openItem(messageItem(newID("msg"), "", "in_progress"))
+				e.event("response.content_part.added", map[string]interface{}{
+					"item_id":       e.items[e.open].ID,
+					"output_index":  e.open,
+					"content_index": 0,
+					"part":          outputContent{Type: "output_text", Annotations: []interface{}{}},
+				})
+			}
+			e.text.WriteString(delta)
+			e.resp.Content += delta
+			e.event("response.output_text.delta", map[string]interface{}{
+				"item_id":       e.items[e.open].ID,
+				"output_index":  e.open,
+				"content_index": 0,
+				"delta":         delta,
+			})
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			index, ok := e.toolItem[tc.Index]
+			if !ok {
+				e.openItem(functionCallItem(newID("fc"), tc.ID, tc.Function.Name, "", "in_progress"))
+				index = e.open
+				e.toolItem[tc.Index] = index
+			}
+			if tc.Function.Arguments == "" {
+				continue
+			}
+			item := &e.items[index]
+			*item.Arguments += tc.Function.Arguments
+			e.event("response.function_call_arguments.delta", map[string]interface{}{
+				"item_id":      item.ID,
+				"output_index": index,
+				"delta":        tc.Function.Arguments,
+			})
+		}
+		if choice.FinishReason != nil {
+			e.resp.FinishReason = *choice.FinishReason
+		}
+	}
+
+	if chunk.Usage != nil {
+		e.resp.Usage = chunk.Usage
+	}
+	return e.buf.Bytes()
+}
+
+// Finish returns the events that end the stream, up to response.completed,
+// and the whole response as a chat response.
+func (e *ResponsesStreamEncoder) Finish() ([]byte, *llm.ChatResponse) {
+	e.buf.Reset()
+	e.start()
+	e.closeItem()
+
+	out := e.req.response(e.id, e.createdAt, e.model, "completed")
+	finish(&out, e.items, e.resp.FinishReason, e.resp.Usage)
+	e.event("response.completed", map[string]interface{}{"response": out})
+
+	resp := e.resp
+	resp.ID, resp.Model = e.id, e.model
+	for _, item := range e.items {
+		if item.Type == "function_call" {
+			resp.ToolCalls = append(resp.ToolCalls, llm.ToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: llm.FunctionCall{Name: item.Name, Arguments: *item.Arguments},
+			})
+		}
+	}
+	return e.buf.Bytes(), &resp
+}
+
+// Error returns an error event, for failures after the stream has started.
+func (e *ResponsesStreamEncoder) Error(err error) []byte {
+	e.buf.Reset()
+	pe := llm.NewInternalError("Internal server error")
+	errors.As(err, &pe)
+
+	payload := map[string]interface{}{"message": pe.Message, "code": nil, "param": nil}
+	if pe.Code != "" {
+		payload["code"] = pe.Code
+	}
+	if pe.Param != "" {
+		payload["param"] = pe.Param
+	}
+	e.event("error", payload)
+	return e.buf.Bytes()
+}
+
+func (e *ResponsesStreamEncoder) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	out := e.req.response(e.id, e.createdAt, e.model, "in_progress")
+	e.event("response.created", map[string]interface{}{"response": out})
+	e.event("response.in_progress", map[string]interface{}{"response": out})
+}
+
+func (e *ResponsesStreamEncoder) openItem(item outputItem) {
+	e.closeItem()
+	e.items = append(e.items, item)
+	e.open = len(e.items) - 1
+	e.event("response.output_item.added", map[string]interface{}{
+		"output_index": e.open,
+		"item":         item,
+	})
+}
+
+func (e *ResponsesStreamEncoder) closeItem() {
+	if e.open < 0 {
+		return
+	}
+	index := e.open
+	item := &e.items[index]
+	e.open = -1
+
+	switch item.Type {
+	case "message":
+		text := e.text.String()
+		e.text.Reset()
+		part := outputContent{Type: "output_text", Text: text, Annotations: []interface{}{}}
+		e.event("response.output_text.done", map[string]interface{}{
+			"item_id":       item.ID,
+			"output_index":  index,
+			"content_index": 0,
+			"text":          text,
+		})
+		e.event("response.content_part.done", map[string]interface{}{
+			"item_id":       item.ID,
+			"output_index":  index,
+			"content_index": 0,
+			"part":          part,
+		})
+		*item = messageItem(item.ID, text, "completed")
+	case "function_call":
+		e.event("response.function_call_arguments.done", map[string]interface{}{
+			"item_id":      item.ID,
+			"output_index": index,
+			"arguments":    *item.Arguments,
+		})
+		item.Status = "completed"
+	}
+
+	e.event("response.output_item.done", map[string]interface{}{
+		"output_index": index,
+		"item":         *item,
+	})
+}
+
+func (e *ResponsesStreamEncoder) event(name string, payload map[string]interface{}) {
+	payload["type"] = name
+	payload["sequence_number"] = e.seq
+	e.seq++
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(&e.buf, "event: %s\ndata: %s\n\n", name, data)
+}
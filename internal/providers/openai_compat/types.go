@@ -32,6 +32,15 @@ type chatRequest struct {
 	Verbosity         *llm.Verbosity  `json:"verbosity,omitempty"`
 }
 
+// completionRequest is a chat completions request as clients send it. Stop
+// may be a string or an array, and newer clients send max_completion_tokens
+// in place of max_tokens.
+type completionRequest struct {
+	chatRequest
+	Stop                json.RawMessage `json:"stop,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+}
+
 type responseFormat struct {
 	Type       string      `json:"type"`
 	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
@@ -69,6 +78,8 @@ type chatResponse struct {
 	Choices           []choice `json:"choices"`
 	Usage             *usage   `json:"usage,omitempty"`
 	ServiceTier       *string  `json:"service_tier,omitempty"`
+	// PromptFilterResults is Azure's content filtering verdict on the prompt.
+	PromptFilterResults json.RawMessage `json:"prompt_filter_results,omitempty"`
 }
 
 type choice struct {
@@ -76,11 +87,13 @@ type choice struct {
 	Message      message   `json:"message"`
 	FinishReason string    `json:"finish_reason"`
 	Logprobs     *logprobs `json:"logprobs,omitempty"`
+	// ContentFilterResults is Azure's content filtering verdict on the choice.
+	ContentFilterResults json.RawMessage `json:"content_filter_results,omitempty"`
 }
 
 type message struct {
 	Role        string        `json:"role"`
-	Content     string        `json:"content"`
+	Content     *string       `json:"content"` // Null when the model only calls tools
 	Refusal     *string       `json:"refusal,omitempty"`
 	Annotations []interface{} `json:"annotations,omitempty"`
 	ToolCalls   []toolCall    `json:"tool_calls,omitempty"`
@@ -122,6 +135,24 @@ type usage struct {
 	CompletionTokensDetails *tokensDetails `json:"completion_tokens_details,omitempty"`
 }
 
+func (u *usage) toUsage() *llm.Usage {
+	if u == nil {
+		return nil
+	}
+	out := &llm.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if d := u.PromptTokensDetails; d != nil && d.CachedTokens != nil {
+		out.CachedTokens = *d.CachedTokens
+	}
+	if d := u.CompletionTokensDetails; d != nil && d.ReasoningTokens != nil {
+		out.ReasoningTokens = *d.ReasoningTokens
+	}
+	return out
+}
+
 type tokensDetails struct {
 	CachedTokens             *int `json:"cached_tokens,omitempty"`
 	AudioTokens              *int `json:"audio_tokens,omitempty"`
@@ -141,6 +172,15 @@ type errorResponse struct {
 	} `json:"error"`
 }
 
+// apiError is the error object the OpenAI API sends, with param and code
+// null when they do not apply.
+type apiError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
 // --- Streaming types ---
 
 type streamChunk struct {
@@ -149,7 +189,7 @@ type streamChunk struct {
 	Created           int64          `json:"created"`
 	Model             string         `json:"model"`
 	Choices           []streamChoice `json:"choices"`
-	Usage             *streamUsage   `json:"usage,omitempty"`
+	Usage             *usage         `json:"usage,omitempty"`
 	SystemFingerprint *string        `json:"system_fingerprint,omitempty"`
 	ServiceTier       *string        `json:"service_tier,omitempty"`
 }
@@ -162,13 +202,177 @@ type streamChoice struct {
 }
 
 type streamDelta struct {
-	Role      *string       `json:"role,omitempty"`
-	Content   *string       `json:"content,omitempty"`
-	ToolCalls []interface{} `json:"tool_calls,omitempty"`
+	Role      *string         `json:"role,omitempty"`
+	Content   *string         `json:"content,omitempty"`
+	ToolCalls []toolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// toolCallDelta is a streamed piece of a tool call. Only the first piece for
+// an index carries the ID, type and name.
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// --- Embeddings ---
+
+type embeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     *int     `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+	User           *string  `json:"user,omitempty"`
+}
+
+type embeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// --- Responses API ---
+
+type responsesRequest struct {
+	Model              string            `json:"model"`
+	Input              json.RawMessage   `json:"input"` // A string or an array of input items
+	Instructions       *string           `json:"instructions,omitempty"`
+	PreviousResponseID *string           `json:"previous_response_id,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Temperature        *float32          `json:"temperature,omitempty"`
+	TopP               *float32          `json:"top_p,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Tools              []responsesTool   `json:"tools,omitempty"`
+	ToolChoice         json.RawMessage   `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	Text               *responsesText    `json:"text,omitempty"`
+	User               *string           `json:"user,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// responsesTool is a tool definition. Only function tools can be translated;
+// built-in tools such as web_search are served by OpenAI alone.
+type responsesTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+type responsesText struct {
+	Format    *textFormat    `json:"format,omitempty"`
+	Verbosity *llm.Verbosity `json:"verbosity,omitempty"`
+}
+
+type textFormat struct {
+	Type   string          `json:"type"` // "text", "json_object" or "json_schema"
+	Name   string          `json:"name,omitempty"`
+	Schema json.RawMessage `json:"schema,omitempty"`
+	Strict *bool           `json:"strict,omitempty"`
+}
+
+// inputItem is one entry of a Responses API input: a message, a function
+// call made by the model, or the output of one.
+type inputItem struct {
+	Type      string          `json:"type"` // Messages may omit it
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"` // A string or an array of content parts
+	CallID    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"` // A string or an array of content parts
+}
+
+type inputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL string `json:"image_url"`
+	Detail   string `json:"detail"`
+	FileID   string `json:"file_id"`
+	FileData string `json:"file_data"`
+	FileURL  string `json:"file_url"`
+	Filename string `json:"filename"`
+}
+
+type responseObject struct {
+	ID                 string             `json:"id"`
+	Object             string             `json:"object"`
+	CreatedAt          int64              `json:"created_at"`
+	Status             string             `json:"status"`
+	Error              *apiError          `json:"error"`
+	IncompleteDetails  *incompleteDetails `json:"incomplete_details"`
+	Instructions       *string            `json:"instructions"`
+	MaxOutputTokens    *int               `json:"max_output_tokens"`
+	Model              string             `json:"model"`
+	Output             []outputItem       `json:"output"`
+	ParallelToolCalls  bool               `json:"parallel_tool_calls"`
+	PreviousResponseID *string            `json:"previous_response_id"`
+	Store              bool               `json:"store"`
+	Temperature        *float32           `json:"temperature"`
+	Text               responsesText      `json:"text"`
+	ToolChoice         json.RawMessage    `json:"tool_choice"`
+	Tools              []responsesTool    `json:"tools"`
+	TopP               *float32           `json:"top_p"`
+	Usage              *responsesUsage    `json:"usage"`
+	User               *string            `json:"user,omitempty"`
+	Metadata           map[string]string  `json:"metadata"`
+}
+
+type incompleteDetails struct {
+	Reason string `json:"reason"` // "max_output_tokens" or "content_filter"
+}
+
+// outputItem is a message or a function call produced by the model.
+type outputItem struct {
+	Type      string           `json:"type"`
+	ID        string           `json:"id"`
+	Status    string           `json:"status"`
+	Role      string           `json:"role,omitempty"`
+	Content   *[]outputContent `json:"content,omitempty"`
+	CallID    string           `json:"call_id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Arguments *string          `json:"arguments,omitempty"`
+}
+
+type outputContent struct {
+	Type        string        `json:"type"` // "output_text"
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+type responsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokens        int `json:"output_tokens"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+	TotalTokens int `json:"total_tokens"`
 }
 
-type streamUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+func (u *responsesUsage) toUsage() *llm.Usage {
+	if u == nil {
+		return nil
+	}
+	return &llm.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     u.InputTokensDetails.CachedTokens,
+		ReasoningTokens:  u.OutputTokensDetails.ReasoningTokens,
+	}
 }
@@ -1,70 +1,50 @@
 package providers
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
+	"github.com/atozi-ai/gateway/internal/balancer"
+	"github.com/atozi-ai/gateway/internal/cache"
 	"github.com/atozi-ai/gateway/internal/circuitbreaker"
+	"github.com/atozi-ai/gateway/internal/config"
 	"github.com/atozi-ai/gateway/internal/domain/llm"
 	"github.com/atozi-ai/gateway/internal/failover"
+	"github.com/atozi-ai/gateway/internal/metrics"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
-	"github.com/atozi-ai/gateway/internal/providers/ai21"
-	"github.com/atozi-ai/gateway/internal/providers/anthropic"
-	"github.com/atozi-ai/gateway/internal/providers/anyscale"
-	"github.com/atozi-ai/gateway/internal/providers/arcee"
-	"github.com/atozi-ai/gateway/internal/providers/aws_bedrock"
-	"github.com/atozi-ai/gateway/internal/providers/azure"
-	"github.com/atozi-ai/gateway/internal/providers/baseten"
-	"github.com/atozi-ai/gateway/internal/providers/cerebras"
-	"github.com/atozi-ai/gateway/internal/providers/chutes"
-	"github.com/atozi-ai/gateway/internal/providers/cloudflare"
-	"github.com/atozi-ai/gateway/internal/providers/cohere"
-	"github.com/atozi-ai/gateway/internal/providers/deepinfra"
-	"github.com/atozi-ai/gateway/internal/providers/deepseek"
-	"github.com/atozi-ai/gateway/internal/providers/fireworks"
-	"github.com/atozi-ai/gateway/internal/providers/friendli"
-	"github.com/atozi-ai/gateway/internal/providers/gemini"
-	"github.com/atozi-ai/gateway/internal/providers/groq"
-	"github.com/atozi-ai/gateway/internal/providers/hyperbolic"
-	"github.com/atozi-ai/gateway/internal/providers/liquid"
-	"github.com/atozi-ai/gateway/internal/providers/minimax"
-	"github.com/atozi-ai/gateway/internal/providers/mistral"
-	"github.com/atozi-ai/gateway/internal/providers/modelrun"
-	"github.com/atozi-ai/gateway/internal/providers/moonshot"
-	"github.com/atozi-ai/gateway/internal/providers/morph"
-	"github.com/atozi-ai/gateway/internal/providers/nebius"
-	"github.com/atozi-ai/gateway/internal/providers/nextbit"
-	"github.com/atozi-ai/gateway/internal/providers/novita"
-	"github.com/atozi-ai/gateway/internal/providers/nvidia"
-	"github.com/atozi-ai/gateway/internal/providers/ollama"
-	"github.com/atozi-ai/gateway/internal/providers/openai"
-	"github.com/atozi-ai/gateway/internal/providers/ovhcloud"
-	"github.com/atozi-ai/gateway/internal/providers/parasail"
-	"github.com/atozi-ai/gateway/internal/providers/perplexity"
-	"github.com/atozi-ai/gateway/internal/providers/phala"
-	"github.com/atozi-ai/gateway/internal/providers/replicate"
-	"github.com/atozi-ai/gateway/internal/providers/sambanova"
-	"github.com/atozi-ai/gateway/internal/providers/scaleway"
-	"github.com/atozi-ai/gateway/internal/providers/siliconflow"
-	"github.com/atozi-ai/gateway/internal/providers/stepfun"
-	"github.com/atozi-ai/gateway/internal/providers/together"
-	"github.com/atozi-ai/gateway/internal/providers/upstage"
-	"github.com/atozi-ai/gateway/internal/providers/venice"
-	"github.com/atozi-ai/gateway/internal/providers/vertex"
-	"github.com/atozi-ai/gateway/internal/providers/xai"
-	"github.com/atozi-ai/gateway/internal/providers/xiaomi"
-	"github.com/atozi-ai/gateway/internal/providers/zai"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
+	"github.com/atozi-ai/gateway/internal/pricing"
 	"github.com/atozi-ai/gateway/internal/retry"
+	"github.com/sony/gobreaker"
 )
 
+// ProviderManager resolves models to providers. Its configuration can be
+// replaced at runtime with Update; requests that already resolved a provider
+// keep using the one built from the previous configuration.
 type ProviderManager struct {
-	mu                      sync.RWMutex
-	providers               map[string]llm.Provider
-	cbManager               *circuitbreaker.CircuitBreakerManager
-	enableRetryWithFallback bool
+	current atomic.Pointer[providerSet]
+	prices  atomic.Pointer[pricing.Catalog]
+}
+
+// providerSet holds the providers built from a single configuration.
+type providerSet struct {
+	mu        sync.RWMutex
+	providers map[string]llm.Provider
+	cbManager *circuitbreaker.CircuitBreakerManager
+	// inherited holds pools of earlier configurations whose settings are
+	// unchanged in this one, by name. Their state is carried over to the
+	// pools of this set as they are built.
+	inherited map[string]llm.Provider
+	cache     cache.Store        // Nil when caching is disabled
+	semantic  *cache.VectorIndex // Nil when semantic caching is disabled
+	prices    *atomic.Pointer[pricing.Catalog]
+	cfg       *config.Config
 }
 
 var (
@@ -72,32 +52,272 @@ var (
 	managerOnce    sync.Once
 )
 
-func GetProviderManager() *ProviderManager {
-	managerOnce.Do(func() {
-		retryWithFallback := os.Getenv("RETRY_WITH_FALLBACK")
-		enableRetryWithFallback := retryWithFallback == "true" || retryWithFallback == "1"
-
-		defaultManager = &ProviderManager{
-			providers:               make(map[string]llm.Provider),
-			enableRetryWithFallback: enableRetryWithFallback,
-			cbManager: circuitbreaker.NewCircuitBreakerManager(circuitbreaker.CircuitBreakerConfig{
-				FailureThreshold: 5,
-				SuccessThreshold: 3,
-				Timeout:          30 * time.Second,
-			}),
+// NewProviderManager creates a manager for the given configuration. It fails if
+// the configuration refers to providers the gateway does not know.
+func NewProviderManager(cfg *config.Config) (*ProviderManager, error) {
+	m := &ProviderManager{}
+	m.prices.Store(pricing.NewCatalog(nil))
+	if err := m.Update(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update validates cfg and makes it the active configuration. Providers are
+// rebuilt lazily from the new configuration. Circuit breakers and pool
+// health state are kept for upstreams whose settings are unchanged, so a
+// reload does not send traffic back to a failing one.
+func (m *ProviderManager) Update(cfg *config.Config) error {
+	if err := ValidateConfig(cfg); err != nil {
+		return err
+	}
+
+	m.current.Store(&providerSet{
+		providers: make(map[string]llm.Provider),
+		cfg:       cfg,
+		cbManager: m.breakers(cfg),
+		inherited: m.unchangedPools(cfg),
+		cache:     m.cacheStore(cfg.Cache),
+		semantic:  m.semanticIndex(cfg.Cache),
+		prices:    &m.prices,
+	})
+	return nil
+}
+
+// breakers returns the circuit breaker manager for cfg. It adopts the
+// current breakers of upstreams whose settings are unchanged, unless the
+// breaker settings themselves changed.
+func (m *ProviderManager) breakers(cfg *config.Config) *circuitbreaker.CircuitBreakerManager {
+	cbManager := circuitbreaker.NewCircuitBreakerManager(circuitbreaker.CircuitBreakerConfig{
+		FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+		SuccessThreshold: cfg.CircuitBreaker.SuccessThreshold,
+		Timeout:          cfg.CircuitBreaker.Timeout,
+	})
+
+	prev := m.current.Load()
+	if prev == nil || prev.cfg.CircuitBreaker != cfg.CircuitBreaker {
+		return cbManager
+	}
+	before := upstreams(prev.cfg)
+	for name, settings := range upstreams(cfg) {
+		if old, ok := before[name]; !ok || !reflect.DeepEqual(old, settings) {
+			continue
+		}
+		if cb := prev.cbManager.GetBreaker(name); cb != nil {
+			cbManager.Adopt(name, cb)
 		}
+	}
+	return cbManager
+}
+
+// unchangedPools returns the current pools, built or inherited, whose
+// settings are the same in cfg.
+func (m *ProviderManager) unchangedPools(cfg *config.Config) map[string]llm.Provider {
+	prev := m.current.Load()
+	if prev == nil {
+		return nil
+	}
+
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+
+	pools := make(map[string]llm.Provider)
+	next := &providerSet{cfg: cfg}
+	for name := range poolNames(cfg) {
+		before, _ := prev.pool(name)
+		after, _ := next.pool(name)
+		if !reflect.DeepEqual(before, after) || !reflect.DeepEqual(prev.deploymentUpstreams(name), next.deploymentUpstreams(name)) {
+			continue
+		}
+		if p, ok := prev.providers["pool:"+name]; ok {
+			pools[name] = p
+		} else if p, ok := prev.inherited[name]; ok {
+			pools[name] = p
+		}
+	}
+	return pools
+}
+
+// upstream is what a circuit breaker guards: a provider's settings and, for
+// a pool deployment, the model requested from it.
+type upstream struct {
+	model    string
+	settings config.ProviderConfig
+}
+
+// upstreams returns every upstream of cfg by the name of its breaker.
+func upstreams(cfg *config.Config) map[string]upstream {
+	set := &providerSet{cfg: cfg}
+	all := make(map[string]upstream)
+	for name := range factories {
+		all[name] = upstream{settings: cfg.Providers[name]}
+	}
+	for name := range poolNames(cfg) {
+		for breaker, u := range set.deploymentUpstreams(name) {
+			all[breaker] = u
+		}
+	}
+	return all
+}
+
+// poolNames returns the names of the configured pools and canonical models.
+func poolNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool, len(cfg.Pools)+len(canonicalModels))
+	for name := range canonicalModels {
+		names[name] = true
+	}
+	for name := range cfg.Pools {
+		names[name] = true
+	}
+	return names
+}
+
+// deploymentUpstreams returns the upstreams of the named pool's deployments
+// by the names of their breakers.
+func (s *providerSet) deploymentUpstreams(name string) map[string]upstream {
+	pool, _ := s.pool(name)
+	all := make(map[string]upstream, len(pool.Deployments))
+	for _, d := range pool.Deployments {
+		providerName, _, _ := strings.Cut(d.Model, "/")
+		all[deploymentBreaker(name, d)] = upstream{
+			model:    d.Model,
+			settings: s.cfg.Providers[providerName].Overlay(d.ProviderConfig),
+		}
+	}
+	return all
+}
 
-		logger.Log.Info().
-			Bool("enable_retry_with_fallback", enableRetryWithFallback).
-			Msg("Provider manager initialized")
+// deploymentBreaker is the name a pool deployment's breaker is registered
+// under: pool/deployment.
+func deploymentBreaker(pool string, d config.DeploymentConfig) string {
+	label := d.Name
+	if label == "" {
+		label = d.Model
+	}
+	return pool + "/" + label
+}
+
+// SetPrices makes the manager route lowest-cost pools by the prices in c,
+// which includes those set in the config, instead of the built-in ones.
+func (m *ProviderManager) SetPrices(c *pricing.Catalog) {
+	m.prices.Store(c)
+}
+
+// cacheStore returns the response cache for cfg. The current store is kept
+// when the cache settings are unchanged, so reloads do not empty it.
+func (m *ProviderManager) cacheStore(cfg config.CacheConfig) cache.Store {
+	if prev := m.current.Load(); prev != nil {
+		prevCfg := prev.cfg.Cache
+		prevCfg.Semantic = cfg.Semantic
+		if prevCfg == cfg {
+			return prev.cache
+		}
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Backend == "disk" {
+		store, err := cache.NewDiskStore(cfg.Dir, cfg.TTL, cfg.MaxEntries)
+		if err != nil {
+			logger.Log.Error().Err(err).Msg("Failed to open response cache; caching is disabled")
+			return nil
+		}
+		return store
+	}
+	return cache.NewMemoryStore(cfg.TTL, cfg.MaxEntries)
+}
+
+// semanticIndex returns the semantic cache index for cfg, keeping the current
+// one when its settings are unchanged.
+func (m *ProviderManager) semanticIndex(cfg config.CacheConfig) *cache.VectorIndex {
+	if prev := m.current.Load(); prev != nil && prev.cfg.Cache.Semantic == cfg.Semantic && prev.cfg.Cache.TTL == cfg.TTL {
+		return prev.semantic
+	}
+	if !cfg.Semantic.Enabled {
+		return nil
+	}
+	return cache.NewVectorIndex(cfg.TTL, cfg.Semantic.MaxEntries)
+}
+
+// Init sets up the default manager from cfg. Use Update on the manager
+// returned by GetProviderManager to change the configuration later.
+func Init(cfg *config.Config) error {
+	if err := GetProviderManager().Update(cfg); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Int("configured_providers", len(cfg.Providers)).
+		Int("aliases", len(cfg.Aliases)).
+		Int("pools", len(cfg.Pools)).
+		Bool("enable_retry_with_fallback", cfg.Retry.WithFallback).
+		Msg("Provider manager initialized")
+	return nil
+}
+
+func GetProviderManager() *ProviderManager {
+	managerOnce.Do(func() {
+		defaultManager, _ = NewProviderManager(config.Default())
 	})
 	return defaultManager
 }
 
+// ValidateConfig checks that every provider named in cfg is known.
+func ValidateConfig(cfg *config.Config) error {
+	var problems []string
+
+	for name := range cfg.Providers {
+		if _, ok := factories[name]; !ok {
+			problems = append(problems, fmt.Sprintf("providers.%s: unknown provider", name))
+		}
+	}
+
+	for alias, target := range cfg.Aliases {
+		for _, spec := range failover.ParseModelWithFallbacks(target) {
+			if _, ok := cfg.Pools[spec]; ok {
+				continue
+			}
+			if _, ok := canonicalModels[spec]; ok {
+				continue
+			}
+			providerName, _, _ := strings.Cut(spec, "/")
+			if _, ok := factories[providerName]; !ok {
+				problems = append(problems, fmt.Sprintf("aliases.%s: unknown provider %q", alias, providerName))
+			}
+		}
+	}
+
+	for pool, pc := range cfg.Pools {
+		for i, d := range pc.Deployments {
+			providerName, _, _ := strings.Cut(d.Model, "/")
+			if _, ok := factories[providerName]; !ok {
+				problems = append(problems, fmt.Sprintf("pools.%s.deployments[%d]: unknown provider %q", pool, i, providerName))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
 func (m *ProviderManager) Get(qualifiedModel string, apiKey string, endpoint string) (llm.Provider, string, error) {
+	set := m.current.Load()
+
+	if target, ok := set.cfg.Aliases[qualifiedModel]; ok {
+		qualifiedModel = target
+	}
+
 	models := failover.ParseModelWithFallbacks(qualifiedModel)
 
 	if len(models) == 1 {
+		if _, ok := set.pool(models[0]); ok {
+			return set.getPool(models[0])
+		}
+
 		providerName, model, ok := strings.Cut(models[0], "/")
 		if !ok {
 			return nil, "", &llm.ProviderError{
@@ -108,7 +328,7 @@ func (m *ProviderManager) Get(qualifiedModel string, apiKey string, endpoint str
 			}
 		}
 
-		provider, err := m.getProvider(providerName, endpoint, true)
+		provider, err := set.getProvider(providerName, endpoint, true)
 		if err != nil {
 			return nil, "", err
 		}
@@ -118,9 +338,25 @@ func (m *ProviderManager) Get(qualifiedModel string, apiKey string, endpoint str
 
 	var providersWithConfig []failover.ProviderWithConfig
 	var finalModel string
-	enableRetries := m.enableRetryWithFallback
+	enableRetries := set.cfg.Retry.WithFallback
 
 	for i, modelSpec := range models {
+		if _, ok := set.pool(modelSpec); ok {
+			provider, model, err := set.getPool(modelSpec)
+			if err != nil {
+				logger.Log.Warn().
+					Str("model_spec", modelSpec).
+					Err(err).
+					Msg("Failed to create pool for fallback")
+				continue
+			}
+			if i == 0 {
+				finalModel = model
+			}
+			providersWithConfig = append(providersWithConfig, failover.ProviderWithConfig{Provider: provider})
+			continue
+		}
+
 		providerName, model, ok := strings.Cut(modelSpec, "/")
 		if !ok {
 			return nil, "", &llm.ProviderError{
@@ -135,7 +371,7 @@ func (m *ProviderManager) Get(qualifiedModel string, apiKey string, endpoint str
 			finalModel = model
 		}
 
-		provider, err := m.getProvider(providerName, endpoint, enableRetries)
+		provider, err := set.getProvider(providerName, endpoint, enableRetries)
 		if err != nil {
 			logger.Log.Warn().
 				Str("model_spec", modelSpec).
@@ -159,35 +395,141 @@ func (m *ProviderManager) Get(qualifiedModel string, apiKey string, endpoint str
 		}
 	}
 
-	failoverProvider := failover.NewFailoverProvider(providersWithConfig)
+	failoverProvider := failover.NewFailoverProvider(providersWithConfig, failover.FallbackConfig{
+		EnableRetries:      enableRetries,
+		StreamBufferTokens: set.cfg.Retry.StreamBufferTokens,
+	})
 	return failoverProvider, finalModel, nil
 }
 
-func (m *ProviderManager) getProvider(name string, endpoint string, enableRetry bool) (llm.Provider, error) {
+func (s *providerSet) getProvider(name string, endpoint string, enableRetry bool) (llm.Provider, error) {
 	cacheKey := fmt.Sprintf("%s:%s:%v", name, endpoint, enableRetry)
 
-	m.mu.RLock()
-	if provider, exists := m.providers[cacheKey]; exists {
-		m.mu.RUnlock()
+	s.mu.RLock()
+	if provider, exists := s.providers[cacheKey]; exists {
+		s.mu.RUnlock()
 		return provider, nil
 	}
-	m.mu.RUnlock()
+	s.mu.RUnlock()
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if provider, exists := m.providers[cacheKey]; exists {
+	if provider, exists := s.providers[cacheKey]; exists {
 		return provider, nil
 	}
 
-	var baseProvider llm.Provider
-	switch name {
-	case "openai":
-		baseProvider = openai.New()
-	case "ai21":
-		baseProvider = ai21.New()
-	case "azure":
-		if endpoint == "" {
+	pc := s.cfg.Providers[name]
+	breaker := name
+	if name == "azure" && endpoint != "" && endpoint != pc.BaseURL {
+		// The server's credentials are not sent to an endpoint the caller
+		// chose; the caller has to bring an Azure key of its own. Its
+		// failures are kept off the configured endpoint's breaker.
+		pc.BaseURL = endpoint
+		pc.APIKey, pc.TenantID, pc.ClientID, pc.ClientSecret = "", "", "", ""
+		breaker = name + "@" + endpoint
+	}
+
+	provider, err := s.build(name, pc, breaker, false, enableRetry)
+	if err != nil {
+		return nil, err
+	}
+	s.providers[cacheKey] = provider
+	return provider, nil
+}
+
+// pool returns the configured pool or canonical model called name.
+func (s *providerSet) pool(name string) (config.PoolConfig, bool) {
+	if pool, ok := s.cfg.Pools[name]; ok {
+		return pool, true
+	}
+	pool, ok := canonicalModels[name]
+	return pool, ok
+}
+
+// getPool returns the balancing provider of the named pool and the model of
+// its first deployment. Deployments are not retried on their own; the pool
+// moves a failed request on to the next deployment instead.
+func (s *providerSet) getPool(name string) (llm.Provider, string, error) {
+	pool, _ := s.pool(name)
+	_, model, _ := strings.Cut(pool.Deployments[0].Model, "/")
+	cacheKey := "pool:" + name
+
+	s.mu.RLock()
+	if provider, exists := s.providers[cacheKey]; exists {
+		s.mu.RUnlock()
+		return provider, model, nil
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if provider, exists := s.providers[cacheKey]; exists {
+		return provider, model, nil
+	}
+
+	// A pass-through key is taken to be for the provider of a configured
+	// pool's first deployment, as the pool's model is. The hosts of a
+	// canonical model are peers, so none of them is sent one.
+	var keyProvider string
+	if _, ok := s.cfg.Pools[name]; ok {
+		keyProvider, _, _ = strings.Cut(pool.Deployments[0].Model, "/")
+	}
+
+	deployments := make([]balancer.Deployment, len(pool.Deployments))
+	for i, d := range pool.Deployments {
+		label := d.Name
+		if label == "" {
+			label = d.Model
+		}
+		// Each deployment gets a breaker of its own.
+		breaker := deploymentBreaker(name, d)
+
+		providerName, deploymentModel, _ := strings.Cut(d.Model, "/")
+		pc := s.cfg.Providers[providerName].Overlay(d.ProviderConfig)
+		provider, err := s.build(providerName, pc, breaker, d.HasCredentials(), false)
+		if err != nil {
+			return nil, "", err
+		}
+		deployments[i] = balancer.Deployment{
+			Name:          label,
+			Provider:      provider,
+			Model:         deploymentModel,
+			Weight:        d.Weight,
+			Breaker:       s.cbManager.GetBreaker(breaker),
+			ContextLength: d.ContextLength,
+			Tools:         d.Tools == nil || *d.Tools,
+			JSONMode:      d.JSONMode == nil || *d.JSONMode,
+			Price: func() (pricing.Price, bool) {
+				return s.prices.Load().Lookup(providerName, deploymentModel)
+			},
+			Serves: deploymentServes(providerName, pc, d.HasCredentials(), keyProvider),
+		}
+	}
+
+	provider := balancer.NewPoolProvider(name, deployments, balancer.Config{
+		Strategy:           pool.Strategy,
+		FailureThreshold:   pool.FailureThreshold,
+		EjectionTime:       pool.EjectionTime,
+		MaxEjectionTime:    pool.MaxEjectionTime,
+		SlowStart:          pool.SlowStart,
+		StreamBufferTokens: s.cfg.Retry.StreamBufferTokens,
+	})
+	if prev, ok := s.inherited[name]; ok {
+		balancer.Inherit(provider, prev)
+	}
+	s.providers[cacheKey] = provider
+	return provider, model, nil
+}
+
+// build creates the named provider from pc and wraps it in the gateway's
+// decorators, with its circuit breaker registered as breaker. ownCredentials
+// marks a pool deployment with credentials of its own, which are used
+// instead of any the caller brings. The caller must hold s.mu.
+func (s *providerSet) build(name string, pc config.ProviderConfig, breaker string, ownCredentials bool, enableRetry bool) (llm.Provider, error) {
+	if name == "azure" {
+		if pc.BaseURL == "" {
 			return nil, &llm.ProviderError{
 				StatusCode: 400,
 				Message:    "azure provider requires an endpoint to be provided",
@@ -195,98 +537,10 @@ func (m *ProviderManager) getProvider(name string, endpoint string, enableRetry
 				Code:       "missing_endpoint",
 			}
 		}
-		baseProvider = azure.New("", endpoint)
-	case "aws_bedrock":
-		region := os.Getenv("AWS_REGION")
-		if region == "" {
-			region = "us-east-1"
-		}
-		baseProvider = aws_bedrock.New(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), region)
-	case "baseten":
-		baseProvider = baseten.New()
-	case "anyscale":
-		baseProvider = anyscale.New()
-	case "cerebras":
-		baseProvider = cerebras.New()
-	case "cloudflare":
-		baseProvider = cloudflare.New()
-	case "xai":
-		baseProvider = xai.New()
-	case "zai":
-		baseProvider = zai.New()
-	case "anthropic":
-		baseProvider = anthropic.New()
-	case "gemini":
-		baseProvider = gemini.New()
-	case "vertex":
-		baseProvider = vertex.New(os.Getenv("GOOGLE_PROJECT_ID"), os.Getenv("GOOGLE_LOCATION"))
-	case "groq":
-		baseProvider = groq.New()
-	case "hyperbolic":
-		baseProvider = hyperbolic.New()
-	case "minimax":
-		baseProvider = minimax.New()
-	case "deepinfra":
-		baseProvider = deepinfra.New()
-	case "deepseek":
-		baseProvider = deepseek.New()
-	case "mistral":
-		baseProvider = mistral.New()
-	case "moonshot":
-		baseProvider = moonshot.New()
-	case "nebius":
-		baseProvider = nebius.New()
-	case "nvidia":
-		baseProvider = nvidia.New()
-	case "ovhcloud":
-		baseProvider = ovhcloud.New()
-	case "together":
-		baseProvider = together.New()
-	case "upstage":
-		baseProvider = upstage.New()
-	case "fireworks":
-		baseProvider = fireworks.New()
-	case "friendli":
-		baseProvider = friendli.New()
-	case "perplexity":
-		baseProvider = perplexity.New()
-	case "replicate":
-		baseProvider = replicate.New()
-	case "sambanova":
-		baseProvider = sambanova.New()
-	case "scaleway":
-		baseProvider = scaleway.New()
-	case "venice":
-		baseProvider = venice.New()
-	case "cohere":
-		baseProvider = cohere.New()
-	case "novita":
-		baseProvider = novita.New()
-	case "ollama":
-		baseProvider = ollama.New()
-	case "siliconflow":
-		baseProvider = siliconflow.New()
-	case "stepfun":
-		baseProvider = stepfun.New()
-	case "xiaomi":
-		baseProvider = xiaomi.New()
-	case "liquid":
-		baseProvider = liquid.New()
-	case "arcee":
-		baseProvider = arcee.New()
-	case "chutes":
-		baseProvider = chutes.New()
-	case "morph":
-		baseProvider = morph.New()
-	case "nextbit":
-		baseProvider = nextbit.New()
-	case "parasail":
-		baseProvider = parasail.New()
-	case "phala":
-		baseProvider = phala.New()
-	case "modelrun":
-		baseProvider = modelrun.New()
-	default:
+	}
+
+	newProvider, ok := factories[name]
+	if !ok {
 		return nil, &llm.ProviderError{
 			StatusCode: 400,
 			Message:    fmt.Sprintf("unknown provider: %q", name),
@@ -295,26 +549,150 @@ func (m *ProviderManager) getProvider(name string, endpoint string, enableRetry
 		}
 	}
 
-	wrappedProvider := m.cbManager.WrapProvider(baseProvider)
+	baseProvider := tracing.NewTracedProvider(newProvider(pc))
+
+	wrappedProvider := s.cbManager.WrapProviderAs(baseProvider, breaker)
 
-	if enableRetry {
+	if enableRetry && s.cfg.Retry.MaxRetries > 0 {
 		wrappedProvider = retry.NewRetryableProvider(wrappedProvider, retry.Config{
-			MaxRetries:   3,
-			InitialDelay: 500 * time.Millisecond,
-			MaxDelay:     10 * time.Second,
-			Multiplier:   2.0,
+			MaxRetries:         s.cfg.Retry.MaxRetries,
+			InitialDelay:       s.cfg.Retry.InitialDelay,
+			MaxDelay:           s.cfg.Retry.MaxDelay,
+			Multiplier:         s.cfg.Retry.Multiplier,
+			RetryableCodes:     s.cfg.Retry.RetryableCodes,
+			StreamBufferTokens: s.cfg.Retry.StreamBufferTokens,
 		})
 	}
 
-	m.providers[cacheKey] = wrappedProvider
+	wrappedProvider = metrics.NewInstrumentedProvider(wrappedProvider, s.knownModel(name))
+
+	if s.semantic != nil {
+		embedProvider, embedModel, _ := strings.Cut(s.cfg.Cache.Semantic.EmbeddingModel, "/")
+		wrappedProvider = cache.NewSemanticProvider(wrappedProvider, s.semantic, cache.SemanticConfig{
+			Embedder:       &setEmbedder{set: s, name: embedProvider},
+			EmbeddingModel: embedModel,
+			Threshold:      s.cfg.Cache.Semantic.Threshold,
+		})
+	}
+
+	// The exact-match cache goes outside the semantic one so that identical
+	// prompts are served without computing an embedding.
+	if s.cache != nil {
+		wrappedProvider = cache.NewCachingProvider(wrappedProvider, s.cache)
+	}
+
+	// Credentials are bound outside the caches, so callers without any for
+	// the provider are turned away before a lookup or an embedding, and the
+	// caches key entries by the upstream key actually used. Bedrock and
+	// Vertex authenticate with cloud credentials rather than an API key, so
+	// virtual-key credentials do not apply to them, nor to deployments that
+	// bring their own.
+	if ownCredentials {
+		wrappedProvider = withOwnCredentials(wrappedProvider)
+	} else if name != "aws_bedrock" && name != "vertex" {
+		wrappedProvider = withCredentials(name, wrappedProvider, hasDefaultCredentials(name, pc))
+	}
 
 	return wrappedProvider, nil
 }
 
+// knownModel returns a function reporting whether a model of provider name
+// is priced or named by an alias, a pool, a canonical model or the semantic
+// cache, and so may be used as a metrics label.
+func (s *providerSet) knownModel(name string) func(model string) bool {
+	return func(model string) bool {
+		if _, ok := s.prices.Load().Lookup(name, model); ok {
+			return true
+		}
+		spec := name + "/" + model
+		if spec == s.cfg.Cache.Semantic.EmbeddingModel {
+			return true
+		}
+		for _, target := range s.cfg.Aliases {
+			if slices.Contains(failover.ParseModelWithFallbacks(target), spec) {
+				return true
+			}
+		}
+		for _, pools := range []map[string]config.PoolConfig{s.cfg.Pools, canonicalModels} {
+			for _, pool := range pools {
+				for _, d := range pool.Deployments {
+					if d.Model == spec {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+// setEmbedder embeds semantic cache prompts with a provider of the set. The
+// provider is resolved on first use, as getProvider holds the set's lock
+// while building the semantic cache.
+type setEmbedder struct {
+	set  *providerSet
+	name string
+}
+
+func (e *setEmbedder) Name() string {
+	return e.name
+}
+
+func (e *setEmbedder) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	provider, err := e.set.getProvider(e.name, "", true)
+	if err != nil {
+		return nil, err
+	}
+	return llm.Embed(ctx, provider, req)
+}
+
+// IsAlias reports whether name is a configured model alias, a pool or a
+// canonical model.
+func (m *ProviderManager) IsAlias(name string) bool {
+	set := m.current.Load()
+	if _, ok := set.cfg.Aliases[name]; ok {
+		return true
+	}
+	_, ok := set.pool(name)
+	return ok
+}
+
+// Targets returns the provider/model specs a request for qualifiedModel may
+// be served by: every model of a fallback chain, with pools and canonical
+// models replaced by their deployments.
+func (m *ProviderManager) Targets(qualifiedModel string) []string {
+	set := m.current.Load()
+	if target, ok := set.cfg.Aliases[qualifiedModel]; ok {
+		qualifiedModel = target
+	}
+
+	var targets []string
+	for _, spec := range failover.ParseModelWithFallbacks(qualifiedModel) {
+		pool, ok := set.pool(spec)
+		if !ok {
+			targets = append(targets, spec)
+			continue
+		}
+		for _, d := range pool.Deployments {
+			targets = append(targets, d.Model)
+		}
+	}
+	return targets
+}
+
 func (m *ProviderManager) GetCircuitBreakerState(name string) string {
-	return m.cbManager.GetState(name)
+	return m.current.Load().cbManager.GetState(name)
+}
+
+// CircuitBreakerStates returns the breaker states of the active configuration.
+func (m *ProviderManager) CircuitBreakerStates() map[string]gobreaker.State {
+	return m.current.Load().cbManager.States()
 }
 
 func Get(qualifiedModel string, apiKey string, endpoint string) (llm.Provider, string, error) {
 	return GetProviderManager().Get(qualifiedModel, apiKey, endpoint)
 }
+
+func Targets(qualifiedModel string) []string {
+	return GetProviderManager().Targets(qualifiedModel)
+}
@@ -0,0 +1,114 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/atozi-ai/gateway/internal/config"
+)
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Providers["openai"] = config.ProviderConfig{APIKey: "sk-openai"}
+	cfg.Providers["groq"] = config.ProviderConfig{APIKey: "sk-groq"}
+	cfg.Pools["p"] = config.PoolConfig{Deployments: []config.DeploymentConfig{
+		{Name: "a", Model: "openai/gpt-4o"},
+		{Name: "b", Model: "groq/llama-3.3-70b-versatile"},
+	}}
+	return cfg
+}
+
+func TestUpdateKeepsUnchangedBreakers(t *testing.T) {
+	m, err := NewProviderManager(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range []string{"openai/gpt-4o", "groq/llama-3.3-70b-versatile", "p"} {
+		if _, _, err := m.Get(model, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := m.current.Load().cbManager
+
+	cfg := testConfig()
+	cfg.Providers["groq"] = config.ProviderConfig{APIKey: "sk-groq-rotated"}
+	if err := m.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	next := m.current.Load().cbManager
+
+	tests := []struct {
+		breaker string
+		kept    bool
+	}{
+		{"openai", true},
+		{"p/a", true},
+		{"groq", false},
+		{"p/b", false}, // Laid over the groq settings
+	}
+	for _, tt := range tests {
+		before, after := prev.GetBreaker(tt.breaker), next.GetBreaker(tt.breaker)
+		if before == nil {
+			t.Fatalf("breaker %s not registered", tt.breaker)
+		}
+		if kept := before == after; kept != tt.kept {
+			t.Errorf("breaker %s kept = %v, want %v", tt.breaker, kept, tt.kept)
+		}
+	}
+
+	// A change to the breaker settings starts every breaker over.
+	cfg = testConfig()
+	cfg.CircuitBreaker.FailureThreshold++
+	if err := m.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if next.GetBreaker("openai") == m.current.Load().cbManager.GetBreaker("openai") {
+		t.Error("breaker kept after the breaker settings changed")
+	}
+}
+
+func TestUpdateInheritsUnchangedPools(t *testing.T) {
+	m, err := NewProviderManager(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, _, err := m.Get("p", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unrelated change keeps the pool's state, even over reloads before
+	// the pool is next used.
+	cfg := testConfig()
+	cfg.Aliases["fast"] = "groq/llama-3.3-70b-versatile"
+	for i := 0; i < 2; i++ {
+		if err := m.Update(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.current.Load().inherited["p"]; got != pool {
+			t.Fatalf("reload %d: pool state not carried over", i+1)
+		}
+	}
+
+	// A change to a deployment, or to the provider settings it is laid
+	// over, does not.
+	for _, change := range []func(*config.Config){
+		func(cfg *config.Config) { cfg.Pools["p"].Deployments[1].Weight = 2 },
+		func(cfg *config.Config) { cfg.Providers["openai"] = config.ProviderConfig{APIKey: "sk-rotated"} },
+	} {
+		cfg := testConfig()
+		change(cfg)
+		if err := m.Update(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m.current.Load().inherited["p"]; ok {
+			t.Error("pool state carried over a change to its deployments")
+		}
+		// Start from a set whose pool is built again.
+		if err := m.Update(testConfig()); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := m.Get("p", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+const (
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	defaultTokenURI    = "https://oauth2.googleapis.com/token"
+
+	// refreshWindow is how long before expiry a token is renewed.
+	refreshWindow = 5 * time.Minute
+)
+
+// credentialsFile is a Google credentials JSON file: a service account key,
+// or the authorized_user file written by
+// "gcloud auth application-default login".
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// tokenSource mints OAuth access tokens from a credentials file and caches
+// them until shortly before they expire. Without a file it falls back to a
+// fixed token from GOOGLE_ACCESS_TOKEN or GOOGLE_OAUTH_TOKEN.
+//
+// Concurrent callers share one token exchange, and none of them holds the
+// lock while it runs.
+type tokenSource struct {
+	path       string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	refresh *tokenRefresh // In flight, or nil
+}
+
+// tokenRefresh is one token exchange. done is closed once the rest is set.
+type tokenRefresh struct {
+	done    chan struct{}
+	token   string
+	expires time.Time
+	err     error
+}
+
+func newTokenSource(path string, httpClient *http.Client) *tokenSource {
+	return &tokenSource{path: path, httpClient: httpClient}
+}
+
+func authError(message string) *llm.ProviderError {
+	return &llm.ProviderError{
+		StatusCode: 401,
+		Message:    message,
+		Type:       "authentication_error",
+		Code:       "gcp_credentials_failed",
+	}
+}
+
+// Token returns a valid access token.
+func (t *tokenSource) Token(ctx context.Context) (string, error) {
+	if t.path == "" {
+		token := os.Getenv("GOOGLE_ACCESS_TOKEN")
+		if token == "" {
+			token = os.Getenv("GOOGLE_OAUTH_TOKEN")
+		}
+		if token == "" {
+			return "", &llm.ProviderError{
+				StatusCode: 400,
+				Message:    "GCP credentials required. Set GOOGLE_APPLICATION_CREDENTIALS to a service account key file",
+				Type:       "invalid_request_error",
+				Code:       "missing_gcp_credentials",
+			}
+		}
+		return token, nil
+	}
+
+	t.mu.Lock()
+	if t.token != "" && time.Until(t.expires) > refreshWindow {
+		token := t.token
+		t.mu.Unlock()
+		return token, nil
+	}
+	r := t.refresh
+	if r == nil {
+		r = &tokenRefresh{done: make(chan struct{})}
+		t.refresh = r
+		// The exchange is shared, so it must not end with the caller that
+		// started it; the HTTP client's timeout bounds it instead.
+		go t.run(context.WithoutCancel(ctx), r)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// run performs the exchange r and caches its token.
+func (t *tokenSource) run(ctx context.Context, r *tokenRefresh) {
+	r.token, r.expires, r.err = t.fetch(ctx)
+
+	t.mu.Lock()
+	if r.err == nil {
+		t.token, t.expires = r.token, r.expires
+	}
+	t.refresh = nil
+	t.mu.Unlock()
+	close(r.done)
+}
+
+// fetch exchanges the credentials file for a new access token.
+func (t *tokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	creds, err := readCredentialsFile(t.path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var form url.Values
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultTokenURI
+	}
+	switch creds.Type {
+	case "service_account":
+		assertion, err := signJWT(creds, tokenURI, time.Now())
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		}
+	default:
+		return "", time.Time{}, authError(fmt.Sprintf("unsupported Google credentials type %q in %s", creds.Type, t.path))
+	}
+
+	token, expiresIn, err := t.exchange(ctx, tokenURI, form)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(time.Duration(expiresIn) * time.Second), nil
+}
+
+func (t *tokenSource) exchange(ctx context.Context, tokenURI string, form url.Values) (string, int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, llm.NewInternalError(fmt.Sprintf("failed to create token request: %v", err))
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return "", 0, llm.NewProviderError(503, fmt.Sprintf("token request failed: %v", err), "service_unavailable", "request_failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, llm.NewInternalError(fmt.Sprintf("failed to read token response: %v", err))
+	}
+
+	var out tokenResponse
+	if err := json.Unmarshal(body, &out); err != nil && resp.StatusCode == http.StatusOK {
+		return "", 0, llm.NewInternalError(fmt.Sprintf("failed to decode token response: %v", err))
+	}
+	if resp.StatusCode != http.StatusOK || out.AccessToken == "" {
+		message := out.ErrorDescription
+		if message == "" {
+			message = out.Error
+		}
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		return "", 0, authError(fmt.Sprintf("token exchange failed (%d): %s", resp.StatusCode, message))
+	}
+	return out.AccessToken, out.ExpiresIn, nil
+}
+
+func readCredentialsFile(path string) (*credentialsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, authError(fmt.Sprintf("failed to read Google credentials: %v", err))
+	}
+	var creds credentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, authError(fmt.Sprintf("invalid Google credentials file %s: %v", path, err))
+	}
+	return &creds, nil
+}
+
+// signJWT returns the signed assertion a service account exchanges for an
+// access token.
+func signJWT(creds *credentialsFile, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", authError("service account private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", authError(fmt.Sprintf("invalid service account private_key: %v", err))
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", authError("service account private_key is not an RSA key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	if err != nil {
+		return "", llm.NewInternalError(fmt.Sprintf("failed to marshal JWT header: %v", err))
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", llm.NewInternalError(fmt.Sprintf("failed to marshal JWT claims: %v", err))
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", llm.NewInternalError(fmt.Sprintf("failed to sign JWT: %v", err))
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
@@ -0,0 +1,385 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atozi-ai/gateway/internal/domain/llm"
+)
+
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func pkcs8PEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// verifyJWT checks the signature of token with testKey and returns its
+// header and claims.
+func verifyJWT(t *testing.T, token string) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts, want 3", len(parts))
+	}
+	enc := base64.RawURLEncoding
+	signature, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+		t.Fatalf("JWT signature does not verify: %v", err)
+	}
+	for i, v := range []*map[string]any{&header, &claims} {
+		data, err := enc.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return header, claims
+}
+
+func TestSignJWT(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	creds := &credentialsFile{PrivateKeyID: "kid-1", ClientEmail: "sa@p.iam.gserviceaccount.com", PrivateKey: pkcs8PEM(t, testKey)}
+
+	token, err := signJWT(creds, "https://token.example", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, claims := verifyJWT(t, token)
+	if header["alg"] != "RS256" || header["kid"] != "kid-1" {
+		t.Errorf("header = %v, want RS256 with kid-1", header)
+	}
+	want := map[string]any{
+		"iss":   "sa@p.iam.gserviceaccount.com",
+		"scope": cloudPlatformScope,
+		"aud":   "https://token.example",
+		"iat":   float64(now.Unix()),
+		"exp":   float64(now.Add(time.Hour).Unix()),
+	}
+	for k, v := range want {
+		if claims[k] != v {
+			t.Errorf("claim %s = %v, want %v", k, claims[k], v)
+		}
+	}
+
+	// PKCS#1 keys, as older key files hold, sign too.
+	creds.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)}))
+	if token, err := signJWT(creds, "aud", now); err != nil {
+		t.Errorf("PKCS#1 key: %v", err)
+	} else {
+		verifyJWT(t, token)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]string{
+		"not PEM":    "not a key",
+		"not a key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")})),
+		"not RSA":    pkcs8PEM(t, ecKey),
+		"empty file": "",
+	} {
+		creds.PrivateKey = key
+		var pe *llm.ProviderError
+		if _, err := signJWT(creds, "aud", now); !errors.As(err, &pe) || pe.Code != "gcp_credentials_failed" {
+			t.Errorf("%s: err = %v, want gcp_credentials_failed", name, err)
+		}
+	}
+}
+
+// tokenServer is a token endpoint that answers with handle and counts the
+// requests it gets.
+func tokenServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func grant(token string, expiresIn int) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": token, "expires_in": expiresIn})
+	}
+}
+
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestSource(path string) *tokenSource {
+	return newTokenSource(path, &http.Client{Timeout: 5 * time.Second})
+}
+
+func TestTokenServiceAccount(t *testing.T) {
+	var assertion string
+	srv, calls := tokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.PostForm.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q, want the JWT bearer grant", got)
+		}
+		assertion = r.PostForm.Get("assertion")
+		grant("ya29.sa", 3600)(w, r)
+	})
+	ts := newTestSource(writeCredentials(t, map[string]string{
+		"type":         "service_account",
+		"client_email": "sa@p.iam.gserviceaccount.com",
+		"private_key":  pkcs8PEM(t, testKey),
+		"token_uri":    srv.URL,
+	}))
+
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil || token != "ya29.sa" {
+			t.Fatalf("Token = %q, %v; want ya29.sa", token, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1 while the token is fresh", n)
+	}
+	if _, claims := verifyJWT(t, assertion); claims["aud"] != srv.URL {
+		t.Errorf("aud = %v, want the token_uri %s", claims["aud"], srv.URL)
+	}
+}
+
+func TestTokenAuthorizedUser(t *testing.T) {
+	srv, _ := tokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		want := map[string]string{"grant_type": "refresh_token", "client_id": "cid", "client_secret": "csecret", "refresh_token": "rt"}
+		for k, v := range want {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+		grant("ya29.user", 3600)(w, r)
+	})
+	ts := newTestSource(writeCredentials(t, map[string]string{
+		"type":          "authorized_user",
+		"client_id":     "cid",
+		"client_secret": "csecret",
+		"refresh_token": "rt",
+		"token_uri":     srv.URL,
+	}))
+
+	if token, err := ts.Token(context.Background()); err != nil || token != "ya29.user" {
+		t.Errorf("Token = %q, %v; want ya29.user", token, err)
+	}
+}
+
+func TestTokenRenewsNearExpiry(t *testing.T) {
+	srv, calls := tokenServer(t, grant("ya29.short", int(refreshWindow/time.Second)-1))
+	ts := newTestSource(writeCredentials(t, map[string]string{"type": "authorized_user", "token_uri": srv.URL}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := ts.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("token endpoint called %d times, want a renewal for a token within the refresh window", n)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		creds   map[string]string
+		handle  func(http.ResponseWriter, *http.Request)
+		status  int
+		code    string
+		message string
+	}{
+		{
+			name: "OAuth error",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+			},
+			status: 401, code: "gcp_credentials_failed", message: "token exchange failed (400): Token has been expired or revoked.",
+		},
+		{
+			name: "error without a description",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+			},
+			status: 401, code: "gcp_credentials_failed", message: "invalid_client",
+		},
+		{
+			name: "plain text error",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "upstream exploded", http.StatusBadGateway)
+			},
+			status: 401, code: "gcp_credentials_failed", message: "(502): upstream exploded",
+		},
+		{
+			name: "no access token",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"expires_in":3600}`))
+			},
+			status: 401, code: "gcp_credentials_failed", message: "token exchange failed (200)",
+		},
+		{
+			name:   "malformed response",
+			handle: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) },
+			status: 500, message: "failed to decode token response",
+		},
+		{
+			name:   "unreachable",
+			creds:  map[string]string{"type": "authorized_user", "token_uri": closed.URL},
+			status: 503, code: "request_failed", message: "token request failed",
+		},
+		{
+			name:   "unsupported type",
+			creds:  map[string]string{"type": "external_account"},
+			status: 401, code: "gcp_credentials_failed", message: `unsupported Google credentials type "external_account"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := tt.creds
+			if tt.handle != nil {
+				srv, _ := tokenServer(t, tt.handle)
+				creds = map[string]string{"type": "authorized_user", "token_uri": srv.URL}
+			}
+			_, err := newTestSource(writeCredentials(t, creds)).Token(context.Background())
+			var pe *llm.ProviderError
+			if !errors.As(err, &pe) {
+				t.Fatalf("err = %v, want a provider error", err)
+			}
+			if pe.StatusCode != tt.status || (tt.code != "" && pe.Code != tt.code) || !strings.Contains(pe.Message, tt.message) {
+				t.Errorf("err = %v, want %d %s containing %q", pe, tt.status, tt.code, tt.message)
+			}
+		})
+	}
+
+	// A failed exchange is not cached.
+	srv, calls := tokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+	})
+	ts := newTestSource(writeCredentials(t, map[string]string{"type": "authorized_user", "token_uri": srv.URL}))
+	ts.Token(context.Background())
+	ts.Token(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Errorf("token endpoint called %d times after failures, want 2", n)
+	}
+}
+
+func TestTokenFiles(t *testing.T) {
+	var pe *llm.ProviderError
+	if _, err := newTestSource(filepath.Join(t.TempDir(), "missing.json")).Token(context.Background()); !errors.As(err, &pe) || pe.Code != "gcp_credentials_failed" {
+		t.Errorf("missing file: err = %v, want gcp_credentials_failed", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestSource(path).Token(context.Background()); !errors.As(err, &pe) || pe.Code != "gcp_credentials_failed" {
+		t.Errorf("malformed file: err = %v, want gcp_credentials_failed", err)
+	}
+
+	// Without a file, a fixed token comes from the environment.
+	t.Setenv("GOOGLE_ACCESS_TOKEN", "")
+	t.Setenv("GOOGLE_OAUTH_TOKEN", "")
+	if _, err := newTestSource("").Token(context.Background()); !errors.As(err, &pe) || pe.Code != "missing_gcp_credentials" {
+		t.Errorf("no credentials: err = %v, want missing_gcp_credentials", err)
+	}
+	t.Setenv("GOOGLE_OAUTH_TOKEN", "ya29.env")
+	if token, err := newTestSource("").Token(context.Background()); err != nil || token != "ya29.env" {
+		t.Errorf("Token = %q, %v; want ya29.env", token, err)
+	}
+}
+
+func TestTokenSharesRefresh(t *testing.T) {
+	release := make(chan struct{})
+	srv, calls := tokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		grant("ya29.shared", 3600)(w, r)
+	})
+	ts := newTestSource(writeCredentials(t, map[string]string{"type": "authorized_user", "token_uri": srv.URL}))
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _ = ts.Token(context.Background())
+		}()
+	}
+
+	// While the exchange is stuck, a caller that gives up is not held back
+	// by it, and does not end it for the others.
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := ts.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Token with an expired context = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, token := range tokens {
+		if token != "ya29.shared" {
+			t.Errorf("caller %d got %q, want ya29.shared", i, token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1 for concurrent callers", n)
+	}
+}
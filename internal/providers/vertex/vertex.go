@@ -1,41 +1,113 @@
 package vertex
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
+	openaicompat "github.com/atozi-ai/gateway/internal/providers/openai_compat"
 )
 
+var (
+	// locationPattern matches region names such as us-central1 and global.
+	locationPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+	// projectIDPattern matches GCP project IDs, including legacy
+	// domain-scoped ones such as example.com:my-project.
+	projectIDPattern = regexp.MustCompile(`^([a-z0-9.-]+:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+)
+
+// Provider implements llm.Provider for Vertex AI through its OpenAI-compatible
+// chat completions endpoint.
 type Provider struct {
 	projectID  string
 	location   string
+	baseURL    string
+	tokens     *tokenSource
 	httpClient *http.Client
 }
 
+// Config holds the default project, location and client settings for Vertex AI.
+type Config struct {
+	ProjectID string
+	Location  string
+	// CredentialsFile is a service account key or authorized_user JSON file.
+	// It defaults to GOOGLE_APPLICATION_CREDENTIALS.
+	CredentialsFile string
+	// BaseURL replaces https://LOCATION-aiplatform.googleapis.com, e.g. with
+	// a Private Service Connect endpoint.
+	BaseURL string
+	Timeout time.Duration
+}
+
 func New(projectID, location string) *Provider {
+	return NewWithConfig(Config{
+		ProjectID: projectID,
+		Location:  location,
+	})
+}
+
+func NewWithConfig(cfg Config) *Provider {
+	location := cfg.Location
 	if location == "" {
 		location = "us-central1"
 	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 120 * time.Second
+	}
+	credentials := cfg.CredentialsFile
+	if credentials == "" {
+		credentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	// A service account key names its project, which saves configuring it.
+	projectID := cfg.ProjectID
+	if projectID == "" && credentials != "" {
+		if creds, err := readCredentialsFile(credentials); err == nil {
+			projectID = creds.ProjectID
+		}
+	}
+
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: tracing.Transport(nil),
+	}
 	return &Provider{
-		projectID: projectID,
-		location:  location,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		projectID:  projectID,
+		location:   location,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		tokens:     newTokenSource(credentials, httpClient),
+		httpClient: httpClient,
 	}
 }
 
 func (p *Provider) Name() string { return "vertex" }
 
 func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	client, req, err := p.getClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return client.Chat(ctx, req)
+}
+
+func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
+	client, req, err := p.getClient(ctx, req)
+	if err != nil {
+		return err
+	}
+	return client.ChatStream(ctx, req, callback)
+}
+
+// getClient returns a client for the project and location of req, and req
+// with its model and access token set for Vertex.
+func (p *Provider) getClient(ctx context.Context, req llm.ChatRequest) (*openaicompat.Client, llm.ChatRequest, error) {
 	projectID := p.projectID
 	location := p.location
 
@@ -47,67 +119,61 @@ func (p *Provider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResp
 	}
 
 	if projectID == "" {
-		return nil, &llm.ProviderError{
+		return nil, req, &llm.ProviderError{
 			StatusCode: 400,
-			Message:    "GCP project ID required. Provide gcp_project_id in request options",
+			Message:    "GCP project ID required. Provide gcpProjectID in request options",
 			Type:       "invalid_request_error",
 			Code:       "missing_gcp_project_id",
 		}
 	}
-
-	reqBody := convertToVertexRequest(req)
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	modelName := req.Model
-	if strings.HasPrefix(modelName, "vertex/") {
-		modelName = strings.TrimPrefix(modelName, "vertex/")
-	}
-	if strings.HasPrefix(modelName, "google/") {
-		modelName = strings.TrimPrefix(modelName, "google/")
-	}
-
-	url := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/endpoints/%s/openapi",
-		location, projectID, location, modelName)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	token := os.Getenv("GOOGLE_ACCESS_TOKEN")
-	if token == "" {
-		token = os.Getenv("GOOGLE_OAUTH_TOKEN")
+	// Both end up in the host and path of the request URL.
+	if !projectIDPattern.MatchString(projectID) {
+		return nil, req, &llm.ProviderError{
+			StatusCode: 400,
+			Message:    fmt.Sprintf("invalid GCP project ID %q", projectID),
+			Type:       "invalid_request_error",
+			Code:       "invalid_gcp_project_id",
+			Param:      "gcpProjectID",
+		}
 	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+token)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if !locationPattern.MatchString(location) {
+		return nil, req, &llm.ProviderError{
+			StatusCode: 400,
+			Message:    fmt.Sprintf("invalid GCP location %q", location),
+			Type:       "invalid_request_error",
+			Code:       "invalid_gcp_location",
+			Param:      "gcpLocation",
+		}
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	token, err := p.tokens.Token(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, req, err
 	}
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("vertex API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	var vertexResp VertexResponse
-	if err := json.Unmarshal(respBody, &vertexResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	req.APIKey = token
+	req.Model = modelName(req.Model)
+
+	root := p.baseURL
+	if root == "" {
+		host := "aiplatform.googleapis.com"
+		if location != "global" {
+			host = location + "-" + host
+		}
+		root = "https://" + host
 	}
 
-	return convertFromVertexResponse(vertexResp), nil
+	client := openaicompat.NewClientWithCustomHTTP(openaicompat.Config{
+		BaseURL: fmt.Sprintf("%s/v1/projects/%s/locations/%s/endpoints/openapi", root, projectID, location),
+	}, p.httpClient)
+	return client, req, nil
 }
 
-func (p *Provider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
-	return fmt.Errorf("streaming not yet implemented for Vertex AI")
+// modelName returns the model ID as the OpenAI-compatible endpoint expects
+// it: publisher/model, where Gemini models are published by google.
+func modelName(model string) string {
+	model = strings.TrimPrefix(model, "vertex/")
+	if !strings.Contains(model, "/") {
+		model = "google/" + model
+	}
+	return model
 }
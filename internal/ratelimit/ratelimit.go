@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/atozi-ai/gateway/internal/keys"
+	"github.com/atozi-ai/gateway/internal/metrics"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/time/rate"
@@ -128,35 +130,59 @@ func (rl *RateLimiter) evictOldest(count int) {
 	}
 }
 
+// UpdateConfig replaces the limits. Clients that are already tracked keep
+// their window counts but use the new limits from their next request on.
+func (rl *RateLimiter) UpdateConfig(config RateLimitConfig) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.config = config
+	for _, client := range rl.clients {
+		client.secondLimiter.SetLimit(rate.Limit(config.RequestsPerSecond))
+		client.secondLimiter.SetBurst(config.Burst)
+	}
+}
+
 func (rl *RateLimiter) Allow(key string) (bool, string) {
+	window, reason := rl.check(key)
+	return window == "", reason
+}
+
+// check returns the exhausted window ("second", "minute", "hour" or "day")
+// and a reason, or an empty window if the request is allowed.
+func (rl *RateLimiter) check(key string) (string, string) {
 	client := rl.getClient(key)
 
+	rl.mu.RLock()
+	config := rl.config
+	rl.mu.RUnlock()
+
 	if !client.secondLimiter.Allow() {
-		return false, "Rate limit exceeded (per second)"
+		return "second", "Rate limit exceeded (per second)"
 	}
 
-	if rl.config.RequestsPerMinute > 0 {
-		allowed, reason := client.minuteWindow.allow(rl.config.RequestsPerMinute, time.Minute)
+	if config.RequestsPerMinute > 0 {
+		allowed, reason := client.minuteWindow.allow(config.RequestsPerMinute, time.Minute)
 		if !allowed {
-			return false, "Rate limit exceeded (per minute): " + reason
+			return "minute", "Rate limit exceeded (per minute): " + reason
 		}
 	}
 
-	if rl.config.RequestsPerHour > 0 {
-		allowed, reason := client.hourWindow.allow(rl.config.RequestsPerHour, time.Hour)
+	if config.RequestsPerHour > 0 {
+		allowed, reason := client.hourWindow.allow(config.RequestsPerHour, time.Hour)
 		if !allowed {
-			return false, "Rate limit exceeded (per hour): " + reason
+			return "hour", "Rate limit exceeded (per hour): " + reason
 		}
 	}
 
-	if rl.config.RequestsPerDay > 0 {
-		allowed, reason := client.dayWindow.allow(rl.config.RequestsPerDay, 24*time.Hour)
+	if config.RequestsPerDay > 0 {
+		allowed, reason := client.dayWindow.allow(config.RequestsPerDay, 24*time.Hour)
 		if !allowed {
-			return false, "Rate limit exceeded (per day): " + reason
+			return "day", "Rate limit exceeded (per day): " + reason
 		}
 	}
 
-	return true, ""
+	return "", ""
 }
 
 func (wc *windowCounter) allow(limit int, window time.Duration) (bool, string) {
@@ -214,8 +240,9 @@ func RateLimit(rl *RateLimiter) func(http.Handler) http.Handler {
 				return
 			}
 
-			allowed, reason := rl.Allow(apiKey)
-			if !allowed {
+			window, reason := rl.check(apiKey)
+			if window != "" {
+				metrics.RecordRateLimited(window)
 				logger.Log.Warn().
 					Str("api_key", truncate(apiKey, 8)).
 					Str("reason", reason).
@@ -235,7 +262,7 @@ func RateLimit(rl *RateLimiter) func(http.Handler) http.Handler {
 func extractAPIKey(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return ""
+		return keys.SDKKeyHeader(r)
 	}
 
 	const bearerPrefix = "Bearer "
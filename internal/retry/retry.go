@@ -3,11 +3,14 @@ package retry
 import (
 	"context"
 	"errors"
+	"io"
 	"math"
 	"time"
 
 	"github.com/atozi-ai/gateway/internal/domain/llm"
+	"github.com/atozi-ai/gateway/internal/metrics"
 	"github.com/atozi-ai/gateway/internal/platform/logger"
+	"github.com/atozi-ai/gateway/internal/platform/tracing"
 )
 
 type Config struct {
@@ -16,6 +19,9 @@ type Config struct {
 	MaxDelay       time.Duration
 	Multiplier     float64
 	RetryableCodes []int
+	// StreamBufferTokens holds back the first tokens of a stream so a failure
+	// within them can still be retried. Zero commits at the first content.
+	StreamBufferTokens int
 }
 
 var DefaultConfig = Config{
@@ -96,6 +102,8 @@ func (r *retryableProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm
 				Dur("delay", delay).
 				Msg("Retrying request")
 
+			metrics.RecordRetry(r.provider.Name())
+
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -103,7 +111,9 @@ func (r *retryableProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm
 			}
 		}
 
-		resp, err := r.provider.Chat(ctx, req)
+		attemptCtx, span := tracing.StartAttempt(ctx, attempt, r.provider.Name())
+		resp, err := r.provider.Chat(attemptCtx, req)
+		tracing.End(span, err)
 		if err == nil {
 			if attempt > 0 {
 				logger.Log.Info().
@@ -143,8 +153,11 @@ func (r *retryableProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm
 	return nil, lastErr
 }
 
+// ChatStream retries only while nothing has reached the client. A failure
+// after that ends the stream with a stream_interrupted error.
 func (r *retryableProvider) ChatStream(ctx context.Context, req llm.ChatRequest, callback func(*llm.StreamChunk) error) error {
 	var lastErr error
+	guard := llm.NewStreamGuard(callback, r.config.StreamBufferTokens)
 
 	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
 		if attempt > 0 {
@@ -155,6 +168,8 @@ func (r *retryableProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 				Dur("delay", delay).
 				Msg("Retrying streaming request")
 
+			metrics.RecordRetry(r.provider.Name())
+
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -162,14 +177,12 @@ func (r *retryableProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 			}
 		}
 
-		err := r.provider.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
-			if attempt > 0 && chunk.Choices != nil && len(chunk.Choices) > 0 {
-				logger.Log.Info().
-					Str("provider", r.provider.Name()).
-					Msg("Streaming succeeded after retry")
-			}
-			return callback(chunk)
-		})
+		attemptCtx, span := tracing.StartAttempt(ctx, attempt, r.provider.Name())
+		err := r.provider.ChatStream(attemptCtx, req, guard.Send)
+		if err == nil {
+			err = guard.Flush()
+		}
+		tracing.End(span, err)
 
 		if err == nil {
 			if attempt > 0 {
@@ -181,7 +194,14 @@ func (r *retryableProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 			return nil
 		}
 
-		lastErr = err
+		lastErr = guard.Fail(err)
+		if guard.Committed() {
+			logger.Log.Warn().
+				Str("provider", r.provider.Name()).
+				Err(err).
+				Msg("Stream failed after output was sent, not retrying")
+			return lastErr
+		}
 
 		if !isRetryable(err, r.config.RetryableCodes) {
 			logger.Log.Warn().
@@ -210,6 +230,112 @@ func (r *retryableProvider) ChatStream(ctx context.Context, req llm.ChatRequest,
 	return lastErr
 }
 
+func (r *retryableProvider) Embed(ctx context.Context, req llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := calculateDelay(attempt-1, r.config)
+			logger.Log.Info().
+				Str("provider", r.provider.Name()).
+				Int("attempt", attempt).
+				Dur("delay", delay).
+				Msg("Retrying embeddings request")
+
+			metrics.RecordRetry(r.provider.Name())
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		attemptCtx, span := tracing.StartAttempt(ctx, attempt, r.provider.Name())
+		resp, err := llm.Embed(attemptCtx, r.provider, req)
+		tracing.End(span, err)
+		if err == nil {
+			return resp, nil
+		}
+
+		lastErr = err
+
+		if !isRetryable(err, r.config.RetryableCodes) {
+			return nil, err
+		}
+
+		logger.Log.Warn().
+			Str("provider", r.provider.Name()).
+			Err(err).
+			Int("attempt", attempt+1).
+			Int("max_retries", r.config.MaxRetries).
+			Int("status_code", getStatusCode(err)).
+			Msg("Retryable error for embeddings, will retry")
+	}
+
+	logger.Log.Error().
+		Str("provider", r.provider.Name()).
+		Err(lastErr).
+		Int("max_retries", r.config.MaxRetries).
+		Msg("All retry attempts exhausted for embeddings")
+
+	return nil, lastErr
+}
+
+// Respond retries opening a forwarded Responses API request. Once the
+// upstream has answered, its body is the caller's.
+func (r *retryableProvider) Respond(ctx context.Context, req llm.ResponsesRequest) (io.ReadCloser, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := calculateDelay(attempt-1, r.config)
+			logger.Log.Info().
+				Str("provider", r.provider.Name()).
+				Int("attempt", attempt).
+				Dur("delay", delay).
+				Msg("Retrying responses request")
+
+			metrics.RecordRetry(r.provider.Name())
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		attemptCtx, span := tracing.StartAttempt(ctx, attempt, r.provider.Name())
+		body, err := llm.Respond(attemptCtx, r.provider, req)
+		tracing.End(span, err)
+		if err == nil {
+			return body, nil
+		}
+
+		lastErr = err
+
+		if !isRetryable(err, r.config.RetryableCodes) {
+			return nil, err
+		}
+
+		logger.Log.Warn().
+			Str("provider", r.provider.Name()).
+			Err(err).
+			Int("attempt", attempt+1).
+			Int("max_retries", r.config.MaxRetries).
+			Int("status_code", getStatusCode(err)).
+			Msg("Retryable error for responses, will retry")
+	}
+
+	logger.Log.Error().
+		Str("provider", r.provider.Name()).
+		Err(lastErr).
+		Int("max_retries", r.config.MaxRetries).
+		Msg("All retry attempts exhausted for responses")
+
+	return nil, lastErr
+}
+
 func getStatusCode(err error) int {
 	var pe *llm.ProviderError
 	if errors.As(err, &pe) {